@@ -3,21 +3,32 @@ package main
 import (
 	"context"
 	"crypto/tls"
-	"fmt"
 	"log"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
+	"web-app-firewall-ml-detection/internal/acme"
 	"web-app-firewall-ml-detection/internal/api"
+	"web-app-firewall-ml-detection/internal/chain"
+	"web-app-firewall-ml-detection/internal/config"
 	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/decisions"
+	"web-app-firewall-ml-detection/internal/dnsbackend"
+	"web-app-firewall-ml-detection/internal/dnsserver"
 	"web-app-firewall-ml-detection/internal/limiter"
 	"web-app-firewall-ml-detection/internal/logger"
+	"web-app-firewall-ml-detection/internal/metrics"
+	"web-app-firewall-ml-detection/internal/netutil"
+	"web-app-firewall-ml-detection/internal/origin"
+	"web-app-firewall-ml-detection/internal/role"
+	"web-app-firewall-ml-detection/internal/sessionstore"
 
-	"golang.org/x/crypto/acme/autocert"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // getEnv handles fallback values for environment variables
@@ -28,11 +39,20 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-// CORSMiddleware handles Preflight and Headers
-func CORSMiddleware(next http.Handler) http.Handler {
+// configPath is where main and `config validate` both look for the
+// optional YAML layer; CONFIG_PATH lets deploys point elsewhere without a
+// code change.
+func configPath() string {
+	return getEnv("CONFIG_PATH", "config/gateway.yaml")
+}
+
+// CORSMiddleware handles Preflight and Headers, reading FrontendURL/
+// AllowedOrigins from the live *config.Store so a reload (file change,
+// SIGHUP, or Mongo override) takes effect on the next request without a
+// restart.
+func CORSMiddleware(store *config.Store, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		envOrigins := getEnv("FRONTEND_URL", "https://www.minishield.tech")
-		allowedOrigins := strings.Split(envOrigins, ",")
+		allowedOrigins := store.Current().AllowedOrigins
 		requestOrigin := r.Header.Get("Origin")
 
 		for _, origin := range allowedOrigins {
@@ -55,17 +75,57 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// runConfigValidate backs the `config validate` CLI subcommand: load the
+// layered config and confirm Mongo/DNS are actually reachable, so a bad
+// deploy fails in CI instead of at the first request.
+func runConfigValidate() {
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		log.Fatalf("❌ config validate: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := database.Connect(cfg.MongoURI)
+	if err != nil {
+		log.Fatalf("❌ config validate: MongoDB unreachable: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		log.Fatalf("❌ config validate: MongoDB ping failed: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	if err := database.ConnectDNS(cfg.DNSUser, cfg.DNSPass, cfg.DNSHost, cfg.DNSName); err != nil {
+		log.Fatalf("❌ config validate: DNS SQL database unreachable: %v", err)
+	}
+	defer database.CloseDNS()
+
+	log.Println("✅ config validate: OK")
+}
+
 func main() {
+	if len(os.Args) >= 3 && os.Args[1] == "config" && os.Args[2] == "validate" {
+		runConfigValidate()
+		return
+	}
+
 	// 1. CONFIGURATION
-	mongoURI := getEnv("MONGO_URI", "mongodb://mongo:27017")
-	defaultOrigin := getEnv("ORIGIN_URL", "http://origin:3000")
-	mlURL := getEnv("ML_URL", "http://ml_scorer:8000/predict")
-	wafPublicIP := getEnv("WAF_PUBLIC_IP", "64.227.156.70")
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		log.Fatal("Config load failed:", err)
+	}
+	store := config.NewStore(cfg)
+
+	mongoURI := cfg.MongoURI
+	defaultOrigin := cfg.OriginURL
+	mlURL := cfg.MLURL
+	wafPublicIP := cfg.WafPublicIP
 
-	dnsUser := getEnv("DNS_DB_USER", "pdns")
-	dnsPass := getEnv("DNS_DB_PASS", "pdns_password")
-	dnsHost := getEnv("DNS_DB_HOST", "dns_sql_db")
-	dnsDB := getEnv("DNS_DB_NAME", "powerdns")
+	dnsUser := cfg.DNSUser
+	dnsPass := cfg.DNSPass
+	dnsHost := cfg.DNSHost
+	dnsDB := cfg.DNSName
 
 	// 2. CONNECT DB (MongoDB)
 	log.Println("Connecting to MongoDB...")
@@ -73,7 +133,8 @@ func main() {
 	if err != nil {
 		log.Fatal("MongoDB Connection failed:", err)
 	}
-	defer client.Disconnect(context.Background())
+	// [UPDATED] Disconnected explicitly during graceful shutdown below,
+	// after the servers have drained, rather than via defer on exit.
 
 	// 3. CONNECT DB (MySQL for DNS)
 	log.Println("Connecting to DNS SQL Database...")
@@ -84,7 +145,40 @@ func main() {
 
 	// 4. INIT COMPONENTS
 	logger.Init(client, "waf")
-	rateLimiter := limiter.New(100, 1*time.Minute)
+	logger.SetRingSize(cfg.LogSink.SSEReplayBufferSize)
+	// [NEW] Batches LogAttack's Mongo writes instead of one InsertOne
+	// goroutine per request, falling back to a disk-backed WAL when Mongo
+	// is unreachable so an outage doesn't lose attack logs.
+	if err := logger.InitSink(
+		client, "waf",
+		cfg.LogSink.BatchSize,
+		cfg.LogSink.QueueSize,
+		time.Duration(cfg.LogSink.FlushIntervalMillis)*time.Millisecond,
+		cfg.LogSink.WALDir,
+		cfg.LogSink.BreakerThreshold,
+		time.Duration(cfg.LogSink.BreakerBackoffSeconds)*time.Second,
+	); err != nil {
+		log.Fatalf("❌ Critical: could not start log sink: %v", err)
+	}
+	api.JWTSecret = []byte(cfg.JWTSecret)
+	database.MaxRecordsPerUser = cfg.MaxUserRecords
+	database.MaxRecordsPerDomain = cfg.MaxRecordsPerDomain
+	database.MaxDomainsPerUser = cfg.MaxDomainsPerUser
+	database.VerificationTokenSecret = []byte(cfg.JWTSecret)
+
+	// [NEW] Redis.Addr set backs every RateLimiter in this file with a
+	// RedisStore instead of its own in-process MemoryStore, so every
+	// gateway node behind the same IP sees the same sliding-window
+	// counters; empty (the default) leaves each node counting on its own.
+	// Each limiter gets its own store (prefixed by purpose) so distinct
+	// routes never share a counter even when they share one Redis instance.
+	newRateLimitStore := func(purpose string) limiter.Store {
+		if cfg.Redis.Addr != "" {
+			return limiter.NewRedisStore(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, purpose)
+		}
+		return limiter.NewMemoryStore()
+	}
+	rateLimiter := limiter.NewWithStore(newRateLimitStore("ratelimit"), cfg.RateLimitRPM, 1*time.Minute)
 
 	page404, err := os.ReadFile("pages/404.html")
 	if err != nil {
@@ -96,51 +190,25 @@ func main() {
 		log.Fatalf("❌ Critical: Could not load pages/502.html: %v", err)
 	}
 
-	// 5. REVERSE PROXY LOGIC (Dynamic Origin Switching)
-	director := func(req *http.Request) {
-		incomingHost := req.Host
-		var targetURL *url.URL
-
-		// [UPDATED] Look up Full Record to check OriginSSL Preference
-		// NOTE: Ensure database.GetOriginRecord is defined in mongo.go
-		record, err := database.GetOriginRecord(client, incomingHost)
-
-		if err == nil && record != nil {
-			rawTarget := record.Content
-			
-			// DYNAMIC SCHEME SELECTION
-			// If user set "origin_ssl: true" -> Use HTTPS
-			// If not set (false) -> Use HTTP (Legacy behavior)
-			if record.OriginSSL {
-				if len(rawTarget) < 4 || rawTarget[:4] != "http" {
-					rawTarget = "https://" + rawTarget
-				}
-			} else {
-				if len(rawTarget) < 4 || rawTarget[:4] != "http" {
-					rawTarget = "http://" + rawTarget
-				}
-			}
-
-			targetURL, _ = url.Parse(rawTarget)
-			log.Printf("[Proxy] Routing %s -> %s (SSL: %v)", incomingHost, rawTarget, record.OriginSSL)
-		} else {
-			// Fallback if no user record exists
-			targetURL, _ = url.Parse(defaultOrigin)
-			log.Printf("[Proxy] No user record found for %s, using default: %s", incomingHost, defaultOrigin)
-		}
+	// [NEW] Built before the director below so both it and apiHandler
+	// share one request-path cache of domains/rules/policies/origin pools
+	// instead of the director hitting Mongo directly on every request.
+	rulesConfig := api.NewRulesConfig()
 
-		req.URL.Scheme = targetURL.Scheme
-		req.URL.Host = targetURL.Host
-		req.Header.Set("X-Forwarded-Host", incomingHost)
-		req.Header.Set("X-Forwarded-Proto", "https")
-		req.Header.Set("X-Real-IP", req.RemoteAddr)
-	}
+	// 5. REVERSE PROXY LOGIC (Health-Checked Origin Pools)
+	// [UPDATED] A host can now have several A/AAAA records; originPicker
+	// chooses one per request (round-robin by default) and the director
+	// stashes the rest so FailoverTransport can retry on 5xx/conn errors.
+	originPicker := origin.NewPicker(origin.Policy(getEnv("ORIGIN_POOL_POLICY", string(origin.RoundRobin))))
+	director := origin.NewDirector(client, defaultOrigin, originPicker, rulesConfig)
+	go origin.StartHealthChecks(client)
 
 	// --- DEFINE THE PROXY WITH ERROR HANDLER ---
 	proxy := &httputil.ReverseProxy{
 		Director: director,
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
 			log.Printf("🔥 Proxy Error for %s: %v", r.Host, err)
+			metrics.IncUpstreamError(r.Host)
 
 			if r.Context().Err() != nil {
 				return
@@ -152,80 +220,421 @@ func main() {
 		},
 		// [CRITICAL] Skip SSL verification for Backend
 		// We trust our backend IP even if the cert doesn't match the IP address.
-		Transport: &http.Transport{
+		Transport: origin.NewFailoverTransport(&http.Transport{
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+		}),
 	}
 
-	// 6. INIT API HANDLER
-	apiHandler := api.NewAPIHandler(client, proxy, rateLimiter, mlURL, defaultOrigin, wafPublicIP, page404)
+	// [NEW] DNS backend. cfg.DNSProvider ("powerdns_sql", "powerdns_api",
+	// "cloudflare", "route53") picks explicitly; left empty, we fall back to
+	// the legacy credential-presence chain so existing deployments that
+	// never set it keep working unchanged.
+	var dnsProvider dnsbackend.Provider
+	switch cfg.DNSProvider {
+	case "powerdns_api":
+		dnsProvider = dnsbackend.NewPowerDNSAPIProvider(cfg.PowerDNSAPI.URL, cfg.PowerDNSAPI.APIKey)
+	case "cloudflare":
+		dnsProvider = dnsbackend.NewCloudflareProvider(cfg.Cloudflare.APIToken, cfg.Cloudflare.ZoneIDs)
+	case "route53":
+		dnsProvider = dnsbackend.NewRoute53Provider(cfg.Route53.AccessKeyID, cfg.Route53.SecretAccessKey, cfg.Route53.HostedZoneIDs)
+	case "powerdns_sql":
+		dnsProvider = dnsbackend.NewPowerDNSProvider()
+	default:
+		dnsProvider = dnsbackend.NewPowerDNSProvider()
+		if cfg.Cloudflare.APIToken != "" {
+			dnsProvider = dnsbackend.NewCloudflareProvider(cfg.Cloudflare.APIToken, cfg.Cloudflare.ZoneIDs)
+		} else if cfg.Route53.AccessKeyID != "" {
+			dnsProvider = dnsbackend.NewRoute53Provider(cfg.Route53.AccessKeyID, cfg.Route53.SecretAccessKey, cfg.Route53.HostedZoneIDs)
+		}
+	}
 
-	// 7. DEFINE ROUTES
-	mux := http.NewServeMux()
-	mux.HandleFunc("/api/status", apiHandler.SystemStatus)
-	mux.HandleFunc("/api/auth/register", apiHandler.Register)
-	mux.HandleFunc("/api/auth/login", apiHandler.Login)
-	mux.HandleFunc("/api/auth/logout", apiHandler.Logout)
-	mux.HandleFunc("/api/auth/check", api.AuthMiddleware(apiHandler.CheckAuth))
-	mux.HandleFunc("/api/stream", apiHandler.SSEHandler)
-	mux.HandleFunc("/api/domains", api.AuthMiddleware(apiHandler.ListDomains))
-	mux.HandleFunc("/api/domains/add", api.AuthMiddleware(apiHandler.AddDomain))
-	mux.HandleFunc("/api/domains/verify", api.AuthMiddleware(apiHandler.VerifyDomain))
-	mux.HandleFunc("/api/dns/records", api.AuthMiddleware(apiHandler.ManageRecords))
-	mux.HandleFunc("/api/rules/global", api.AuthMiddleware(apiHandler.GetGlobalRules))
-	mux.HandleFunc("/api/rules/custom", api.AuthMiddleware(apiHandler.GetCustomRules))
-	mux.HandleFunc("/api/rules/custom/add", api.AuthMiddleware(apiHandler.AddCustomRule))
-	mux.HandleFunc("/api/rules/custom/delete", api.AuthMiddleware(apiHandler.DeleteCustomRule))
-	mux.HandleFunc("/api/rules/toggle", api.AuthMiddleware(apiHandler.ToggleRule))
-	mux.HandleFunc("/api/logs/secure", api.AuthMiddleware(apiHandler.SecuredLogsHandler))
-	mux.HandleFunc("/", apiHandler.WAFHandler)
+	// [NEW] Optional in-process authoritative DNS server (internal/dnsserver),
+	// an alternative to running PowerDNS/MySQL externally. Disabled unless an
+	// operator sets DNS_SERVER_ENABLED; dnsServer stays nil and
+	// apiHandler.DNSServer reports it absent from /api/status either way.
+	var dnsServer *dnsserver.Server
+	var cancelDNSServer context.CancelFunc
+	if cfg.DNSServer.Enabled {
+		dnsServer = dnsserver.NewServer(client, dnsserver.Config{
+			Addr:        cfg.DNSServer.Addr,
+			WafPublicIP: wafPublicIP,
+			Recursors:   cfg.DNSServer.RecursorList,
+		})
+		var dnsServerCtx context.Context
+		dnsServerCtx, cancelDNSServer = context.WithCancel(context.Background())
+		if err := dnsServer.Start(dnsServerCtx); err != nil {
+			log.Printf("[ERROR] dnsserver: failed to start: %v", err)
+			cancelDNSServer()
+			dnsServer = nil
+		}
+	}
 
-	// ---------------------------------------------------------
-	// 8. HTTPS AUTO-CERT CONFIGURATION
-	// ---------------------------------------------------------
+	// [NEW] Optional multi-node WAF rule/policy sync (internal/database.Syncer),
+	// mirroring rules/rule_policies/domains/dns_records out to one or more
+	// replica WAF nodes on a configurable interval. Disabled unless an
+	// operator sets REPLICA_SYNC_ENABLED (or replica_sync.enabled in the
+	// config file) and lists at least one replica's mongo URI.
+	var syncer *database.Syncer
+	var cancelSync context.CancelFunc
+	if cfg.ReplicaSync.Enabled && len(cfg.ReplicaSync.Replicas) > 0 {
+		replicas := make(map[string]*mongo.Client, len(cfg.ReplicaSync.Replicas))
+		for name, uri := range cfg.ReplicaSync.Replicas {
+			replicaClient, err := database.Connect(uri)
+			if err != nil {
+				log.Printf("[ERROR] replica sync: failed to connect to replica %q: %v", name, err)
+				continue
+			}
+			replicas[name] = replicaClient
+		}
+		if len(replicas) > 0 {
+			syncer = database.NewSyncer(client, replicas, database.SyncConfig{
+				Collections:     cfg.ReplicaSync.Collections,
+				ProtectedFields: cfg.ReplicaSync.ProtectedFields,
+				Interval:        cfg.ReplicaSync.Interval,
+			})
+			var syncCtx context.Context
+			syncCtx, cancelSync = context.WithCancel(context.Background())
+			go syncer.Run(syncCtx)
+		}
+	}
 
-	hostPolicy := func(ctx context.Context, host string) error {
-		// 1. Allow Admin/Dashboard domains explicitly
-		if host == "api.minishield.tech" || host == "test2.minishield.tech" || host == "minishield.tech" {
-			return nil
+	// [NEW] CrowdSec-compatible threat feed (internal/decisions), disabled
+	// by default. GeoIP enrichment is best-effort: a bad/missing mmdb path
+	// just leaves Decision.Country unresolved rather than failing boot.
+	var decisionsFeed *decisions.Feed
+	var decisionsBanPage []byte
+	if cfg.Decisions.Enabled {
+		var geo *decisions.GeoIP
+		if cfg.Decisions.GeoIPPath != "" {
+			var err error
+			geo, err = decisions.OpenGeoIP(cfg.Decisions.GeoIPPath)
+			if err != nil {
+				log.Printf("Warning: GeoIP database unavailable, decisions will have no country data: %v", err)
+			}
 		}
+		pollInterval := time.Duration(cfg.Decisions.PollIntervalSeconds) * time.Second
+		decisionsFeed = decisions.NewFeed(cfg.Decisions.LAPIURL, cfg.Decisions.APIKey, pollInterval, geo)
+		decisionsFeed.Start(context.Background())
 
-		// 2. Allow User Domains & Subdomains
-		if database.IsHostAllowed(client, host) {
-			return nil
+		if cfg.Decisions.BanPagePath != "" {
+			if b, err := os.ReadFile(cfg.Decisions.BanPagePath); err == nil {
+				decisionsBanPage = b
+			} else {
+				log.Printf("Warning: could not load decisions ban page %s: %v", cfg.Decisions.BanPagePath, err)
+			}
 		}
+	}
+
+	// [NEW] Trusted-proxy chain clientIP is resolved behind everywhere
+	// (WAFHandler, rate limiting, access logging, auth audit trails). A bad
+	// CIDR in config degrades to trusting nothing rather than failing boot.
+	trustedProxies, err := netutil.NewTrustedProxyConfig(cfg.TrustedProxyList, cfg.ClientIPHeader)
+	if err != nil {
+		log.Printf("Warning: invalid TRUSTED_PROXIES entry, trusting no proxies: %v", err)
+		trustedProxies = netutil.TrustedProxyConfig{}
+	}
 
-		return fmt.Errorf("host %s not allowed", host)
+	// [NEW] Sessions follow the same Redis.Addr convention as the rate
+	// limiter stores above: Redis for multi-node deployments that need a
+	// shared revocation check on every /auth/check, Mongo otherwise.
+	var sessionStore sessionstore.Store
+	if cfg.Redis.Addr != "" {
+		sessionStore = sessionstore.NewRedisStore(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+	} else {
+		sessionStore = sessionstore.NewMongoStore(client)
 	}
 
-	certManager := autocert.Manager{
-		Prompt:     autocert.AcceptTOS,
-		HostPolicy: hostPolicy,
-		Cache:      autocert.DirCache("certs"),
+	// 6. INIT API HANDLER (also boots the ACME DNS-01 certificate manager)
+	apiHandler := api.NewAPIHandler(client, proxy, rateLimiter, mlURL, defaultOrigin, wafPublicIP, page404, dnsProvider, dnsServer, syncer, rulesConfig, cfg.ACME.Email, acme.ResolveDirectoryURL(cfg.ACME.DirectoryURL, cfg.ACME.Staging), cfg.ACME.EABKeyID, cfg.ACME.EABHMACKey, decisionsFeed, decisionsBanPage, trustedProxies, sessionStore)
+	// [UPDATED] SSE/log pagination live on their own handler now that the
+	// live stream is filtered per-user (previously wired to apiHandler,
+	// which never actually had these methods).
+	logHandler := api.NewLogHandler(client)
+
+	// [NEW] Enable social login (Google/GitHub/OIDC) if configured.
+	api.LoadOAuthProviders(getEnv("OAUTH_CONFIG_PATH", "config/oauth_providers.json"))
+	// [NEW] Enable Keycloak-style OIDC realms (authorization-code + PKCE,
+	// JWKS-verified ID tokens) alongside the above, if configured.
+	api.LoadOIDCProviders(cfg)
+	// [NEW] Let AuthMiddleware accept "Authorization: Bearer <jwt>" tokens
+	// from trusted external issuers (CI, service accounts, federated
+	// tenants), verified against each issuer's own JWKS.
+	api.InitExternalAuth(client, cfg)
+
+	// 7. DEFINE ROUTES
+	// [UPDATED] Every API route is now declared as a chain.Chain rather than
+	// hand-nesting api.AuthMiddleware(...) calls, so cross-cutting behavior
+	// (request IDs, panic recovery, gzip, access logging, per-route rate
+	// limits) can be added once here instead of edited into every handler.
+	//
+	// base:   every API route — request ID, panic recovery, gzip, access log.
+	// authed: base + session auth, for anything behind a login.
+	base := chain.New(chain.RequestID, chain.Recover, chain.Gzip, chain.AccessLog(trustedProxies))
+	authed := base.Append(chain.Adapt(api.AuthMiddleware))
+
+	// [NEW] Login/register get their own per-route+IP limiter, independent
+	// of the global `rateLimiter` guarding the proxied WAF hot path.
+	authLimiter := limiter.NewWithStore(newRateLimitStore("authratelimit"), cfg.AuthRateLimitRPM, 1*time.Minute)
+
+	// [NEW] Token-bucket quota on /api/dns/records, keyed by user_id rather
+	// than IP — separate read/write budgets so a dashboard polling GET
+	// doesn't eat into the burst a user needs for a batch of record edits.
+	dnsRecordsLimiter := limiter.NewUserRateLimiter(30, 0.5, 10, 0.2)
+
+	// [NEW] RateLimitRPM/AuthRateLimitRPM are hot-reloadable: a config file
+	// change, SIGHUP, or "config_overrides" Mongo document pushes the new
+	// thresholds into both limiters without dropping a single client's
+	// window state or restarting the gateway.
+	if err := store.Watch(configPath(), client, func(next *config.Config) {
+		rateLimiter.SetLimit(next.RateLimitRPM)
+		authLimiter.SetLimit(next.AuthRateLimitRPM)
+		log.Printf("♻️  config reloaded: rate_limit_rpm=%d auth_rate_limit_rpm=%d", next.RateLimitRPM, next.AuthRateLimitRPM)
+	}); err != nil {
+		log.Printf("[ERROR] config: failed to start watcher: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/status", base.ThenFunc(apiHandler.SystemStatus))
+	mux.Handle("/api/auth/register", base.Append(chain.RateLimit(authLimiter, "/api/auth/register", trustedProxies)).ThenFunc(apiHandler.Register))
+	mux.Handle("/api/auth/login", base.Append(chain.RateLimit(authLimiter, "/api/auth/login", trustedProxies)).ThenFunc(apiHandler.Login))
+	mux.Handle("/api/auth/logout", base.ThenFunc(apiHandler.Logout))
+	mux.Handle("/api/auth/check", authed.ThenFunc(apiHandler.CheckAuth))
+	// [NEW] Refresh-token rotation: unauthenticated (the whole point is
+	// recovering from an expired auth_token) but rate-limited like the
+	// other credential-bearing auth endpoints.
+	mux.Handle("/api/auth/refresh", base.Append(chain.RateLimit(authLimiter, "/api/auth/refresh", trustedProxies)).ThenFunc(apiHandler.RefreshToken))
+	mux.Handle("/api/auth/sessions", authed.ThenFunc(apiHandler.ListSessions))
+	mux.Handle("/api/auth/sessions/revoke", authed.ThenFunc(apiHandler.RevokeSession))
+	mux.Handle("/api/auth/{provider}/start", base.ThenFunc(apiHandler.StartOAuth))
+	mux.Handle("/api/auth/{provider}/callback", base.ThenFunc(apiHandler.OAuthCallback))
+	// [NEW] Keycloak-style OIDC realms: separate path from the plain OAuth
+	// flow above since the redirect carries a PKCE code_challenge the
+	// generic provider never needs.
+	mux.Handle("/auth/oidc/{provider}/login", base.ThenFunc(apiHandler.LoginOIDC))
+	mux.Handle("/auth/oidc/{provider}/callback", base.ThenFunc(apiHandler.CallbackOIDC))
+	// [UPDATED] Per-user filtering needs to know who's asking, so the live
+	// stream now sits behind auth like everything else under /api.
+	mux.Handle("/api/stream", authed.ThenFunc(logHandler.SSEHandler))
+	mux.Handle("/api/domains", authed.ThenFunc(apiHandler.ListDomains))
+	mux.Handle("/api/domains/add", authed.ThenFunc(apiHandler.AddDomain))
+	mux.Handle("/api/domains/verify", authed.ThenFunc(apiHandler.VerifyDomain))
+	// [NEW] Domain membership (RBAC) — only owners/admins manage who has access.
+	mux.Handle("/api/domains/{id}/members", authed.ThenFunc(apiHandler.RequireRole(role.Admin, apiHandler.AddDomainMember)))
+	mux.Handle("/api/domains/{id}/members/list", authed.ThenFunc(apiHandler.RequireRole(role.Viewer, apiHandler.ListDomainMembers)))
+	// [NEW] Domain-scoped ACME certificate management (internal/acme.Manager),
+	// on-demand counterparts to the automatic issuance startCertRenewalTicker
+	// already drives every 12h.
+	mux.Handle("/api/domains/{id}/certificate/issue", authed.ThenFunc(apiHandler.RequireRole(role.Editor, apiHandler.IssueCertificate)))
+	mux.Handle("/api/domains/{id}/certificate/status", authed.ThenFunc(apiHandler.RequireRole(role.Viewer, apiHandler.GetCertificateStatus)))
+	mux.Handle("/api/domains/{id}/certificate/revoke", authed.ThenFunc(apiHandler.RequireRole(role.Editor, apiHandler.RevokeCertificate)))
+	// [UPDATED] DNS records now require at least Editor access on the domain, not ownership.
+	mux.Handle("/api/dns/records", authed.Append(chain.RateLimitByUser(dnsRecordsLimiter, "/api/dns/records")).ThenFunc(apiHandler.RequireRole(role.Editor, apiHandler.ManageRecords)))
+	mux.Handle("/api/dns/records/{id}/issue-cert", authed.ThenFunc(apiHandler.IssueRecordCertificate))
+	// [NEW] Append-only trail of DNS mutations (internal/audit) plus a
+	// revert endpoint that replays a logged change's inverse through the
+	// same validation addRecord/updateRecord/deleteRecord already run.
+	mux.Handle("/api/dns/audit", authed.ThenFunc(apiHandler.RequireRole(role.Viewer, apiHandler.DNSAudit)))
+	mux.Handle("/api/dns/records/{id}/revert", authed.ThenFunc(apiHandler.RevertRecord))
+	// [NEW] Current usage against database.RecordUsage/DomainUsage, so the
+	// UI can show "17/65 records used" without counting client-side.
+	mux.Handle("/api/dns/quota", authed.ThenFunc(apiHandler.DNSQuota))
+	// [NEW] Internal DNS-01 challenge solver exposed over HTTP so an
+	// external ACME client (lego, certmagic, autocert) can obtain certs for
+	// a proxied hostname through our authoritative zone. See
+	// internal/acme.HTTPProvider for a challenge.Provider that drives these.
+	mux.Handle("/api/dns/acme/present", authed.ThenFunc(apiHandler.RequireRole(role.Editor, apiHandler.PresentACMEChallenge)))
+	mux.Handle("/api/dns/acme/cleanup", authed.ThenFunc(apiHandler.RequireRole(role.Editor, apiHandler.CleanupACMEChallenge)))
+	// [NEW] Bulk zone management: BIND master file / RFC 8427 JSON import
+	// and export, for migrating a domain in from another DNS host instead
+	// of recreating dozens of records through /api/dns/records one at a time.
+	mux.Handle("/api/dns/zones/{id}/import", authed.ThenFunc(apiHandler.RequireRole(role.Editor, apiHandler.ImportZone)))
+	mux.Handle("/api/dns/zones/{id}/export", authed.ThenFunc(apiHandler.RequireRole(role.Viewer, apiHandler.ExportZone)))
+	mux.Handle("/api/rules/global", authed.ThenFunc(apiHandler.GetGlobalRules))
+	mux.Handle("/api/rules/custom", authed.ThenFunc(apiHandler.GetCustomRules))
+	mux.Handle("/api/rules/custom/add", authed.ThenFunc(apiHandler.AddCustomRule))
+	mux.Handle("/api/rules/custom/delete", authed.ThenFunc(apiHandler.DeleteCustomRule))
+	mux.Handle("/api/rules/toggle", authed.ThenFunc(apiHandler.ToggleRule))
+	mux.Handle("/api/logs/secure", authed.ThenFunc(logHandler.SecuredLogsHandler))
+	// [NEW] Lets cluster/admin tooling detect when the rules cache changed.
+	mux.Handle("/api/admin/config/fingerprint", authed.ThenFunc(apiHandler.RulesFingerprint))
+	// Alias for the WAF-rules-specific path callers doing an If-Match
+	// compare-and-swap against AddCustomRule/DeleteCustomRule/ToggleRule
+	// expect; same handler, same fingerprint.
+	mux.Handle("/api/admin/waf/fingerprint", authed.ThenFunc(apiHandler.RulesFingerprint))
+	// [NEW] Per-SSE-subscriber drop counters, so an operator can see which
+	// dashboards are too slow to keep up with the live log stream.
+	mux.Handle("/api/admin/sse/stats", authed.ThenFunc(logHandler.SSESubscriberStats))
+	// [NEW] Manual fallback for the change-stream watcher below — forces an
+	// immediate ReloadRules instead of waiting on stream propagation.
+	mux.Handle("/internal/reload", authed.ThenFunc(apiHandler.ManualReload))
+	mux.Handle("/api/replica/status", authed.ThenFunc(apiHandler.ReplicaStatus))
+	mux.Handle("/api/decisions", authed.ThenFunc(apiHandler.ListDecisions))
+	mux.Handle("/api/decisions/add", authed.ThenFunc(apiHandler.AddDecision))
+	mux.Handle("/api/decisions/expire", authed.ThenFunc(apiHandler.ExpireDecision))
+	// [UPDATED] No gzip/access-log here: the proxied body is the origin's,
+	// not ours to re-encode, and waf.go already logs every verdict.
+	mux.Handle("/", chain.New(chain.RequestID, chain.Recover).ThenFunc(apiHandler.WAFHandler))
+
+	// ---------------------------------------------------------
+	// 8. HTTPS CONFIGURATION
+	// ---------------------------------------------------------
+	// Certificates are issued/renewed by apiHandler.CertManager via DNS-01
+	// against our own authoritative records, so no HTTP-01 challenge path
+	// (and therefore no autocert.Manager) is needed any more — but ACME
+	// TLS-ALPN-01 ("acme-tls/1") still negotiates straight off this
+	// GetCertificate hook if we ever switch a domain to that challenge type,
+	// so it's kept alongside h2 in NextProtos below.
+	//
+	// [NEW] Curated to TLS 1.2+ with AEAD-only, forward-secret cipher
+	// suites; TLS 1.3's own suite list isn't configurable and doesn't need
+	// to be.
+	tlsConfig := &tls.Config{
+		GetCertificate: apiHandler.CertManager.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1", "acme-tls/1"},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
 	}
 
 	// HTTPS Server
+	// [NEW] metrics.Middleware wraps CORSMiddleware so request counters/
+	// latency histograms cover the full request, CORS preflight included.
+	// [NEW] Per-server timeouts so a slow/stalled client can't pin a
+	// goroutine (and a proxied connection) open indefinitely.
 	httpsServer := &http.Server{
-		Addr:    ":443",
-		Handler: CORSMiddleware(mux),
-		TLSConfig: &tls.Config{
-			GetCertificate: certManager.GetCertificate,
-		},
+		Addr:              ":443",
+		Handler:           metrics.Middleware(CORSMiddleware(store, mux)),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	redirectHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := "https://" + r.Host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+	httpServer := &http.Server{
+		Addr:              ":80",
+		Handler:           redirectHandler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
 	}
 
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{
+		Addr:              getEnv("METRICS_ADDR", "127.0.0.1:9090"),
+		Handler:           metricsMux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	// [NEW] Event-driven rules-cache invalidation: watch rules/policies/
+	// domains/dns_records via Mongo change streams so an edit made on
+	// another node is picked up here without waiting for that node's own
+	// mutation handler (or a restart) to propagate it. /internal/reload and
+	// SIGHUP above remain the manual fallback if change streams aren't
+	// available (e.g. standalone Mongo without a replica set).
+	watchCtx, cancelWatch := context.WithCancel(context.Background())
+	go apiHandler.WatchRuleChanges(watchCtx)
+
+	// [NEW] SIGHUP also force-reloads the rules cache, matching the
+	// existing config-file SIGHUP behavior in internal/config/store.go.
+	ruleHup := make(chan os.Signal, 1)
+	signal.Notify(ruleHup, syscall.SIGHUP)
+	go func() {
+		for range ruleHup {
+			log.Println("♻️  rules: SIGHUP received, forcing reload")
+			apiHandler.ReloadRules()
+		}
+	}()
+
 	// ---------------------------------------------------------
 	// 9. START SERVERS
 	// ---------------------------------------------------------
 
+	// [NEW] Prometheus scrape endpoint, deliberately not exposed on :443/:80.
 	go func() {
-		log.Println("✅ Starting HTTP Server on :80 (ACME Challenge + Redirect)")
-		if err := http.ListenAndServe(":80", certManager.HTTPHandler(nil)); err != nil {
-			log.Fatalf("HTTP Server Failed: %v", err)
+		log.Printf("📊 Starting internal metrics listener on %s", metricsServer.Addr)
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] metrics listener failed: %v", err)
 		}
 	}()
 
-	log.Println("🔒 Starting HTTPS WAF on :443")
-	if err := httpsServer.ListenAndServeTLS("", ""); err != nil {
-		log.Fatalf("HTTPS Server Failed: %v", err)
+	go func() {
+		log.Println("✅ Starting HTTP Server on :80 (redirect to HTTPS)")
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] HTTP server failed: %v", err)
+		}
+	}()
+
+	go func() {
+		log.Println("🔒 Starting HTTPS WAF on :443")
+		if err := httpsServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] HTTPS server failed: %v", err)
+		}
+	}()
+
+	// ---------------------------------------------------------
+	// 10. GRACEFUL SHUTDOWN
+	// ---------------------------------------------------------
+	// [NEW] On SIGTERM/SIGINT, stop taking new connections, let in-flight
+	// requests (including proxied ones) drain, then tear down every
+	// background dependency in the reverse order it was started.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-stop
+	log.Printf("🛑 Received %s, shutting down gracefully...", sig)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for name, srv := range map[string]*http.Server{
+		"https":   httpsServer,
+		"http":    httpServer,
+		"metrics": metricsServer,
+	} {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("[ERROR] %s server shutdown: %v", name, err)
+		}
 	}
-}
\ No newline at end of file
+
+	// [NEW] Flush and stop the batched log sink before closing SSE/Mongo so
+	// its last partial batch isn't lost to a race with client.Disconnect.
+	logger.DrainSink(shutdownCtx)
+
+	// [NEW] Close every live SSE subscription so SSEHandler's select loop
+	// sees a closed channel and returns instead of leaking a goroutine per
+	// connected client.
+	logger.CloseAll()
+
+	// [NEW] Stop the change-stream watchers started above.
+	cancelWatch()
+
+	// [NEW] Stop the embedded authoritative DNS server, if one was started.
+	if dnsServer != nil {
+		if err := dnsServer.Stop(); err != nil {
+			log.Printf("[ERROR] dnsserver: shutdown: %v", err)
+		}
+		cancelDNSServer()
+	}
+
+	// [NEW] Stop the replica syncer, if one was started.
+	if cancelSync != nil {
+		cancelSync()
+	}
+
+	database.CloseDNS()
+	client.Disconnect(context.Background())
+
+	log.Println("✅ Shutdown complete")
+}
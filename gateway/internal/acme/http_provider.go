@@ -0,0 +1,89 @@
+package acme
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPProvider implements lego's challenge.Provider by driving another
+// gateway instance's /api/dns/acme/present and /api/dns/acme/cleanup
+// endpoints (internal/api's PresentACMEChallenge/CleanupACMEChallenge),
+// rather than touching Mongo/PowerDNS directly the way DNSProvider does.
+// It's for an ACME client that isn't this process — a sibling service that
+// wants certificates for a hostname this gateway's zone is authoritative
+// for, but only has an API token, not a database connection.
+type HTTPProvider struct {
+	// BaseURL is the gateway's address, e.g. "https://gateway.internal".
+	BaseURL string
+	// DomainID identifies which domain_members-scoped zone the challenge
+	// belongs to, matching the domain_id query parameter the endpoints
+	// require.
+	DomainID string
+	// AuthToken is sent as "Authorization: Bearer <AuthToken>", same as
+	// every other authenticated endpoint under /api.
+	AuthToken string
+
+	// HTTPClient is used for both calls; a zero value gets a 30s timeout
+	// applied lazily by do().
+	HTTPClient *http.Client
+}
+
+// NewHTTPProvider builds an HTTPProvider targeting baseURL for the zone
+// identified by domainID, authenticating with authToken.
+func NewHTTPProvider(baseURL, domainID, authToken string) *HTTPProvider {
+	return &HTTPProvider{
+		BaseURL:   baseURL,
+		DomainID:  domainID,
+		AuthToken: authToken,
+	}
+}
+
+type httpChallengeRequest struct {
+	Domain  string `json:"domain"`
+	Token   string `json:"token"`
+	KeyAuth string `json:"key_auth"`
+}
+
+// Present calls POST {BaseURL}/api/dns/acme/present.
+func (p *HTTPProvider) Present(domainName, token, keyAuth string) error {
+	return p.do("present", domainName, token, keyAuth)
+}
+
+// CleanUp calls POST {BaseURL}/api/dns/acme/cleanup.
+func (p *HTTPProvider) CleanUp(domainName, token, keyAuth string) error {
+	return p.do("cleanup", domainName, token, keyAuth)
+}
+
+func (p *HTTPProvider) do(action, domainName, token, keyAuth string) error {
+	body, err := json.Marshal(httpChallengeRequest{Domain: domainName, Token: token, KeyAuth: keyAuth})
+	if err != nil {
+		return fmt.Errorf("acme: failed to encode %s request: %w", action, err)
+	}
+
+	url := fmt.Sprintf("%s/api/dns/acme/%s?domain_id=%s", p.BaseURL, action, p.DomainID)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("acme: failed to build %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.AuthToken)
+
+	client := p.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("acme: %s request failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("acme: %s request returned %s", action, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,607 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const certsCollection = "certificates"
+
+// accountsCollection persists one ACME account key per ProviderKind, so a
+// restart reuses the already-registered account instead of registering a
+// fresh one (and silently orphaning the old one at the CA) every boot.
+const accountsCollection = "acme_accounts"
+
+// storedAccount is the account-key document shape kept in accountsCollection.
+type storedAccount struct {
+	Kind         string    `bson:"_id"`
+	Email        string    `bson:"email"`
+	KeyPEM       []byte    `bson:"key_pem"`
+	RegisteredAt time.Time `bson:"registered_at"`
+}
+
+// RenewBefore controls how long before expiry a certificate is reissued.
+const RenewBefore = 30 * 24 * time.Hour
+
+// ResolveDirectoryURL picks the ACME directory a deployment registers
+// against: an explicit directoryURL (config.ACMEConfig.DirectoryURL, e.g.
+// for a private CA) always wins, otherwise staging selects Let's
+// Encrypt's staging directory, and the zero value is the real production
+// directory.
+func ResolveDirectoryURL(directoryURL string, staging bool) string {
+	if directoryURL != "" {
+		return directoryURL
+	}
+	if staging {
+		return lego.LEDirectoryStaging
+	}
+	return lego.LEDirectoryProduction
+}
+
+// acmeUser is the minimal registration.User implementation lego requires.
+type acmeUser struct {
+	Email        string
+	Registration *registration.Resource
+	key          *ecdsa.PrivateKey
+}
+
+func (u *acmeUser) GetEmail() string                        { return u.Email }
+func (u *acmeUser) GetRegistration() *registration.Resource { return u.Registration }
+func (u *acmeUser) GetPrivateKey() crypto.PrivateKey        { return u.key }
+
+// storedCert is the document shape we keep in the "certificates"
+// collection. Most documents are zone-wide (_id is the domain name,
+// RecordID empty); IssueForRecord instead keys one by the single DNS
+// record it was issued for, for a host that needs its own origin cert
+// rather than reusing the zone's wildcard.
+type storedCert struct {
+	Domain    string    `bson:"_id"`
+	Hostname  string    `bson:"hostname"` // SNI name this cert covers; GetCertificate's cache key
+	RecordID  string    `bson:"record_id,omitempty"`
+	CertPEM   []byte    `bson:"cert_pem"`
+	KeyPEM    []byte    `bson:"key_pem"`
+	IssuedAt  time.Time `bson:"issued_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// Manager issues and renews Let's Encrypt certificates for every active
+// domain, solving DNS-01 with whichever provider each domain is configured
+// for (detector.Domain.DNSProvider), and serves the results straight out of
+// an in-memory cache backed by the "certificates" collection.
+type Manager struct {
+	client       *mongo.Client
+	contactEmail string
+
+	// directoryURL is the ACME server every client registers against;
+	// empty falls back to lego.LEDirectoryProduction. Set it to
+	// lego.LEDirectoryStaging (or a private CA's directory) via
+	// ResolveDirectoryURL to avoid burning Let's Encrypt's production
+	// rate limit while testing.
+	directoryURL string
+
+	// eabKeyID/eabHMACKey are an External Account Binding credential pair
+	// for CAs that require pre-provisioned accounts (private/enterprise
+	// CAs) rather than open registration. Either empty falls back to a
+	// plain Register call, same as before EAB support existed.
+	eabKeyID   string
+	eabHMACKey string
+
+	mu      sync.Mutex
+	clients map[ProviderKind]*lego.Client // lazily registered, one account per kind
+
+	certsMu sync.RWMutex
+	certs   map[string]*tls.Certificate
+}
+
+// NewManager registers the default (internal) ACME account up front so boot
+// fails loudly if that path is broken; Cloudflare/Route53 accounts are
+// registered lazily the first time a domain actually uses them, since those
+// need external credentials that may not be configured in every deployment.
+// eabKeyID/eabHMACKey are optional External Account Binding credentials;
+// pass "" for both to register the way Let's Encrypt itself expects.
+// directoryURL is normally the output of ResolveDirectoryURL; "" registers
+// against lego.LEDirectoryProduction.
+func NewManager(client *mongo.Client, contactEmail, directoryURL, eabKeyID, eabHMACKey string) (*Manager, error) {
+	m := &Manager{
+		client:       client,
+		contactEmail: contactEmail,
+		directoryURL: directoryURL,
+		eabKeyID:     eabKeyID,
+		eabHMACKey:   eabHMACKey,
+		clients:      make(map[ProviderKind]*lego.Client),
+		certs:        make(map[string]*tls.Certificate),
+	}
+
+	if _, err := m.clientFor(ProviderInternal); err != nil {
+		return nil, err
+	}
+
+	m.RefreshCache()
+	return m, nil
+}
+
+// clientFor returns the registered lego.Client for kind, creating and
+// registering an ACME account for it on first use.
+func (m *Manager) clientFor(kind ProviderKind) (*lego.Client, error) {
+	if kind == "" {
+		kind = ProviderInternal
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.clients[kind]; ok {
+		return c, nil
+	}
+
+	key, isNewKey, err := m.loadOrGenerateAccountKey(kind)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to load/generate account key for %s: %w", kind, err)
+	}
+
+	user := &acmeUser{Email: m.contactEmail, key: key}
+	cfg := lego.NewConfig(user)
+	cfg.CADirURL = m.directoryURL
+	if cfg.CADirURL == "" {
+		cfg.CADirURL = lego.LEDirectoryProduction
+	}
+
+	legoClient, err := lego.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to init client for %s: %w", kind, err)
+	}
+
+	provider, err := newChallengeProvider(kind, m.client)
+	if err != nil {
+		return nil, err
+	}
+	if err := legoClient.Challenge.SetDNS01Provider(provider); err != nil {
+		return nil, fmt.Errorf("acme: failed to set dns-01 provider for %s: %w", kind, err)
+	}
+
+	// A brand-new key has never been registered with the CA; a key loaded
+	// back from Mongo was already registered on a previous boot, so
+	// re-registering would just waste a round trip (lego treats a repeat
+	// Register as a no-op CA-side, but we'd still rather skip it).
+	if isNewKey {
+		var reg *registration.Resource
+		if m.eabKeyID != "" && m.eabHMACKey != "" {
+			reg, err = legoClient.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+				TermsOfServiceAgreed: true,
+				Kid:                  m.eabKeyID,
+				HmacEncoded:          m.eabHMACKey,
+			})
+		} else {
+			reg, err = legoClient.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("acme: failed to register account for %s: %w", kind, err)
+		}
+		user.Registration = reg
+		if err := m.persistAccountKey(kind, key); err != nil {
+			log.Printf("[ERROR] acme: registered account for %s but failed to persist its key: %v", kind, err)
+		}
+	} else {
+		reg, err := legoClient.Registration.QueryRegistration()
+		if err != nil {
+			return nil, fmt.Errorf("acme: failed to re-query registration for %s: %w", kind, err)
+		}
+		user.Registration = reg
+	}
+
+	m.clients[kind] = legoClient
+	return legoClient, nil
+}
+
+// loadOrGenerateAccountKey returns the persisted account key for kind if
+// one exists in accountsCollection, generating (but not yet persisting) a
+// fresh one otherwise. The bool return is true when the key is new, so
+// clientFor knows whether it still needs to Register with the CA.
+func (m *Manager) loadOrGenerateAccountKey(kind ProviderKind) (*ecdsa.PrivateKey, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var doc storedAccount
+	err := m.client.Database(database.DBName).Collection(accountsCollection).
+		FindOne(ctx, bson.M{"_id": string(kind)}).Decode(&doc)
+	if err == nil {
+		block, _ := pem.Decode(doc.KeyPEM)
+		if block == nil {
+			return nil, false, fmt.Errorf("stored account key for %s is not valid PEM", kind)
+		}
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to parse stored account key for %s: %w", kind, err)
+		}
+		return key, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+// persistAccountKey upserts kind's account key into accountsCollection so
+// clientFor reuses the same CA-registered account across restarts.
+func (m *Manager) persistAccountKey(kind ProviderKind, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = m.client.Database(database.DBName).Collection(accountsCollection).ReplaceOne(
+		ctx, bson.M{"_id": string(kind)},
+		storedAccount{Kind: string(kind), Email: m.contactEmail, KeyPEM: keyPEM, RegisteredAt: time.Now()},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// providerKindFor looks up which DNS-01 solver a domain is configured to
+// use, defaulting to ProviderInternal when unset or the domain can't be
+// found (e.g. a bare hostname passed in directly).
+func (m *Manager) providerKindFor(domain string) ProviderKind {
+	d, err := database.GetDomainByName(m.client, domain)
+	if err != nil || d == nil || d.DNSProvider == "" {
+		return ProviderInternal
+	}
+	return ProviderKind(d.DNSProvider)
+}
+
+// IssueOrRenew obtains (or replaces) the certificate for a domain, persists
+// it in the "certificates" collection, and hot-swaps the in-memory cache.
+func (m *Manager) IssueOrRenew(domain string) error {
+	legoClient, err := m.clientFor(m.providerKindFor(domain))
+	if err != nil {
+		return fmt.Errorf("acme: failed to get client for %s: %w", domain, err)
+	}
+
+	req := certificate.ObtainRequest{
+		Domains: sanListFor(m.client, domain),
+		Bundle:  true,
+	}
+
+	cert, err := legoClient.Certificate.Obtain(req)
+	if err != nil {
+		return fmt.Errorf("acme: failed to obtain certificate for %s: %w", domain, err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate)
+	if err != nil {
+		return fmt.Errorf("acme: failed to parse issued certificate for %s: %w", domain, err)
+	}
+
+	doc := storedCert{
+		Domain:    domain,
+		Hostname:  domain,
+		CertPEM:   cert.Certificate,
+		KeyPEM:    cert.PrivateKey,
+		IssuedAt:  time.Now(),
+		ExpiresAt: leaf.NotAfter,
+	}
+
+	if err := m.persist(domain, doc); err != nil {
+		return err
+	}
+
+	tlsCert, err := tls.X509KeyPair(cert.Certificate, cert.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("acme: failed to build tls.Certificate for %s: %w", domain, err)
+	}
+
+	m.certsMu.Lock()
+	m.certs[domain] = &tlsCert
+	m.certsMu.Unlock()
+
+	log.Printf("🔒 ACME: issued certificate for %s (expires %s)", domain, leaf.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// persist upserts doc into the "certificates" collection under _id.
+func (m *Manager) persist(id string, doc storedCert) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := m.client.Database(database.DBName).Collection(certsCollection).ReplaceOne(
+		ctx, bson.M{"_id": id}, doc, options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("acme: failed to persist certificate for %s: %w", id, err)
+	}
+	return nil
+}
+
+// IssueForRecord obtains a certificate for a single DNS record's hostname
+// rather than the whole zone — for a host that needs its own origin cert
+// instead of reusing the zone's wildcard. On success it stores the cert
+// under the record's id (not the hostname, so deleting/renaming the record
+// doesn't orphan a cert keyed by a name that no longer exists) and flips
+// the record's OriginSSL flag on.
+func (m *Manager) IssueForRecord(recordID string) error {
+	record, err := database.GetDNSRecordByID(m.client, recordID)
+	if err != nil {
+		return fmt.Errorf("acme: record %s not found: %w", recordID, err)
+	}
+
+	domain, err := database.GetDomainByID(m.client, record.DomainID)
+	kind := ProviderInternal
+	if err == nil && domain != nil && domain.DNSProvider != "" {
+		kind = ProviderKind(domain.DNSProvider)
+	}
+
+	legoClient, err := m.clientFor(kind)
+	if err != nil {
+		return fmt.Errorf("acme: failed to get client for %s: %w", record.Name, err)
+	}
+
+	req := certificate.ObtainRequest{Domains: []string{record.Name}, Bundle: true}
+	cert, err := legoClient.Certificate.Obtain(req)
+	if err != nil {
+		return fmt.Errorf("acme: failed to obtain certificate for %s: %w", record.Name, err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate)
+	if err != nil {
+		return fmt.Errorf("acme: failed to parse issued certificate for %s: %w", record.Name, err)
+	}
+
+	doc := storedCert{
+		Domain:    recordID,
+		Hostname:  record.Name,
+		RecordID:  recordID,
+		CertPEM:   cert.Certificate,
+		KeyPEM:    cert.PrivateKey,
+		IssuedAt:  time.Now(),
+		ExpiresAt: leaf.NotAfter,
+	}
+	if err := m.persist(recordID, doc); err != nil {
+		return err
+	}
+
+	tlsCert, err := tls.X509KeyPair(cert.Certificate, cert.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("acme: failed to build tls.Certificate for %s: %w", record.Name, err)
+	}
+	m.certsMu.Lock()
+	m.certs[record.Name] = &tlsCert
+	m.certsMu.Unlock()
+
+	if err := database.UpdateDNSRecordOriginSSL(m.client, "", recordID, true); err != nil {
+		return fmt.Errorf("acme: issued certificate for %s but failed to flag origin_ssl: %w", record.Name, err)
+	}
+
+	log.Printf("🔒 ACME: issued record-scoped certificate for %s (expires %s)", record.Name, leaf.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// sanListFor builds the certificate's SAN list: the apex plus a wildcard
+// covering every subdomain by default, and also every individual proxied
+// A/AAAA/CNAME record name already in dnsRecords — so a subdomain stays
+// covered even if a future order drops the wildcard (e.g. a CA/policy that
+// rejects it for this zone).
+func sanListFor(client *mongo.Client, domain string) []string {
+	sans := []string{domain, "*." + domain}
+
+	d, err := database.GetDomainByName(client, domain)
+	if err != nil || d == nil {
+		return sans
+	}
+	records, err := database.GetDNSRecords(client, d.ID)
+	if err != nil {
+		return sans
+	}
+
+	seen := map[string]bool{domain: true, "*." + domain: true}
+	for _, r := range records {
+		if !r.Proxied || (r.Type != "A" && r.Type != "AAAA" && r.Type != "CNAME") {
+			continue
+		}
+		name := strings.TrimSuffix(r.Name, ".")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		sans = append(sans, name)
+	}
+	return sans
+}
+
+// RefreshCache reloads all stored certificates from Mongo into memory. It is
+// safe to call this from ReloadRules so a cert issued on another node (or by
+// a previous process) becomes visible without a restart.
+func (m *Manager) RefreshCache() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := m.client.Database(database.DBName).Collection(certsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		log.Printf("[ERROR] acme: RefreshCache failed to load certificates: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var docs []storedCert
+	if err := cursor.All(ctx, &docs); err != nil {
+		log.Printf("[ERROR] acme: RefreshCache failed to decode certificates: %v", err)
+		return
+	}
+
+	newCerts := make(map[string]*tls.Certificate, len(docs))
+	for _, d := range docs {
+		tlsCert, err := tls.X509KeyPair(d.CertPEM, d.KeyPEM)
+		if err != nil {
+			log.Printf("[ERROR] acme: skipping corrupt certificate for %s: %v", d.Domain, err)
+			continue
+		}
+		hostname := d.Hostname
+		if hostname == "" {
+			hostname = d.Domain // pre-existing docs predate the Hostname field
+		}
+		newCerts[hostname] = &tlsCert
+	}
+
+	m.certsMu.Lock()
+	m.certs = newCerts
+	m.certsMu.Unlock()
+}
+
+// GetCertificate is the tls.Config.GetCertificate callback: it serves the
+// cached certificate for the SNI name, falling back to its parent zone so
+// subdomains reuse the wildcard issued alongside the root domain.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.certsMu.RLock()
+	defer m.certsMu.RUnlock()
+
+	if cert, ok := m.certs[hello.ServerName]; ok {
+		return cert, nil
+	}
+	if cert, ok := m.certs[rootZone(hello.ServerName)]; ok {
+		return cert, nil
+	}
+	return nil, fmt.Errorf("acme: no certificate available for %s", hello.ServerName)
+}
+
+// Status reports a lightweight health summary for SystemStatus: a
+// constructed Manager is always considered "up" (NewManager fails loudly
+// if the default account can't register); CertCount is how many
+// certificates are currently cached, so an operator can sanity-check that
+// TLS issuance is actually producing something.
+func (m *Manager) Status() (certCount int) {
+	m.certsMu.RLock()
+	defer m.certsMu.RUnlock()
+	return len(m.certs)
+}
+
+// CertInfo looks up the stored certificate for domain, for the cert-status
+// API endpoint. found is false if nothing has been issued for it yet.
+func (m *Manager) CertInfo(domain string) (issuedAt, expiresAt time.Time, found bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var doc storedCert
+	err = m.client.Database(database.DBName).Collection(certsCollection).FindOne(ctx, bson.M{"_id": domain}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	return doc.IssuedAt, doc.ExpiresAt, true, nil
+}
+
+// Revoke tells the CA to revoke domain's currently-issued certificate, then
+// removes it from both the "certificates" collection and the in-memory
+// cache so GetCertificate stops serving it immediately. It errors if no
+// certificate has been issued for domain yet.
+func (m *Manager) Revoke(domain string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var doc storedCert
+	err := m.client.Database(database.DBName).Collection(certsCollection).FindOne(ctx, bson.M{"_id": domain}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return fmt.Errorf("acme: no certificate issued for %s", domain)
+	}
+	if err != nil {
+		return fmt.Errorf("acme: failed to load certificate for %s: %w", domain, err)
+	}
+
+	legoClient, err := m.clientFor(m.providerKindFor(domain))
+	if err != nil {
+		return fmt.Errorf("acme: failed to get client for %s: %w", domain, err)
+	}
+	if err := legoClient.Certificate.Revoke(doc.CertPEM); err != nil {
+		return fmt.Errorf("acme: CA rejected revocation for %s: %w", domain, err)
+	}
+
+	if _, err := m.client.Database(database.DBName).Collection(certsCollection).DeleteOne(ctx, bson.M{"_id": domain}); err != nil {
+		return fmt.Errorf("acme: revoked %s at the CA but failed to remove it from storage: %w", domain, err)
+	}
+
+	m.certsMu.Lock()
+	delete(m.certs, doc.Hostname)
+	m.certsMu.Unlock()
+
+	log.Printf("🔒 ACME: revoked certificate for %s", domain)
+	return nil
+}
+
+// RenewDue walks the given domain list and re-issues any certificate that is
+// missing or within RenewBefore of expiring.
+func (m *Manager) RenewDue(domains []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, domain := range domains {
+		var doc storedCert
+		err := m.client.Database(database.DBName).Collection(certsCollection).
+			FindOne(ctx, bson.M{"_id": domain}).Decode(&doc)
+
+		needsIssue := err != nil || time.Until(doc.ExpiresAt) < RenewBefore
+		if !needsIssue {
+			continue
+		}
+		if err := m.IssueOrRenew(domain); err != nil {
+			log.Printf("[ERROR] acme: renewal failed for %s: %v", domain, err)
+		}
+	}
+}
+
+// RenewDueRecords is RenewDue's counterpart for certificates IssueForRecord
+// issued: it scans the "certificates" collection itself (rather than taking
+// a caller-supplied list) since record-scoped certs aren't enumerable from
+// the active-domains list the way zone-wide ones are.
+func (m *Manager) RenewDueRecords() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := m.client.Database(database.DBName).Collection(certsCollection).
+		Find(ctx, bson.M{"record_id": bson.M{"$exists": true, "$ne": ""}})
+	if err != nil {
+		log.Printf("[ERROR] acme: RenewDueRecords failed to list record certs: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var docs []storedCert
+	if err := cursor.All(ctx, &docs); err != nil {
+		log.Printf("[ERROR] acme: RenewDueRecords failed to decode record certs: %v", err)
+		return
+	}
+
+	for _, doc := range docs {
+		if time.Until(doc.ExpiresAt) >= RenewBefore {
+			continue
+		}
+		if err := m.IssueForRecord(doc.RecordID); err != nil {
+			log.Printf("[ERROR] acme: record renewal failed for %s: %v", doc.Hostname, err)
+		}
+	}
+}
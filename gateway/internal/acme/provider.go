@@ -0,0 +1,105 @@
+package acme
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// pendingChallenge tracks everything CleanUp needs to remove a TXT record
+// that Present previously created, since lego only gives us the FQDN back.
+type pendingChallenge struct {
+	recordID string
+	value    string
+}
+
+// DNSProvider implements lego's challenge.Provider so Let's Encrypt can be
+// satisfied with DNS-01 using nothing but the records we already serve
+// authoritatively. No external DNS provider/API key is required.
+type DNSProvider struct {
+	client *mongo.Client
+
+	mu      sync.Mutex
+	pending map[string]pendingChallenge // fqdn -> challenge bookkeeping
+}
+
+// NewDNSProvider wires the DNS-01 solver to the Mongo-backed DomainRepository
+// the rest of the gateway already uses for DNS records.
+func NewDNSProvider(client *mongo.Client) *DNSProvider {
+	return &DNSProvider{
+		client:  client,
+		pending: make(map[string]pendingChallenge),
+	}
+}
+
+// Present creates the "_acme-challenge.<domain>" TXT record lego's HTTP-01
+// fallback is never needed for, fulfilling the DNS-01 challenge.
+func (p *DNSProvider) Present(domainName, token, keyAuth string) error {
+	fqdn, value := dns01.GetRecord(domainName, keyAuth)
+	recordName := strings.TrimSuffix(fqdn, ".")
+
+	zone := rootZone(domainName)
+	domain, err := database.GetDomainByName(p.client, zone)
+	if err != nil || domain == nil {
+		return fmt.Errorf("acme: zone %s is not managed by this gateway: %w", zone, err)
+	}
+
+	recordID, err := database.CreateDNSRecord(p.client, database.DNSRecord{
+		DomainID: domain.ID,
+		Name:     recordName,
+		Type:     "TXT",
+		Content:  value,
+		TTL:      120,
+		Proxied:  false,
+	})
+	if err != nil {
+		return fmt.Errorf("acme: failed to insert challenge record: %w", err)
+	}
+
+	// Meta records never proxy, so publish it straight to the resolution
+	// backend as well (wafIP is irrelevant for TXT).
+	if err := database.AddPowerDNSRecord(recordName, "TXT", value, false, ""); err != nil {
+		return fmt.Errorf("acme: failed to publish challenge record: %w", err)
+	}
+
+	p.mu.Lock()
+	p.pending[fqdn] = pendingChallenge{recordID: recordID, value: value}
+	p.mu.Unlock()
+
+	return nil
+}
+
+// CleanUp removes the TXT record created by Present.
+func (p *DNSProvider) CleanUp(domainName, token, keyAuth string) error {
+	fqdn, _ := dns01.GetRecord(domainName, keyAuth)
+	recordName := strings.TrimSuffix(fqdn, ".")
+
+	p.mu.Lock()
+	pending, ok := p.pending[fqdn]
+	delete(p.pending, fqdn)
+	p.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if err := database.DeleteDNSRecord(p.client, "", pending.recordID); err != nil {
+		return fmt.Errorf("acme: failed to remove challenge record: %w", err)
+	}
+	return database.DeletePowerDNSRecordByContent(recordName, "TXT", pending.value)
+}
+
+// rootZone returns the apex (e.g. "example.com" for "www.example.com") the
+// way the rest of the domain-onboarding flow already does.
+func rootZone(domain string) string {
+	parts := strings.Split(domain, ".")
+	if len(parts) < 2 {
+		return domain
+	}
+	return parts[len(parts)-2] + "." + parts[len(parts)-1]
+}
@@ -0,0 +1,43 @@
+package acme
+
+import (
+	"fmt"
+
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/providers/dns/cloudflare"
+	"github.com/go-acme/lego/v4/providers/dns/route53"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ProviderKind selects which DNS-01 solver a domain's certificates use.
+// Stored on detector.Domain.DNSProvider; empty means ProviderInternal.
+type ProviderKind string
+
+const (
+	// ProviderInternal solves the challenge against our own authoritative
+	// PowerDNS zone — no external account or API key required. This is the
+	// default for every domain onboarded through AddDomain.
+	ProviderInternal ProviderKind = "internal"
+	// ProviderCloudflare and ProviderRoute53 are for users who point their
+	// domain at an external DNS host instead of delegating to our NS pair.
+	ProviderCloudflare ProviderKind = "cloudflare"
+	ProviderRoute53    ProviderKind = "route53"
+)
+
+// newChallengeProvider builds the lego challenge.Provider for kind.
+// Cloudflare and Route53 read their credentials from the environment the
+// same way lego's own CLI does (CLOUDFLARE_DNS_API_TOKEN, AWS_* vars) since
+// the gateway has no per-domain secret vault yet — every domain using a
+// given external provider shares one set of account credentials.
+func newChallengeProvider(kind ProviderKind, client *mongo.Client) (challenge.Provider, error) {
+	switch kind {
+	case ProviderCloudflare:
+		return cloudflare.NewDNSProvider()
+	case ProviderRoute53:
+		return route53.NewDNSProvider()
+	case ProviderInternal, "":
+		return NewDNSProvider(client), nil
+	default:
+		return nil, fmt.Errorf("acme: unknown dns provider kind %q", kind)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/models"
+	"web-app-firewall-ml-detection/internal/role"
+)
+
+// acmeChallengeRequest is the body both PresentACMEChallenge and
+// CleanupACMEChallenge accept — the same three arguments lego's
+// challenge.Provider.Present/CleanUp pass their DNS-01 solver, so any ACME
+// client driving this gateway over HTTP (lego, certmagic, autocert with a
+// custom challenge.Provider) can forward them verbatim.
+type acmeChallengeRequest struct {
+	Domain  string `json:"domain"`
+	Token   string `json:"token"`
+	KeyAuth string `json:"key_auth"`
+}
+
+// PresentACMEChallenge handles POST /api/dns/acme/present?domain_id=xxx.
+// It publishes the "_acme-challenge.<domain>" TXT record CertManager's own
+// DNS-01 solver (acme.DNSProvider) would publish for itself, so an external
+// ACME client can obtain a certificate for a proxied hostname using this
+// gateway's authoritative zone instead of needing its own DNS credentials.
+// It goes straight through h.ACMESolver rather than DNSService.AddRecord, so
+// it skips the user-facing TTL range and conflict checks that would reject
+// a TXT record coexisting with whatever's already on the name — but
+// ownership of the zone is still enforced exactly like /api/dns/records.
+func (h *APIHandler) PresentACMEChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domain, req, ok := h.authorizeACMEChallenge(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.ACMESolver.Present(req.Domain, req.Token, req.KeyAuth); err != nil {
+		h.WriteJSONError(w, "Failed to publish challenge record: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"domain": domain.Name,
+	})
+}
+
+// CleanupACMEChallenge handles POST /api/dns/acme/cleanup?domain_id=xxx,
+// removing a TXT record PresentACMEChallenge previously published.
+func (h *APIHandler) CleanupACMEChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domain, req, ok := h.authorizeACMEChallenge(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.ACMESolver.CleanUp(req.Domain, req.Token, req.KeyAuth); err != nil {
+		h.WriteJSONError(w, "Failed to remove challenge record: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"domain": domain.Name,
+	})
+}
+
+// authorizeACMEChallenge decodes an acmeChallengeRequest and confirms the
+// caller holds at least role.Editor on the domain whose zone req.Domain
+// falls under, writing an error response and returning ok=false otherwise.
+func (h *APIHandler) authorizeACMEChallenge(w http.ResponseWriter, r *http.Request) (*models.Domain, acmeChallengeRequest, bool) {
+	domainID := r.URL.Query().Get("domain_id")
+	if domainID == "" {
+		h.WriteJSONError(w, "domain_id is required", http.StatusBadRequest)
+		return nil, acmeChallengeRequest{}, false
+	}
+
+	var req acmeChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.WriteJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return nil, acmeChallengeRequest{}, false
+	}
+	if req.Domain == "" || req.KeyAuth == "" {
+		h.WriteJSONError(w, "domain and key_auth are required", http.StatusBadRequest)
+		return nil, acmeChallengeRequest{}, false
+	}
+
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.WriteJSONError(w, "Domain not found", http.StatusNotFound)
+		return nil, acmeChallengeRequest{}, false
+	}
+
+	// req.Domain must be the zone itself or a name under it — it's the
+	// hostname the ACME order actually covers, which may be a subdomain of
+	// the domain record's root.
+	zone := getRootDomain(req.Domain)
+	if zone != domain.Name {
+		h.WriteJSONError(w, "domain is not under the zone identified by domain_id", http.StatusBadRequest)
+		return nil, acmeChallengeRequest{}, false
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	userRole, err := database.GetMemberRole(h.MongoClient, domain.ID, userID)
+	if err != nil || !role.Satisfies(userRole, role.Editor) {
+		h.WriteJSONError(w, "Forbidden: insufficient role on this domain", http.StatusForbidden)
+		return nil, acmeChallengeRequest{}, false
+	}
+
+	return domain, req, true
+}
@@ -7,13 +7,18 @@ import (
 	"log"
 	"net/http"
 	"net/http/httputil"
-	"sync"
-	"sync/atomic"
 	"time"
 
+	"web-app-firewall-ml-detection/internal/acme"
 	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/decisions"
 	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/dnsbackend"
+	"web-app-firewall-ml-detection/internal/dnsserver"
 	"web-app-firewall-ml-detection/internal/limiter"
+	"web-app-firewall-ml-detection/internal/netutil"
+	"web-app-firewall-ml-detection/internal/rdap"
+	"web-app-firewall-ml-detection/internal/sessionstore"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -34,22 +39,73 @@ type APIHandler struct {
 	WafPublicIP      string
 	UnconfiguredPage []byte
 
-	// RULES CACHE
-	rulesMutex sync.RWMutex
-	domainRules map[string][]detector.WAFRule
-	
-	// [NEW] Domain Metadata Cache (Host -> Domain Info)
-	// This allows WAF to know UserID/DomainID without querying DB every request
-	domainMap      map[string]detector.Domain 
-	
-	globalFallback []detector.WAFRule
+	// [UPDATED] Rules/domains/policies now live behind a fingerprinted,
+	// atomically-swapped RulesConfig rather than a raw RWMutex + maps, so
+	// readers on the WAF hot path never block on a reload and external
+	// controllers get a compare-and-swap primitive via DoLockedAction.
+	Config *RulesConfig
+
+	// [NEW] Automatic TLS via ACME DNS-01 (nil if the ACME account could not
+	// be registered, e.g. no network access during local development).
+	CertManager *acme.Manager
+
+	// [NEW] Where DNS records actually get published. dns.go calls this
+	// instead of internal/database's PowerDNS functions directly, so an
+	// operator can swap in dnsbackend.CloudflareProvider without touching
+	// the HTTP handlers.
+	DNSProvider dnsbackend.Provider
+
+	// [NEW] The optional in-process authoritative DNS server (nil unless
+	// DNSServer.Enabled is set in config) — SystemStatus reads Stats() off
+	// this to report query rates and zone counts alongside dns_server.
+	DNSServer *dnsserver.Server
+
+	// [NEW] The optional multi-node rule/policy replica syncer (nil unless
+	// ReplicaSync.Enabled is set in config) — ReplicaStatus reads Status()
+	// off this for GET /api/replica/status.
+	Syncer *database.Syncer
+
+	// [NEW] Resolves live nameservers for domain verification via IANA's
+	// RDAP bootstrap registry, with a cached/fallback chain. SystemStatus
+	// reads Stats() off this to report cache hit rate and provider errors.
+	RDAP *rdap.Resolver
 
-	// Stats
-	reqCount uint64
-	rpm      uint64
+	// [NEW] Solves DNS-01 challenges against our own authoritative zone.
+	// CertManager uses its own instance of this for the certs it issues
+	// itself; acme_challenge.go exposes this one over HTTP so an external
+	// ACME client can drive the same solve without a direct Mongo/PowerDNS
+	// connection.
+	ACMESolver *acme.DNSProvider
+
+	// [NEW] CrowdSec-compatible threat feed (nil unless Decisions.Enabled
+	// is set in config) — WAFHandler consults its Trie on clientIP before
+	// any rule/ML check, and /api/decisions reads/writes it directly.
+	Decisions *decisions.Feed
+
+	// [NEW] Served instead of the generic "WAF Blocked" body when
+	// WAFHandler short-circuits on a Decisions hit; nil falls back to the
+	// generic body, same relationship UnconfiguredPage has to its 404.
+	DecisionsBanPage []byte
+
+	// [NEW] The trusted-proxy chain clientIP resolves behind, via
+	// netutil.RealIP — zero value trusts nothing, so an unconfigured
+	// gateway falls back to r.RemoteAddr exactly like before.
+	TrustedProxies netutil.TrustedProxyConfig
+
+	// [NEW] Where refresh-token sessions actually live. auth.go/sessions.go
+	// call this instead of internal/database's refresh-token functions
+	// directly, so an operator can swap in sessionstore.NewRedisStore for a
+	// multi-node deployment without touching the login/refresh/logout flow.
+	Sessions sessionstore.Store
 }
 
-func NewAPIHandler(client *mongo.Client, proxy *httputil.ReverseProxy, limiter *limiter.RateLimiter, mlURL, originURL, wafPublicIP string, unconfiguredPage []byte) *APIHandler {
+func NewAPIHandler(client *mongo.Client, proxy *httputil.ReverseProxy, limiter *limiter.RateLimiter, mlURL, originURL, wafPublicIP string, unconfiguredPage []byte, dnsProvider dnsbackend.Provider, dnsServer *dnsserver.Server, syncer *database.Syncer, rulesConfig *RulesConfig, acmeEmail, acmeDirectoryURL, acmeEABKeyID, acmeEABHMACKey string, decisionsFeed *decisions.Feed, decisionsBanPage []byte, trustedProxies netutil.TrustedProxyConfig, sessions sessionstore.Store) *APIHandler {
+	if rulesConfig == nil {
+		rulesConfig = NewRulesConfig()
+	}
+	if sessions == nil {
+		sessions = sessionstore.NewMongoStore(client)
+	}
 	h := &APIHandler{
 		MongoClient:      client,
 		Proxy:            proxy,
@@ -58,16 +114,65 @@ func NewAPIHandler(client *mongo.Client, proxy *httputil.ReverseProxy, limiter *
 		OriginURL:        originURL,
 		WafPublicIP:      wafPublicIP,
 		UnconfiguredPage: unconfiguredPage,
-		domainRules:      make(map[string][]detector.WAFRule),
-		domainMap:        make(map[string]detector.Domain), // [NEW]
+		Config:           rulesConfig,
+		DNSProvider:      dnsProvider,
+		DNSServer:        dnsServer,
+		Syncer:           syncer,
+		RDAP:             rdap.NewResolver(client),
+		ACMESolver:       acme.NewDNSProvider(client),
+		Decisions:        decisionsFeed,
+		DecisionsBanPage: decisionsBanPage,
+		TrustedProxies:   trustedProxies,
+		Sessions:         sessions,
+	}
+
+	// [NEW] ACME certificate manager. Registration needs outbound network
+	// access, so we degrade gracefully instead of crashing the gateway.
+	if acmeEmail == "" {
+		acmeEmail = "admin@minishield.tech"
+	}
+	if mgr, err := acme.NewManager(client, acmeEmail, acmeDirectoryURL, acmeEABKeyID, acmeEABHMACKey); err != nil {
+		log.Printf("[ERROR] ACME: manager unavailable, TLS will not auto-renew: %v", err)
+	} else {
+		h.CertManager = mgr
+		go h.startCertRenewalTicker()
 	}
 
 	h.ReloadRules()
-	go h.startStatsTicker()
 
 	return h
 }
 
+// startCertRenewalTicker periodically re-issues certificates for every
+// active domain that is missing one or close to expiry.
+func (h *APIHandler) startCertRenewalTicker() {
+	ticker := time.NewTicker(12 * time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		domains, err := database.GetAllDomains(h.MongoClient)
+		if err != nil {
+			log.Printf("[ERROR] ACME: renewal tick failed to list domains: %v", err)
+			continue
+		}
+		var names []string
+		for _, d := range domains {
+			if d.Status == "active" {
+				names = append(names, d.Name)
+			}
+		}
+		h.CertManager.RenewDue(names)
+		h.CertManager.RenewDueRecords()
+		h.CertManager.RefreshCache()
+	}
+}
+
+// clientIP resolves r's true client IP behind h.TrustedProxies, replacing
+// the handful of duplicate "take the first X-Forwarded-For value"
+// implementations that used to live in waf.go and auth.go separately.
+func (h *APIHandler) clientIP(r *http.Request) string {
+	return netutil.RealIP(r, h.TrustedProxies)
+}
+
 func (h *APIHandler) WriteJSONError(w http.ResponseWriter, message string, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -77,14 +182,24 @@ func (h *APIHandler) WriteJSONError(w http.ResponseWriter, message string, code
 	})
 }
 
-// ReloadRules: Merges Rules and Updates Domain Cache
-// ... (lines 1-76 remain the same)
+// writeFingerprintConflict responds 409 when a mutating rules/policy
+// endpoint's If-Match fingerprint no longer matches the live Config (another
+// operator's edit landed first), including the new fingerprint so the
+// caller can refresh and retry instead of guessing at it.
+func (h *APIHandler) writeFingerprintConflict(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":      "error",
+		"message":     "rules cache changed since your If-Match fingerprint; refresh and retry",
+		"fingerprint": h.Config.Fingerprint(),
+	})
+}
 
-// ReloadRules: Merges Rules and Updates Domain Cache
+// ReloadRules: Merges Rules and Updates Domain Cache. The whole thing is
+// recomputed on every call, but a new snapshot is only published (and
+// readers only ever see a new pointer) if the fingerprint actually changed.
 func (h *APIHandler) ReloadRules() {
-	h.rulesMutex.Lock()
-	defer h.rulesMutex.Unlock()
-
 	// 1. Fetch All Data
 	allRules, err := database.GetRules(h.MongoClient, bson.M{})
 	if err != nil {
@@ -108,6 +223,14 @@ func (h *APIHandler) ReloadRules() {
 		return
 	}
 
+	// [NEW] Skip the rebuild entirely if nothing actually changed since the
+	// last reload — a ticker firing or an unrelated write shouldn't pay for
+	// a map rebuild + swap.
+	fingerprint := computeFingerprint(allRules, policies, domains, dnsRecords)
+	if fingerprint == h.Config.Fingerprint() {
+		return
+	}
+
 	// 2. Build the Domain Map (The Routing Table)
 	newDomainMap := make(map[string]detector.Domain)
 	
@@ -132,8 +255,6 @@ func (h *APIHandler) ReloadRules() {
 		}
 	}
 
-	h.domainMap = newDomainMap
-
 	// 3. Separate Global vs Private Rules (Existing Logic)
 	globalRules := []detector.WAFRule{}
 	privateRules := make(map[string][]detector.WAFRule)
@@ -164,14 +285,14 @@ func (h *APIHandler) ReloadRules() {
 		// A. Global Rules
 		for _, r := range globalRules {
 			if isEnabled(r.ID, d.ID, policyMap, true) {
-				effective = append(effective, r)
+				effective = append(effective, compileRuleConstraints(r))
 			}
 		}
 		// B. Private Rules
 		if userRules, ok := privateRules[d.UserID]; ok {
 			for _, r := range userRules {
 				if isEnabled(r.ID, d.ID, policyMap, true) {
-					effective = append(effective, r)
+					effective = append(effective, compileRuleConstraints(r))
 				}
 			}
 		}
@@ -188,10 +309,50 @@ func (h *APIHandler) ReloadRules() {
 		}
 	}
 
-	h.domainRules = newDomainRules
-	h.globalFallback = globalRules
+	// [NEW] Group A/AAAA dns_records by host for RulesConfig.OriginPool, so
+	// internal/origin.Director stops hitting Mongo on every proxied
+	// request for the same pool GetOriginPool would have returned.
+	newOriginPools := make(map[string][]database.DNSRecord)
+	for _, r := range dnsRecords {
+		if r.Type == "A" || r.Type == "AAAA" {
+			newOriginPools[r.Name] = append(newOriginPools[r.Name], r)
+		}
+	}
+
+	next := &rulesSnapshot{
+		domainRules:    newDomainRules,
+		domainMap:      newDomainMap,
+		globalFallback: globalRules,
+		fingerprint:    fingerprint,
+		originPools:    newOriginPools,
+	}
+	if !h.Config.swapIfChanged(next) {
+		return
+	}
 
-	log.Printf("♻️  Rules Reloaded. Routing active for %d hosts.", len(h.domainMap))
+	// [NEW] Keep the TLS cert cache in sync with anything issued since the
+	// last reload (e.g. by a renewal tick on another node).
+	if h.CertManager != nil {
+		h.CertManager.RefreshCache()
+	}
+
+	log.Printf("♻️  Rules Reloaded (v%d). Routing active for %d hosts.", h.Config.Version(), len(newDomainMap))
+}
+
+// compileRuleConstraints compiles rule's Allowed*/Denied* lists into
+// rule.Constraints so CheckRequest never parses a CIDR or glob on the hot
+// path. A rule whose lists were already rejected by AddCustomRule's own
+// CompileConstraints call should never fail to compile again here, but if
+// one does (e.g. a value written before validation existed), the rule
+// loads with no constraints rather than dropping it from the ruleset.
+func compileRuleConstraints(rule detector.WAFRule) detector.WAFRule {
+	constraints, err := detector.CompileConstraints(rule)
+	if err != nil {
+		log.Printf("[WARN] ReloadRules: rule %s has invalid constraints, loading unconstrained: %v", rule.ID, err)
+		return rule
+	}
+	rule.Constraints = constraints
+	return rule
 }
 
 func isEnabled(ruleID, domainID string, policies map[policyKey]bool, def bool) bool {
@@ -204,11 +365,3 @@ func isEnabled(ruleID, domainID string, policies map[policyKey]bool, def bool) b
 	return def
 }
 
-func (h *APIHandler) startStatsTicker() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-	for range ticker.C {
-		count := atomic.SwapUint64(&h.reqCount, 0)
-		atomic.StoreUint64(&h.rpm, count)
-	}
-}
\ No newline at end of file
@@ -3,19 +3,49 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 	"web-app-firewall-ml-detection/internal/database"
 	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/sessionstore"
 
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // JWT Secret (Use os.Getenv in production)
 var JWTSecret = []byte("super_secret_waf_key_change_me")
 
+// LoginProvider authenticates a set of credentials against a backing store
+// and returns the internal User on success. The password/email flow below
+// is the default implementation; OAuthProvider (oauth.go) covers external
+// identity providers instead.
+type LoginProvider interface {
+	AttemptLogin(ctx context.Context, credentials detector.UserInput) (detector.User, error)
+}
+
+// passwordLoginProvider is the bcrypt/email LoginProvider the gateway has
+// always shipped with.
+type passwordLoginProvider struct {
+	client *mongo.Client
+}
+
+func (p *passwordLoginProvider) AttemptLogin(ctx context.Context, credentials detector.UserInput) (detector.User, error) {
+	user, err := database.GetUserByEmail(p.client, credentials.Email)
+	if err != nil {
+		return detector.User{}, errors.New("invalid email or password")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)); err != nil {
+		return detector.User{}, errors.New("invalid email or password")
+	}
+	return *user, nil
+}
+
 func (h *APIHandler) Register(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -51,12 +81,16 @@ func (h *APIHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	// Save to DB
 	if err := database.CreateUser(h.MongoClient, user); err != nil {
+		if errors.Is(err, database.ErrDuplicate) {
+			h.WriteJSONError(w, "Email already registered", http.StatusConflict)
+			return
+		}
 		h.WriteJSONError(w, "Registration failed:  "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message":  "User registered successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "User registered successfully"})
 }
 
 func (h *APIHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -72,64 +106,156 @@ func (h *APIHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := database.GetUserByEmail(h.MongoClient, input.Email)
+	ip, ua := h.clientIP(r), r.UserAgent()
+
+	// [NEW] Refuse a login already inside a failed-attempt lockout without
+	// even touching bcrypt, so a locked-out account can't be used to keep
+	// probing passwords.
+	if locked, retryAfter, err := database.CheckLoginLock(h.MongoClient, input.Email); err == nil && locked {
+		database.RecordAuthEvent(h.MongoClient, input.Email, ip, ua, "locked")
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		h.WriteJSONError(w, "Too many failed attempts. Try again later.", http.StatusTooManyRequests)
+		return
+	}
+
+	provider := &passwordLoginProvider{client: h.MongoClient}
+	user, err := provider.AttemptLogin(r.Context(), input)
 	if err != nil {
-		h.WriteJSONError(w, "Invalid email or password", http.StatusUnauthorized)
+		locked, retryAfter, lockErr := database.RegisterLoginAttempt(h.MongoClient, input.Email, false)
+		if lockErr == nil && locked {
+			database.RecordAuthEvent(h.MongoClient, input.Email, ip, ua, "locked")
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+			h.WriteJSONError(w, "Too many failed attempts. Try again later.", http.StatusTooManyRequests)
+			return
+		}
+		database.RecordAuthEvent(h.MongoClient, input.Email, ip, ua, "invalid_credentials")
+		h.WriteJSONError(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
+	database.RegisterLoginAttempt(h.MongoClient, input.Email, true)
+	database.RecordAuthEvent(h.MongoClient, input.Email, ip, ua, "success")
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		h.WriteJSONError(w, "Invalid email or password", http.StatusUnauthorized)
+	if err := h.issueSession(w, r, user); err != nil {
+		h.WriteJSONError(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	// Generate JWT
-	expiration := time.Now().Add(24 * time.Hour)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"email":   user.Email,
-		"exp":     expiration.Unix(),
+	// Return User Info
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Login successful",
+		"user": map[string]string{
+			"id":    user.ID,
+			"name":  user.Name,
+			"email": user.Email,
+		},
 	})
+}
 
-	tokenString, err := token.SignedString(JWTSecret)
+// AccessTokenTTL/RefreshTokenTTL bound the two cookies issueSession mints:
+// a short-lived HS256 access token for normal API auth (auth_token), and a
+// long-lived opaque refresh token (refresh_token, backed by the
+// refresh_tokens collection) that RefreshToken can rotate into a new pair
+// without forcing a full login every 15 minutes.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+const (
+	accessCookieName  = "auth_token"
+	refreshCookieName = "refresh_token"
+)
+
+// cookieDomain mirrors the dev/prod cookie-scope logic every session
+// cookie on this gateway needs: ".minishield.tech" in prod so api./www.
+// share the cookie, "" (host-only) in dev so it works on localhost.
+func cookieDomain() string {
+	if os.Getenv("APP_ENV") == "production" {
+		return ".minishield.tech"
+	}
+	return ""
+}
+
+// issueSession mints both halves of a session — the auth_token access JWT
+// and an opaque refresh_token — and sets them as cookies. The password
+// flow above and the OAuth/OIDC callbacks (oauth.go, oidc.go) all share
+// this so a session looks identical regardless of how the user
+// authenticated. The refresh token is minted first so its session id can
+// be embedded in the access token, letting CheckAuth confirm later that
+// the session it was issued under is still live.
+func (h *APIHandler) issueSession(w http.ResponseWriter, r *http.Request, user detector.User) error {
+	sessionID, err := h.issueRefreshToken(w, r, user.ID, "")
 	if err != nil {
-		h.WriteJSONError(w, "Failed to generate token", http.StatusInternalServerError)
-		return
+		return err
 	}
+	return h.setAccessCookie(w, user.ID, user.Email, sessionID)
+}
 
-	// Determine if we are in Production
-	isProd := os.Getenv("APP_ENV") == "production"
+// setAccessCookie mints a short-lived HS256 access token and sets it as
+// the auth_token cookie. Shared by issueSession (first login) and
+// RefreshToken (rotation), since both ultimately hand the client a fresh
+// access token the same way. sessionID is the refresh token's jti, carried
+// as the "sid" claim so CheckAuth can look the session back up.
+func (h *APIHandler) setAccessCookie(w http.ResponseWriter, userID, email, sessionID string) error {
+	expiration := time.Now().Add(AccessTokenTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"email":   email,
+		"sid":     sessionID,
+		"exp":     expiration.Unix(),
+	})
 
-	// Dynamic Domain Logic:
-	// - Prod: ".minishield.tech" (Allows cookie sharing between api. and www.)
-	// - Dev:  "" (Empty string defaults to "Host Only", required for localhost)
-	cookieDomain := ""
-	if isProd {
-		cookieDomain = ".minishield.tech"
+	tokenString, err := token.SignedString(JWTSecret)
+	if err != nil {
+		return err
 	}
 
 	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
+		Name:     accessCookieName,
 		Value:    tokenString,
 		Expires:  expiration,
 		HttpOnly: true,
 		Path:     "/",
-		
-		// Dynamic Settings
-		Domain:   cookieDomain,
-		Secure:   true,               // True in Prod (HTTPS), False in Dev (HTTP)
+		Domain:   cookieDomain(),
+		Secure:   true,                  // True in Prod (HTTPS), False in Dev (HTTP)
 		SameSite: http.SameSiteNoneMode, // Lax is best for normal navigation
 	})
+	return nil
+}
 
-	// Return User Info
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Login successful",
-		"user":  map[string]string{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-		},
+// issueRefreshToken mints a new opaque refresh token, persists it (hashed)
+// via h.Sessions, sets it as the refresh_token cookie, and — when rotating
+// an existing session rather than starting a new one — atomically marks
+// replacedJTI as rotated to the new token's jti so reuse of the old raw
+// value can be detected later. It returns the new session's jti so callers
+// can embed it in the access token's "sid" claim.
+func (h *APIHandler) issueRefreshToken(w http.ResponseWriter, r *http.Request, userID, replacedJTI string) (string, error) {
+	raw, err := randomState() // 128 bits of crypto/rand, base64-url encoded
+	if err != nil {
+		return "", err
+	}
+
+	jti, err := h.Sessions.Create(r.Context(), userID, raw, r.UserAgent(), h.clientIP(r), RefreshTokenTTL)
+	if err != nil {
+		return "", err
+	}
+	if replacedJTI != "" {
+		if err := h.Sessions.Rotate(r.Context(), replacedJTI, jti); err != nil {
+			return "", err
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    raw,
+		Expires:  time.Now().Add(RefreshTokenTTL),
+		HttpOnly: true,
+		Path:     "/",
+		Domain:   cookieDomain(),
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
 	})
+	return jti, nil
 }
 
 func (h *APIHandler) CheckAuth(w http.ResponseWriter, r *http.Request) {
@@ -139,6 +265,19 @@ func (h *APIHandler) CheckAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// [NEW] A valid access JWT isn't enough on its own — confirm the
+	// session it was minted under hasn't been revoked (logout, a killed
+	// session, or a detected refresh-token reuse) since. sessionID is
+	// empty for tokens from a trusted external issuer (external_auth.go),
+	// which this gateway doesn't hold a session for.
+	if sessionID, _ := r.Context().Value("session_id").(string); sessionID != "" {
+		sess, err := h.Sessions.Get(r.Context(), sessionID)
+		if err != nil || sess.Revoked || time.Now().After(sess.ExpiresAt) {
+			h.WriteJSONError(w, "Session no longer active", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	// Fetch full user details to get the Name
 	user, err := database.GetUserByID(h.MongoClient, userID)
 	userName := "Unknown"
@@ -158,88 +297,96 @@ func (h *APIHandler) CheckAuth(w http.ResponseWriter, r *http.Request) {
 
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("auth_token")
+		userID, sessionID, err := resolveSession(r)
 		if err != nil {
 			// MANUAL JSON ERROR RESPONSE
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{
 				"status":  "error",
-				"message": "Unauthorized: No session cookie",
+				"message": "Unauthorized: " + err.Error(),
 			})
 			return
 		}
 
-		token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-			return JWTSecret, nil
-		})
+		ctx := context.WithValue(r.Context(), "user_id", userID)
+		ctx = context.WithValue(ctx, "session_id", sessionID)
+		next(w, r.WithContext(ctx))
+	}
+}
 
-		if err != nil || !token.Valid {
-			// MANUAL JSON ERROR RESPONSE
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{
-				"status":  "error",
-				"message": "Unauthorized: Invalid token",
-			})
-			return
-		}
+// resolveSession authenticates a request either via the local auth_token
+// cookie (HS256, minted by issueSession) or, if no cookie is present, an
+// "Authorization: Bearer <jwt>" header signed by one of the trusted
+// external issuers loaded by InitExternalAuth (external_auth.go) — so CI
+// pipelines and service accounts can call the management API without ever
+// minting a local session. The returned sessionID is empty for external
+// tokens, since this gateway holds no session for them.
+func resolveSession(r *http.Request) (userID string, sessionID string, err error) {
+	if cookie, err := r.Cookie("auth_token"); err == nil {
+		return resolveLocalToken(cookie.Value)
+	}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			// MANUAL JSON ERROR RESPONSE
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{
-				"status":  "error",
-				"message": "Unauthorized: Invalid claims",
-			})
-			return
-		}
+	bearer := r.Header.Get("Authorization")
+	if !strings.HasPrefix(bearer, "Bearer ") {
+		return "", "", errors.New("No session cookie")
+	}
+	userID, err = verifyExternalToken(r.Context(), strings.TrimPrefix(bearer, "Bearer "))
+	return userID, "", err
+}
 
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			// MANUAL JSON ERROR RESPONSE
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{
-				"status":  "error",
-				"message": "Unauthorized",
-			})
-			return
-		}
+func resolveLocalToken(tokenString string) (string, string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return JWTSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return "", "", errors.New("Invalid token")
+	}
 
-		ctx := context.WithValue(r.Context(), "user_id", userID)
-		next(w, r.WithContext(ctx))
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", "", errors.New("Invalid claims")
 	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return "", "", errors.New("Invalid claims")
+	}
+	// sid is absent on tokens minted before session liveness checks
+	// existed; CheckAuth treats an empty sessionID as "nothing to check".
+	sessionID, _ := claims["sid"].(string)
+	return userID, sessionID, nil
 }
 
 func (h *APIHandler) Logout(w http.ResponseWriter, r *http.Request) {
-    // 1. Determine Environment (MUST match Login logic)
-    isProd := os.Getenv("APP_ENV") == "production"
-
-    cookieDomain := ""
-    if isProd {
-        cookieDomain = ".minishield.tech"
-    }
-
-    // 2. Clear the Cookie
-    // We set the same Name, Path, Domain, Secure, and HttpOnly attributes.
-    // We only change Value to "" and Expires to a past date.
-    http.SetCookie(w, &http.Cookie{
-        Name:     "auth_token",
-        Value:    "",              // Empty value
-        Expires:  time.Unix(0, 0), // Expire immediately (1970)
-        
-        // These MUST match what you set in Login:
-        HttpOnly: true,
-        Path:     "/",
-        Domain:   cookieDomain,    // Crucial: Match the domain!
-        Secure:   true,         // Crucial: Match the Secure flag!
-        SameSite: http.SameSiteLaxMode,
-		
-    })
-
-    w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
-}
\ No newline at end of file
+	// Revoke the refresh token server-side too, not just the cookies —
+	// otherwise a copy of the cookie captured before logout would still
+	// mint new sessions via /auth/refresh forever.
+	if cookie, err := r.Cookie(refreshCookieName); err == nil {
+		if sess, err := h.Sessions.GetByHash(r.Context(), sessionstore.HashToken(cookie.Value)); err == nil {
+			h.Sessions.Revoke(r.Context(), sess.ID)
+		}
+	}
+
+	clearCookie(w, accessCookieName)
+	clearCookie(w, refreshCookieName)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+}
+
+// clearCookie expires a session cookie immediately, matching the Path,
+// Domain, Secure and HttpOnly attributes it was set with so the browser
+// actually overwrites it instead of leaving the original in place.
+func clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Path:     "/",
+		Domain:   cookieDomain(),
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
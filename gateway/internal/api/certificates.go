@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"web-app-firewall-ml-detection/internal/core"
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/role"
+)
+
+// IssueRecordCertificate handles POST /api/dns/records/{id}/issue-cert: it
+// drives CertManager through a DNS-01 challenge for this one record's
+// hostname, persists the resulting certificate, and flips OriginSSL on once
+// it succeeds — a record-scoped counterpart to the zone-wide certs
+// CertManager already issues automatically for every active domain.
+func (h *APIHandler) IssueRecordCertificate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	recordID := r.PathValue("id")
+	if recordID == "" {
+		h.WriteJSONError(w, "record id is required", http.StatusBadRequest)
+		return
+	}
+
+	record, err := database.GetDNSRecordByID(h.MongoClient, recordID)
+	if err != nil {
+		h.WriteJSONError(w, "Record not found", http.StatusNotFound)
+		return
+	}
+
+	// Ownership is keyed by the record's domain, not the record itself.
+	userID := r.Context().Value("user_id").(string)
+	userRole, err := database.GetMemberRole(h.MongoClient, record.DomainID, userID)
+	if err != nil || !role.Satisfies(userRole, role.Editor) {
+		h.WriteJSONError(w, "Forbidden: insufficient role on this domain", http.StatusForbidden)
+		return
+	}
+
+	if h.CertManager == nil {
+		h.WriteJSONError(w, "ACME certificate manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := h.CertManager.IssueForRecord(recordID); err != nil {
+		h.WriteJSONError(w, "Certificate issuance failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Certificate issued for " + record.Name,
+	})
+}
+
+// IssueCertificate handles POST /api/domains/{id}/certificate/issue: a
+// domain-scoped, on-demand counterpart to the automatic issuance
+// startCertRenewalTicker already drives every 12h — useful right after a
+// domain is added, instead of waiting for the next renewal pass. {id} is a
+// domain id, so access is gated by RequireRole(role.Editor) at the route
+// level rather than an inline ownership check like IssueRecordCertificate.
+func (h *APIHandler) IssueCertificate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domainID := r.PathValue("id")
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.writeError(w, core.ErrDomainNotFound)
+		return
+	}
+
+	if h.CertManager == nil {
+		h.WriteJSONError(w, "ACME certificate manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := h.CertManager.IssueOrRenew(domain.Name); err != nil {
+		h.writeError(w, fmt.Errorf("%w: %v", core.ErrCertIssuanceFailed, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Certificate issued for " + domain.Name,
+	})
+}
+
+// GetCertificateStatus handles GET /api/domains/{id}/certificate/status,
+// reporting whether a zone-wide certificate has been issued for the domain
+// and, if so, when it was issued and when it expires.
+func (h *APIHandler) GetCertificateStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domainID := r.PathValue("id")
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.writeError(w, core.ErrDomainNotFound)
+		return
+	}
+
+	if h.CertManager == nil {
+		h.WriteJSONError(w, "ACME certificate manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	issuedAt, expiresAt, found, err := h.CertManager.CertInfo(domain.Name)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to load certificate status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domain":     domain.Name,
+		"issued":     found,
+		"issued_at":  issuedAt,
+		"expires_at": expiresAt,
+	})
+}
+
+// RevokeCertificate handles POST /api/domains/{id}/certificate/revoke,
+// revoking the domain's currently-issued certificate at the CA and
+// dropping it from storage and the in-memory cache.
+func (h *APIHandler) RevokeCertificate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domainID := r.PathValue("id")
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.writeError(w, core.ErrDomainNotFound)
+		return
+	}
+
+	if h.CertManager == nil {
+		h.WriteJSONError(w, "ACME certificate manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := h.CertManager.Revoke(domain.Name); err != nil {
+		h.writeError(w, fmt.Errorf("%w: %v", core.ErrCertIssuanceFailed, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Certificate revoked for " + domain.Name,
+	})
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/pkg/response"
+)
+
+// CertStatus reports what internal/acme knows about a domain's certificate:
+// whether one has been issued, and when it was issued/expires. Requires the
+// same ownership check as VerifyDomain since expiry timing can leak whether
+// a domain is actively maintained.
+func (h *APIHandler) CertStatus(w http.ResponseWriter, r *http.Request) {
+	domainID := r.URL.Query().Get("id")
+	if domainID == "" {
+		h.WriteJSONError(w, "Missing domain id", http.StatusBadRequest)
+		return
+	}
+
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.WriteJSONError(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	if domain.UserID != userID {
+		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	if h.CertManager == nil {
+		h.WriteJSONError(w, "ACME subsystem is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	issuedAt, expiresAt, found, err := h.CertManager.CertInfo(domain.Name)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to look up certificate", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		response.JSON(w, map[string]interface{}{
+			"domain": domain.Name,
+			"status": "not_issued",
+			"mode":   domain.EffectiveTLSMode(),
+		}, http.StatusOK)
+		return
+	}
+
+	response.JSON(w, map[string]interface{}{
+		"domain":     domain.Name,
+		"status":     "issued",
+		"mode":       domain.EffectiveTLSMode(),
+		"issued_at":  issuedAt,
+		"expires_at": expiresAt,
+	}, http.StatusOK)
+}
+
+// ForceRenewCert obtains a fresh certificate for the domain immediately,
+// bypassing the 12h renewal ticker's expiry window — useful right after
+// switching TLSMode to "acme" or after rotating DNS providers.
+func (h *APIHandler) ForceRenewCert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domainID := r.URL.Query().Get("id")
+	if domainID == "" {
+		h.WriteJSONError(w, "Missing domain id", http.StatusBadRequest)
+		return
+	}
+
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.WriteJSONError(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	if domain.UserID != userID {
+		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	if h.CertManager == nil {
+		h.WriteJSONError(w, "ACME subsystem is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	if domain.EffectiveTLSMode() != "acme" {
+		h.WriteJSONError(w, "Domain's TLSMode is not \"acme\"", http.StatusConflict)
+		return
+	}
+
+	if err := h.CertManager.IssueOrRenew(domain.Name); err != nil {
+		h.WriteJSONError(w, "Renewal failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	response.JSON(w, map[string]interface{}{
+		"domain": domain.Name,
+		"status": "renewed",
+	}, http.StatusOK)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/metrics"
+)
+
+// rulesSnapshot is the immutable routing table ReloadRules builds on every
+// change. Readers (the WAF hot path) get a consistent view of all three
+// maps via a single atomic pointer swap instead of an RWMutex.
+type rulesSnapshot struct {
+	domainRules    map[string][]detector.WAFRule
+	domainMap      map[string]detector.Domain
+	globalFallback []detector.WAFRule
+	fingerprint    string
+
+	// originPools is dns_records' A/AAAA rows grouped by host, the same
+	// lookup internal/origin.Director used to make with its own
+	// GetOriginPool round trip to Mongo on every proxied request. It's
+	// rebuilt from the same dnsRecords slice ReloadRules already fetches
+	// for domainRules/domainMap, so caching it here is free.
+	originPools map[string][]database.DNSRecord
+
+	// version is a monotonically increasing counter bumped on every
+	// published snapshot, so WAFHandler can log which version evaluated a
+	// request — handy for "why did this rule fire" tickets when a change
+	// landed on one node via WatchRuleChanges while another was mid-request.
+	version uint64
+}
+
+// ErrStale is returned by DoLockedAction when the fingerprint passed in no
+// longer matches the live config, meaning something else changed it first.
+var ErrStale = errors.New("config: fingerprint is stale, reload and retry")
+
+// RulesConfig is a lock-free-for-readers, compare-and-swap-for-writers cache
+// of rules/policies/domains/dns_records. ReloadRules recomputes the whole
+// thing but only publishes a new snapshot (and touches the write mutex) if
+// the fingerprint actually changed, so a reload triggered by an unrelated
+// write is nearly free.
+type RulesConfig struct {
+	current atomic.Value // *rulesSnapshot
+
+	// writeMu serializes ReloadRules swaps and DoLockedAction callbacks so
+	// a compare-and-swap against the fingerprint can't race itself.
+	writeMu sync.Mutex
+}
+
+// NewRulesConfig returns an empty config with a zero-value (but non-nil)
+// snapshot, so readers never have to nil-check before the first ReloadRules.
+func NewRulesConfig() *RulesConfig {
+	c := &RulesConfig{}
+	c.current.Store(&rulesSnapshot{
+		domainRules: make(map[string][]detector.WAFRule),
+		domainMap:   make(map[string]detector.Domain),
+	})
+	return c
+}
+
+// Snapshot returns the current routing table. Safe for concurrent use
+// without locking; the returned value never mutates in place.
+func (c *RulesConfig) Snapshot() *rulesSnapshot {
+	return c.current.Load().(*rulesSnapshot)
+}
+
+// Fingerprint returns the stable hash of the config that produced the
+// current snapshot, for admin tooling to detect staleness.
+func (c *RulesConfig) Fingerprint() string {
+	return c.Snapshot().fingerprint
+}
+
+// Version returns the monotonically increasing counter of the live
+// snapshot, bumped on every actual reload (not on no-op fingerprint
+// matches).
+func (c *RulesConfig) Version() uint64 {
+	return c.Snapshot().version
+}
+
+// OriginPool returns the cached A/AAAA records for host, so
+// internal/origin.Director can pick an upstream without a per-request
+// Mongo round trip. The bool reports whether host has any pooled records
+// at all (as opposed to an empty pool), matching map-lookup comma-ok
+// semantics.
+func (c *RulesConfig) OriginPool(host string) ([]database.DNSRecord, bool) {
+	pool, ok := c.Snapshot().originPools[host]
+	metrics.ObserveCacheLookup("origin_pool", ok)
+	return pool, ok
+}
+
+// swapIfChanged installs next as the live snapshot unless its fingerprint
+// already matches what's live, in which case it's a no-op (returns false).
+func (c *RulesConfig) swapIfChanged(next *rulesSnapshot) bool {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.Fingerprint() == next.fingerprint {
+		return false
+	}
+	next.version = c.Snapshot().version + 1
+	c.current.Store(next)
+	return true
+}
+
+// DoLockedAction runs cb only if fingerprint still matches the live config,
+// serialized against other callers and against ReloadRules. This gives
+// external controllers (e.g. an admin UI doing a bulk enable/disable of
+// policies) a compare-and-swap primitive: read the fingerprint, prepare the
+// writes, then call DoLockedAction with that fingerprint so a concurrent
+// reload doesn't silently clobber assumptions the caller made.
+func (c *RulesConfig) DoLockedAction(fingerprint string, cb func(*rulesSnapshot) error) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.Fingerprint() != fingerprint {
+		return ErrStale
+	}
+	return cb(c.Snapshot())
+}
+
+// computeFingerprint hashes a stable (sorted) representation of everything
+// that feeds ReloadRules, so two identical configs always hash the same
+// regardless of Mongo's natural document order.
+func computeFingerprint(rules []detector.WAFRule, policies []detector.RulePolicy, domains []detector.Domain, dnsRecords []database.DNSRecord) string {
+	sortedRules := append([]detector.WAFRule(nil), rules...)
+	sort.Slice(sortedRules, func(i, j int) bool { return sortedRules[i].ID < sortedRules[j].ID })
+
+	sortedPolicies := append([]detector.RulePolicy(nil), policies...)
+	sort.Slice(sortedPolicies, func(i, j int) bool { return sortedPolicies[i].ID < sortedPolicies[j].ID })
+
+	sortedDomains := append([]detector.Domain(nil), domains...)
+	sort.Slice(sortedDomains, func(i, j int) bool { return sortedDomains[i].ID < sortedDomains[j].ID })
+
+	sortedRecords := append([]database.DNSRecord(nil), dnsRecords...)
+	sort.Slice(sortedRecords, func(i, j int) bool { return sortedRecords[i].ID < sortedRecords[j].ID })
+
+	h := sha256.New()
+	for _, r := range sortedRules {
+		fmt.Fprintf(h, "rule:%s:%s:%d:%v;", r.ID, r.OwnerID, r.OnMatch.ScoreAdd, r.OnMatch.HardBlock)
+	}
+	for _, p := range sortedPolicies {
+		fmt.Fprintf(h, "policy:%s:%s:%s:%v;", p.ID, p.RuleID, p.DomainID, p.Enabled)
+	}
+	for _, d := range sortedDomains {
+		fmt.Fprintf(h, "domain:%s:%s:%s;", d.ID, d.Name, d.Status)
+	}
+	for _, r := range sortedRecords {
+		fmt.Fprintf(h, "record:%s:%s:%s:%s;", r.ID, r.DomainID, r.Name, r.Type)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
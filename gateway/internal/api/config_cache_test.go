@@ -0,0 +1,115 @@
+package api
+
+import (
+	"testing"
+
+	"web-app-firewall-ml-detection/internal/detector"
+)
+
+// TestRulesConfigSnapshotNeverNilBeforeReload verifies NewRulesConfig's
+// promise that readers never need to nil-check before the first
+// ReloadRules: Snapshot, Fingerprint, and Version must all work against a
+// freshly constructed config.
+func TestRulesConfigSnapshotNeverNilBeforeReload(t *testing.T) {
+	c := NewRulesConfig()
+
+	if c.Snapshot() == nil {
+		t.Fatal("Snapshot() should never be nil, even before the first ReloadRules")
+	}
+	if c.Fingerprint() != "" {
+		t.Errorf("Fingerprint() = %q, want empty before the first ReloadRules", c.Fingerprint())
+	}
+	if c.Version() != 0 {
+		t.Errorf("Version() = %d, want 0 before the first ReloadRules", c.Version())
+	}
+}
+
+// TestSwapIfChangedSkipsIdenticalFingerprint verifies a snapshot whose
+// fingerprint matches the live one is a no-op — the point of computing the
+// fingerprint at all is to make a reload triggered by an unrelated write
+// nearly free.
+func TestSwapIfChangedSkipsIdenticalFingerprint(t *testing.T) {
+	c := NewRulesConfig()
+
+	first := &rulesSnapshot{fingerprint: "same"}
+	if !c.swapIfChanged(first) {
+		t.Fatal("first swap with a new fingerprint should publish")
+	}
+	if c.Version() != 1 {
+		t.Fatalf("Version() = %d, want 1 after the first real swap", c.Version())
+	}
+
+	second := &rulesSnapshot{fingerprint: "same"}
+	if c.swapIfChanged(second) {
+		t.Error("swapIfChanged with an unchanged fingerprint should be a no-op")
+	}
+	if c.Version() != 1 {
+		t.Errorf("Version() = %d, want unchanged at 1 after a no-op swap", c.Version())
+	}
+
+	third := &rulesSnapshot{fingerprint: "different"}
+	if !c.swapIfChanged(third) {
+		t.Fatal("swap with a changed fingerprint should publish")
+	}
+	if c.Version() != 2 {
+		t.Errorf("Version() = %d, want 2 after the second real swap", c.Version())
+	}
+}
+
+// TestDoLockedActionRejectsStaleFingerprint verifies the compare-and-swap
+// contract DoLockedAction gives external controllers: a caller's fingerprint
+// must still match the live config or the callback never runs.
+func TestDoLockedActionRejectsStaleFingerprint(t *testing.T) {
+	c := NewRulesConfig()
+	c.swapIfChanged(&rulesSnapshot{fingerprint: "v1"})
+
+	called := false
+	err := c.DoLockedAction("not-v1", func(*rulesSnapshot) error {
+		called = true
+		return nil
+	})
+	if err != ErrStale {
+		t.Errorf("err = %v, want ErrStale", err)
+	}
+	if called {
+		t.Error("callback should not run against a stale fingerprint")
+	}
+
+	called = false
+	err = c.DoLockedAction("v1", func(*rulesSnapshot) error {
+		called = true
+		return nil
+	})
+	if err != nil {
+		t.Errorf("err = %v, want nil for a matching fingerprint", err)
+	}
+	if !called {
+		t.Error("callback should run when the fingerprint matches")
+	}
+}
+
+// TestComputeFingerprintIsOrderIndependent verifies two inputs differing
+// only in slice order hash identically, since computeFingerprint sorts
+// before hashing specifically so Mongo's natural document order can't
+// cause spurious reloads.
+func TestComputeFingerprintIsOrderIndependent(t *testing.T) {
+	a := []detector.WAFRule{{ID: "1"}, {ID: "2"}}
+	b := []detector.WAFRule{{ID: "2"}, {ID: "1"}}
+
+	fpA := computeFingerprint(a, nil, nil, nil)
+	fpB := computeFingerprint(b, nil, nil, nil)
+	if fpA != fpB {
+		t.Errorf("fingerprints differ by input order: %q != %q", fpA, fpB)
+	}
+}
+
+// TestComputeFingerprintChangesWithContent verifies an actual content
+// change (not just reordering) produces a different fingerprint.
+func TestComputeFingerprintChangesWithContent(t *testing.T) {
+	a := []detector.WAFRule{{ID: "1", OwnerID: "alice"}}
+	b := []detector.WAFRule{{ID: "1", OwnerID: "bob"}}
+
+	if computeFingerprint(a, nil, nil, nil) == computeFingerprint(b, nil, nil, nil) {
+		t.Error("fingerprints should differ when rule content differs")
+	}
+}
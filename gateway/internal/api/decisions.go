@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"web-app-firewall-ml-detection/pkg/response"
+)
+
+// ListDecisions returns every active threat-feed decision (CrowdSec-pulled
+// or manually added). 404 if Decisions isn't enabled — there's nothing to
+// list.
+func (h *APIHandler) ListDecisions(w http.ResponseWriter, r *http.Request) {
+	if h.Decisions == nil {
+		h.WriteJSONError(w, "Threat feed is not enabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	response.JSON(w, h.Decisions.List(), http.StatusOK)
+}
+
+// addDecisionRequest is the body AddDecision expects.
+type addDecisionRequest struct {
+	Value      string `json:"value"` // IPv4 address or CIDR
+	Reason     string `json:"reason"`
+	TTLSeconds int    `json:"ttl_seconds"` // 0 means "never expires"
+}
+
+// AddDecision manually bans an IP/CIDR, independent of whatever CrowdSec
+// itself has decided — e.g. to block an attacker immediately while the
+// LAPI feed hasn't caught up yet.
+func (h *APIHandler) AddDecision(w http.ResponseWriter, r *http.Request) {
+	if h.Decisions == nil {
+		h.WriteJSONError(w, "Threat feed is not enabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req addDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.WriteJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Value == "" {
+		h.WriteJSONError(w, "value is required", http.StatusBadRequest)
+		return
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if err := h.Decisions.AddManual(req.Value, req.Reason, ttl); err != nil {
+		h.WriteJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response.JSON(w, map[string]string{"status": "added"}, http.StatusOK)
+}
+
+// ExpireDecision removes a manually-added or CrowdSec-pulled ban early.
+func (h *APIHandler) ExpireDecision(w http.ResponseWriter, r *http.Request) {
+	if h.Decisions == nil {
+		h.WriteJSONError(w, "Threat feed is not enabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	value := r.URL.Query().Get("value")
+	if value == "" {
+		h.WriteJSONError(w, "value is required", http.StatusBadRequest)
+		return
+	}
+
+	h.Decisions.Expire(value)
+	response.JSON(w, map[string]string{"status": "expired"}, http.StatusOK)
+}
@@ -4,12 +4,16 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"os"
 	"regexp"
 	"strings"
 
+	"web-app-firewall-ml-detection/internal/audit"
 	"web-app-firewall-ml-detection/internal/database"
 )
 
@@ -26,6 +30,87 @@ type DNSRecordRequest struct {
 	Proxied  bool   `json:"proxied"` // TRUE = Through WAF, FALSE = Direct
 }
 
+// validateRecordContent applies the same per-type format rules (Rule 2)
+// addRecord always has, returning content normalized (trailing-dot stripped
+// for CNAME/MX/NS) for the caller to store. Shared with ImportZone so a
+// bulk-imported record can't slip past a rule a single addRecord call would
+// have rejected.
+func validateRecordContent(rType, content string) (string, error) {
+	switch rType {
+	case "A":
+		ip := net.ParseIP(content)
+		if ip == nil || ip.To4() == nil {
+			return content, errors.New("content must be a valid IPv4 address")
+		}
+	case "AAAA":
+		ip := net.ParseIP(content)
+		if ip == nil || ip.To4() != nil {
+			return content, errors.New("content must be a valid IPv6 address")
+		}
+	case "CNAME":
+		content = strings.TrimSuffix(content, ".")
+		if net.ParseIP(content) != nil {
+			return content, errors.New("CNAME content must be a domain name, not an IP address")
+		}
+		if !domainRegex.MatchString(content) {
+			return content, errors.New("invalid domain format in CNAME content")
+		}
+	case "MX", "NS":
+		content = strings.TrimSuffix(content, ".")
+		if !domainRegex.MatchString(content) {
+			return content, errors.New("invalid domain format")
+		}
+	case "TXT":
+		if len(content) > 2048 {
+			return content, errors.New("TXT record too long")
+		}
+	}
+	return content, nil
+}
+
+// errDNSDatabaseLookup wraps a lookup failure inside checkRecordConflicts so
+// callers can tell "the DB call itself failed" (500) apart from "it
+// succeeded and found a real conflict" (409/400).
+var errDNSDatabaseLookup = errors.New("database error checking conflicts")
+
+// checkRecordConflicts enforces CNAME exclusivity and same-type duplicate
+// rules (Rules 1.1 & 1.2) against records already stored for domainID.
+// Shared by addRecord and ImportZone so a bulk import can't create a zone a
+// single addRecord call would have refused.
+func (h *APIHandler) checkRecordConflicts(domainID, name, rType, content string) error {
+	conflictTypes := []string{"A", "AAAA", "CNAME", "MX", "TXT", "NS"}
+
+	if rType == "CNAME" {
+		for _, t := range conflictTypes {
+			exists, err := database.CheckDNSRecordExists(h.MongoClient, domainID, name, t)
+			if err != nil {
+				return fmt.Errorf("%w: %v", errDNSDatabaseLookup, err)
+			}
+			if exists {
+				return errors.New("CNAME record cannot coexist with other records (including other CNAMEs)")
+			}
+		}
+		return nil
+	}
+
+	exists, err := database.CheckDNSRecordExists(h.MongoClient, domainID, name, "CNAME")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errDNSDatabaseLookup, err)
+	}
+	if exists {
+		return errors.New("cannot add record: a CNAME record already exists for this hostname")
+	}
+
+	exists, err = database.CheckDuplicateDNSRecord(h.MongoClient, domainID, name, rType, content)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errDNSDatabaseLookup, err)
+	}
+	if exists {
+		return errors.New("duplicate record already exists")
+	}
+	return nil
+}
+
 // ManageRecords handles GET, POST, PUT, DELETE for DNS records
 func (h *APIHandler) ManageRecords(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -70,50 +155,15 @@ func (h *APIHandler) addRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 3. STRICT CONTENT VALIDATION (Rule 2)
-	switch req.Type {
-	case "A":
-		// Rule 2.1: MUST be valid IPv4, MUST NOT be IPv6 or hostname
-		ip := net.ParseIP(req.Content)
-		if ip == nil || ip.To4() == nil {
-			h.WriteJSONError(w, "Content must be a valid IPv4 address", http.StatusBadRequest)
-			return
-		}
-	case "AAAA":
-		// Rule 2.2: MUST be valid IPv6, MUST NOT be IPv4
-		ip := net.ParseIP(req.Content)
-		if ip == nil || ip.To4() != nil {
-			h.WriteJSONError(w, "Content must be a valid IPv6 address", http.StatusBadRequest)
-			return
-		}
-	case "CNAME":
-		// Rule 2.3: MUST be FQDN, MUST NOT be IP
-		req.Content = strings.TrimSuffix(req.Content, ".") // Normalize
-
-		if net.ParseIP(req.Content) != nil {
-			h.WriteJSONError(w, "CNAME content must be a domain name, not an IP address", http.StatusBadRequest)
-			return
-		}
-		if !domainRegex.MatchString(req.Content) {
-			h.WriteJSONError(w, "Invalid domain format in CNAME content", http.StatusBadRequest)
-			return
-		}
-	case "MX", "NS":
-		req.Content = strings.TrimSuffix(req.Content, ".")
-		if !domainRegex.MatchString(req.Content) {
-			h.WriteJSONError(w, "Invalid domain format", http.StatusBadRequest)
-			return
-		}
-	case "TXT":
-		if len(req.Content) > 2048 {
-			h.WriteJSONError(w, "TXT record too long", http.StatusBadRequest)
-			return
-		}
-	default:
-		// Optional: Block unknown types
-		// h.WriteJSONError(w, "Unsupported record type", http.StatusBadRequest)
-		// return
+	// 3. STRICT CONTENT VALIDATION (Rule 2) — shared with ImportZone so a
+	// bulk-imported record can't skip a rule a single addRecord call would
+	// have rejected.
+	normalizedContent, err := validateRecordContent(req.Type, req.Content)
+	if err != nil {
+		h.WriteJSONError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	req.Content = normalizedContent
 
 	// 4. Fetch the domain to verify ownership
 	domain, err := database.GetDomainByID(h.MongoClient, req.DomainID)
@@ -124,7 +174,10 @@ func (h *APIHandler) addRecord(w http.ResponseWriter, r *http.Request) {
 
 	// 5. Security: Ensure the user owns this domain
 	userID := r.Context().Value("user_id").(string)
-	if domain.UserID != userID {
+	// [UPDATED] Ownership is no longer the only path to access: any
+	// domain_members role satisfies this check (RequireRole already
+	// enforced the write-level minimum before we got here).
+	if _, err := database.GetMemberRole(h.MongoClient, domain.ID, userID); err != nil {
 		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
@@ -168,56 +221,15 @@ func (h *APIHandler) addRecord(w http.ResponseWriter, r *http.Request) {
 		wafIP = "139.59.76.127"
 	}
 
-	// 10. Check for Conflicts & Duplicates (Rules 1.1 & 1.2)
-
-	// List of types to check against for exclusivity
-	conflictTypes := []string{"A", "AAAA", "CNAME", "MX", "TXT", "NS"}
-
-	// Rule 1.2: CNAME Exclusivity
-	// A hostname that has a CNAME record MUST NOT have any other record type.
-	// A hostname that has other records MUST NOT have a CNAME.
-
-	if req.Type == "CNAME" {
-		// Check if *any* record exists for this name (including existing CNAMEs)
-		for _, t := range conflictTypes {
-			exists, err := database.CheckDNSRecordExists(h.MongoClient, req.DomainID, recordName, t)
-			if err != nil {
-				h.WriteJSONError(w, "Database error checking conflicts", http.StatusInternalServerError)
-				return
-			}
-			if exists {
-				// If checking CNAME against CNAME, it's a duplicate (Rule 1.1)
-				// If checking CNAME against A, it's a coexistence error (Rule 1.2)
-				h.WriteJSONError(w, "CNAME record cannot coexist with other records (including other CNAMEs)", http.StatusConflict)
-				return
-			}
-		}
-	} else {
-		// Adding Non-CNAME (A, AAAA, MX, etc.)
-		// Check if a CNAME already exists
-		exists, err := database.CheckDNSRecordExists(h.MongoClient, req.DomainID, recordName, "CNAME")
-		if err != nil {
-			h.WriteJSONError(w, "Database error checking conflicts", http.StatusInternalServerError)
-			return
-		}
-		if exists {
-			h.WriteJSONError(w, "Cannot add record: A CNAME record already exists for this hostname", http.StatusConflict)
-			return
-		}
-
-		// Rule 1.1: Hostname Uniqueness within Record Type
-		// "A hostname MUST NOT have more than one A record with the same value."
-		// We allow multiple A records (Round Robin) as long as Content (IP) is different.
-		// We use CheckDuplicateDNSRecord which checks (Name + Type + Content).
-		exists, err = database.CheckDuplicateDNSRecord(h.MongoClient, req.DomainID, recordName, req.Type, req.Content)
-		if err != nil {
-			h.WriteJSONError(w, "Database error checking duplicates", http.StatusInternalServerError)
-			return
-		}
-		if exists {
-			h.WriteJSONError(w, "Duplicate record already exists", http.StatusConflict)
-			return
+	// 10. Check for Conflicts & Duplicates (Rules 1.1 & 1.2) — shared with
+	// ImportZone, see checkRecordConflicts.
+	if err := h.checkRecordConflicts(req.DomainID, recordName, req.Type, req.Content); err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, errDNSDatabaseLookup) {
+			status = http.StatusInternalServerError
 		}
+		h.WriteJSONError(w, err.Error(), status)
+		return
 	}
 
 	// 11. Add to MongoDB (Source of Truth)
@@ -228,22 +240,61 @@ func (h *APIHandler) addRecord(w http.ResponseWriter, r *http.Request) {
 		Content:  req.Content,
 		TTL:      req.TTL,
 		Proxied:  req.Proxied,
+		UserID:   userID,
 	}
 
 	recordID, err := database.CreateDNSRecord(h.MongoClient, newRecord)
+	if errors.Is(err, database.ErrRecordQuotaExceeded) {
+		h.WriteJSONError(w, fmt.Sprintf("Record quota exceeded: this account may own at most %d DNS records", database.GetUserRecordQuota(h.MongoClient, userID)), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, database.ErrDomainRecordQuotaExceeded) {
+		h.WriteJSONError(w, fmt.Sprintf("Domain quota exceeded: this domain may hold at most %d DNS records", database.MaxRecordsPerDomain), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		h.WriteJSONError(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	newRecord.ID = recordID
 
-	// 12. Add to PowerDNS (Resolution Backend)
-	err = database.AddPowerDNSRecord(recordName, req.Type, req.Content, req.Proxied, wafIP)
-	if err != nil {
+	// 12. Publish to the authoritative DNS backend (PowerDNS by default, or
+	// whatever Provider this gateway is wired with — see internal/dnsbackend).
+	externalID, provErr := h.DNSProvider.UpsertRecord(newRecord, wafIP, req.Proxied)
+	if externalID != "" {
+		newRecord.ExternalID = externalID
+	}
+
+	// The Mongo write already succeeded, so this gets logged either way —
+	// Result carries whatever happened next, since propagation failing here
+	// doesn't roll back the record we just created.
+	auditResult := audit.ResultSuccess
+	if provErr != nil {
+		auditResult = audit.ResultPropagationFailed
+	}
+	if err := audit.Log(h.MongoClient, audit.Entry{
+		UserID:    userID,
+		DomainID:  domain.ID,
+		RecordID:  recordID,
+		Action:    audit.ActionCreate,
+		After:     &newRecord,
+		RequestIP: h.clientIP(r),
+		UserAgent: r.UserAgent(),
+		Result:    auditResult,
+	}); err != nil {
+		log.Printf("Failed to write audit log entry for record %s: %v", recordID, err)
+	}
+
+	if provErr != nil {
 		// Log error but keep mongo record so user can try deleting/re-adding
-		h.WriteJSONError(w, "DNS Propagation Error: "+err.Error(), http.StatusInternalServerError)
+		h.WriteJSONError(w, "DNS Propagation Error: "+provErr.Error(), http.StatusInternalServerError)
 		return
 	}
+	if externalID != "" {
+		if err := database.SetDNSRecordExternalID(h.MongoClient, recordID, externalID); err != nil {
+			log.Printf("Failed to persist external id for record %s: %v", recordID, err)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -284,7 +335,10 @@ func (h *APIHandler) updateRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	userID := r.Context().Value("user_id").(string)
-	if domain.UserID != userID {
+	// [UPDATED] Ownership is no longer the only path to access: any
+	// domain_members role satisfies this check (RequireRole already
+	// enforced the write-level minimum before we got here).
+	if _, err := database.GetMemberRole(h.MongoClient, domain.ID, userID); err != nil {
 		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
@@ -293,13 +347,33 @@ func (h *APIHandler) updateRecord(w http.ResponseWriter, r *http.Request) {
 	// BRANCH 1: Origin SSL Update
 	// ---------------------------------------------------------
 	if req.Action == "toggle_origin_ssl" {
+		// Best-effort snapshot for the audit entry below — a failure here
+		// isn't fatal to the update itself, it just means Before is empty.
+		beforeRecord, _ := database.GetDNSRecordByID(h.MongoClient, recordID)
+
 		// Call the DB function to update just the SSL flag
-		err := database.UpdateDNSRecordOriginSSL(h.MongoClient, recordID, req.OriginSSL)
+		err := database.UpdateDNSRecordOriginSSL(h.MongoClient, userID, recordID, req.OriginSSL)
+		if errors.Is(err, database.ErrRecordForbidden) {
+			h.WriteJSONError(w, "Forbidden: you do not own this record", http.StatusForbidden)
+			return
+		}
 		if err != nil {
 			h.WriteJSONError(w, "Failed to update Origin SSL: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		if beforeRecord != nil {
+			afterRecord := *beforeRecord
+			afterRecord.OriginSSL = req.OriginSSL
+			if err := audit.Log(h.MongoClient, audit.Entry{
+				UserID: userID, DomainID: domain.ID, RecordID: recordID, Action: audit.ActionUpdate,
+				Before: beforeRecord, After: &afterRecord,
+				RequestIP: h.clientIP(r), UserAgent: r.UserAgent(), Result: audit.ResultSuccess,
+			}); err != nil {
+				log.Printf("Failed to write audit log entry for record %s: %v", recordID, err)
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status":     "success",
@@ -341,26 +415,49 @@ func (h *APIHandler) updateRecord(w http.ResponseWriter, r *http.Request) {
 		typeToDelete = "A"
 	}
 
-	// C. Delete OLD entry from PowerDNS
-	err = database.DeletePowerDNSRecordByContent(oldRecord.Name, typeToDelete, contentToDelete)
-	if err != nil {
+	// C. Remove OLD entry from the DNS backend
+	if err := h.DNSProvider.DeleteRecord(oldRecord.ExternalID, oldRecord.Name, typeToDelete, contentToDelete); err != nil {
 		h.WriteJSONError(w, "Failed to update DNS (Delete Phase): "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// D. Update MongoDB to NEW state
-	err = database.UpdateDNSRecordProxy(h.MongoClient, recordID, req.Proxied)
+	err = database.UpdateDNSRecordProxy(h.MongoClient, userID, recordID, req.Proxied)
+	if errors.Is(err, database.ErrRecordForbidden) {
+		h.WriteJSONError(w, "Forbidden: you do not own this record", http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		h.WriteJSONError(w, "Failed to update database", http.StatusInternalServerError)
 		return
 	}
 
-	// E. Add NEW entry to PowerDNS
-	err = database.AddPowerDNSRecord(oldRecord.Name, oldRecord.Type, oldRecord.Content, req.Proxied, wafIP)
-	if err != nil {
-		h.WriteJSONError(w, "Failed to update DNS (Add Phase): "+err.Error(), http.StatusInternalServerError)
+	// E. Publish NEW entry to the DNS backend
+	externalID, provErr := h.DNSProvider.UpsertRecord(*oldRecord, wafIP, req.Proxied)
+
+	// The Mongo write (D) already succeeded, so this is logged either way —
+	// see the identical reasoning in addRecord.
+	afterRecord := *oldRecord
+	afterRecord.Proxied = req.Proxied
+	auditResult := audit.ResultSuccess
+	if provErr != nil {
+		auditResult = audit.ResultPropagationFailed
+	}
+	if err := audit.Log(h.MongoClient, audit.Entry{
+		UserID: userID, DomainID: domain.ID, RecordID: recordID, Action: audit.ActionUpdate,
+		Before: oldRecord, After: &afterRecord,
+		RequestIP: h.clientIP(r), UserAgent: r.UserAgent(), Result: auditResult,
+	}); err != nil {
+		log.Printf("Failed to write audit log entry for record %s: %v", recordID, err)
+	}
+
+	if provErr != nil {
+		h.WriteJSONError(w, "Failed to update DNS (Add Phase): "+provErr.Error(), http.StatusInternalServerError)
 		return
 	}
+	if err := database.SetDNSRecordExternalID(h.MongoClient, recordID, externalID); err != nil {
+		log.Printf("Failed to persist external id for record %s: %v", recordID, err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -385,7 +482,10 @@ func (h *APIHandler) listRecords(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userID := r.Context().Value("user_id").(string)
-	if domain.UserID != userID {
+	// [UPDATED] Ownership is no longer the only path to access: any
+	// domain_members role satisfies this check (RequireRole already
+	// enforced the write-level minimum before we got here).
+	if _, err := database.GetMemberRole(h.MongoClient, domain.ID, userID); err != nil {
 		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
@@ -419,7 +519,10 @@ func (h *APIHandler) deleteRecord(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userID := r.Context().Value("user_id").(string)
-	if domain.UserID != userID {
+	// [UPDATED] Ownership is no longer the only path to access: any
+	// domain_members role satisfies this check (RequireRole already
+	// enforced the write-level minimum before we got here).
+	if _, err := database.GetMemberRole(h.MongoClient, domain.ID, userID); err != nil {
 		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
@@ -451,20 +554,32 @@ func (h *APIHandler) deleteRecord(w http.ResponseWriter, r *http.Request) {
 		sqlContent = wafIP
 	}
 
-	// 4. Delete from PowerDNS (MySQL)
-	err = database.DeletePowerDNSRecordByContent(record.Name, sqlType, sqlContent)
-	if err != nil {
+	// 4. Remove from the DNS backend
+	if err := h.DNSProvider.DeleteRecord(record.ExternalID, record.Name, sqlType, sqlContent); err != nil {
 		h.WriteJSONError(w, "Failed to delete from DNS backend: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 5. Delete from MongoDB
-	err = database.DeleteDNSRecord(h.MongoClient, recordID)
+	err = database.DeleteDNSRecord(h.MongoClient, userID, recordID)
+	if errors.Is(err, database.ErrRecordForbidden) {
+		h.WriteJSONError(w, "Forbidden: you do not own this record", http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		h.WriteJSONError(w, "Failed to delete record: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Backend delete (step 4) already succeeded by the time we get here, so
+	// this is always a clean success.
+	if err := audit.Log(h.MongoClient, audit.Entry{
+		UserID: userID, DomainID: domain.ID, RecordID: recordID, Action: audit.ActionDelete,
+		Before: record, RequestIP: h.clientIP(r), UserAgent: r.UserAgent(), Result: audit.ResultSuccess,
+	}); err != nil {
+		log.Printf("Failed to write audit log entry for record %s: %v", recordID, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"status":  "success",
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/audit"
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/role"
+)
+
+// DNSAudit handles GET /api/dns/audit?domain_id=...&since=...&cursor=...
+// &action=...&record_id=...&limit=..., paginating through domain_id's
+// dns_audit trail newest-first. since is RFC3339; limit defaults to
+// audit.DefaultPageSize and caps at audit.MaxPageSize. Access is gated by
+// RequireRole(role.Viewer) at the route level, unlike /api/dns/records,
+// which requires role.Editor for every method including GET.
+func (h *APIHandler) DNSAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	domainID := q.Get("domain_id")
+	if domainID == "" {
+		h.WriteJSONError(w, "domain_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var since time.Time
+	if s := q.Get("since"); s != "" {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			h.WriteJSONError(w, "since must be RFC3339", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	limit := audit.DefaultPageSize
+	if l := q.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil {
+			limit = parsed
+		}
+	}
+
+	entries, nextCursor, err := audit.List(h.MongoClient, domainID, q.Get("action"), q.Get("record_id"), q.Get("cursor"), since, limit)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries":     entries,
+		"next_cursor": nextCursor,
+	})
+}
+
+// RevertRecord handles POST /api/dns/records/{id}/revert?audit_id=xxx: it
+// looks up the dns_audit entry audit_id, confirms it's for this record, and
+// replays its inverse — recreate for a logged delete, restore the old
+// proxied/origin_ssl flags for a logged update, delete for a logged create
+// — through the same validateRecordContent/checkRecordConflicts pipeline
+// and database/DNSProvider calls addRecord, updateRecord, and deleteRecord
+// use, so a revert can't write back something that's no longer valid (e.g.
+// the hostname picked up a conflicting record since the original change).
+// Like IssueRecordCertificate, {id} here is a record id, not a domain id,
+// so this isn't wrapped in RequireRole at the route level — ownership is
+// checked inline against the record's actual domain.
+func (h *APIHandler) RevertRecord(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	recordID := r.PathValue("id")
+	auditID := r.URL.Query().Get("audit_id")
+	if recordID == "" || auditID == "" {
+		h.WriteJSONError(w, "record id and audit_id are required", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := audit.Get(h.MongoClient, auditID)
+	if err != nil {
+		h.WriteJSONError(w, "Audit entry not found", http.StatusNotFound)
+		return
+	}
+	if entry.RecordID != recordID {
+		h.WriteJSONError(w, "audit_id does not belong to this record", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	userRole, err := database.GetMemberRole(h.MongoClient, entry.DomainID, userID)
+	if err != nil || !role.Satisfies(userRole, role.Editor) {
+		h.WriteJSONError(w, "Forbidden: insufficient role on this domain", http.StatusForbidden)
+		return
+	}
+
+	var result string
+	switch entry.Action {
+	case audit.ActionCreate:
+		result, err = h.revertCreate(entry)
+	case audit.ActionDelete:
+		result, err = h.revertDelete(entry)
+	case audit.ActionUpdate:
+		result, err = h.revertUpdate(entry)
+	default:
+		err = errUnrevertibleAction
+	}
+	if err != nil {
+		h.WriteJSONError(w, "Revert failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if logErr := audit.Log(h.MongoClient, audit.Entry{
+		UserID: userID, DomainID: entry.DomainID, RecordID: recordID, Action: audit.ActionRevert,
+		Before: entry.After, After: entry.Before,
+		RequestIP: h.clientIP(r), UserAgent: r.UserAgent(), Result: audit.ResultSuccess,
+	}); logErr != nil {
+		log.Printf("Failed to write audit log entry for revert of %s: %v", recordID, logErr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": result,
+	})
+}
+
+var errUnrevertibleAction = errUnrevertible("audit entry's action cannot be reverted")
+
+type errUnrevertible string
+
+func (e errUnrevertible) Error() string { return string(e) }
+
+// revertCreate undoes a logged create by deleting the record it produced —
+// the same DNS-backend-then-Mongo order deleteRecord uses.
+func (h *APIHandler) revertCreate(entry *audit.Entry) (string, error) {
+	if entry.After == nil {
+		return "", errUnrevertible("audit entry has no after-state to delete")
+	}
+	record := entry.After
+
+	sqlType, sqlContent := record.Type, record.Content
+	if record.Proxied && isProxiableType(record.Type) {
+		sqlType = "A"
+		sqlContent = h.WafPublicIP
+	}
+	if err := h.DNSProvider.DeleteRecord(record.ExternalID, record.Name, sqlType, sqlContent); err != nil {
+		return "", err
+	}
+	if err := database.DeleteDNSRecord(h.MongoClient, record.UserID, record.ID); err != nil {
+		return "", err
+	}
+	return "record " + record.Name + " deleted", nil
+}
+
+// revertDelete undoes a logged delete by recreating the record from its
+// before-state, running it through the same validation and conflict checks
+// a fresh addRecord call would.
+func (h *APIHandler) revertDelete(entry *audit.Entry) (string, error) {
+	if entry.Before == nil {
+		return "", errUnrevertible("audit entry has no before-state to restore")
+	}
+	record := *entry.Before
+
+	normalized, err := validateRecordContent(record.Type, record.Content)
+	if err != nil {
+		return "", err
+	}
+	record.Content = normalized
+
+	if err := h.checkRecordConflicts(record.DomainID, record.Name, record.Type, record.Content); err != nil {
+		return "", err
+	}
+
+	record.ID = ""
+	newID, err := database.CreateDNSRecord(h.MongoClient, record)
+	if err != nil {
+		return "", err
+	}
+	record.ID = newID
+
+	externalID, err := h.DNSProvider.UpsertRecord(record, h.WafPublicIP, record.Proxied)
+	if err != nil {
+		return "", err
+	}
+	if externalID != "" {
+		if err := database.SetDNSRecordExternalID(h.MongoClient, newID, externalID); err != nil {
+			log.Printf("Failed to persist external id for reverted record %s: %v", newID, err)
+		}
+	}
+	return "record " + record.Name + " restored", nil
+}
+
+// revertUpdate undoes a logged update by restoring Before's proxied and
+// origin_ssl flags — the two fields updateRecord can change.
+func (h *APIHandler) revertUpdate(entry *audit.Entry) (string, error) {
+	if entry.Before == nil {
+		return "", errUnrevertible("audit entry has no before-state to restore")
+	}
+	before := entry.Before
+
+	current, err := database.GetDNSRecordByID(h.MongoClient, before.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if current.OriginSSL != before.OriginSSL {
+		if err := database.UpdateDNSRecordOriginSSL(h.MongoClient, before.UserID, before.ID, before.OriginSSL); err != nil {
+			return "", err
+		}
+	}
+
+	if current.Proxied != before.Proxied {
+		sqlType, sqlContent := current.Type, current.Content
+		if current.Proxied && isProxiableType(current.Type) {
+			sqlType = "A"
+			sqlContent = h.WafPublicIP
+		}
+		if err := h.DNSProvider.DeleteRecord(current.ExternalID, current.Name, sqlType, sqlContent); err != nil {
+			return "", err
+		}
+		if err := database.UpdateDNSRecordProxy(h.MongoClient, before.UserID, before.ID, before.Proxied); err != nil {
+			return "", err
+		}
+		externalID, err := h.DNSProvider.UpsertRecord(*before, h.WafPublicIP, before.Proxied)
+		if err != nil {
+			return "", err
+		}
+		if err := database.SetDNSRecordExternalID(h.MongoClient, before.ID, externalID); err != nil {
+			log.Printf("Failed to persist external id for reverted record %s: %v", before.ID, err)
+		}
+	}
+
+	return "record " + before.Name + " reverted", nil
+}
+
+func isProxiableType(rType string) bool {
+	return !(rType == "TXT" || rType == "MX" || rType == "NS" || rType == "SOA")
+}
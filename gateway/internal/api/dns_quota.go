@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/pkg/response"
+)
+
+// DNSQuota handles GET /api/dns/quota, reporting the authenticated user's
+// current usage against database.RecordUsage/DomainUsage (e.g. "17/65
+// records used") so the UI can show remaining headroom without the client
+// having to count records itself.
+func (h *APIHandler) DNSQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+
+	recordsUsed, recordsQuota, err := database.RecordUsage(h.MongoClient, userID)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to load record usage", http.StatusInternalServerError)
+		return
+	}
+
+	domainsUsed, domainsQuota, err := database.DomainUsage(h.MongoClient, userID)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to load domain usage", http.StatusInternalServerError)
+		return
+	}
+
+	response.JSON(w, map[string]interface{}{
+		"records": map[string]int{
+			"used":  recordsUsed,
+			"quota": recordsQuota,
+		},
+		"domains": map[string]int{
+			"used":  domainsUsed,
+			"quota": domainsQuota,
+		},
+		"max_records_per_domain": database.MaxRecordsPerDomain,
+	}, http.StatusOK)
+}
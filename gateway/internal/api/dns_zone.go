@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/models"
+	"web-app-firewall-ml-detection/internal/role"
+
+	"github.com/miekg/dns"
+)
+
+// zoneRecordDiff is one line of ImportZone's added/updated/skipped/errors
+// report, and also one element of ExportZone's format=json output — close
+// enough to RFC 8427's per-RR JSON shape (NAME/TYPE/TTL/rdata) to be read by
+// any RFC 8427-aware tool without the full message envelope.
+type zoneRecordDiff struct {
+	Name   string `json:"NAME"`
+	Type   string `json:"TYPEname"`
+	TTL    int    `json:"TTL,omitempty"`
+	RData  string `json:"rdata,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// zoneImportResult is ImportZone's response body, for both a real import and
+// a dry_run=true preview.
+type zoneImportResult struct {
+	Added   []zoneRecordDiff `json:"added"`
+	Updated []zoneRecordDiff `json:"updated"`
+	Skipped []zoneRecordDiff `json:"skipped"`
+	Errors  []string         `json:"errors"`
+}
+
+// ImportZone handles POST /api/dns/zones/{id}/import?dry_run=true. The
+// request body is a BIND master file for the domain identified by the {id}
+// path value, parsed with miekg/dns's zone parser (the same library
+// internal/dnsserver already depends on). Every record runs through
+// validateRecordContent + checkRecordConflicts — the exact pipeline
+// addRecord uses for a single record — before anything is written.
+//
+// There's no real Mongo transaction backing this (nothing else in the
+// codebase uses mongo.Client.StartSession either): "single transaction"
+// here means validate-everything-first — if any line fails, nothing from
+// the file is written, Mongo or DNS backend. dry_run=true stops at the
+// validation/diff step and never touches either.
+func (h *APIHandler) ImportZone(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domain, ok := h.authorizeZoneAccess(w, r, role.Editor)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	result := zoneImportResult{}
+	var toCreate []database.DNSRecord
+
+	zp := dns.NewZoneParser(strings.NewReader(string(body)), dns.Fqdn(domain.Name), "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		hdr := rr.Header()
+		name := strings.TrimSuffix(hdr.Name, ".")
+		rType := dns.TypeToString[hdr.Rrtype]
+		// RR.String() is "<header> <rdata>"; trimming the header text back
+		// off is the only type-agnostic way to recover just the rdata
+		// without a type switch over every RR miekg/dns knows about.
+		content := strings.TrimSpace(strings.TrimPrefix(rr.String(), hdr.String()))
+
+		normalized, err := validateRecordContent(rType, content)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s %s: %v", name, rType, err))
+			continue
+		}
+
+		if err := h.checkRecordConflicts(domain.ID, name, rType, normalized); err != nil {
+			result.Skipped = append(result.Skipped, zoneRecordDiff{Name: name, Type: rType, TTL: int(hdr.Ttl), RData: normalized, Reason: err.Error()})
+			continue
+		}
+
+		toCreate = append(toCreate, database.DNSRecord{
+			DomainID: domain.ID,
+			Name:     name,
+			Type:     rType,
+			Content:  normalized,
+			TTL:      int(hdr.Ttl),
+		})
+		result.Added = append(result.Added, zoneRecordDiff{Name: name, Type: rType, TTL: int(hdr.Ttl), RData: normalized})
+	}
+	// zp.Err() carries the zone parser's own syntax errors (malformed RR
+	// syntax, unbalanced parens, …), reported with the line number baked
+	// into its message by the library itself.
+	if err := zp.Err(); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	if len(result.Errors) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	for i, rec := range toCreate {
+		recordID, err := database.CreateDNSRecord(h.MongoClient, rec)
+		if err != nil {
+			h.WriteJSONError(w, fmt.Sprintf("Failed to save %s %s: %v", rec.Name, rec.Type, err), http.StatusInternalServerError)
+			return
+		}
+		rec.ID = recordID
+		if _, err := h.DNSProvider.UpsertRecord(rec, h.WafPublicIP, rec.Proxied); err != nil {
+			h.WriteJSONError(w, fmt.Sprintf("DNS propagation failed for %s %s: %v", rec.Name, rec.Type, err), http.StatusInternalServerError)
+			return
+		}
+		toCreate[i] = rec
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ExportZone handles GET /api/dns/zones/{id}/export?format=bind|json,
+// dumping every record for the domain identified by the {id} path value as
+// either a BIND master file or an RFC-8427-flavored JSON array. format
+// defaults to "bind".
+func (h *APIHandler) ExportZone(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domain, ok := h.authorizeZoneAccess(w, r, role.Viewer)
+	if !ok {
+		return
+	}
+
+	records, err := database.GetDNSRecords(h.MongoClient, domain.ID)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to load records", http.StatusInternalServerError)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "json" {
+		out := make([]zoneRecordDiff, 0, len(records))
+		for _, rec := range records {
+			out = append(out, zoneRecordDiff{Name: dns.Fqdn(rec.Name), Type: rec.Type, TTL: rec.TTL, RData: rec.Content})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "$ORIGIN %s\n", dns.Fqdn(domain.Name))
+	for _, rec := range records {
+		fmt.Fprintf(&sb, "%s\t%d\tIN\t%s\t%s\n", dns.Fqdn(rec.Name), rec.TTL, rec.Type, rec.Content)
+	}
+
+	w.Header().Set("Content-Type", "text/dns")
+	w.Write([]byte(sb.String()))
+}
+
+// authorizeZoneAccess resolves the {id} path value to a domain and confirms
+// the caller holds at least minRole on it — import needs Editor (it
+// mutates records), export only needs Viewer (it just reads them).
+func (h *APIHandler) authorizeZoneAccess(w http.ResponseWriter, r *http.Request, minRole role.Role) (*models.Domain, bool) {
+	domainID := r.PathValue("id")
+	if domainID == "" {
+		h.WriteJSONError(w, "domain id is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.WriteJSONError(w, "Domain not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	userRole, err := database.GetMemberRole(h.MongoClient, domain.ID, userID)
+	if err != nil || !role.Satisfies(userRole, minRole) {
+		h.WriteJSONError(w, "Forbidden: insufficient role on this domain", http.StatusForbidden)
+		return nil, false
+	}
+
+	return domain, true
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/pkg/response"
+)
+
+// DSRecords re-emits the DS record set a domain's owner must have
+// published at their registrar — the same set DomainService.VerifyDomainOwner returns inline
+// on a successful verification, exposed again here for whenever they just
+// need to look it up (lost it, registrar UI wants it re-pasted, etc.).
+func (h *APIHandler) DSRecords(w http.ResponseWriter, r *http.Request) {
+	domainID := r.URL.Query().Get("id")
+	if domainID == "" {
+		h.WriteJSONError(w, "Missing domain id", http.StatusBadRequest)
+		return
+	}
+
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.WriteJSONError(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	if domain.UserID != userID {
+		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	ds, err := database.CurrentDSRecords(h.MongoClient, domain.Name)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to load DS records", http.StatusInternalServerError)
+		return
+	}
+
+	response.JSON(w, map[string]interface{}{
+		"domain":     domain.Name,
+		"ds_records": ds,
+	}, http.StatusOK)
+}
+
+// RotateDSKey starts a double-DS KSK rollover for a domain: a new KSK is
+// published alongside the current one and both DS records come back in the
+// response. The caller publishes newDS at their registrar, waits out its
+// DS TTL, then calls PromoteDSKey to retire oldDS.
+func (h *APIHandler) RotateDSKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domainID := r.URL.Query().Get("id")
+	if domainID == "" {
+		h.WriteJSONError(w, "Missing domain id", http.StatusBadRequest)
+		return
+	}
+
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.WriteJSONError(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	if domain.UserID != userID {
+		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	oldDS, newDS, err := database.RotateKSK(h.MongoClient, domain.Name)
+	if err != nil {
+		h.WriteJSONError(w, "Rollover failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	response.JSON(w, map[string]interface{}{
+		"domain": domain.Name,
+		"status": "pending_rollover",
+		"old_ds": oldDS,
+		"new_ds": newDS,
+	}, http.StatusOK)
+}
+
+// PromoteDSKey completes a rollover started by RotateDSKey, retiring the
+// domain's old KSK once its successor's DS has propagated.
+func (h *APIHandler) PromoteDSKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domainID := r.URL.Query().Get("id")
+	if domainID == "" {
+		h.WriteJSONError(w, "Missing domain id", http.StatusBadRequest)
+		return
+	}
+
+	domain, err := database.GetDomainByID(h.MongoClient, domainID)
+	if err != nil {
+		h.WriteJSONError(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	if domain.UserID != userID {
+		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	if err := database.PromoteKSK(h.MongoClient, domain.Name); err != nil {
+		h.WriteJSONError(w, "Promotion failed: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	response.JSON(w, map[string]interface{}{
+		"domain": domain.Name,
+		"status": "rollover_complete",
+	}, http.StatusOK)
+}
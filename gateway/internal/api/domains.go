@@ -1,18 +1,19 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
 	"strings"
 	"time"
 
+	"web-app-firewall-ml-detection/internal/core"
 	"web-app-firewall-ml-detection/internal/database"
 	"web-app-firewall-ml-detection/internal/detector"
+	applog "web-app-firewall-ml-detection/internal/log"
 )
 
 var realNameservers = []string{
@@ -22,13 +23,6 @@ var realNameservers = []string{
 
 const nsSuffix = ".ns.minishield.tech"
 
-// RDAP Response Structure (The Official Registrar Data)
-type RDAPResponse struct {
-	Nameservers []struct {
-		LdhName string `json:"ldhName"` // This holds "ns1.example.com"
-	} `json:"nameservers"`
-}
-
 func getRootDomain(domain string) string {
 	parts := strings.Split(domain, ".")
 	if len(parts) < 2 {
@@ -83,18 +77,24 @@ func (h *APIHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
 	// 3. Save to MongoDB
 	createdDomain, err := database.CreateDomain(h.MongoClient, domain)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key") {
-			h.WriteJSONError(w, "Domain already exists", http.StatusConflict)
+		if errors.Is(err, database.ErrDuplicate) {
+			h.writeError(w, core.ErrDuplicateDomain)
+			return
+		}
+		if errors.Is(err, database.ErrDomainQuotaExceeded) {
+			h.WriteJSONError(w, fmt.Sprintf("Domain quota exceeded: this account may own at most %d domains", database.MaxDomainsPerUser), http.StatusForbidden)
 			return
 		}
 		h.WriteJSONError(w, "Failed to create domain in DB", http.StatusInternalServerError)
 		return
 	}
 
-	// 4. Provision PowerDNS Zone (SOA and NS only)
-	err = database.CreateDNSZone(domain.Name, domain.Nameservers)
-	if err != nil {
-		log.Printf("ERROR: Failed to create DNS Zone: %v", err)
+	// 4. Provision the zone (SOA and NS only) through whichever
+	// dnsbackend.Provider this deployment is configured for, instead of
+	// hard-coding the PowerDNS/SQL path — so a Cloudflare/Route53/PowerDNS-API
+	// operator gets a real zone here too.
+	if err := h.DNSProvider.CreateZone(domain.Name, domain.Nameservers); err != nil {
+		applog.Errorf("Failed to create DNS zone for %s: %v", domain.Name, err)
 	}
 
 	// NOTE: We do NOT create a default A record here. The zone is created empty.
@@ -104,50 +104,6 @@ func (h *APIHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(createdDomain)
 }
 
-// checkRegistrarRDAP queries the Official Registry (RDAP) to find the configured Nameservers.
-func checkRegistrarRDAP(domain string) ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	// rdap.org is a redirector that finds the correct registry (like Verisign, Radix, etc.)
-	url := fmt.Sprintf("https://rdap.org/domain/%s", domain)
-	
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/rdap+json")
-
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 404 {
-		return nil, fmt.Errorf("domain not registered found")
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var rdapResp RDAPResponse
-	if err := json.Unmarshal(body, &rdapResp); err != nil {
-		return nil, err
-	}
-
-	var nameservers []string
-	for _, ns := range rdapResp.Nameservers {
-		cleanName := strings.TrimSuffix(ns.LdhName, ".")
-		nameservers = append(nameservers, cleanName)
-	}
-
-	return nameservers, nil
-}
-
 func (h *APIHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -162,25 +118,21 @@ func (h *APIHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
 
 	domain, err := database.GetDomainByID(h.MongoClient, domainID)
 	if err != nil {
-		h.WriteJSONError(w, "Domain not found", http.StatusNotFound)
+		h.writeError(w, core.ErrDomainNotFound)
 		return
 	}
 
 	userID := r.Context().Value("user_id").(string)
 	if domain.UserID != userID {
-		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
+		h.writeError(w, core.ErrUnauthorizedDomain)
 		return
 	}
 
 	// 4. SECURITY CHECK: Use RDAP to check the Registrar directly.
-	foundNS, err := checkRegistrarRDAP(domain.Name)
+	rdapResp, err := h.RDAP.Lookup(r.Context(), domain.Name)
 	if err != nil {
-		log.Printf("RDAP Lookup failed: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Verification Unavailable", 
-			"details": err.Error(),
-		})
+		applog.Warnf("RDAP lookup failed for %s: %v", domain.Name, err)
+		h.writeError(w, fmt.Errorf("%w: %v", core.ErrRDAPUnavailable, err))
 		return
 	}
 
@@ -189,7 +141,7 @@ func (h *APIHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
 	
 	for _, assignedNS := range domain.Nameservers {
 		found := false
-		for _, liveNS := range foundNS {
+		for _, liveNS := range rdapResp.Nameservers {
 			if strings.EqualFold(liveNS, assignedNS) {
 				found = true
 				break
@@ -222,6 +174,15 @@ if verified {
 			return
 		}
 
+		// 3. Kick off ACME issuance in the background: the zone only
+		// became resolvable this instant, so the first DNS-01 attempt can
+		// easily lose a propagation race. startCertRenewalTicker would
+		// eventually pick this domain up anyway, but that's up to a 12h
+		// wait for a brand-new domain's first certificate.
+		if h.CertManager != nil {
+			go h.issueCertificateWithBackoff(domain.Name)
+		}
+
 		json.NewEncoder(w).Encode(map[string]string{
 			"status":  "active",
 			"message": "Domain successfully verified! You are now the owner.",
@@ -232,14 +193,43 @@ if verified {
 			"status":             "pending_verification",
 			"message":            "Verification failed. Your Registrar nameservers do not match the assigned ones.",
 			"assigned_ns":        domain.Nameservers,
-			"found_at_registrar": foundNS,
+			"found_at_registrar": rdapResp.Nameservers,
 		})
 	}
 }
 
+// certIssueBackoff is how long issueCertificateWithBackoff waits between
+// retries — DNS-01 failures right after VerifyDomain are almost always
+// propagation lag, not a permanent error, so a few minutes of slack clears
+// most of them well before the next startCertRenewalTicker pass.
+var certIssueBackoff = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute}
+
+// issueCertificateWithBackoff calls CertManager.IssueOrRenew for domainName,
+// retrying on failure so a newly verified domain never blocks its HTTP
+// response on ACME. It logs an error and gives up after the last retry; the regular
+// renewal ticker will pick the domain up on its next pass.
+func (h *APIHandler) issueCertificateWithBackoff(domainName string) {
+	attempts := len(certIssueBackoff) + 1
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(certIssueBackoff[attempt-1])
+		}
+		if err := h.CertManager.IssueOrRenew(domainName); err != nil {
+			lastErr = err
+			applog.Warnf("ACME: issuance attempt %d/%d failed for %s: %v", attempt+1, attempts, domainName, err)
+			continue
+		}
+		return
+	}
+	applog.Errorf("ACME: giving up issuing a certificate for %s after %d attempts: %v", domainName, attempts, lastErr)
+}
+
 func (h *APIHandler) ListDomains(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(string)
-	domains, err := database.GetDomainsByUser(h.MongoClient, userID)
+	// [UPDATED] Include domains the user was invited onto, not just ones
+	// they own, per the domain_members RBAC model.
+	domains, err := database.GetAccessibleDomains(h.MongoClient, userID)
 	if err != nil {
 		h.WriteJSONError(w, "Failed to fetch domains", http.StatusInternalServerError)
 		return
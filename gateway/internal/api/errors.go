@@ -0,0 +1,32 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"web-app-firewall-ml-detection/internal/core"
+)
+
+// writeError maps one of internal/core's sentinel errors onto the HTTP
+// status a client should see, via h.WriteJSONError, so call sites don't
+// have to hand-roll a status code (or worse, string-match err.Error()) for
+// every failure. Anything that isn't one of the sentinels below falls back
+// to a generic 500 with err's own message.
+func (h *APIHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrDomainNotFound):
+		h.WriteJSONError(w, "Domain not found", http.StatusNotFound)
+	case errors.Is(err, core.ErrDuplicateDomain):
+		h.WriteJSONError(w, "Domain already exists", http.StatusConflict)
+	case errors.Is(err, core.ErrUnauthorizedDomain):
+		h.WriteJSONError(w, "Unauthorized", http.StatusForbidden)
+	case errors.Is(err, core.ErrDNSProviderUnavailable):
+		h.WriteJSONError(w, "DNS provider unavailable", http.StatusServiceUnavailable)
+	case errors.Is(err, core.ErrRDAPUnavailable):
+		h.WriteJSONError(w, "Verification Unavailable: "+err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, core.ErrCertIssuanceFailed):
+		h.WriteJSONError(w, "Certificate issuance failed: "+err.Error(), http.StatusInternalServerError)
+	default:
+		h.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
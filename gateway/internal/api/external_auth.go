@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"web-app-firewall-ml-detection/internal/config"
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// trustedIssuer pairs a JWKS cache with the audience/auto-provisioning
+// policy a trusted_issuers entry was configured with.
+type trustedIssuer struct {
+	jwks          *oidc.JWKSCache
+	audience      string
+	autoProvision bool
+}
+
+// trustedIssuers holds every externally-signed-JWT issuer the management
+// API accepts Bearer tokens from, keyed by the `iss` claim value, built
+// once at startup by LoadTrustedIssuers.
+var trustedIssuers = map[string]*trustedIssuer{}
+
+// externalAuthClient backs auto-provisioning for trusted-issuer tokens.
+// AuthMiddleware has no receiver (and so no APIHandler) to read
+// MongoClient from, hence this package-level var set by InitExternalAuth.
+var externalAuthClient *mongo.Client
+
+// InitExternalAuth wires Mongo access for trusted-issuer auto-provisioning
+// and loads cfg.TrustedIssuers. Must be called once at startup before
+// AuthMiddleware can see any Bearer token.
+func InitExternalAuth(client *mongo.Client, cfg *config.Config) {
+	externalAuthClient = client
+	LoadTrustedIssuers(cfg)
+}
+
+// LoadTrustedIssuers builds the trustedIssuers map from cfg. An empty list
+// just leaves Bearer-token auth unavailable, same as a missing
+// oauth_providers.json leaves social login unavailable.
+func LoadTrustedIssuers(cfg *config.Config) {
+	issuers := make(map[string]*trustedIssuer, len(cfg.TrustedIssuers))
+	for _, c := range cfg.TrustedIssuers {
+		issuers[c.Issuer] = &trustedIssuer{
+			jwks:          oidc.NewJWKSCache(c.JWKSURL),
+			audience:      c.Audience,
+			autoProvision: c.AutoProvision,
+		}
+		log.Printf("✅ Trusted external JWT issuer enabled: %s", c.Issuer)
+	}
+	trustedIssuers = issuers
+}
+
+// verifyExternalToken validates tokenString against whichever trusted
+// issuer its (still-unverified) `iss` claim names, then maps its `sub` to
+// an internal user id, auto-provisioning on first sight if the issuer
+// allows it and an `email` claim is present.
+func verifyExternalToken(ctx context.Context, tokenString string) (string, error) {
+	var unverifiedClaims jwt.MapClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &unverifiedClaims); err != nil {
+		return "", errors.New("malformed token")
+	}
+
+	iss, _ := unverifiedClaims["iss"].(string)
+	issuer, ok := trustedIssuers[iss]
+	if !ok {
+		return "", fmt.Errorf("unknown or untrusted issuer %q", iss)
+	}
+
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != "RS256" {
+			return nil, fmt.Errorf("unexpected signing method %q", t.Method.Alg())
+		}
+		kid, _ := t.Header["kid"].(string)
+		return issuer.jwks.Key(kid)
+	}, jwt.WithIssuer(iss), jwt.WithAudience(issuer.audience), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return "", errors.New("signature or claim verification failed")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", errors.New("unexpected claims type")
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return "", errors.New("token missing sub")
+	}
+
+	// [NEW] Namespaced by issuer so a subject id can never collide across
+	// two different trusted issuers, mirroring the "oidc:<realm>" AuthType
+	// namespacing in oidc.go.
+	authType := "issuer:" + iss
+	if user, err := database.GetUserByExternalID(externalAuthClient, authType, sub); err == nil {
+		return user.ID, nil
+	}
+
+	email, _ := claims["email"].(string)
+	if !issuer.autoProvision || email == "" {
+		return "", fmt.Errorf("no linked user for subject %q", sub)
+	}
+
+	name, _ := claims["name"].(string)
+	if name == "" {
+		name = email
+	}
+
+	// email_verified gates linking to an existing local account with the
+	// same email: without it, a trusted issuer that lets anyone register
+	// victim@example.com would be enough to take over victim's password
+	// account on first Bearer-token use. See
+	// database.ErrEmailOwnedByUnverifiedProvider.
+	emailVerified, _ := claims["email_verified"].(bool)
+
+	user, err := database.UpsertOAuthUser(externalAuthClient, authType, sub, email, name, emailVerified)
+	if err != nil {
+		return "", fmt.Errorf("auto-provisioning failed: %w", err)
+	}
+	return user.ID, nil
+}
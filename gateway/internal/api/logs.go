@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"web-app-firewall-ml-detection/internal/database"
 	"web-app-firewall-ml-detection/internal/logger"
@@ -13,6 +15,33 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// paginationFilter builds a database.LogFilter from the request's query
+// string, shared by GetLogs and SecuredLogsHandler so both read the exact
+// same page/limit semantics. The live SSE stream has no pages; it resumes
+// by Last-Event-ID instead.
+func paginationFilter(r *http.Request, userID string) database.LogFilter {
+	query := r.URL.Query()
+
+	pageStr := query.Get("page")
+	page, _ := strconv.ParseInt(pageStr, 10, 64)
+	if page < 1 {
+		page = 1
+	}
+
+	limitStr := query.Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+	if limit < 1 {
+		limit = 20
+	}
+
+	return database.LogFilter{
+		UserID:   userID,
+		DomainID: query.Get("domain_id"),
+		Page:     page,
+		Limit:    limit,
+	}
+}
+
 type LogHandler struct {
 	MongoClient *mongo.Client
 }
@@ -24,25 +53,23 @@ func NewLogHandler(client *mongo.Client) *LogHandler {
 func (h *LogHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(string)
 
-	query := r.URL.Query()
-	domainID := query.Get("domain_id")
-	
-	pageStr := query.Get("page")
-	page, _ := strconv.ParseInt(pageStr, 10, 64)
-	if page < 1 { page = 1 }
+	result, err := database.GetLogs(h.MongoClient, paginationFilter(r, userID))
+	if err != nil {
+		utils.WriteError(w, "Failed to fetch logs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	limitStr := query.Get("limit")
-	limit, _ := strconv.ParseInt(limitStr, 10, 64)
-	if limit < 1 { limit = 20 }
+	utils.WriteSuccess(w, result, http.StatusOK)
+}
 
-	filter := database.LogFilter{
-		UserID:   userID,
-		DomainID: domainID,
-		Page:     page,
-		Limit:    limit,
-	}
+// SecuredLogsHandler is the authenticated pagination endpoint for the logs
+// dashboard. It shares database.LogFilter/GetLogs with GetLogs above, so the
+// same page of data an admin pages through here is exactly what the SSE
+// stream below would have replayed for the same user/domain.
+func (h *LogHandler) SecuredLogsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
 
-	result, err := database.GetLogs(h.MongoClient, filter)
+	result, err := database.GetLogs(h.MongoClient, paginationFilter(r, userID))
 	if err != nil {
 		utils.WriteError(w, "Failed to fetch logs: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -51,31 +78,100 @@ func (h *LogHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
 	utils.WriteSuccess(w, result, http.StatusOK)
 }
 
+// SSEHandler streams live log events to one client, scoped to the
+// authenticated user (and optionally one domain/severity via query params),
+// with backpressure handled by logger.Subscribe's bounded, drop-oldest
+// channel. A reconnecting client that sends "Last-Event-ID" is first
+// replayed whatever it missed from the ring buffer before switching over to
+// the live feed.
 func (h *LogHandler) SSEHandler(w http.ResponseWriter, r *http.Request) {
 	// SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	// CORS for SSE if needed
-	w.Header().Set("Access-Control-Allow-Origin", "*") 
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	userID, _ := r.Context().Value("user_id").(string)
+
+	// [UPDATED] Last-Event-ID now feeds SubscribeOptions.SinceID directly,
+	// so Subscribe itself replays whatever was missed before this handler
+	// ever reads the live channel, instead of a separate Replay call racing
+	// against it. ?last_id= is a fallback for EventSource callers that can't
+	// set the header on the initial request (the browser API always sends
+	// it on reconnect, but a first-load resume needs the query param).
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_id")
+	}
+	sinceID, _ := strconv.ParseUint(lastEventID, 10, 64)
+	var minScore int
+	if v := r.URL.Query().Get("min_score"); v != "" {
+		minScore, _ = strconv.Atoi(v)
+	}
+	var actions []string
+	if severity := r.URL.Query().Get("severity"); severity != "" {
+		actions = strings.Split(severity, ",")
+	}
+
+	sub := logger.Subscribe(logger.SubscribeOptions{
+		SinceID:  sinceID,
+		UserID:   userID,
+		DomainID: r.URL.Query().Get("domain_id"),
+		MinScore: minScore,
+		Actions:  actions,
+	})
+	defer logger.Unsubscribe(sub)
 
-	logsCh := logger.GetBroadcastChannel()
-	
-	// Flush immediately to establish connection
-	if f, ok := w.(http.Flusher); ok {
-		f.Flush()
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
 	}
 
+	// [NEW] Proxies (and some browsers) close an SSE connection they've
+	// seen no bytes on for a while; a periodic comment line keeps it open
+	// without the client having to parse it as a real event.
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	var lastDropped uint64
 	for {
 		select {
-		case entry := <-logsCh:
-			data, _ := json.Marshal(entry)
-			fmt.Fprintf(w, "data: %s\n\n", data)
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			writeSSEEvent(w, ev)
+
+			// [NEW] Let a slow client know it's falling behind instead of
+			// silently losing entries to the drop-oldest buffer.
+			if dropped := sub.Dropped(); dropped != lastDropped {
+				fmt.Fprintf(w, "event: dropped\ndata: %d\n\n", dropped)
+				lastDropped = dropped
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			if flusher != nil {
+				flusher.Flush()
 			}
 		case <-r.Context().Done():
 			return
 		}
 	}
-}
\ No newline at end of file
+}
+
+func writeSSEEvent(w http.ResponseWriter, ev logger.Event) {
+	data, _ := json.Marshal(ev.Log)
+	fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.ID, data)
+}
+
+// SSESubscriberStats reports every currently connected SSE client's
+// filter and drop count, so an operator can tell which dashboards are too
+// slow to keep up with the live stream instead of guessing from Mongo.
+func (h *LogHandler) SSESubscriberStats(w http.ResponseWriter, r *http.Request) {
+	utils.WriteSuccess(w, logger.Subscribers(), http.StatusOK)
+}
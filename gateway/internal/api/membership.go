@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/role"
+)
+
+type addMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// AddDomainMember invites an existing user onto a domain with a role.
+// POST /api/domains/{id}/members — guarded by RequireRole(role.Admin) in
+// main.go, since only owners/admins may change membership.
+func (h *APIHandler) AddDomainMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domainID := domainIDFromRequest(r)
+	inviterID := r.Context().Value("user_id").(string)
+
+	var req addMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.WriteJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	grantedRole := role.Role(req.Role)
+	if !role.Valid(grantedRole) {
+		h.WriteJSONError(w, "role must be one of: owner, admin, editor, viewer", http.StatusBadRequest)
+		return
+	}
+	if grantedRole == role.Owner {
+		h.WriteJSONError(w, "Ownership cannot be granted through invitations", http.StatusBadRequest)
+		return
+	}
+
+	target, err := database.GetUserByEmail(h.MongoClient, req.Email)
+	if err != nil {
+		h.WriteJSONError(w, "No user registered with that email", http.StatusNotFound)
+		return
+	}
+
+	member := database.DomainMember{
+		DomainID:  domainID,
+		UserID:    target.ID,
+		Role:      grantedRole,
+		InvitedBy: inviterID,
+	}
+	if err := database.AddMember(h.MongoClient, member); err != nil {
+		h.WriteJSONError(w, "Failed to add member: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Member added",
+		"member":  member,
+	})
+}
+
+// ListDomainMembers returns everyone with access to a domain.
+// GET /api/domains/{id}/members/list
+func (h *APIHandler) ListDomainMembers(w http.ResponseWriter, r *http.Request) {
+	domainID := domainIDFromRequest(r)
+	members, err := database.GetMembersByDomain(h.MongoClient, domainID)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
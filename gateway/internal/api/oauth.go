@@ -0,0 +1,286 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"golang.org/x/oauth2/google"
+)
+
+// ExternalIdentity is what every OAuthProvider boils its UserInfo endpoint
+// down to, regardless of the shape the provider actually returns.
+type ExternalIdentity struct {
+	ExternalID string
+	Email      string
+	Name       string
+
+	// EmailVerified mirrors the provider's own email_verified userinfo
+	// field; a provider that never sends it is treated as unverified
+	// rather than assumed trustworthy.
+	EmailVerified bool
+}
+
+// OAuthProvider lets the gateway accept logins from Google, GitHub, or any
+// generic OIDC issuer without OAuthCallback knowing which one it's talking
+// to.
+type OAuthProvider interface {
+	Name() string
+	AuthCodeURL(state string) string
+	Exchange(ctx context.Context, code string) (*oauth2.Token, error)
+	UserInfo(ctx context.Context, token *oauth2.Token) (ExternalIdentity, error)
+}
+
+// ProviderConfig is the on-disk shape of config/oauth_providers.json.
+type ProviderConfig struct {
+	Name         string `json:"name"` // "google", "github", or "oidc"
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RedirectURL  string `json:"redirect_url"`
+
+	// Only used by the generic "oidc" provider.
+	IssuerURL string `json:"issuer_url,omitempty"`
+	AuthURL   string `json:"auth_url,omitempty"`
+	TokenURL  string `json:"token_url,omitempty"`
+	UserInfoURL string `json:"userinfo_url,omitempty"`
+}
+
+// oauthProviders holds every provider enabled via config/oauth_providers.json,
+// keyed by name, built once at startup.
+var oauthProviders = map[string]OAuthProvider{}
+
+// LoadOAuthProviders reads the provider list from disk and builds the
+// concrete OAuthProvider for each enabled entry. Missing/unreadable config
+// is not fatal: OAuth login is simply unavailable, same as the ACME manager
+// degrading when it can't reach the network.
+func LoadOAuthProviders(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("[WARN] OAuth: no provider config at %s, social login disabled: %v", path, err)
+		return
+	}
+
+	var configs []ProviderConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		log.Printf("[ERROR] OAuth: failed to parse %s: %v", path, err)
+		return
+	}
+
+	for _, c := range configs {
+		switch c.Name {
+		case "google":
+			oauthProviders["google"] = &genericOAuthProvider{
+				name: "google",
+				cfg: &oauth2.Config{
+					ClientID:     c.ClientID,
+					ClientSecret: c.ClientSecret,
+					RedirectURL:  c.RedirectURL,
+					Scopes:       []string{"openid", "email", "profile"},
+					Endpoint:     google.Endpoint,
+				},
+				userInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+				idField:     "sub",
+			}
+		case "github":
+			oauthProviders["github"] = &genericOAuthProvider{
+				name: "github",
+				cfg: &oauth2.Config{
+					ClientID:     c.ClientID,
+					ClientSecret: c.ClientSecret,
+					RedirectURL:  c.RedirectURL,
+					Scopes:       []string{"read:user", "user:email"},
+					Endpoint:     github.Endpoint,
+				},
+				userInfoURL: "https://api.github.com/user",
+				idField:     "id",
+			}
+		case "oidc":
+			oauthProviders[c.Name] = &genericOAuthProvider{
+				name: c.Name,
+				cfg: &oauth2.Config{
+					ClientID:     c.ClientID,
+					ClientSecret: c.ClientSecret,
+					RedirectURL:  c.RedirectURL,
+					Scopes:       []string{"openid", "email", "profile"},
+					Endpoint: oauth2.Endpoint{
+						AuthURL:  c.AuthURL,
+						TokenURL: c.TokenURL,
+					},
+				},
+				userInfoURL: c.UserInfoURL,
+				idField:     "sub",
+			}
+		default:
+			log.Printf("[WARN] OAuth: unknown provider %q in config, skipping", c.Name)
+			continue
+		}
+		log.Printf("✅ OAuth provider enabled: %s", c.Name)
+	}
+}
+
+// genericOAuthProvider implements OAuthProvider for any issuer that speaks
+// standard oauth2 + a JSON userinfo endpoint (which covers Google, GitHub,
+// and plain OIDC).
+type genericOAuthProvider struct {
+	name        string
+	cfg         *oauth2.Config
+	userInfoURL string
+	idField     string
+}
+
+func (p *genericOAuthProvider) Name() string { return p.name }
+
+func (p *genericOAuthProvider) AuthCodeURL(state string) string {
+	return p.cfg.AuthCodeURL(state, oauth2.AccessTypeOnline)
+}
+
+func (p *genericOAuthProvider) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	return p.cfg.Exchange(ctx, code)
+}
+
+func (p *genericOAuthProvider) UserInfo(ctx context.Context, token *oauth2.Token) (ExternalIdentity, error) {
+	client := p.cfg.Client(ctx, token)
+	resp, err := client.Get(p.userInfoURL)
+	if err != nil {
+		return ExternalIdentity{}, fmt.Errorf("oauth: userinfo request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ExternalIdentity{}, fmt.Errorf("oauth: userinfo returned %d", resp.StatusCode)
+	}
+
+	var raw map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return ExternalIdentity{}, fmt.Errorf("oauth: failed to decode userinfo: %w", err)
+	}
+
+	identity := ExternalIdentity{
+		Email:         stringField(raw, "email"),
+		Name:          stringField(raw, "name"),
+		EmailVerified: boolField(raw, "email_verified") || boolField(raw, "verified_email"),
+	}
+	if id, ok := raw[p.idField]; ok {
+		identity.ExternalID = fmt.Sprintf("%v", id)
+	}
+	if identity.Name == "" {
+		identity.Name = stringField(raw, "login") // GitHub username fallback
+	}
+	return identity, nil
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	if v, ok := m[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func boolField(m map[string]interface{}, key string) bool {
+	v, _ := m[key].(bool)
+	return v
+}
+
+// StartOAuth redirects the browser to the provider's consent screen.
+// Routed as GET /api/auth/{provider}/start.
+func (h *APIHandler) StartOAuth(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oauthProviders[r.PathValue("provider")]
+	if !ok {
+		h.WriteJSONError(w, "Unknown OAuth provider", http.StatusNotFound)
+		return
+	}
+
+	state, err := randomState()
+	if err != nil {
+		h.WriteJSONError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "oauth_state",
+		Value:    state,
+		Expires:  time.Now().Add(10 * time.Minute),
+		HttpOnly: true,
+		Path:     "/",
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, provider.AuthCodeURL(state), http.StatusFound)
+}
+
+// OAuthCallback exchanges the authorization code, maps the external
+// identity to an internal user (auto-provisioning on first login), and
+// mints the same auth_token session as the password flow.
+// Routed as GET /api/auth/{provider}/callback.
+func (h *APIHandler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oauthProviders[r.PathValue("provider")]
+	if !ok {
+		h.WriteJSONError(w, "Unknown OAuth provider", http.StatusNotFound)
+		return
+	}
+
+	stateCookie, err := r.Cookie("oauth_state")
+	if err != nil || r.URL.Query().Get("state") != stateCookie.Value {
+		h.WriteJSONError(w, "Invalid OAuth state", http.StatusBadRequest)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		h.WriteJSONError(w, "Missing authorization code", http.StatusBadRequest)
+		return
+	}
+
+	token, err := provider.Exchange(r.Context(), code)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to exchange authorization code", http.StatusBadGateway)
+		return
+	}
+
+	identity, err := provider.UserInfo(r.Context(), token)
+	if err != nil || identity.ExternalID == "" {
+		h.WriteJSONError(w, "Failed to fetch user info", http.StatusBadGateway)
+		return
+	}
+
+	user, err := database.UpsertOAuthUser(h.MongoClient, provider.Name(), identity.ExternalID, identity.Email, identity.Name, identity.EmailVerified)
+	if errors.Is(err, database.ErrEmailOwnedByUnverifiedProvider) {
+		h.WriteJSONError(w, "An account with this email already exists; sign in with your password to link this provider", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.WriteJSONError(w, "Failed to provision user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.issueSession(w, r, *user); err != nil {
+		h.WriteJSONError(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	frontend := os.Getenv("FRONTEND_URL")
+	if frontend == "" {
+		frontend = "https://www.minishield.tech"
+	}
+	http.Redirect(w, r, frontend+"/dashboard", http.StatusFound)
+}
+
+func randomState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,30 @@
+package api
+
+import "testing"
+
+// TestBoolFieldMissingIsFalse verifies a provider that never sends
+// email_verified (or sends a non-bool value) is treated as unverified
+// rather than assumed trustworthy — see ExternalIdentity.EmailVerified.
+func TestBoolFieldMissingIsFalse(t *testing.T) {
+	if boolField(map[string]interface{}{}, "email_verified") {
+		t.Error("a missing field should report false")
+	}
+	if boolField(map[string]interface{}{"email_verified": "true"}, "email_verified") {
+		t.Error("a non-bool value should report false, not be coerced")
+	}
+}
+
+func TestBoolFieldReadsBoolValue(t *testing.T) {
+	if !boolField(map[string]interface{}{"email_verified": true}, "email_verified") {
+		t.Error("a true bool value should report true")
+	}
+	if boolField(map[string]interface{}{"email_verified": false}, "email_verified") {
+		t.Error("a false bool value should report false")
+	}
+}
+
+func TestStringFieldMissingIsEmpty(t *testing.T) {
+	if got := stringField(map[string]interface{}{}, "name"); got != "" {
+		t.Errorf("stringField() = %q, want empty for a missing key", got)
+	}
+}
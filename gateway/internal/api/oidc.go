@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/config"
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/oidc"
+)
+
+// oidcProviders holds every Keycloak-style realm enabled via
+// Config.OIDCProviders, keyed by name, built once at startup by
+// LoadOIDCProviders.
+var oidcProviders = map[string]*oidc.Provider{}
+
+// LoadOIDCProviders builds the configured oidc.Provider set from
+// cfg.OIDCProviders. An empty list is not an error: OIDC login is simply
+// unavailable, same as LoadOAuthProviders degrading when its config file
+// is missing.
+func LoadOIDCProviders(cfg *config.Config) {
+	providers := make(map[string]*oidc.Provider, len(cfg.OIDCProviders))
+	for _, c := range cfg.OIDCProviders {
+		providers[c.Name] = oidc.NewKeycloakProvider(c.Name, c.IssuerURL, c.ClientID, c.ClientSecret, c.RedirectURL)
+		log.Printf("✅ OIDC provider enabled: %s", c.Name)
+	}
+	oidcProviders = providers
+}
+
+// oidcStateCookie/oidcPKCECookie carry this one login attempt's CSRF state
+// and PKCE code_verifier between LoginOIDC and CallbackOIDC. They're kept
+// separate from oauth_state (oauth.go) since the plain OAuth flow never
+// needs a code_verifier.
+const (
+	oidcStateCookie = "oidc_state"
+	oidcPKCECookie  = "oidc_pkce_verifier"
+)
+
+// LoginOIDC redirects to the realm's authorization endpoint with a CSRF
+// state and a PKCE code_challenge, both stashed in short-lived cookies for
+// CallbackOIDC to check against.
+// Routed as GET /auth/oidc/{provider}/login.
+func (h *APIHandler) LoginOIDC(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidcProviders[r.PathValue("provider")]
+	if !ok {
+		h.WriteJSONError(w, "Unknown OIDC provider", http.StatusNotFound)
+		return
+	}
+
+	state, err := randomState()
+	if err != nil {
+		h.WriteJSONError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCEVerifier()
+	if err != nil {
+		h.WriteJSONError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	expires := time.Now().Add(10 * time.Minute)
+	http.SetCookie(w, &http.Cookie{
+		Name: oidcStateCookie, Value: state, Expires: expires,
+		HttpOnly: true, Path: "/", Secure: true, SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name: oidcPKCECookie, Value: verifier, Expires: expires,
+		HttpOnly: true, Path: "/", Secure: true, SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, provider.AuthCodeURL(state, challenge), http.StatusFound)
+}
+
+// CallbackOIDC exchanges the authorization code (with the stashed PKCE
+// verifier) for an ID token, verifies it against the realm's JWKS,
+// auto-provisions/links a detector.User by provider+subject, and mints the
+// same auth_token session as the password and plain OAuth flows.
+// Routed as GET /auth/oidc/{provider}/callback.
+func (h *APIHandler) CallbackOIDC(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidcProviders[r.PathValue("provider")]
+	if !ok {
+		h.WriteJSONError(w, "Unknown OIDC provider", http.StatusNotFound)
+		return
+	}
+
+	stateCookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || r.URL.Query().Get("state") != stateCookie.Value {
+		h.WriteJSONError(w, "Invalid OIDC state", http.StatusBadRequest)
+		return
+	}
+	verifierCookie, err := r.Cookie(oidcPKCECookie)
+	if err != nil {
+		h.WriteJSONError(w, "Missing PKCE verifier", http.StatusBadRequest)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		h.WriteJSONError(w, "Missing authorization code", http.StatusBadRequest)
+		return
+	}
+
+	token, err := provider.Exchange(r.Context(), code, verifierCookie.Value)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to exchange authorization code", http.StatusBadGateway)
+		return
+	}
+
+	identity, err := provider.VerifyIDToken(token.IDToken)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to verify ID token", http.StatusUnauthorized)
+		return
+	}
+
+	// authType is namespaced per realm so two Keycloak realms (or a realm
+	// and a plain "oidc" OAuth provider) can never collide on external id.
+	authType := "oidc:" + provider.Name
+	user, err := database.UpsertOAuthUser(h.MongoClient, authType, identity.Subject, identity.Email, identity.Name, identity.EmailVerified)
+	if errors.Is(err, database.ErrEmailOwnedByUnverifiedProvider) {
+		h.WriteJSONError(w, "An account with this email already exists; sign in with your password to link this provider", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.WriteJSONError(w, "Failed to provision user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.issueSession(w, r, *user); err != nil {
+		h.WriteJSONError(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	frontend := os.Getenv("FRONTEND_URL")
+	if frontend == "" {
+		frontend = "https://www.minishield.tech"
+	}
+	http.Redirect(w, r, frontend+"/dashboard", http.StatusFound)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/role"
+)
+
+// domainIDFromRequest resolves the domain a request is scoped to: a path
+// value ("/api/domains/{id}/..."), a query param ("?domain_id=..."), or —
+// for a POST with neither, like addRecord's "/api/dns/records" — the
+// top-level "domain_id" field of its JSON body.
+func domainIDFromRequest(r *http.Request) string {
+	if id := r.PathValue("id"); id != "" {
+		return id
+	}
+	if id := r.URL.Query().Get("domain_id"); id != "" {
+		return id
+	}
+	if r.Method == http.MethodPost {
+		return domainIDFromJSONBody(r)
+	}
+	return ""
+}
+
+// domainIDFromJSONBody peeks at a POST body's "domain_id" field without
+// consuming it, restoring r.Body afterward so the handler's own
+// json.NewDecoder still sees the full payload.
+func domainIDFromJSONBody(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var payload struct {
+		DomainID string `json:"domain_id"`
+	}
+	json.Unmarshal(body, &payload)
+	return payload.DomainID
+}
+
+// RequireRole wraps a handler so it only runs if the authenticated user
+// holds at least minRole on the domain the request targets. It must sit
+// inside AuthMiddleware, which populates "user_id" in the context.
+func (h *APIHandler) RequireRole(minRole role.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("user_id").(string)
+		if !ok {
+			h.WriteJSONError(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		domainID := domainIDFromRequest(r)
+		if domainID == "" {
+			h.WriteJSONError(w, "domain_id is required", http.StatusBadRequest)
+			return
+		}
+
+		userRole, err := database.GetMemberRole(h.MongoClient, domainID, userID)
+		if err != nil || !role.Satisfies(userRole, minRole) {
+			h.WriteJSONError(w, "Forbidden: insufficient role on this domain", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "domain_role", userRole)
+		next(w, r.WithContext(ctx))
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
@@ -11,6 +12,17 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// ifMatchFingerprint reads the caller's If-Match header, falling back to the
+// config's own live fingerprint when it's absent so an old client that
+// never sends one keeps working exactly as before — DoLockedAction still
+// serializes the write against a concurrent ReloadRules either way.
+func (h *APIHandler) ifMatchFingerprint(r *http.Request) string {
+	if fp := r.Header.Get("If-Match"); fp != "" {
+		return fp
+	}
+	return h.Config.Fingerprint()
+}
+
 // Helper to determine if a rule is enabled based on user policies
 func resolveEnabledStatus(ruleID, domainID string, policies map[policyKey]bool, defaultState bool) bool {
 	// 1.Check Specific Domain Policy
@@ -123,7 +135,23 @@ func (h *APIHandler) AddCustomRule(w http.ResponseWriter, r *http.Request) {
 		rule.OnMatch.ScoreAdd = 5
 	}
 
-	if err := database.AddRule(h.MongoClient, rule); err != nil {
+	// Validate the constraint lists up front so a bad CIDR/glob is
+	// rejected at creation time with a field-level message the UI can
+	// highlight, instead of silently loading unconstrained at the next
+	// ReloadRules.
+	if _, err := detector.CompileConstraints(rule); err != nil {
+		h.WriteJSONError(w, "Invalid constraint: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.Config.DoLockedAction(h.ifMatchFingerprint(r), func(*rulesSnapshot) error {
+		return database.AddRule(h.MongoClient, rule)
+	})
+	if errors.Is(err, ErrStale) {
+		h.writeFingerprintConflict(w)
+		return
+	}
+	if err != nil {
 		h.WriteJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -147,7 +175,14 @@ func (h *APIHandler) DeleteCustomRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := database.DeleteRule(h.MongoClient, ruleID, userID); err != nil {
+	err := h.Config.DoLockedAction(h.ifMatchFingerprint(r), func(*rulesSnapshot) error {
+		return database.DeleteRule(h.MongoClient, ruleID, userID)
+	})
+	if errors.Is(err, ErrStale) {
+		h.writeFingerprintConflict(w)
+		return
+	}
+	if err != nil {
 		h.WriteJSONError(w, "Cannot delete rule: "+err.Error(), http.StatusForbidden)
 		return
 	}
@@ -190,7 +225,14 @@ func (h *APIHandler) ToggleRule(w http.ResponseWriter, r *http.Request) {
 		Enabled:  payload.Enabled,
 	}
 
-	if err := database.UpsertRulePolicy(h.MongoClient, policy); err != nil {
+	err := h.Config.DoLockedAction(h.ifMatchFingerprint(r), func(*rulesSnapshot) error {
+		return database.UpsertRulePolicy(h.MongoClient, policy)
+	})
+	if errors.Is(err, ErrStale) {
+		h.writeFingerprintConflict(w)
+		return
+	}
+	if err != nil {
 		log.Printf("[ERROR] Failed to save policy: %v", err)
 		h.WriteJSONError(w, "Failed to update policy", http.StatusInternalServerError)
 		return
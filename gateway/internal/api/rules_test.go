@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestIfMatchFingerprintPrefersHeader verifies a caller's explicit If-Match
+// header wins over the config's own live fingerprint.
+func TestIfMatchFingerprintPrefersHeader(t *testing.T) {
+	cfg := NewRulesConfig()
+	cfg.swapIfChanged(&rulesSnapshot{fingerprint: "live"})
+	h := &APIHandler{Config: cfg}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("If-Match", "caller-supplied")
+
+	if got := h.ifMatchFingerprint(req); got != "caller-supplied" {
+		t.Errorf("ifMatchFingerprint() = %q, want the If-Match header value", got)
+	}
+}
+
+// TestIfMatchFingerprintFallsBackToLiveConfig verifies an old client that
+// never sends If-Match still gets a fingerprint that matches the live
+// config, so DoLockedAction doesn't spuriously reject its write as stale.
+func TestIfMatchFingerprintFallsBackToLiveConfig(t *testing.T) {
+	cfg := NewRulesConfig()
+	cfg.swapIfChanged(&rulesSnapshot{fingerprint: "live"})
+	h := &APIHandler{Config: cfg}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+
+	if got := h.ifMatchFingerprint(req); got != "live" {
+		t.Errorf("ifMatchFingerprint() = %q, want the config's live fingerprint %q", got, "live")
+	}
+}
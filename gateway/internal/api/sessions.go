@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/logger"
+	"web-app-firewall-ml-detection/internal/sessionstore"
+)
+
+// RefreshToken exchanges a valid refresh_token cookie for a new
+// access/refresh pair, rotating the refresh token in the process. It is
+// mounted outside AuthMiddleware since the whole point is to let the
+// client recover from an expired auth_token without a full login.
+func (h *APIHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(refreshCookieName)
+	if err != nil {
+		h.WriteJSONError(w, "No refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	rt, err := h.Sessions.GetByHash(r.Context(), sessionstore.HashToken(cookie.Value))
+	if err != nil {
+		h.WriteJSONError(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	// Reuse of an already-rotated token means the raw value leaked — treat
+	// it as a stolen token, kill every session in the family, and record it
+	// as a security event rather than a routine auth failure.
+	if rt.RotatedTo != "" {
+		h.Sessions.RevokeAll(r.Context(), rt.UserID)
+		clearCookie(w, accessCookieName)
+		clearCookie(w, refreshCookieName)
+		logger.LogAttack(rt.UserID, "", h.clientIP(r), r.URL.Path,
+			"refresh token reuse detected — session family revoked",
+			"Blocked", "AuthAnomaly", nil, 100, 0, detector.FullRequest{}, "")
+		h.WriteJSONError(w, "Refresh token already used", http.StatusUnauthorized)
+		return
+	}
+	if rt.Revoked || time.Now().After(rt.ExpiresAt) {
+		h.WriteJSONError(w, "Refresh token expired or revoked", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := database.GetUserByID(h.MongoClient, rt.UserID)
+	if err != nil {
+		h.WriteJSONError(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	newSessionID, err := h.issueRefreshToken(w, r, user.ID, rt.ID)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to rotate refresh token", http.StatusInternalServerError)
+		return
+	}
+	if err := h.setAccessCookie(w, user.ID, user.Email, newSessionID); err != nil {
+		h.WriteJSONError(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Token refreshed"})
+}
+
+// sessionView is what /auth/sessions hands back — never the token hash,
+// just enough for a user to recognize and optionally kill a session.
+type sessionView struct {
+	ID        string    `json:"id"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+}
+
+// ListSessions returns every active (non-revoked, unexpired) refresh-token
+// session for the authenticated user.
+func (h *APIHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		h.WriteJSONError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := h.Sessions.List(r.Context(), userID)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]sessionView, 0, len(tokens))
+	for _, t := range tokens {
+		views = append(views, sessionView{
+			ID:        t.ID,
+			IssuedAt:  t.IssuedAt,
+			ExpiresAt: t.ExpiresAt,
+			UserAgent: t.UserAgent,
+			IP:        t.IP,
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"sessions": views})
+}
+
+// RevokeSession lets a user kill one of their own sessions (e.g. "log out
+// this device") by jti, without having to log out everywhere.
+func (h *APIHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		h.WriteJSONError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var input struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.ID == "" {
+		h.WriteJSONError(w, "Invalid JSON Body", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.Sessions.List(r.Context(), userID)
+	if err != nil {
+		h.WriteJSONError(w, "Failed to look up sessions", http.StatusInternalServerError)
+		return
+	}
+	owned := false
+	for _, t := range tokens {
+		if t.ID == input.ID {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		h.WriteJSONError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.Sessions.Revoke(r.Context(), input.ID); err != nil {
+		h.WriteJSONError(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Session revoked"})
+}
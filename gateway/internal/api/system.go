@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"net/http"
 	"runtime"
-	"sync/atomic"
 	"time"
+
+	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/metrics"
+	"web-app-firewall-ml-detection/internal/rdap"
 	"web-app-firewall-ml-detection/pkg/response"
 )
 
@@ -26,30 +29,33 @@ func (h *APIHandler) SystemStatus(w http.ResponseWriter, r *http.Request) {
 	// 1.GATEWAY STATS (Self)
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
-	currentRPM := atomic.LoadUint64(&h.rpm)
+	// [UPDATED] RPM/p95 now come from the metrics.Middleware-fed tracker
+	// instead of the old per-minute atomic counter.
+	stats := metrics.Snapshot()
 
 	statusMap["gateway"] = ComponentStatus{
 		Status:  "Online",
 		Memory:  fmt.Sprintf("%v MB", m.Alloc/1024/1024),
 		CPU:     fmt.Sprintf("%d Goroutines", runtime.NumGoroutine()),
-		Network: fmt.Sprintf("%d Req/min", currentRPM),
+		Network: fmt.Sprintf("%d Req/min (p95 %.0fms)", stats.RPM, stats.P95Millis),
 	}
 
 	// 2.DATABASE STATS
-	// MongoDB manages its own resources, so we mark CPU/Mem as "Managed" 
-	// but we could query serverStatus if strict stats were needed.
+	// [UPDATED] Report the measured round-trip of the ping itself rather
+	// than a hardcoded "Managed (External)" placeholder.
+	pingStart := time.Now()
 	if err := h.MongoClient.Ping(context.Background(), nil); err == nil {
 		statusMap["database"] = ComponentStatus{
-			Status:  "Online", 
-			Memory:  "Managed (External)", 
+			Status:  "Online",
+			Memory:  "Managed (External)",
 			CPU:     "Managed (External)",
-			Network: "N/A", // DB doesn't track "Req/min" in this context easily
+			Network: fmt.Sprintf("%dms ping", time.Since(pingStart).Milliseconds()),
 		}
 	} else {
 		statusMap["database"] = ComponentStatus{
-			Status: "Offline",
-			Memory: "0 MB",
-			CPU:    "0%",
+			Status:  "Offline",
+			Memory:  "0 MB",
+			CPU:     "0%",
 			Network: "0 Req/min",
 		}
 	}
@@ -57,9 +63,111 @@ func (h *APIHandler) SystemStatus(w http.ResponseWriter, r *http.Request) {
 	// 3.ML SCORER STATS
 	statusMap["ml_scorer"] = fetchRemoteHealth(h.MLURL)
 
+	// 3a. ML CIRCUIT BREAKER STATE
+	// Surfaced separately from ml_scorer (which just probes /health) since
+	// the breaker can be open even when /health looks fine again moments
+	// later, e.g. right after a burst of timeouts leaves its rolling error
+	// window still past breakerTripRate.
+	statusMap["ml_breaker"] = ComponentStatus{
+		Status:  detector.BreakerStatus(h.MLURL),
+		CPU:     "n/a",
+		Memory:  "n/a",
+		Network: "n/a",
+	}
+
+	// 3b. ML BATCHING PIPELINE
+	// batchSize is the dispatcher's current adaptive target, not a fixed
+	// config value — it shrinks/grows with the scorer's own p95 latency.
+	batchSize, queueDepth, p95 := detector.BatchStatus(h.MLURL)
+	statusMap["ml_batcher"] = ComponentStatus{
+		Status:  "Online",
+		CPU:     fmt.Sprintf("batch size %d", batchSize),
+		Memory:  fmt.Sprintf("%d queued", queueDepth),
+		Network: fmt.Sprintf("p95 %.0fms", p95),
+	}
+
+	// 3b-2. RDAP RESOLVER
+	// ProviderErrs/CacheHits only move once something actually calls
+	// h.RDAP.Lookup — VerifyDomain (POST /api/domains/verify) does, so this
+	// reports all-zero until a domain verification has run.
+	rdapStats := h.RDAP.Stats()
+	statusMap["rdap"] = ComponentStatus{
+		Status:  "Online",
+		CPU:     fmt.Sprintf("%d cache hits", rdapStats.CacheHits),
+		Memory:  fmt.Sprintf("%d cache misses", rdapStats.CacheMisses),
+		Network: fmt.Sprintf("%d provider errors", sumProviderErrs(rdapStats.ProviderErrs)),
+	}
+
+	// 3c. ACME / TLS SUBSYSTEM
+	// CertManager is nil when the account couldn't register at boot (e.g.
+	// no outbound network in local dev) — surfaced as Offline so an
+	// operator isn't left guessing why domains never get a certificate.
+	if h.CertManager != nil {
+		statusMap["acme"] = ComponentStatus{
+			Status:  "Online",
+			CPU:     "Managed (Background)",
+			Memory:  fmt.Sprintf("%d certs cached", h.CertManager.Status()),
+			Network: "Renews every 12h",
+		}
+	} else {
+		statusMap["acme"] = ComponentStatus{
+			Status:  "Offline",
+			CPU:     "0%",
+			Memory:  "0 certs cached",
+			Network: "n/a",
+		}
+	}
+
+	// 4. EMBEDDED DNS SERVER STATS (internal/dnsserver, optional)
+	// [NEW] Only present when DNSServer.Enabled started one; the external
+	// PowerDNS/Cloudflare path (internal/dnsbackend) has no live stats to
+	// report here since it isn't a process this gateway runs itself.
+	if h.DNSServer != nil {
+		dnsStats := h.DNSServer.Stats()
+		statusMap["dns_server"] = ComponentStatus{
+			Status:  "Online",
+			CPU:     "Managed (Embedded)",
+			Memory:  fmt.Sprintf("%d zones", dnsStats.ZoneCount),
+			Network: fmt.Sprintf("%d Req/min (%d total)", dnsStats.QueriesPerMin, dnsStats.TotalQueries),
+		}
+	}
+
+	// 4b. THREAT FEED (internal/decisions, optional)
+	// [NEW] Only present when Decisions.Enabled started one.
+	if h.Decisions != nil {
+		decStats := h.Decisions.Stats()
+		statusMap["decisions"] = ComponentStatus{
+			Status:  "Online",
+			CPU:     fmt.Sprintf("%d active bans", decStats.ActiveDecisions),
+			Memory:  fmt.Sprintf("last pull %s ago", decStats.LastPullAge.Round(time.Second)),
+			Network: fmt.Sprintf("%d pull errors", decStats.PullErrors),
+		}
+	}
+
 	response.JSON(w, statusMap, http.StatusOK)
 }
 
+// ReplicaStatus returns the most recent SyncStatus from the optional
+// multi-node rule/policy syncer (internal/database.Syncer) — 404 if
+// ReplicaSync.Enabled wasn't set, since there's nothing to report.
+func (h *APIHandler) ReplicaStatus(w http.ResponseWriter, r *http.Request) {
+	if h.Syncer == nil {
+		h.WriteJSONError(w, "Replica sync is not enabled", http.StatusNotFound)
+		return
+	}
+	response.JSON(w, h.Syncer.Status(), http.StatusOK)
+}
+
+// sumProviderErrs totals a Stats snapshot's per-provider error counts for
+// the single-line Network summary SystemStatus reports.
+func sumProviderErrs(errs map[rdap.Provider]int64) int64 {
+	var total int64
+	for _, n := range errs {
+		total += n
+	}
+	return total
+}
+
 // Helper to fetch rich stats from Python services
 func fetchRemoteHealth(baseURL string) ComponentStatus {
 	rootURL := baseURL
@@ -73,7 +181,10 @@ func fetchRemoteHealth(baseURL string) ComponentStatus {
 
 	healthURL := rootURL + "/health"
 	client := http.Client{Timeout: 5 * time.Second} // Increased timeout for slow model loading
+	start := time.Now()
 	resp, err := client.Get(healthURL)
+	callLatency := time.Since(start)
+	metrics.ObserveMLScorer(callLatency)
 	if err != nil {
 		// Log the error for debugging without spamming on every status check
 		// Users can check container logs if ML scorer appears offline
@@ -123,6 +234,34 @@ func fetchRemoteHealth(baseURL string) ComponentStatus {
 		Status:  status,
 		CPU:     pythonStats.CPU,
 		Memory:  pythonStats.Memory,
-		Network: pythonStats.Network,
+		Network: fmt.Sprintf("%s (%dms call)", pythonStats.Network, callLatency.Milliseconds()),
+	}
+}
+
+// RulesFingerprint exposes the current rules-cache fingerprint so external
+// controllers (an admin UI doing bulk policy edits, another node in a
+// cluster) can detect staleness before pushing a change: read this, send it
+// back as If-Match on AddCustomRule/DeleteCustomRule/ToggleRule, and a 409
+// means someone else's edit landed first.
+func (h *APIHandler) RulesFingerprint(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, map[string]interface{}{
+		"fingerprint": h.Config.Fingerprint(),
+		"version":     h.Config.Version(),
+	}, http.StatusOK)
+}
+
+// ManualReload forces an immediate ReloadRules, bypassing the change-stream
+// watcher (watch.go) — useful when change streams aren't available (e.g. a
+// standalone Mongo without a replica set/oplog) or to confirm a just-made
+// edit took effect without waiting on stream propagation.
+func (h *APIHandler) ManualReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.WriteJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
+	h.ReloadRules()
+	response.JSON(w, map[string]interface{}{
+		"fingerprint": h.Config.Fingerprint(),
+		"version":     h.Config.Version(),
+	}, http.StatusOK)
 }
\ No newline at end of file
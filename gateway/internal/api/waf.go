@@ -1,38 +1,33 @@
 package api
 
 import (
-	"bytes"
 	"io"
 	"log"
+	"mime"
 	"net"
 	"net/http"
 	"strings"
-	"sync/atomic"
+	"time"
 
 	"web-app-firewall-ml-detection/internal/detector"
 	"web-app-firewall-ml-detection/internal/logger"
+	"web-app-firewall-ml-detection/internal/metrics"
 )
 
-// Helper to extract IP
-func getRealIP(r *http.Request) string {
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
+// multipartBoundary reports whether r's body is multipart/form-data and,
+// if so, returns its boundary — so WAFHandler can route it through
+// detector.CaptureMultipart instead of treating it as an opaque blob.
+func multipartBoundary(r *http.Request) (string, bool) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return "", false
 	}
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
+	boundary, ok := params["boundary"]
+	return boundary, ok && boundary != ""
 }
 
 func (h *APIHandler) WAFHandler(w http.ResponseWriter, r *http.Request) {
-	atomic.AddUint64(&h.reqCount, 1)
-
-	clientIP := getRealIP(r)
-	bodyBytes, _ := io.ReadAll(r.Body)
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	clientIP := h.clientIP(r)
 
 	host := r.Host
 	if strings.Contains(host, ":") {
@@ -41,11 +36,29 @@ func (h *APIHandler) WAFHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// [UPDATED] Lookup Rules AND Domain Metadata (UserID/DomainID)
-	h.rulesMutex.RLock()
-	currentRules, rulesExist := h.domainRules[host]
-	domainInfo, domainExists := h.domainMap[host] // Use the new cache
-	h.rulesMutex.RUnlock()
+	// [NEW] Threat feed check, before any rule/ML scoring: a CrowdSec (or
+	// manually-added) ban on clientIP short-circuits straight to 403,
+	// skipping the cost of capturing/scoring a request we're not going to
+	// forward either way.
+	if h.Decisions != nil {
+		if decision, banned := h.Decisions.Trie().Lookup(clientIP); banned {
+			log.Printf("⛔ THREAT FEED BLOCK: %s | Reason: %s | Origin: %s", clientIP, decision.Reason, decision.Origin)
+			logger.LogAttack("", "", clientIP, r.URL.Path, decision.Reason, "Blocked", "ThreatFeed", []string{decision.Origin}, 100, 0, detector.FullRequest{}, decision.Reason)
+			w.WriteHeader(http.StatusForbidden)
+			if len(h.DecisionsBanPage) > 0 {
+				w.Write(h.DecisionsBanPage)
+			} else {
+				w.Write([]byte("Access Denied: your IP is on a threat feed block list"))
+			}
+			return
+		}
+	}
+
+	// [UPDATED] Lookup Rules AND Domain Metadata (UserID/DomainID) via a
+	// lock-free snapshot read instead of an RWMutex.
+	snap := h.Config.Snapshot()
+	currentRules, rulesExist := snap.domainRules[host]
+	domainInfo, domainExists := snap.domainMap[host]
 
 	// 1. UNCONFIGURED DOMAIN CHECK
 	if !rulesExist || !domainExists {
@@ -64,11 +77,56 @@ func (h *APIHandler) WAFHandler(w http.ResponseWriter, r *http.Request) {
 	userID := domainInfo.UserID
 	domainID := domainInfo.ID
 
+	// [UPDATED] Stream-capture the body instead of io.ReadAll: at most
+	// domainInfo's configured inspection cap ever lands in memory, no
+	// matter how large the real request turns out to be. The returned
+	// Reader reproduces the full original body (sample + whatever of
+	// r.Body wasn't read) so the proxy still forwards everything to
+	// origin.
+	maxInspect := domainInfo.EffectiveMaxInspectBytes()
+	var body detector.InspectedBody
+	var err error
+	if boundary, ok := multipartBoundary(r); ok {
+		var files []detector.FilePartDigest
+		body, files, err = detector.CaptureMultipart(r, boundary, maxInspect)
+		if len(files) > 0 {
+			log.Printf("📎 %d file part(s) on %s skipped from inspection, hashed for malware-scan hook", len(files), host)
+		}
+	} else {
+		body, err = detector.CaptureBody(r, maxInspect)
+	}
+	if err != nil {
+		log.Printf("[ERROR] WAFHandler: failed to read body for %s: %v", host, err)
+		h.WriteJSONError(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(body.Reader)
+	bodyBytes := body.Sample
+
+	// [NEW] A body that ran past the inspection cap couldn't be fully
+	// scored — honor the domain's configured oversize_action instead of
+	// silently scoring a truncated sample as if it were the whole request.
+	if body.Oversized {
+		action := domainInfo.EffectiveOversizeAction()
+		log.Printf("📦 Body on %s exceeded inspection cap (%d bytes), oversize_action=%s", host, maxInspect, action)
+		switch action {
+		case detector.OversizeBlock:
+			metrics.IncWAFBlock("oversize_body")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("WAF Blocked: body exceeds inspection limit"))
+			return
+		case detector.OversizeMonitor:
+			h.Proxy.ServeHTTP(w, r)
+			return
+		}
+		// OversizeAllow: fall through and score whatever fit under the cap.
+	}
+
 	// Rate Limiting
 	limitReached := h.RateLimiter.IsRateLimited(clientIP)
 
 	// 1. Rule Engine Check
-	ruleScore, triggeredTags, ruleBlock, rulePayload := detector.CheckRequest(r, currentRules, limitReached)
+	ruleScore, triggeredTags, ruleBlock, rulePayload := detector.CheckRequest(r, bodyBytes, currentRules, limitReached)
 
 	var isAnomaly bool
 	var confidence float64
@@ -76,7 +134,9 @@ func (h *APIHandler) WAFHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 2. ML Engine Check
 	if !ruleBlock && ruleScore < 15 {
+		mlStart := time.Now()
 		isAnomaly, confidence, mlTag, mlTrigger = detector.CheckML(r, bodyBytes, h.MLURL)
+		metrics.ObserveMLScorer(time.Since(mlStart))
 	}
 
 	// 3. Final Decision
@@ -111,13 +171,14 @@ headers := make(map[string][]string)
 	// [UPDATED] LogAttack call now includes userID and domainID
 	switch verdict {
 	case detector.Block:
-		log.Printf("⛔ BLOCKED IP: %s | Host: %s | Reason: %s", clientIP, host, reason)
+		log.Printf("⛔ BLOCKED IP: %s | Host: %s | Reason: %s | Ruleset v%d", clientIP, host, reason, snap.version)
+		metrics.IncWAFBlock(reason)
 		logger.LogAttack(userID, domainID, clientIP, r.URL.Path, reason, "Blocked", source, triggeredTags, ruleScore, confidence, fullReq, finalTrigger)
 		w.WriteHeader(http.StatusForbidden)
 		w.Write([]byte("WAF Blocked: " + reason))
 
 	case detector.Monitor:
-		log.Printf("⚠️ FLAGGED IP: %s | Host: %s", clientIP, host)
+		log.Printf("⚠️ FLAGGED IP: %s | Host: %s | Ruleset v%d", clientIP, host, snap.version)
 		logger.LogAttack(userID, domainID, clientIP, r.URL.Path, reason, "Flagged", source, triggeredTags, ruleScore, confidence, fullReq, finalTrigger)
 		h.Proxy.ServeHTTP(w, r)
 
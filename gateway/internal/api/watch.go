@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// watchedCollections feed ReloadRules: any insert/update/delete on one of
+// these can change the effective ruleset for some domain.
+var watchedCollections = []string{"rules", "rule_policies", "domains", "dns_records"}
+
+// reloadDebounce coalesces a burst of change events (e.g. a bulk policy
+// toggle touching hundreds of documents) into a single ReloadRules call
+// instead of one per event.
+const reloadDebounce = 250 * time.Millisecond
+
+// WatchRuleChanges watches rules/rule_policies/domains/dns_records via
+// MongoDB change streams and calls ReloadRules whenever any of them
+// change, so an edit made on one node is picked up by every other node
+// without waiting for that node's own mutation handler to run. ReloadRules
+// itself remains the cold-start path (called once from NewAPIHandler) and
+// the fallback if change streams are unavailable (e.g. Mongo isn't running
+// as a replica set) — in that case each collection's watcher just logs and
+// returns, and the gateway keeps serving whatever ReloadRules last computed.
+//
+// Call this in its own goroutine; it blocks until ctx is cancelled.
+func (h *APIHandler) WatchRuleChanges(ctx context.Context) {
+	reloadCh := make(chan struct{}, 1)
+
+	for _, coll := range watchedCollections {
+		go h.watchCollection(ctx, coll, reloadCh)
+	}
+
+	debounce := time.NewTimer(0)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+	pending := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reloadCh:
+			if !pending {
+				pending = true
+				debounce.Reset(reloadDebounce)
+			}
+		case <-debounce.C:
+			if pending {
+				pending = false
+				h.ReloadRules()
+			}
+		}
+	}
+}
+
+// watchCollection runs a single change-stream cursor against one
+// collection, signalling reloadCh on every event until ctx is cancelled or
+// the stream errors out (e.g. standalone Mongo without oplog support).
+func (h *APIHandler) watchCollection(ctx context.Context, collection string, reloadCh chan<- struct{}) {
+	stream, err := h.MongoClient.Database(database.DBName).Collection(collection).Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		log.Printf("[WARN] watchCollection(%s): change streams unavailable, falling back to explicit ReloadRules calls only: %v", collection, err)
+		return
+	}
+	defer stream.Close(ctx)
+
+	for stream.Next(ctx) {
+		var event bson.M
+		if err := stream.Decode(&event); err != nil {
+			continue
+		}
+		select {
+		case reloadCh <- struct{}{}:
+		default:
+		}
+	}
+}
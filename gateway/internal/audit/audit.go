@@ -0,0 +1,138 @@
+// Package audit is an append-only log of DNS record mutations — who changed
+// what, when, and whether the change actually reached the DNS backend.
+// Entries are written by internal/api's addRecord/updateRecord/deleteRecord
+// right after their Mongo write succeeds, independent of the PowerDNS
+// propagation call that follows (Result records that outcome separately),
+// so a "Mongo wrote but PowerDNS failed" case leaves a trail instead of
+// silently vanishing.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionName = "dns_audit"
+
+// Outcomes Log's Result field is set to.
+const (
+	ResultSuccess           = "success"
+	ResultPropagationFailed = "propagation_failed"
+)
+
+// Actions Log's Action field is set to. "revert" marks an entry created by
+// RevertRecord replaying the inverse of another entry, so it's visible in
+// the trail alongside the change it undid.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionRevert = "revert"
+)
+
+// Entry is one row in dns_audit.
+type Entry struct {
+	ID        string              `bson:"_id,omitempty" json:"id"`
+	Timestamp time.Time           `bson:"timestamp" json:"timestamp"`
+	UserID    string              `bson:"user_id" json:"user_id"`
+	DomainID  string              `bson:"domain_id" json:"domain_id"`
+	RecordID  string              `bson:"record_id" json:"record_id"`
+	Action    string              `bson:"action" json:"action"`
+	Before    *database.DNSRecord `bson:"before,omitempty" json:"before,omitempty"`
+	After     *database.DNSRecord `bson:"after,omitempty" json:"after,omitempty"`
+	RequestIP string              `bson:"request_ip" json:"request_ip"`
+	UserAgent string              `bson:"user_agent" json:"user_agent"`
+	Result    string              `bson:"result" json:"result"`
+}
+
+// Log appends entry to dns_audit, stamping an ID and Timestamp if unset.
+// Entries are never updated or deleted after insertion — RevertRecord logs
+// a new ActionRevert entry of its own rather than touching the one it's
+// reversing.
+func Log(client *mongo.Client, entry Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), database.TimeoutDuration)
+	defer cancel()
+
+	if entry.ID == "" {
+		entry.ID = primitive.NewObjectID().Hex()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	_, err := client.Database(database.DBName).Collection(collectionName).InsertOne(ctx, entry)
+	return err
+}
+
+// Get fetches a single entry by ID, for RevertRecord to look up the change
+// audit_id is reversing.
+func Get(client *mongo.Client, id string) (*Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), database.TimeoutDuration)
+	defer cancel()
+
+	var entry Entry
+	if err := client.Database(database.DBName).Collection(collectionName).FindOne(ctx, bson.M{"_id": id}).Decode(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// DefaultPageSize and MaxPageSize bound List's limit param the same way a
+// caller-supplied page size is bounded anywhere else paginated in this repo.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// List returns up to limit entries for domainID, newest first, optionally
+// filtered by action and/or recordID and constrained to entries at or after
+// since (a zero Time means no lower bound). cursor is the ID of the last
+// entry from a previous page (exclusive); pass "" for the first page.
+// nextCursor is "" once there are no more pages.
+func List(client *mongo.Client, domainID, action, recordID, cursor string, since time.Time, limit int) (entries []Entry, nextCursor string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), database.TimeoutDuration)
+	defer cancel()
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	filter := bson.M{"domain_id": domainID}
+	if action != "" {
+		filter["action"] = action
+	}
+	if recordID != "" {
+		filter["record_id"] = recordID
+	}
+	if !since.IsZero() {
+		filter["timestamp"] = bson.M{"$gte": since}
+	}
+	if cursor != "" {
+		filter["_id"] = bson.M{"$lt": cursor}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit))
+	cur, err := client.Database(database.DBName).Collection(collectionName).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cur.Close(ctx)
+
+	if err := cur.All(ctx, &entries); err != nil {
+		return nil, "", err
+	}
+	if len(entries) == limit {
+		nextCursor = entries[len(entries)-1].ID
+	}
+	return entries, nextCursor, nil
+}
@@ -0,0 +1,87 @@
+package certmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/core"
+	"web-app-firewall-ml-detection/internal/repository/sql"
+
+	"github.com/go-acme/lego/v4/challenge/dns01"
+)
+
+// pendingChallenge tracks what CleanUp needs to remove a TXT record Present
+// previously created, since lego only hands CleanUp the FQDN/keyAuth back.
+type pendingChallenge struct {
+	recordID string
+}
+
+// DNSProvider implements lego's challenge.Provider over the gateway's own
+// PowerDNS zone (via sql.DNSRepository), so DNS-01 can be satisfied without
+// any external DNS host or API key.
+type DNSProvider struct {
+	dnsRepo *sql.DNSRepository
+
+	mu      sync.Mutex
+	pending map[string]pendingChallenge // fqdn -> challenge bookkeeping
+}
+
+// NewDNSProvider wires the DNS-01 solver to the same sql.DNSRepository the
+// rest of the new domain-onboarding stack uses for DNS records.
+func NewDNSProvider(dnsRepo *sql.DNSRepository) *DNSProvider {
+	return &DNSProvider{
+		dnsRepo: dnsRepo,
+		pending: make(map[string]pendingChallenge),
+	}
+}
+
+// Present creates the "_acme-challenge.<domain>" TXT record that satisfies
+// the DNS-01 challenge.
+func (p *DNSProvider) Present(domainName, token, keyAuth string) error {
+	fqdn, value := dns01.GetRecord(domainName, keyAuth)
+	recordName := strings.TrimSuffix(fqdn, ".")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := p.dnsRepo.CreateRecord(ctx, core.DNSRecord{
+		Name:    recordName,
+		Type:    "TXT",
+		Content: value,
+		TTL:     120,
+	})
+	if err != nil {
+		return fmt.Errorf("certmanager: failed to insert challenge record for %s: %w", domainName, err)
+	}
+
+	p.mu.Lock()
+	p.pending[fqdn] = pendingChallenge{recordID: id}
+	p.mu.Unlock()
+
+	return nil
+}
+
+// CleanUp removes the TXT record created by Present.
+func (p *DNSProvider) CleanUp(domainName, token, keyAuth string) error {
+	fqdn, _ := dns01.GetRecord(domainName, keyAuth)
+
+	p.mu.Lock()
+	pending, ok := p.pending[fqdn]
+	delete(p.pending, fqdn)
+	p.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := p.dnsRepo.DeleteRecord(ctx, pending.recordID); err != nil {
+		return fmt.Errorf("certmanager: failed to remove challenge record for %s: %w", domainName, err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/core"
+)
+
+// CertificateStore is the in-memory, SNI-keyed cache proxy.ProxyManager's
+// tls.Config.GetCertificate reads from. It is fed by Manager: Refresh loads
+// everything persisted in CertificateRepository, and Set is the invalidation
+// hook Manager calls after every issuance/renewal so a new cert is visible
+// without a Refresh round-trip.
+type CertificateStore struct {
+	certRepo   core.CertificateRepository
+	domainRepo core.DomainRepository
+	fallback   *tls.Certificate
+
+	mu    sync.RWMutex
+	certs map[string]*tls.Certificate
+}
+
+// NewCertificateStore builds a store with a generated self-signed fallback
+// certificate, so GetCertificate always has something to hand back for a
+// recognized-but-not-yet-issued domain instead of failing the handshake.
+func NewCertificateStore(certRepo core.CertificateRepository, domainRepo core.DomainRepository) (*CertificateStore, error) {
+	fallback, err := generateSelfSigned()
+	if err != nil {
+		return nil, fmt.Errorf("certmanager: failed to generate fallback certificate: %w", err)
+	}
+
+	s := &CertificateStore{
+		certRepo:   certRepo,
+		domainRepo: domainRepo,
+		fallback:   fallback,
+		certs:      make(map[string]*tls.Certificate),
+	}
+	return s, nil
+}
+
+// Refresh reloads every persisted certificate into memory. Safe to call
+// periodically so a cert issued by another process/node becomes visible.
+func (s *CertificateStore) Refresh(ctx context.Context) error {
+	docs, err := s.certRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("certmanager: failed to load certificates: %w", err)
+	}
+
+	certs := make(map[string]*tls.Certificate, len(docs))
+	for _, d := range docs {
+		tlsCert, err := tls.X509KeyPair(d.CertPEM, d.KeyPEM)
+		if err != nil {
+			log.Printf("[ERROR] certmanager: skipping corrupt certificate for %s: %v", d.Domain, err)
+			continue
+		}
+		certs[d.Domain] = &tlsCert
+	}
+
+	s.mu.Lock()
+	s.certs = certs
+	s.mu.Unlock()
+	return nil
+}
+
+// Set installs (or replaces) the cached certificate for domain. Manager
+// calls this right after a successful issuance/renewal.
+func (s *CertificateStore) Set(domain string, cert *tls.Certificate) {
+	s.mu.Lock()
+	s.certs[domain] = cert
+	s.mu.Unlock()
+}
+
+// GetCertificate is the tls.Config.GetCertificate callback. It refuses to
+// terminate TLS for any SNI name that isn't a domain we actually manage, so
+// this never acts as an open TLS terminator for arbitrary hostnames.
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := hello.ServerName
+	if name == "" {
+		return nil, fmt.Errorf("certmanager: no SNI name presented")
+	}
+
+	domain, err := s.domainRepo.GetByName(context.Background(), name)
+	if err != nil || domain == nil {
+		return nil, fmt.Errorf("certmanager: %s is not a managed domain", name)
+	}
+
+	s.mu.RLock()
+	cert, ok := s.certs[name]
+	s.mu.RUnlock()
+	if ok {
+		return cert, nil
+	}
+
+	// Managed but not yet issued (e.g. ACME still running) — degrade to the
+	// self-signed fallback rather than failing the handshake outright.
+	return s.fallback, nil
+}
+
+// generateSelfSigned builds an ephemeral self-signed certificate used only
+// as a placeholder until a domain's real certificate is issued.
+func generateSelfSigned() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "minishield-placeholder"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
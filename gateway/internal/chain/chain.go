@@ -0,0 +1,61 @@
+// Package chain provides a small middleware-composition helper so routes in
+// cmd/server/main.go can be declared as chain.New(cors, reqID, logger).Then(handler)
+// instead of hand-nesting wrapper calls around every single handler.
+package chain
+
+import "net/http"
+
+// Middleware wraps an http.Handler with additional behavior (auth, logging,
+// rate limiting, ...). It is the same shape net/http already uses for
+// handler wrapping, so existing wrappers like CORSMiddleware or
+// api.AuthMiddleware (once adapted to take/return http.Handler) slot in
+// directly.
+type Middleware func(http.Handler) http.Handler
+
+// Chain is an ordered, immutable list of Middleware. Middlewares run in the
+// order they were supplied to New/Append: the first one is outermost, so it
+// sees the request before anything after it.
+type Chain struct {
+	middlewares []Middleware
+}
+
+// New builds a Chain from the given middlewares, outermost first.
+func New(middlewares ...Middleware) Chain {
+	c := Chain{middlewares: make([]Middleware, len(middlewares))}
+	copy(c.middlewares, middlewares)
+	return c
+}
+
+// Append returns a new Chain with additional middlewares added to the end,
+// leaving the receiver untouched so a shared base chain (e.g. the set every
+// route gets) can be reused and extended per route.
+func (c Chain) Append(middlewares ...Middleware) Chain {
+	next := make([]Middleware, 0, len(c.middlewares)+len(middlewares))
+	next = append(next, c.middlewares...)
+	next = append(next, middlewares...)
+	return Chain{middlewares: next}
+}
+
+// Then wraps final with every middleware in the chain, outermost first, and
+// returns the composed http.Handler.
+func (c Chain) Then(final http.Handler) http.Handler {
+	h := final
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+	return h
+}
+
+// ThenFunc is Then for a plain handler function.
+func (c Chain) ThenFunc(final http.HandlerFunc) http.Handler {
+	return c.Then(final)
+}
+
+// Adapt lifts an http.HandlerFunc-wrapping middleware (the shape
+// api.AuthMiddleware and APIHandler.RequireRole already use) into a
+// Middleware, so those wrappers can be composed into a Chain unchanged.
+func Adapt(mw func(http.HandlerFunc) http.HandlerFunc) Middleware {
+	return func(next http.Handler) http.Handler {
+		return mw(next.ServeHTTP)
+	}
+}
@@ -0,0 +1,188 @@
+package chain
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/limiter"
+	"web-app-firewall-ml-detection/internal/logger"
+	"web-app-firewall-ml-detection/internal/netutil"
+)
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// RequestIDHeader is set on every response so it can be correlated with
+// client-side logs or support tickets.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID stamps every request with a short random ID, available to
+// downstream handlers via RequestIDFromContext and echoed back on the
+// response so it can be grepped out of client-side logs.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := randomID()
+		if err != nil {
+			id = "unknown"
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the ID stamped by RequestID, or "" if the
+// request never passed through it.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func randomID() (string, error) {
+	buf := make([]byte, 9)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Recover turns a panic anywhere downstream into a 500 instead of taking the
+// whole server down, and logs it with the request ID so it can be traced.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Printf("🔥 PANIC [%s] %s %s: %v", RequestIDFromContext(r.Context()), r.Method, r.URL.Path, rec)
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// gzipResponseWriter wraps http.ResponseWriter so Write() transparently
+// compresses the body once the client has been confirmed to accept it.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	return g.gz.Write(b)
+}
+
+// Gzip compresses the response body when the client sent
+// "Accept-Encoding: gzip", mirroring the standard net/http/httputil pattern.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+
+		next.ServeHTTP(&gzipResponseWriter{ResponseWriter: w, gz: gz}, r)
+	})
+}
+
+// statusRecorder captures the status code a handler wrote so AccessLog can
+// report it after the fact (http.ResponseWriter doesn't expose it directly).
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// AccessLog logs every request (method, path, status, latency, request ID)
+// and mirrors a lightweight entry onto the existing SSE log broadcast
+// channel so the dashboard's live log view isn't limited to WAF verdicts.
+// trusted resolves the logged IP through the same trusted-proxy chain as
+// everywhere else, so the access log can't be poisoned with a spoofed
+// X-Forwarded-For either.
+func AccessLog(trusted netutil.TrustedProxyConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(rec, r)
+
+			duration := time.Since(start)
+			log.Printf("📜 [%s] %s %s -> %d (%s)", RequestIDFromContext(r.Context()), r.Method, r.URL.Path, rec.status, duration)
+
+			logger.Publish(detector.AttackLog{
+				Timestamp:   start,
+				IP:          netutil.RealIP(r, trusted),
+				RequestPath: r.URL.Path,
+				Action:      "Access",
+				Source:      "access-log",
+				Request: detector.FullRequest{
+					Method: r.Method,
+					URL:    r.URL.String(),
+				},
+			})
+		})
+	}
+}
+
+// RateLimit throttles a single route by client IP, independent of every
+// other route's budget — unlike the single global RateLimiter each route
+// handler used to share. route is only used to label the 429 log line.
+// trusted resolves ip the same way every other IP-based decision in the
+// gateway does, so a spoofed X-Forwarded-For can't be used to dodge the
+// limiter.
+func RateLimit(rl *limiter.RateLimiter, route string, trusted netutil.TrustedProxyConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := netutil.RealIP(r, trusted)
+			if rl.IsRateLimited(ip) {
+				log.Printf("⚠️ Rate limited %s on %s", ip, route)
+				if retryAfter := rl.RetryAfter(ip); retryAfter > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte("Too Many Requests"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimitByUser throttles a route by the authenticated user_id rather
+// than client IP, with separate token-bucket budgets for read (GET/HEAD)
+// and write requests — must sit inside Adapt(api.AuthMiddleware), which
+// populates "user_id" in the context. route is only used to label the 429
+// log line.
+func RateLimitByUser(rl *limiter.UserRateLimiter, route string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value("user_id").(string)
+			if userID != "" && !rl.Allow(userID, r.Method) {
+				log.Printf("⚠️ Rate limited user %s on %s", userID, route)
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte("Too Many Requests"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
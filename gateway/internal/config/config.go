@@ -1,62 +1,497 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// Config is every tunable the gateway used to read once via inline getEnv
+// calls in main.go, now centralized behind a single layered loader
+// (defaults < YAML file < env) so every caller agrees on one source of
+// truth. Fields tagged `validate` are enforced by Validate.
 type Config struct {
-	AppEnv         string
-	Port           string
-	MongoURI       string
-	FrontendURL    string
-	AllowedOrigins []string
+	AppEnv         string   `yaml:"app_env" validate:"required"`
+	Port           string   `yaml:"port" validate:"required"`
+	MongoURI       string   `yaml:"mongo_uri" validate:"required"`
+	FrontendURL    string   `yaml:"frontend_url" validate:"required"`
+	AllowedOrigins []string `yaml:"-"`
 
 	// WAF Settings
-	OriginURL   string
-	MLURL       string
-	WafPublicIP string
+	OriginURL   string `yaml:"origin_url" validate:"required"`
+	MLURL       string `yaml:"ml_url" validate:"required"`
+	WafPublicIP string `yaml:"waf_public_ip" validate:"required"`
 
 	// DNS DB
-	DNSUser string
-	DNSPass string
-	DNSHost string
-	DNSName string
+	DNSUser string `yaml:"dns_user"`
+	DNSPass string `yaml:"dns_pass"`
+	DNSHost string `yaml:"dns_host"`
+	DNSName string `yaml:"dns_name"`
 
 	// Security
-	JWTSecret string
+	JWTSecret string `yaml:"jwt_secret" validate:"required,min=16"`
+
+	// [NEW] Rate limiting. Unlike everything above, these two are read
+	// from the live *Store on every reload (see Store.Watch), not just at
+	// boot — a threshold change takes effect without a restart.
+	RateLimitRPM     int `yaml:"rate_limit_rpm" validate:"min=1"`
+	AuthRateLimitRPM int `yaml:"auth_rate_limit_rpm" validate:"min=1"`
+
+	// [NEW] Overrides database.MaxRecordsPerUser at boot — how many DNS
+	// records a single user may own at once across the shared
+	// PowerDNS/Cloudflare/Route53 backend.
+	MaxUserRecords int `yaml:"max_user_records" validate:"min=1"`
+
+	// [NEW] Overrides database.MaxRecordsPerDomain/MaxDomainsPerUser at
+	// boot — the per-zone record cap and the per-user domain cap.
+	MaxRecordsPerDomain int `yaml:"max_records_per_domain" validate:"min=1"`
+	MaxDomainsPerUser   int `yaml:"max_domains_per_user" validate:"min=1"`
+
+	// [NEW] Keycloak-style OIDC realms available for SSO, in addition to
+	// the password flow and the plain OAuth providers in
+	// config/oauth_providers.json. An empty list just leaves OIDC login
+	// unavailable, same as a missing oauth_providers.json.
+	OIDCProviders []OIDCProvider `yaml:"oidc_providers"`
+
+	// [NEW] External issuers the management API accepts
+	// "Authorization: Bearer <jwt>" tokens from, so CI pipelines/service
+	// accounts/federated tenants can call the API without a local
+	// auth_token cookie. An empty list leaves Bearer-token auth
+	// unavailable; the cookie flow is unaffected either way.
+	TrustedIssuers []TrustedIssuer `yaml:"trusted_issuers"`
+
+	// [NEW] Lets an operator offload authoritative DNS to Cloudflare
+	// instead of our own PowerDNS/MySQL instance (internal/dnsbackend). A
+	// zero-value Cloudflare (empty APIToken) leaves the gateway on the
+	// PowerDNS provider, same as before dnsbackend existed.
+	Cloudflare CloudflareConfig `yaml:"cloudflare"`
+
+	// [NEW] Same idea as Cloudflare, for operators who host authoritative
+	// DNS on Route 53 instead. A zero-value Route53 (empty AccessKeyID)
+	// leaves the gateway on whichever of PowerDNS/Cloudflare it'd otherwise
+	// pick.
+	Route53 Route53Config `yaml:"route53"`
+
+	// [NEW] Lets an operator drive our own PowerDNS instance through its
+	// REST API instead of writing straight into its MySQL backend — the
+	// auth server picks up changes immediately, no manual pdns_control
+	// notify needed. A zero-value PowerDNSAPI (empty URL) leaves the
+	// gateway on the SQL provider.
+	PowerDNSAPI PowerDNSAPIConfig `yaml:"powerdns_api"`
+
+	// [NEW] Explicitly selects which internal/dnsbackend.Provider to
+	// construct: "powerdns_sql", "powerdns_api", "cloudflare", or
+	// "route53". Empty falls back to the legacy credential-presence
+	// chain (Cloudflare token set > Route53 keys set > PowerDNS SQL), so
+	// existing deployments that never set this keep working unchanged.
+	DNSProvider string `yaml:"dns_provider"`
+
+	// [NEW] Optional in-process authoritative DNS server (internal/dnsserver),
+	// an alternative to running PowerDNS/MySQL externally. Disabled by
+	// default so existing deployments aren't suddenly asked to bind :53.
+	DNSServer DNSServerConfig `yaml:"dns_server"`
+
+	// [NEW] Optional multi-node WAF rule/policy sync (internal/database.Syncer),
+	// for horizontally-scaled deployments that want more than one gateway
+	// sharing the same rules/policies/domains/dns_records without pointing
+	// every node at one MongoDB. Disabled by default — a single-node
+	// deployment (the common case) never needs it.
+	ReplicaSync ReplicaSyncConfig `yaml:"replica_sync"`
+
+	// [NEW] External Account Binding credentials for internal/acme, needed
+	// by private/enterprise CAs that require a pre-provisioned account
+	// rather than open registration. Both empty (the default) leaves
+	// acme.Manager registering the way Let's Encrypt itself expects.
+	ACME ACMEConfig `yaml:"acme"`
+
+	// [NEW] Backs internal/limiter.RateLimiter with Redis instead of its
+	// in-process MemoryStore, so every gateway node shares the same
+	// sliding-window counters. A zero-value Redis (empty Addr) leaves the
+	// gateway on MemoryStore, same as before RedisStore existed.
+	Redis RedisConfig `yaml:"redis"`
+
+	// [NEW] CrowdSec-compatible threat feed (internal/decisions). Disabled
+	// by default — WAFHandler skips the Decisions check entirely rather
+	// than consulting an empty trie.
+	Decisions DecisionsConfig `yaml:"decisions"`
+
+	// ThreatIntel configures internal/service/threatintel.Feed, the
+	// orphaned WAFService lane's equivalent of Decisions above. Disabled
+	// by default for the same reason.
+	ThreatIntel ThreatIntelConfig `yaml:"threat_intel"`
+
+	// [NEW] Proxies/load balancers allowed to set X-Forwarded-For/Forwarded,
+	// as a comma-separated CIDR (or bare IP) list, e.g.
+	// "10.0.0.0/8,173.245.48.0/20". Empty means no hop is trusted, so
+	// netutil.RealIP falls back to r.RemoteAddr — the safe default for a
+	// gateway that isn't actually behind anything.
+	TrustedProxies   string   `yaml:"trusted_proxies"`
+	TrustedProxyList []string `yaml:"-"`
+
+	// [NEW] A single-value header (e.g. "CF-Connecting-IP",
+	// "True-Client-IP") netutil.RealIP reads instead of walking
+	// X-Forwarded-For, but only when the immediate peer is itself in
+	// TrustedProxies. Empty disables this shortcut.
+	ClientIPHeader string `yaml:"client_ip_header"`
+
+	// [NEW] Tunes logger.Sink, the batched/WAL-backed writer behind
+	// logger.LogAttack. Defaults are sane for a single-node deployment;
+	// WALDir only needs to change if /var/lib isn't writable/persistent
+	// in a given environment.
+	LogSink LogSinkConfig `yaml:"log_sink"`
 }
 
-func Load() *Config {
-	appEnv := getEnv("APP_ENV", "development")
-	
-	// Base allowed origins from Env
-	frontendURL := getEnv("FRONTEND_URL", "https://www.minishield.tech")
-	origins := strings.Split(frontendURL, ",")
+// ReplicaSyncConfig configures internal/database.Syncer. Replicas maps a
+// replica name (the key GET /api/replica/status reports it under) to its
+// mongo URI.
+type ReplicaSyncConfig struct {
+	Enabled bool `yaml:"enabled"`
 
-	// Automatically allow localhost:3000 in development
-	if appEnv == "development" {
-		origins = append(origins, "http://localhost:3000")
-	}
+	// Cron is a 5-field cron expression; only the "*/N * * * *" shape
+	// (every N minutes) is understood today — anything else falls back to
+	// database.DefaultSyncInterval. Computed into Interval by Load, the
+	// same relationship Recursors has to RecursorList above.
+	Cron     string        `yaml:"cron"`
+	Interval time.Duration `yaml:"-"`
+
+	Replicas map[string]string `yaml:"replicas"`
+
+	// Collections defaults to database.DefaultSyncCollections when empty —
+	// e.g. set to ["rules", "rule_policies"] to mirror WAF config without
+	// replicating dns_records too.
+	Collections []string `yaml:"collections"`
+
+	// ProtectedFields lets a replica keep its own value for a field
+	// instead of the primary's, e.g. {"dns_records": ["origin_ssl"]}.
+	ProtectedFields map[string][]string `yaml:"protected_fields"`
+}
+
+// DNSServerConfig configures internal/dnsserver.Server. Recursors is kept
+// as the raw comma-separated string (same idea as Config.FrontendURL vs.
+// AllowedOrigins) and split into RecursorList by Load.
+type DNSServerConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Addr      string `yaml:"addr"`
+	Recursors string `yaml:"recursors"`
+
+	// RecursorList is Recursors split on commas and trimmed; computed by
+	// Load, not read from YAML/env directly.
+	RecursorList []string `yaml:"-"`
+}
+
+// CloudflareConfig is the one Cloudflare account internal/dnsbackend can
+// publish records to. ZoneIDs maps a zone this gateway manages (e.g.
+// "example.com") to the Cloudflare zone id that owns it, since the
+// Cloudflare REST API addresses zones by id rather than name.
+type CloudflareConfig struct {
+	APIToken string            `yaml:"api_token"`
+	ZoneIDs  map[string]string `yaml:"zone_ids"`
+}
+
+// Route53Config is the one AWS account internal/dnsbackend can publish
+// records to. HostedZoneIDs maps a zone this gateway manages (e.g.
+// "example.com") to the Route53 hosted zone id that owns it, the same
+// relationship CloudflareConfig.ZoneIDs has to Cloudflare zone ids.
+type Route53Config struct {
+	AccessKeyID     string            `yaml:"access_key_id"`
+	SecretAccessKey string            `yaml:"secret_access_key"`
+	HostedZoneIDs   map[string]string `yaml:"hosted_zone_ids"`
+}
+
+// ACMEConfig configures internal/acme.Manager's registration and directory
+// selection. Email is the contact address registered with the CA;
+// DirectoryURL overrides which ACME server to register against entirely
+// (a private/enterprise CA), and otherwise Staging picks Let's Encrypt's
+// staging directory (unlimited but untrusted certs, for exercising
+// issuance/renewal without burning the production rate limit) over the
+// real production one. EABKeyID/EABHMACKey are both required to enable
+// External Account Binding; either empty falls back to plain registration.
+type ACMEConfig struct {
+	Email        string `yaml:"email"`
+	DirectoryURL string `yaml:"directory_url"`
+	Staging      bool   `yaml:"staging"`
+	EABKeyID     string `yaml:"eab_key_id"`
+	EABHMACKey   string `yaml:"eab_hmac_key"`
+}
+
+// PowerDNSAPIConfig is the REST endpoint of our own PowerDNS instance, for
+// the "powerdns_api" internal/dnsbackend.Provider.
+type PowerDNSAPIConfig struct {
+	URL    string `yaml:"url"`
+	APIKey string `yaml:"api_key"`
+}
+
+// RedisConfig is the one Redis instance internal/limiter.RateLimiter
+// shares its sliding-window counters against. Addr is "host:port"; an
+// empty Addr is the signal api.APIHandler uses to fall back to
+// limiter.MemoryStore instead of dialing Redis at all.
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+// DecisionsConfig configures internal/decisions.Feed. Enabled false (the
+// default) leaves api.APIHandler.Decisions nil, so WAFHandler never
+// consults it.
+type DecisionsConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// LAPIURL is the CrowdSec Local API base, e.g. "http://crowdsec:8080".
+	LAPIURL string `yaml:"lapi_url"`
+	APIKey  string `yaml:"api_key"`
+
+	// PollIntervalSeconds is how often Feed re-pulls the decisions stream
+	// after its initial startup=true pull.
+	PollIntervalSeconds int `yaml:"poll_interval_seconds"`
+
+	// GeoIPPath is an optional MaxMind GeoLite2-Country mmdb file on disk;
+	// empty leaves Decision.Country unresolved for every ban.
+	GeoIPPath string `yaml:"geoip_path"`
+
+	// BanPagePath is an optional HTML file served instead of the generic
+	// "Access Denied" body when WAFHandler blocks on a Decisions hit.
+	BanPagePath string `yaml:"ban_page_path"`
+}
+
+// ThreatIntelConfig configures internal/service/threatintel.Feed, the
+// community blocklist service.WAFService.CheckRequest consults before rule
+// evaluation. Enabled false (the default) leaves it nil, the same way
+// DecisionsConfig gates internal/decisions.Feed in the other lane.
+type ThreatIntelConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// FeedURL is the HTTP JSON endpoint returning {scope, value, type,
+	// duration, scenario} entries this gateway pulls bans from.
+	FeedURL string `yaml:"feed_url"`
+	APIKey  string `yaml:"api_key"`
+
+	// PollIntervalSeconds is how often Feed re-pulls FeedURL.
+	PollIntervalSeconds int `yaml:"poll_interval_seconds"`
 
+	// PushURL is where locally-generated blocks (repeated high-RuleScore
+	// hits from one IP) get pushed back out to, for federated defense.
+	// Empty disables the reverse stream.
+	PushURL string `yaml:"push_url"`
+}
+
+// LogSinkConfig configures logger.Sink, the batched Mongo writer behind
+// LogAttack. The zero value (Load's defaults below) is what every
+// deployment should run with; the knobs exist for tuning flush behavior
+// under unusual load rather than needing to be set per-environment.
+type LogSinkConfig struct {
+	// BatchSize is how many buffered entries trigger an immediate
+	// InsertMany flush, without waiting for FlushIntervalMillis.
+	BatchSize int `yaml:"batch_size"`
+
+	// FlushIntervalMillis is the maximum time a partial batch waits
+	// before being flushed anyway.
+	FlushIntervalMillis int `yaml:"flush_interval_millis"`
+
+	// QueueSize bounds LogAttack's buffered channel; once full, new
+	// entries are dropped (counted in Sink.Stats().Dropped) rather than
+	// blocking the request that generated them.
+	QueueSize int `yaml:"queue_size"`
+
+	// WALDir is where the write-ahead log's append-only JSON-lines files
+	// live, replayed into Mongo on startup and written to whenever Mongo
+	// is unreachable or the in-memory queue is full.
+	WALDir string `yaml:"wal_dir"`
+
+	// BreakerThreshold is how many consecutive flush failures trip the
+	// circuit breaker; BreakerBackoffSeconds is how long it then skips
+	// Mongo inserts (falling back to the WAL) before trying again.
+	BreakerThreshold      int `yaml:"breaker_threshold"`
+	BreakerBackoffSeconds int `yaml:"breaker_backoff_seconds"`
+
+	// SSEReplayBufferSize bounds logger's in-memory ring buffer of recent
+	// events, which a reconnecting SSE client's Last-Event-ID replays
+	// from. Raising it lets a client survive a longer disconnect without
+	// gaps, at the cost of holding that many more events in memory.
+	SSEReplayBufferSize int `yaml:"sse_replay_buffer_size"`
+}
+
+// TrustedIssuer is one external JWT issuer whose tokens AuthMiddleware
+// will accept, verified against its own JWKS rather than our local HS256
+// secret.
+type TrustedIssuer struct {
+	Issuer   string `yaml:"issuer"`
+	JWKSURL  string `yaml:"jwks_url"`
+	Audience string `yaml:"audience"`
+
+	// AutoProvision allows a first-seen subject with an `email` claim to
+	// be auto-provisioned as a new detector.User, the same way a first
+	// OAuth/OIDC login is. false means only subjects already linked to an
+	// existing user may authenticate this way.
+	AutoProvision bool `yaml:"auto_provision"`
+}
+
+// OIDCProvider is one Keycloak-style realm the gateway is registered with.
+type OIDCProvider struct {
+	Name         string `yaml:"name"`
+	IssuerURL    string `yaml:"issuer_url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	RedirectURL  string `yaml:"redirect_url"`
+}
+
+// defaults mirrors the hardcoded fallbacks main.go's inline getEnv calls
+// used to carry, now the single baseline every layer builds on.
+func defaults() *Config {
 	return &Config{
-		AppEnv:         appEnv,
-		Port:           getEnv("PORT", "443"),
-		MongoURI:       getEnv("MONGO_URI", "mongodb://mongo:27017"),
-		FrontendURL:    frontendURL,
-		AllowedOrigins: origins,
+		AppEnv:      "development",
+		Port:        "443",
+		MongoURI:    "mongodb://mongo:27017",
+		FrontendURL: "https://www.minishield.tech",
+
+		OriginURL:   "http://origin:3000",
+		MLURL:       "http://ml_scorer:8000/predict",
+		WafPublicIP: "157.245.100.147",
+
+		DNSUser: "pdns",
+		DNSPass: "pdns_password",
+		DNSHost: "dns_sql_db",
+		DNSName: "powerdns",
 
-		OriginURL:   getEnv("ORIGIN_URL", "http://origin:3000"),
-		MLURL:       getEnv("ML_URL", "http://ml_scorer:8000/predict"),
-		WafPublicIP: getEnv("WAF_PUBLIC_IP", "157.245.100.147"),
+		JWTSecret: "super_secret_waf_key_change_me",
 
-		DNSUser: getEnv("DNS_DB_USER", "pdns"),
-		DNSPass: getEnv("DNS_DB_PASS", "pdns_password"),
-		DNSHost: getEnv("DNS_DB_HOST", "dns_sql_db"),
-		DNSName: getEnv("DNS_DB_NAME", "powerdns"),
+		RateLimitRPM:        100,
+		AuthRateLimitRPM:    20,
+		MaxUserRecords:      65,
+		MaxRecordsPerDomain: 100,
+		MaxDomainsPerUser:   10,
 
-		JWTSecret: getEnv("JWT_SECRET", "super_secret_waf_key_change_me"),
+		DNSServer: DNSServerConfig{
+			Enabled: false,
+			Addr:    ":53",
+		},
+
+		ReplicaSync: ReplicaSyncConfig{
+			Enabled: false,
+			Cron:    "*/5 * * * *",
+		},
+
+		Decisions: DecisionsConfig{
+			Enabled:             false,
+			PollIntervalSeconds: 15,
+		},
+
+		ThreatIntel: ThreatIntelConfig{
+			Enabled:             false,
+			PollIntervalSeconds: 30,
+		},
+
+		LogSink: LogSinkConfig{
+			BatchSize:             200,
+			FlushIntervalMillis:   500,
+			QueueSize:             5000,
+			WALDir:                "data/log_wal",
+			BreakerThreshold:      5,
+			BreakerBackoffSeconds: 30,
+			SSEReplayBufferSize:   1024,
+		},
+	}
+}
+
+// loadFile merges an optional YAML file onto cfg. A missing path or file is
+// not an error — the file is an optional layer between defaults and env,
+// not a requirement for every deployment.
+func loadFile(cfg *Config, path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
 	}
+	return yaml.Unmarshal(data, cfg)
+}
+
+// applyEnv gives environment variables the final word, same precedence the
+// old inline getEnv(key, fallback) calls gave them over any hardcoded
+// default.
+func applyEnv(cfg *Config) {
+	cfg.AppEnv = getEnv("APP_ENV", cfg.AppEnv)
+	cfg.Port = getEnv("PORT", cfg.Port)
+	cfg.MongoURI = getEnv("MONGO_URI", cfg.MongoURI)
+	cfg.FrontendURL = getEnv("FRONTEND_URL", cfg.FrontendURL)
+
+	cfg.OriginURL = getEnv("ORIGIN_URL", cfg.OriginURL)
+	cfg.MLURL = getEnv("ML_URL", cfg.MLURL)
+	cfg.WafPublicIP = getEnv("WAF_PUBLIC_IP", cfg.WafPublicIP)
+
+	cfg.DNSUser = getEnv("DNS_DB_USER", cfg.DNSUser)
+	cfg.DNSPass = getEnv("DNS_DB_PASS", cfg.DNSPass)
+	cfg.DNSHost = getEnv("DNS_DB_HOST", cfg.DNSHost)
+	cfg.DNSName = getEnv("DNS_DB_NAME", cfg.DNSName)
+
+	cfg.JWTSecret = getEnv("JWT_SECRET", cfg.JWTSecret)
+
+	cfg.RateLimitRPM = getEnvInt("RATE_LIMIT_RPM", cfg.RateLimitRPM)
+	cfg.AuthRateLimitRPM = getEnvInt("AUTH_RATE_LIMIT_RPM", cfg.AuthRateLimitRPM)
+	cfg.MaxUserRecords = getEnvInt("MAX_USER_RECORDS", cfg.MaxUserRecords)
+	cfg.MaxRecordsPerDomain = getEnvInt("MAX_RECORDS_PER_DOMAIN", cfg.MaxRecordsPerDomain)
+	cfg.MaxDomainsPerUser = getEnvInt("MAX_DOMAINS_PER_USER", cfg.MaxDomainsPerUser)
+
+	cfg.Cloudflare.APIToken = getEnv("CLOUDFLARE_API_TOKEN", cfg.Cloudflare.APIToken)
+
+	cfg.Route53.AccessKeyID = getEnv("ROUTE53_ACCESS_KEY_ID", cfg.Route53.AccessKeyID)
+	cfg.Route53.SecretAccessKey = getEnv("ROUTE53_SECRET_ACCESS_KEY", cfg.Route53.SecretAccessKey)
+
+	cfg.DNSServer.Enabled = getEnvBool("DNS_SERVER_ENABLED", cfg.DNSServer.Enabled)
+	cfg.DNSServer.Addr = getEnv("DNS_SERVER_ADDR", cfg.DNSServer.Addr)
+	// e.g. DNS_RECURSORS="1.1.1.1:53,1.0.0.1:53" — empty leaves foreign-zone
+	// queries REFUSED instead of forwarded.
+	cfg.DNSServer.Recursors = getEnv("DNS_RECURSORS", cfg.DNSServer.Recursors)
+
+	cfg.ReplicaSync.Enabled = getEnvBool("REPLICA_SYNC_ENABLED", cfg.ReplicaSync.Enabled)
+	cfg.ReplicaSync.Cron = getEnv("REPLICA_SYNC_CRON", cfg.ReplicaSync.Cron)
+
+	cfg.ThreatIntel.Enabled = getEnvBool("TI_ENABLED", cfg.ThreatIntel.Enabled)
+	cfg.ThreatIntel.FeedURL = getEnv("TI_FEED_URL", cfg.ThreatIntel.FeedURL)
+	cfg.ThreatIntel.APIKey = getEnv("TI_API_KEY", cfg.ThreatIntel.APIKey)
+	cfg.ThreatIntel.PollIntervalSeconds = getEnvInt("TI_POLL_INTERVAL", cfg.ThreatIntel.PollIntervalSeconds)
+	cfg.ThreatIntel.PushURL = getEnv("TI_PUSH_URL", cfg.ThreatIntel.PushURL)
+
+	cfg.ACME.Email = getEnv("ACME_EMAIL", cfg.ACME.Email)
+	cfg.ACME.DirectoryURL = getEnv("ACME_DIRECTORY_URL", cfg.ACME.DirectoryURL)
+	cfg.ACME.Staging = getEnvBool("ACME_STAGING", cfg.ACME.Staging)
+	cfg.ACME.EABKeyID = getEnv("ACME_EAB_KEY_ID", cfg.ACME.EABKeyID)
+	cfg.ACME.EABHMACKey = getEnv("ACME_EAB_HMAC_KEY", cfg.ACME.EABHMACKey)
+
+	cfg.PowerDNSAPI.URL = getEnv("POWERDNS_API_URL", cfg.PowerDNSAPI.URL)
+	cfg.PowerDNSAPI.APIKey = getEnv("POWERDNS_API_KEY", cfg.PowerDNSAPI.APIKey)
+	cfg.DNSProvider = getEnv("DNS_PROVIDER", cfg.DNSProvider)
+
+	cfg.Redis.Addr = getEnv("REDIS_ADDR", cfg.Redis.Addr)
+	cfg.Redis.Password = getEnv("REDIS_PASSWORD", cfg.Redis.Password)
+	cfg.Redis.DB = getEnvInt("REDIS_DB", cfg.Redis.DB)
+
+	cfg.Decisions.Enabled = getEnvBool("DECISIONS_ENABLED", cfg.Decisions.Enabled)
+	cfg.Decisions.LAPIURL = getEnv("DECISIONS_LAPI_URL", cfg.Decisions.LAPIURL)
+	cfg.Decisions.APIKey = getEnv("DECISIONS_API_KEY", cfg.Decisions.APIKey)
+	cfg.Decisions.PollIntervalSeconds = getEnvInt("DECISIONS_POLL_INTERVAL_SECONDS", cfg.Decisions.PollIntervalSeconds)
+	cfg.Decisions.GeoIPPath = getEnv("DECISIONS_GEOIP_PATH", cfg.Decisions.GeoIPPath)
+	cfg.Decisions.BanPagePath = getEnv("DECISIONS_BAN_PAGE_PATH", cfg.Decisions.BanPagePath)
+
+	cfg.TrustedProxies = getEnv("TRUSTED_PROXIES", cfg.TrustedProxies)
+	cfg.ClientIPHeader = getEnv("CLIENT_IP_HEADER", cfg.ClientIPHeader)
+
+	cfg.LogSink.BatchSize = getEnvInt("LOG_SINK_BATCH_SIZE", cfg.LogSink.BatchSize)
+	cfg.LogSink.FlushIntervalMillis = getEnvInt("LOG_SINK_FLUSH_INTERVAL_MILLIS", cfg.LogSink.FlushIntervalMillis)
+	cfg.LogSink.QueueSize = getEnvInt("LOG_SINK_QUEUE_SIZE", cfg.LogSink.QueueSize)
+	cfg.LogSink.WALDir = getEnv("LOG_SINK_WAL_DIR", cfg.LogSink.WALDir)
+	cfg.LogSink.BreakerThreshold = getEnvInt("LOG_SINK_BREAKER_THRESHOLD", cfg.LogSink.BreakerThreshold)
+	cfg.LogSink.BreakerBackoffSeconds = getEnvInt("LOG_SINK_BREAKER_BACKOFF_SECONDS", cfg.LogSink.BreakerBackoffSeconds)
+	cfg.LogSink.SSEReplayBufferSize = getEnvInt("LOG_SINK_SSE_REPLAY_BUFFER_SIZE", cfg.LogSink.SSEReplayBufferSize)
 }
 
 func getEnv(key, fallback string) string {
@@ -64,4 +499,149 @@ func getEnv(key, fallback string) string {
 		return value
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback
+	}
+	return b
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+func computeAllowedOrigins(cfg *Config) []string {
+	origins := strings.Split(cfg.FrontendURL, ",")
+	// Automatically allow localhost:3000 in development
+	if cfg.AppEnv == "development" {
+		origins = append(origins, "http://localhost:3000")
+	}
+	return origins
+}
+
+// splitCommaList splits raw on commas, trimming whitespace and dropping
+// empty entries — the shape every comma-separated string field on Config
+// (DNSServer.Recursors, TrustedProxies) gets split into its list form.
+func splitCommaList(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// computeSyncInterval parses the "*/N * * * *" shape of a 5-field cron
+// expression into an every-N-minutes interval — the only shape
+// ReplicaSyncConfig.Cron needs to support today (its default is
+// "*/5 * * * *"). Anything else, including a real cron schedule with
+// distinct hour/day fields, falls back to database.DefaultSyncInterval
+// rather than silently running every minute or not at all.
+func computeSyncInterval(cron string) time.Duration {
+	fields := strings.Fields(cron)
+	if len(fields) != 5 {
+		return defaultSyncIntervalFallback
+	}
+	minuteField := fields[0]
+	if !strings.HasPrefix(minuteField, "*/") {
+		return defaultSyncIntervalFallback
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(minuteField, "*/"))
+	if err != nil || n <= 0 {
+		return defaultSyncIntervalFallback
+	}
+	return time.Duration(n) * time.Minute
+}
+
+// defaultSyncIntervalFallback mirrors database.DefaultSyncInterval without
+// importing internal/database here just for one constant.
+const defaultSyncIntervalFallback = 5 * time.Minute
+
+// Load builds a Config by layering defaults, the optional YAML file at
+// path, then env vars, and validates the result. path may be "" to skip
+// the file layer entirely (env + defaults only).
+func Load(path string) (*Config, error) {
+	cfg := defaults()
+
+	if err := loadFile(cfg, path); err != nil {
+		return nil, fmt.Errorf("config: failed to read %s: %w", path, err)
+	}
+
+	applyEnv(cfg)
+	cfg.AllowedOrigins = computeAllowedOrigins(cfg)
+	cfg.DNSServer.RecursorList = splitCommaList(cfg.DNSServer.Recursors)
+	cfg.ReplicaSync.Interval = computeSyncInterval(cfg.ReplicaSync.Cron)
+	cfg.TrustedProxyList = splitCommaList(cfg.TrustedProxies)
+
+	if err := Validate(cfg); err != nil {
+		return nil, fmt.Errorf("config: invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate enforces the `validate:"required"`/`validate:"min=N"` struct
+// tags above, so a bad deploy fails at startup (or under `config validate`)
+// instead of the gateway quietly running with an empty JWT secret.
+func Validate(cfg *Config) error {
+	v := reflect.ValueOf(*cfg)
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			if err := checkRule(field.Name, v.Field(i), rule); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkRule(name string, value reflect.Value, rule string) error {
+	switch {
+	case rule == "required":
+		if value.Kind() == reflect.String && value.String() == "" {
+			return fmt.Errorf("%s is required", name)
+		}
+	case strings.HasPrefix(rule, "min="):
+		min, err := strconv.Atoi(strings.TrimPrefix(rule, "min="))
+		if err != nil {
+			return nil
+		}
+		switch value.Kind() {
+		case reflect.String:
+			if len(value.String()) < min {
+				return fmt.Errorf("%s must be at least %d characters", name, min)
+			}
+		case reflect.Int:
+			if int(value.Int()) < min {
+				return fmt.Errorf("%s must be >= %d", name, min)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"github.com/fsnotify/fsnotify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const overridesCollection = "config_overrides"
+
+// Store holds the gateway's live Config behind an atomic pointer so every
+// consumer (CORSMiddleware, the per-route rate limiters, ...) reads
+// whatever was last published instead of a value captured once at startup.
+type Store struct {
+	current atomic.Pointer[Config]
+
+	path     string
+	client   *mongo.Client
+	onReload func(*Config)
+}
+
+// NewStore wraps an already-loaded Config for live reads and, once Watch
+// is called, live updates.
+func NewStore(initial *Config) *Store {
+	s := &Store{}
+	s.current.Store(initial)
+	return s
+}
+
+// Current returns the Config currently in effect.
+func (s *Store) Current() *Config {
+	return s.current.Load()
+}
+
+// Watch starts a background file watcher on path (if non-empty) and a
+// SIGHUP handler, both of which reload and atomically publish a new
+// Config. onReload, if non-nil, runs after every successful reload so
+// callers can push values into components that don't read the Store
+// directly (e.g. limiter.RateLimiter.SetLimit).
+func (s *Store) Watch(path string, client *mongo.Client, onReload func(*Config)) error {
+	s.path = path
+	s.client = client
+	s.onReload = onReload
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("♻️  config: SIGHUP received, reloading")
+			s.reload()
+		}
+	}()
+
+	if path == "" {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("config: failed to start file watcher: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("config: failed to watch %s: %w", path, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				log.Printf("♻️  config: %s changed, reloading", path)
+				s.reload()
+			case watchErr, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[ERROR] config: watcher error: %v", watchErr)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *Store) reload() {
+	next, err := Load(s.path)
+	if err != nil {
+		log.Printf("[ERROR] config: reload failed, keeping previous config: %v", err)
+		return
+	}
+	if err := applyMongoOverrides(s.client, next); err != nil {
+		log.Printf("[ERROR] config: mongo override lookup failed: %v", err)
+	}
+
+	s.current.Store(next)
+	if s.onReload != nil {
+		s.onReload(next)
+	}
+}
+
+// mongoOverride is the shape of the single optional document an operator
+// can drop in the "config_overrides" collection to tweak rate limits
+// cluster-wide without touching the file or env on every node.
+type mongoOverride struct {
+	RateLimitRPM     *int `bson:"rate_limit_rpm,omitempty"`
+	AuthRateLimitRPM *int `bson:"auth_rate_limit_rpm,omitempty"`
+}
+
+// applyMongoOverrides layers the optional Mongo document on top of cfg.
+// It has the highest precedence of all four layers (defaults < file < env
+// < Mongo), since it's meant for a live operator tweak rather than a
+// deploy-time setting.
+func applyMongoOverrides(client *mongo.Client, cfg *Config) error {
+	if client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var doc mongoOverride
+	err := client.Database(database.DBName).Collection(overridesCollection).FindOne(ctx, bson.M{"_id": "live"}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+
+	if doc.RateLimitRPM != nil {
+		cfg.RateLimitRPM = *doc.RateLimitRPM
+	}
+	if doc.AuthRateLimitRPM != nil {
+		cfg.AuthRateLimitRPM = *doc.AuthRateLimitRPM
+	}
+	return nil
+}
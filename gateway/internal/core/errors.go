@@ -0,0 +1,18 @@
+package core
+
+import "errors"
+
+// Sentinel errors repositories in this lane return instead of ad hoc
+// strings, so handlers can errors.Is/errors.As a specific failure (e.g. "was
+// this a duplicate, or did the DB just fall over?") rather than string-match
+// err.Error() the way AddDomain used to for "duplicate key". writeError maps
+// each of these onto an HTTP status; anything else falls back to a generic
+// 500 there.
+var (
+	ErrDomainNotFound         = errors.New("core: domain not found")
+	ErrDuplicateDomain        = errors.New("core: domain already exists")
+	ErrUnauthorizedDomain     = errors.New("core: not authorized for this domain")
+	ErrDNSProviderUnavailable = errors.New("core: dns provider unavailable")
+	ErrRDAPUnavailable        = errors.New("core: rdap lookup unavailable")
+	ErrCertIssuanceFailed     = errors.New("core: certificate issuance failed")
+)
@@ -24,12 +24,21 @@ type UserInput struct {
 // --- Domain & DNS Models ---
 
 type Domain struct {
-	ID        string    `bson:"_id,omitempty" json:"id"`
-	UserID    string    `bson:"user_id" json:"user_id"`
-	Name      string    `bson:"name" json:"name"`
-	Status    string    `bson:"status" json:"status"` // active, pending, etc.
-	CreatedAt time.Time `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	ID           string    `bson:"_id,omitempty" json:"id"`
+	UserID       string    `bson:"user_id" json:"user_id"`
+	Name         string    `bson:"name" json:"name"`
+	Status       string    `bson:"status" json:"status"` // active, pending_verification, etc.
+	Nameservers  []string  `bson:"nameservers,omitempty" json:"nameservers,omitempty"`
+	ProxyEnabled bool      `bson:"proxy_enabled" json:"proxy_enabled"`
+	// VerificationToken backs the TXT-record ownership check (VerifyDomainTXT)
+	// as an alternative to nameserver delegation; set once on AddDomain.
+	VerificationToken string `bson:"verification_token,omitempty" json:"verification_token,omitempty"`
+	// DNSProviderKind selects which core.DNSProvider backend (see
+	// internal/repository/dnsprovider) publishes this domain's records.
+	// Empty means "powerdns", the default every domain is onboarded with.
+	DNSProviderKind string    `bson:"dns_provider_kind,omitempty" json:"dns_provider_kind,omitempty"`
+	CreatedAt       time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time `bson:"updated_at" json:"updated_at"`
 }
 
 type DNSRecord struct {
@@ -44,6 +53,19 @@ type DNSRecord struct {
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 }
 
+// --- Certificate Models ---
+
+// Certificate is an ACME-issued cert/key pair for one domain, persisted so
+// the proxy's TLS listener can serve it without re-issuing on every boot.
+type Certificate struct {
+	Domain    string    `bson:"_id" json:"domain"`
+	CertPEM   []byte    `bson:"cert_pem" json:"-"`
+	KeyPEM    []byte    `bson:"key_pem" json:"-"`
+	NotBefore time.Time `bson:"not_before" json:"not_before"`
+	NotAfter  time.Time `bson:"not_after" json:"not_after"`
+	IssuedAt  time.Time `bson:"issued_at" json:"issued_at"`
+}
+
 // --- WAF Rule Models ---
 
 type WAFRule struct {
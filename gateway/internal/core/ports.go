@@ -34,6 +34,34 @@ type DomainRepository interface {
 	UpdateProxyMode(ctx context.Context, id string, enabled bool) error
 	// Routing Helper
 	GetOriginRecord(ctx context.Context, host string) (*DNSRecord, error)
+	// GetOriginPool returns every A/AAAA record for host, i.e. the full set
+	// of candidate upstreams ProxyManager's health checker can probe and
+	// failover across. Unlike GetOriginRecord it deliberately does not fall
+	// back to CNAME — pooling only makes sense across records that resolve
+	// to concrete addresses.
+	GetOriginPool(ctx context.Context, host string) ([]DNSRecord, error)
+}
+
+// DNSProvider is the pluggable authoritative-DNS backend AddDomain,
+// VerifyDomain, ManageRecords, and ToggleProxyMode mutate through, modeled
+// on how lego selects a DNS-01 solver by name. A concrete implementation
+// (PowerDNS/SQL, Cloudflare, Route53, ...) is resolved once per domain from
+// Domain.DNSProviderKind, so operators can migrate a zone between backends
+// without any handler code change.
+type DNSProvider interface {
+	// EnsureZone makes sure domain exists as a zone on the backend,
+	// creating it if the backend requires that as a separate step.
+	EnsureZone(ctx context.Context, domain string) error
+	CreateRecord(ctx context.Context, domain string, record DNSRecord) (string, error)
+	DeleteRecordsByType(ctx context.Context, domain, recordType string) error
+	ListRecords(ctx context.Context, domain string) ([]DNSRecord, error)
+}
+
+// CertificateRepository persists ACME-issued certificates keyed by domain.
+type CertificateRepository interface {
+	Upsert(ctx context.Context, cert Certificate) error
+	GetByDomain(ctx context.Context, domain string) (*Certificate, error)
+	GetAll(ctx context.Context) ([]Certificate, error)
 }
 
 // RuleRepository handles WAF rules and policies
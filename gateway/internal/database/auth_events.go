@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxLoginAttempts is how many consecutive failed passwords
+// RegisterLoginAttempt tolerates before locking the account out for
+// LoginLockoutDuration.
+const (
+	MaxLoginAttempts     = 5
+	LoginLockoutDuration = 10 * time.Minute
+)
+
+// CheckLoginLock reports whether email is currently locked out (a prior
+// call to RegisterLoginAttempt tripped MaxLoginAttempts and the lockout
+// hasn't expired yet), and how much longer the caller should wait if so.
+// An unknown email reports not-locked, the same as a fresh account — the
+// caller still fails the login on bad credentials either way.
+func CheckLoginLock(client *mongo.Client, email string) (locked bool, retryAfter time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	var doc struct {
+		LockedUntil time.Time `bson:"locked_until"`
+	}
+	err = client.Database(DBName).Collection("users").FindOne(ctx, bson.M{"email": email}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	if time.Now().Before(doc.LockedUntil) {
+		return true, time.Until(doc.LockedUntil), nil
+	}
+	return false, 0, nil
+}
+
+// RegisterLoginAttempt records the outcome of one login attempt for email.
+// ok=true resets the failed-attempt counter and stamps LastLoginAt; ok=false
+// increments it, and once it reaches MaxLoginAttempts, resets the counter
+// and sets LockedUntil to now+LoginLockoutDuration — reported back via
+// locked/retryAfter so the caller can respond before CheckLoginLock would
+// even see it on the next request.
+func RegisterLoginAttempt(client *mongo.Client, email string, ok bool) (locked bool, retryAfter time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	collection := client.Database(DBName).Collection("users")
+
+	if ok {
+		_, err = collection.UpdateOne(ctx, bson.M{"email": email}, bson.M{
+			"$set": bson.M{"failed_attempts": 0, "last_login_at": time.Now(), "locked_until": time.Time{}},
+		})
+		return false, 0, err
+	}
+
+	var doc struct {
+		FailedAttempts int `bson:"failed_attempts"`
+	}
+	if err = collection.FindOne(ctx, bson.M{"email": email}).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			// Unknown email: nothing to lock out, the caller already
+			// treats this as an invalid-credentials response.
+			return false, 0, nil
+		}
+		return false, 0, err
+	}
+
+	attempts := doc.FailedAttempts + 1
+	if attempts >= MaxLoginAttempts {
+		lockedUntil := time.Now().Add(LoginLockoutDuration)
+		_, err = collection.UpdateOne(ctx, bson.M{"email": email}, bson.M{
+			"$set": bson.M{"failed_attempts": 0, "locked_until": lockedUntil},
+		})
+		return true, LoginLockoutDuration, err
+	}
+
+	_, err = collection.UpdateOne(ctx, bson.M{"email": email}, bson.M{"$set": bson.M{"failed_attempts": attempts}})
+	return false, 0, err
+}
+
+// RecordAuthEvent appends one row to auth_events so operators can query
+// brute-force patterns (repeated "invalid_credentials"/"locked" outcomes
+// from one ip or against one email) alongside the existing AttackLog
+// pipeline, which only covers WAF-inspected traffic, not the auth
+// endpoints.
+func RecordAuthEvent(client *mongo.Client, email, ip, userAgent, outcome string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	_, err := client.Database(DBName).Collection("auth_events").InsertOne(ctx, bson.M{
+		"email":      email,
+		"ip":         ip,
+		"user_agent": userAgent,
+		"outcome":    outcome,
+		"timestamp":  time.Now(),
+	})
+	return err
+}
@@ -3,9 +3,11 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	"golang.org/x/net/publicsuffix"
 )
 
 // Package-level variable for MySQL connection
@@ -56,10 +58,13 @@ func AddPowerDNSRecord(name, recordType, content string, proxied bool, wafIP str
 	}
 
 	// First, get the domain_id for the zone
-	var domainID int
-	zoneName := extractZone(name)
+	zoneName, _, err := ResolveZone(name)
+	if err != nil {
+		return err
+	}
 
-	err := dnsDB.QueryRow("SELECT id FROM domains WHERE name = ?", zoneName).Scan(&domainID)
+	var domainID int
+	err = dnsDB.QueryRow("SELECT id FROM domains WHERE name = ?", zoneName).Scan(&domainID)
 	if err != nil {
 		return fmt.Errorf("zone not found: %s (error: %v)", zoneName, err)
 	}
@@ -149,32 +154,100 @@ func DeletePowerDNSRecordByContent(name, recordType, content string) error {
 	return err
 }
 
-// Helper function to extract zone from full record
-func extractZone(recordName string) string {
-	parts := splitDomain(recordName)
-	if len(parts) >= 2 {
-		return parts[len(parts)-2] + "." + parts[len(parts)-1]
+// ResolveZone splits a fully-qualified record name into the DNS zone
+// that's actually provisioned in the "domains" table and the sub-host
+// relative to it — e.g. "www.example.co.uk" -> ("example.co.uk", "www").
+// The Public Suffix List gets the common case right even for multi-label
+// suffixes (".co.uk", ".com.br", ".govt.nz"...) that a naive "last two
+// labels" split got wrong. If PSL disagrees with what the user actually
+// provisioned (a zone PSL doesn't recognize, or a private/internal TLD),
+// fall back to walking candidate parent labels and using the longest one
+// that's a registered zone. AddPowerDNSRecord, GetOriginRecord and any
+// future record-name splitting should go through this rather than
+// re-deriving the zone themselves.
+func ResolveZone(fqdn string) (zone string, subHost string, err error) {
+	fqdn = strings.TrimSuffix(fqdn, ".")
+
+	if candidate, ok := zoneFromPSL(fqdn); ok && zoneExists(candidate) {
+		return candidate, subHostOf(fqdn, candidate), nil
+	}
+
+	if candidate, ok := longestRegisteredZone(fqdn); ok {
+		return candidate, subHostOf(fqdn, candidate), nil
+	}
+
+	return "", "", fmt.Errorf("zone not found: %s", fqdn)
+}
+
+// zoneFromPSL returns the registrable domain (eTLD+1) for fqdn per the
+// Public Suffix List, e.g. "www.example.co.uk" -> "example.co.uk".
+func zoneFromPSL(fqdn string) (string, bool) {
+	zone, err := publicsuffix.EffectiveTLDPlusOne(fqdn)
+	if err != nil {
+		return "", false
 	}
-	return recordName
+	return zone, true
 }
 
-func splitDomain(domain string) []string {
-	var parts []string
-	current := ""
-	for _, c := range domain {
-		if c == '.' {
-			if current != "" {
-				parts = append(parts, current)
-				current = ""
-			}
-		} else {
-			current += string(c)
+// zoneExists reports whether zone is already a provisioned PowerDNS domain.
+func zoneExists(zone string) bool {
+	if dnsDB == nil {
+		return false
+	}
+	var id int
+	return dnsDB.QueryRow("SELECT id FROM domains WHERE name = ?", zone).Scan(&id) == nil
+}
+
+// longestRegisteredZone walks every dot-separated suffix of fqdn, longest
+// (most specific) first, and returns the first one that's actually a
+// provisioned zone. This keeps zone detection working for a domain PSL
+// doesn't know about, since the "domains" table is the ground truth for
+// what this gateway actually manages.
+func longestRegisteredZone(fqdn string) (string, bool) {
+	labels := strings.Split(fqdn, ".")
+	for i := 0; i < len(labels)-1; i++ {
+		candidate := strings.Join(labels[i:], ".")
+		if zoneExists(candidate) {
+			return candidate, true
 		}
 	}
-	if current != "" {
-		parts = append(parts, current)
+	return "", false
+}
+
+// findAuthZone walks fqdn's dot-separated labels right-to-left, most
+// specific first (e.g. "a.b.example.com" -> "a.b.example.com",
+// "b.example.com", "example.com", "com"), issuing an exact-match lookup
+// against the "domains" table for each candidate until one hits — the same
+// walk lego's dns01.FindZoneByFqdn does to find the authoritative zone for
+// an ACME DNS-01 challenge. This replaces the fragile
+// `WHERE ? LIKE CONCAT('%%', name)` match AddPowerDNSRecord used to run,
+// which treated "notexample.com" as a match for a provisioned zone named
+// "example.com" since LIKE only checks for a trailing substring, not a
+// label boundary. Record types beyond A/AAAA/CNAME should resolve their
+// zone through this rather than reimplementing the LIKE query.
+func findAuthZone(fqdn string) (zoneName string, zoneID int64, err error) {
+	if dnsDB == nil {
+		return "", 0, fmt.Errorf("DNS database not connected")
+	}
+
+	fqdn = strings.TrimSuffix(fqdn, ".")
+	labels := strings.Split(fqdn, ".")
+	for i := 0; i < len(labels); i++ {
+		candidate := strings.Join(labels[i:], ".")
+		var id int64
+		if err := dnsDB.QueryRow("SELECT id FROM domains WHERE name = ?", candidate).Scan(&id); err == nil {
+			return candidate, id, nil
+		}
 	}
-	return parts
+	return "", 0, fmt.Errorf("zone not found: %s", fqdn)
+}
+
+// subHostOf returns fqdn's label(s) left of zone, e.g.
+// subHostOf("www.example.co.uk", "example.co.uk") -> "www". Returns "" for
+// the zone apex itself.
+func subHostOf(fqdn, zone string) string {
+	sub := strings.TrimSuffix(fqdn, zone)
+	return strings.TrimSuffix(sub, ".")
 }
 
 // CreateDNSZone creates a new zone in PowerDNS
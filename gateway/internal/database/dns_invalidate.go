@@ -0,0 +1,35 @@
+package database
+
+import "sync"
+
+// dnsInvalidationHooks are called after CreateDNSRecord, DeleteDNSRecord,
+// or UpdateDNSRecordProxy successfully changes dns_records, so an
+// in-process DNS server (internal/dnsserver) can refresh its zone cache
+// immediately instead of waiting on its own change-stream event for a
+// write it just made.
+var (
+	hooksMu              sync.Mutex
+	dnsInvalidationHooks []func()
+)
+
+// RegisterDNSInvalidationHook adds fn to the set fired after every DNS
+// record mutation below. Typically called once, by
+// internal/dnsserver.Server.Start.
+func RegisterDNSInvalidationHook(fn func()) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	dnsInvalidationHooks = append(dnsInvalidationHooks, fn)
+}
+
+// fireDNSInvalidationHooks runs every registered hook. Hooks are expected
+// to be cheap and non-blocking (e.g. a buffered channel send) — this is
+// called inline from the mutation functions above.
+func fireDNSInvalidationHooks() {
+	hooksMu.Lock()
+	hooks := append([]func(){}, dnsInvalidationHooks...)
+	hooksMu.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/role"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxRecordsPerUser caps how many DNS records CreateDNSRecord will let a
+// single user own at once, so one account can't exhaust a shared
+// PowerDNS/Cloudflare zone table. Records created by a system process
+// (UserID left empty — see DNSRecord.UserID) don't count against it. A var,
+// not a const, so main.go can override it from Config.MaxUserRecords at
+// boot; 65 is the fallback an operator who never sets it gets.
+var MaxRecordsPerUser = 65
+
+// MaxRecordsPerDomain caps how many DNS records a single domain/zone may
+// hold, independent of who owns them — protects a shared PowerDNS zone
+// table from one domain's records crowding out every other tenant sharing
+// the same backend. Overridable at boot from Config.MaxRecordsPerDomain,
+// same as MaxRecordsPerUser.
+var MaxRecordsPerDomain = 100
+
+// MaxDomainsPerUser caps how many domains CreateDomain will let a single
+// user onboard. Overridable at boot from Config.MaxDomainsPerUser.
+var MaxDomainsPerUser = 10
+
+// ErrDomainRecordQuotaExceeded is returned by CreateDNSRecord when the
+// record's domain already holds MaxRecordsPerDomain records.
+var ErrDomainRecordQuotaExceeded = errors.New("domain dns record quota exceeded")
+
+// ErrDomainQuotaExceeded is returned by CreateDomain when the owning user
+// already owns MaxDomainsPerUser domains.
+var ErrDomainQuotaExceeded = errors.New("domain quota exceeded")
+
+// GetUserRecordQuota returns how many DNS records userID is allowed to own:
+// their detector.User.MaxRecordsOverride if one is set (paid tiers), or
+// MaxRecordsPerUser otherwise. Falls back to MaxRecordsPerUser on any
+// lookup error, so a transient Mongo hiccup fails closed to the stricter
+// default rather than granting an unbounded quota.
+func GetUserRecordQuota(client *mongo.Client, userID string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	var user detector.User
+	err := client.Database(DBName).Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err != nil || user.MaxRecordsOverride <= 0 {
+		return MaxRecordsPerUser
+	}
+	return user.MaxRecordsOverride
+}
+
+// ErrRecordQuotaExceeded is returned by CreateDNSRecord when record.UserID
+// already owns MaxRecordsPerUser records.
+var ErrRecordQuotaExceeded = errors.New("dns record quota exceeded")
+
+// ErrRecordForbidden is returned by AssertUserOwnsRecord, and by anything
+// that gates a mutation on it, when userID has no access to the domain
+// that owns the record.
+var ErrRecordForbidden = errors.New("not authorized for this dns record")
+
+// RecordUsage reports how many DNS records userID currently owns against
+// their quota (GetUserRecordQuota), for the "17/65 records used" display on
+// GET /api/dns/quota.
+func RecordUsage(client *mongo.Client, userID string) (used, quota int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	count, err := client.Database(DBName).Collection("dns_records").CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(count), GetUserRecordQuota(client, userID), nil
+}
+
+// DomainUsage reports how many domains userID currently owns against
+// MaxDomainsPerUser.
+func DomainUsage(client *mongo.Client, userID string) (used, quota int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	count, err := client.Database(DBName).Collection("domains").CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(count), MaxDomainsPerUser, nil
+}
+
+// AssertUserOwnsRecord verifies userID holds at least role.Editor on the
+// domain that owns recordID — the same minimum ManageRecords already
+// requires for write access — returning ErrRecordForbidden otherwise. This
+// closes the gap where a valid token for one domain could mutate another
+// user's record just by guessing its id, since the HTTP handlers only ever
+// checked the domain_id the caller supplied, not the record's actual owner.
+func AssertUserOwnsRecord(client *mongo.Client, userID, recordID string) error {
+	record, err := GetDNSRecordByID(client, recordID)
+	if err != nil {
+		return ErrRecordForbidden
+	}
+
+	userRole, err := GetMemberRole(client, record.DomainID, userID)
+	if err != nil || !role.Satisfies(userRole, role.Editor) {
+		return ErrRecordForbidden
+	}
+	return nil
+}
@@ -47,6 +47,22 @@ func CreateDNSZone(domainName string, nameservers []string) error {
 	return nil
 }
 
+// TouchZoneSerial bumps change_date on every record in domainName's zone to
+// now — the same freshness signal CreateDNSZone stamps records with on
+// insert. PowerDNS's NATIVE backend re-reads straight from this table, so
+// this is mostly about invalidating its packet cache sooner than the
+// record's own TTL would, right after a proxied/origin record flip.
+func TouchZoneSerial(domainName string) error {
+	if dnsDB == nil {
+		return fmt.Errorf("DNS database not connected")
+	}
+	_, err := dnsDB.Exec(`
+		UPDATE records SET change_date = UNIX_TIMESTAMP()
+		WHERE domain_id = (SELECT id FROM domains WHERE name = ?)
+	`, domainName)
+	return err
+}
+
 // DeleteDNSZone removes a zone and all its records from PowerDNS
 func DeleteDNSZone(domainName string) error {
 	if dnsDB == nil {
@@ -62,10 +78,12 @@ func AddPowerDNSRecord(name, rType, content string, proxied bool, wafIP string)
 		return fmt.Errorf("DNS database not connected")
 	}
 
-	// Find Domain ID by matching the suffix
-	var domainID int64
-	row := dnsDB.QueryRow("SELECT id FROM domains WHERE ? LIKE CONCAT('%%', name) ORDER BY LENGTH(name) DESC LIMIT 1", name)
-	if err := row.Scan(&domainID); err != nil {
+	// Find the authoritative zone by walking labels right-to-left with
+	// exact-match lookups (findAuthZone), not a LIKE substring match —
+	// the latter would match a zone named "example.com" against a record
+	// for "notexample.com".
+	_, domainID, err := findAuthZone(name)
+	if err != nil {
 		return fmt.Errorf("domain not found in SQL for record %s: %v", name, err)
 	}
 
@@ -74,11 +92,11 @@ func AddPowerDNSRecord(name, rType, content string, proxied bool, wafIP string)
 		finalContent = wafIP
 	}
 
-	_, err := dnsDB.Exec(`
-		INSERT INTO records (domain_id, name, type, content, ttl, prio, disabled, change_date, created_at) 
-		VALUES (?, ?, ?, ?, 300, 0, 0, UNIX_TIMESTAMP(), NOW())`, 
+	_, err = dnsDB.Exec(`
+		INSERT INTO records (domain_id, name, type, content, ttl, prio, disabled, change_date, created_at)
+		VALUES (?, ?, ?, ?, 300, 0, 0, UNIX_TIMESTAMP(), NOW())`,
 		domainID, name, rType, finalContent)
-	
+
 	return err
 }
 
@@ -0,0 +1,358 @@
+package database
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const dnssecKeysCollection = "dnssec_keys"
+
+const (
+	dnskeyFlagsKSK     = 257
+	dnskeyFlagsZSK     = 256
+	dnskeyProtocol     = 3
+	dnskeyAlgoED25519  = 15
+	dsDigestTypeSHA256 = 2
+)
+
+// DSRecord is one entry of the DS record set a domain owner must publish at
+// their registrar for PowerDNS's DNSSEC signatures to be trusted.
+type DSRecord struct {
+	KeyTag     uint16 `json:"key_tag" bson:"key_tag"`
+	Algorithm  int    `json:"algorithm" bson:"algorithm"`
+	DigestType int    `json:"digest_type" bson:"digest_type"`
+	Digest     string `json:"digest" bson:"digest"`
+}
+
+// storedDNSSECKey is one zone's KSK or ZSK, private key encrypted at rest
+// (see encryptDNSSECKey). A freshly-published KSK is stored Active, so
+// during a double-DS rollover the zone has two active KSKs until RotateKSK's
+// caller has given the new DS time to propagate and calls PromoteKSK, which
+// deactivates the older one.
+type storedDNSSECKey struct {
+	ID            string    `bson:"_id"`
+	Domain        string    `bson:"domain"`
+	Flags         int       `bson:"flags"`
+	KeyTag        uint16    `bson:"key_tag"`
+	PublicKey     []byte    `bson:"public_key"`
+	PrivateKeyEnc []byte    `bson:"private_key_enc"`
+	Active        bool      `bson:"active"`
+	CreatedAt     time.Time `bson:"created_at"`
+}
+
+// GenerateZoneKeys creates a fresh KSK+ZSK pair (Ed25519) for domainName,
+// persists the private keys (encrypted) to Mongo, writes the matching rows
+// into PowerDNS's cryptokeys table so the zone starts getting signed, and
+// returns the DS record the owner must publish at their registrar — only
+// the KSK gets a DS record; the ZSK is never delegated to directly.
+func GenerateZoneKeys(client *mongo.Client, domainName string) ([]DSRecord, error) {
+	if dnsDB == nil {
+		return nil, fmt.Errorf("DNS database not connected")
+	}
+
+	var zoneID int64
+	row := dnsDB.QueryRow("SELECT id FROM domains WHERE name = ?", domainName)
+	if err := row.Scan(&zoneID); err != nil {
+		return nil, fmt.Errorf("failed to look up zone id for %s: %v", domainName, err)
+	}
+
+	ksk, err := generateAndPersistKey(client, domainName, zoneID, dnskeyFlagsKSK)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := generateAndPersistKey(client, domainName, zoneID, dnskeyFlagsZSK); err != nil {
+		return nil, err
+	}
+
+	return []DSRecord{dsRecordFromStored(domainName, ksk)}, nil
+}
+
+// RotateKSK publishes a brand-new KSK alongside the zone's current one
+// (the "double-DS" method: the old DS stays valid at the registrar while
+// the new one propagates) and returns both so the caller can tell the
+// owner to publish newDS, wait out their registrar's DS TTL, then call
+// PromoteKSK to retire oldDS.
+func RotateKSK(client *mongo.Client, domainName string) (oldDS, newDS DSRecord, err error) {
+	if dnsDB == nil {
+		return DSRecord{}, DSRecord{}, fmt.Errorf("DNS database not connected")
+	}
+
+	current, err := activeKSK(client, domainName)
+	if err != nil {
+		return DSRecord{}, DSRecord{}, err
+	}
+	oldDS = dsRecordFromStored(domainName, current)
+
+	var zoneID int64
+	row := dnsDB.QueryRow("SELECT id FROM domains WHERE name = ?", domainName)
+	if err := row.Scan(&zoneID); err != nil {
+		return DSRecord{}, DSRecord{}, fmt.Errorf("failed to look up zone id for %s: %v", domainName, err)
+	}
+
+	next, err := generateAndPersistKey(client, domainName, zoneID, dnskeyFlagsKSK)
+	if err != nil {
+		return DSRecord{}, DSRecord{}, err
+	}
+	newDS = dsRecordFromStored(domainName, next)
+
+	return oldDS, newDS, nil
+}
+
+// PromoteKSK retires domainName's old KSK (the one superseded by the most
+// recent RotateKSK call) once its DS has had time to propagate: it's
+// deactivated in both Mongo and PowerDNS's cryptokeys table so only the new
+// key signs going forward.
+func PromoteKSK(client *mongo.Client, domainName string) error {
+	if dnsDB == nil {
+		return fmt.Errorf("DNS database not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	coll := client.Database(DBName).Collection(dnssecKeysCollection)
+	cursor, err := coll.Find(ctx, bson.M{"domain": domainName, "flags": dnskeyFlagsKSK, "active": true},
+		options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var active []storedDNSSECKey
+	if err := cursor.All(ctx, &active); err != nil {
+		return err
+	}
+	if len(active) < 2 {
+		return fmt.Errorf("no pending KSK rollover for %s", domainName)
+	}
+
+	old := active[0] // oldest active KSK is the one being retired
+	if _, err := coll.UpdateOne(ctx, bson.M{"_id": old.ID}, bson.M{"$set": bson.M{"active": false}}); err != nil {
+		return err
+	}
+
+	_, err = dnsDB.Exec(`
+		UPDATE cryptokeys SET active = 0
+		WHERE domain_id = (SELECT id FROM domains WHERE name = ?) AND flags = ? AND active = 1
+		ORDER BY id ASC LIMIT 1
+	`, domainName, dnskeyFlagsKSK)
+	if err != nil {
+		return fmt.Errorf("failed to retire old KSK cryptokey row for %s: %v", domainName, err)
+	}
+
+	return nil
+}
+
+// CurrentDSRecords returns the DS records for every currently-active KSK of
+// domainName — normally one, or two mid-rollover — for VerifyDomainOwner and
+// the cert-status-style re-emit endpoint to hand back to the owner.
+func CurrentDSRecords(client *mongo.Client, domainName string) ([]DSRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	cursor, err := client.Database(DBName).Collection(dnssecKeysCollection).Find(ctx,
+		bson.M{"domain": domainName, "flags": dnskeyFlagsKSK, "active": true})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var keys []storedDNSSECKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	ds := make([]DSRecord, 0, len(keys))
+	for _, k := range keys {
+		ds = append(ds, dsRecordFromStored(domainName, k))
+	}
+	return ds, nil
+}
+
+func activeKSK(client *mongo.Client, domainName string) (storedDNSSECKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	var key storedDNSSECKey
+	err := client.Database(DBName).Collection(dnssecKeysCollection).FindOne(ctx,
+		bson.M{"domain": domainName, "flags": dnskeyFlagsKSK, "active": true}).Decode(&key)
+	return key, err
+}
+
+func dsRecordFromStored(domainName string, key storedDNSSECKey) DSRecord {
+	rdata := dnskeyRDATA(key.Flags, key.PublicKey)
+	return computeDS(domainName, key.KeyTag, rdata)
+}
+
+// generateAndPersistKey creates one Ed25519 key with the given DNSKEY
+// flags (KSK=257/ZSK=256), stores it (private half encrypted) in Mongo,
+// and inserts the matching PowerDNS cryptokeys row.
+func generateAndPersistKey(client *mongo.Client, domainName string, zoneID int64, flags int) (storedDNSSECKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return storedDNSSECKey{}, fmt.Errorf("failed to generate DNSSEC key for %s: %v", domainName, err)
+	}
+
+	rdata := dnskeyRDATA(flags, pub)
+	tag := keyTag(rdata)
+
+	privEnc, err := encryptDNSSECKey(priv.Seed())
+	if err != nil {
+		return storedDNSSECKey{}, err
+	}
+
+	doc := storedDNSSECKey{
+		ID:            fmt.Sprintf("%s:%d:%d", domainName, flags, time.Now().UnixNano()),
+		Domain:        domainName,
+		Flags:         flags,
+		KeyTag:        tag,
+		PublicKey:     pub,
+		PrivateKeyEnc: privEnc,
+		Active:        true,
+		CreatedAt:     time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+	if _, err := client.Database(DBName).Collection(dnssecKeysCollection).InsertOne(ctx, doc); err != nil {
+		return storedDNSSECKey{}, fmt.Errorf("failed to persist DNSSEC key for %s: %v", domainName, err)
+	}
+
+	// PowerDNS's gmysql backend stores a key's private half in BIND's
+	// "Private-key-format" text form, keyed by domain_id/flags/active.
+	content := fmt.Sprintf("Private-key-format: v1.2\nAlgorithm: %d (ED25519)\nPrivateKey: %s\n",
+		dnskeyAlgoED25519, base64.StdEncoding.EncodeToString(priv.Seed()))
+
+	_, err = dnsDB.Exec(`
+		INSERT INTO cryptokeys (domain_id, flags, active, content)
+		VALUES (?, ?, 1, ?)
+	`, zoneID, flags, content)
+	if err != nil {
+		return storedDNSSECKey{}, fmt.Errorf("failed to insert cryptokey row for %s: %v", domainName, err)
+	}
+
+	return doc, nil
+}
+
+// dnskeyRDATA builds a DNSKEY record's RDATA (flags, protocol=3, algorithm,
+// public key) per RFC 4034 §2.1 — the input both keyTag and computeDS hash.
+func dnskeyRDATA(flags int, pub ed25519.PublicKey) []byte {
+	rdata := make([]byte, 4+len(pub))
+	rdata[0] = byte(flags >> 8)
+	rdata[1] = byte(flags)
+	rdata[2] = dnskeyProtocol
+	rdata[3] = dnskeyAlgoED25519
+	copy(rdata[4:], pub)
+	return rdata
+}
+
+// keyTag implements RFC 4034 Appendix B.1's algorithm, which covers every
+// algorithm other than the long-obsolete RSA/MD5 (algorithm 1).
+func keyTag(rdata []byte) uint16 {
+	var ac uint32
+	for i, b := range rdata {
+		if i&1 == 1 {
+			ac += uint32(b)
+		} else {
+			ac += uint32(b) << 8
+		}
+	}
+	ac += (ac >> 16) & 0xFFFF
+	return uint16(ac & 0xFFFF)
+}
+
+// computeDS builds the DS record (RFC 4034 §5.1.4): SHA-256 over the zone's
+// canonical wire-format owner name followed by the DNSKEY RDATA.
+func computeDS(domainName string, tag uint16, rdata []byte) DSRecord {
+	digestInput := append(encodeDNSName(domainName), rdata...)
+	sum := sha256.Sum256(digestInput)
+	return DSRecord{
+		KeyTag:     tag,
+		Algorithm:  dnskeyAlgoED25519,
+		DigestType: dsDigestTypeSHA256,
+		Digest:     strings.ToUpper(hex.EncodeToString(sum[:])),
+	}
+}
+
+// encodeDNSName lowercases (DNSSEC's canonical form) and wire-encodes a
+// domain name: length-prefixed labels terminated by a zero-length root
+// label.
+func encodeDNSName(name string) []byte {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	var buf []byte
+	for _, label := range strings.Split(name, ".") {
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, []byte(label)...)
+	}
+	return append(buf, 0)
+}
+
+// encryptDNSSECKey seals a private key under AES-256-GCM using
+// DNSSEC_ENC_KEY (a 32-byte key, base64-encoded) so a Mongo dump alone
+// never exposes a zone's signing keys. Missing/malformed DNSSEC_ENC_KEY is
+// a startup-config error, not something to silently work around, so it's
+// returned rather than falling back to storing the key in the clear.
+func encryptDNSSECKey(seed []byte) ([]byte, error) {
+	block, err := dnssecCipherBlock()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, seed, nil), nil
+}
+
+// decryptDNSSECKey reverses encryptDNSSECKey, for whenever the signer
+// itself needs the raw private key (e.g. a future out-of-process signer
+// reading dnssec_keys directly — PowerDNS's own gmysql backend already has
+// its own copy via the cryptokeys row written alongside it).
+func decryptDNSSECKey(sealed []byte) ([]byte, error) {
+	block, err := dnssecCipherBlock()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("sealed DNSSEC key too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func dnssecCipherBlock() (cipher.Block, error) {
+	encoded := os.Getenv("DNSSEC_ENC_KEY")
+	if encoded == "" {
+		return nil, fmt.Errorf("DNSSEC_ENC_KEY is not set")
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("DNSSEC_ENC_KEY is not valid base64: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("DNSSEC_ENC_KEY must decode to 32 bytes (AES-256), got %d", len(key))
+	}
+	return aes.NewCipher(key)
+}
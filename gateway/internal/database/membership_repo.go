@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/role"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DomainMember binds a user to a domain with a role, independent of who
+// created (owns) the domain.
+type DomainMember struct {
+	ID        string    `bson:"_id,omitempty" json:"id"`
+	DomainID  string    `bson:"domain_id" json:"domain_id"`
+	UserID    string    `bson:"user_id" json:"user_id"`
+	Role      role.Role `bson:"role" json:"role"`
+	InvitedBy string    `bson:"invited_by,omitempty" json:"invited_by,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// AddMember upserts a (user_id, domain_id) -> role binding, e.g. in
+// response to an invitation being accepted.
+func AddMember(client *mongo.Client, member DomainMember) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	if member.ID == "" {
+		member.ID = primitive.NewObjectID().Hex()
+	}
+	member.CreatedAt = time.Now()
+
+	_, err := client.Database(DBName).Collection("domain_members").UpdateOne(ctx,
+		bson.M{"domain_id": member.DomainID, "user_id": member.UserID},
+		bson.M{"$set": member},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// GetMemberRole returns the caller's role on a domain. Domain owners always
+// resolve to role.Owner even if they have no explicit domain_members row
+// (the row is created lazily the first time someone else is invited).
+func GetMemberRole(client *mongo.Client, domainID, userID string) (role.Role, error) {
+	domain, err := GetDomainByID(client, domainID)
+	if err != nil {
+		return "", err
+	}
+	if domain.UserID == userID {
+		return role.Owner, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	var member DomainMember
+	err = client.Database(DBName).Collection("domain_members").
+		FindOne(ctx, bson.M{"domain_id": domainID, "user_id": userID}).Decode(&member)
+	if err != nil {
+		return "", errors.New("no access to this domain")
+	}
+	return member.Role, nil
+}
+
+// GetMembersByDomain lists everyone with explicit access to a domain
+// (excludes the implicit owner row).
+func GetMembersByDomain(client *mongo.Client, domainID string) ([]DomainMember, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	cursor, err := client.Database(DBName).Collection("domain_members").Find(ctx, bson.M{"domain_id": domainID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var members []DomainMember
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// GetAccessibleDomains returns every domain the user can see, whether they
+// own it or were granted a role through domain_members — the RBAC-aware
+// replacement for a plain GetDomainsByUser call in handlers like
+// ListDomains.
+func GetAccessibleDomains(client *mongo.Client, userID string) ([]detector.Domain, error) {
+	ids, err := GetAccessibleDomainIDs(client, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	cursor, err := client.Database(DBName).Collection("domains").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var domains []detector.Domain
+	if err := cursor.All(ctx, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// GetAccessibleDomainIDs returns every domain ID the user can see, whether
+// they own it or were granted a role through domain_members.
+func GetAccessibleDomainIDs(client *mongo.Client, userID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	owned, err := GetDomainsByUser(client, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(owned))
+	for _, d := range owned {
+		ids = append(ids, d.ID)
+	}
+
+	cursor, err := client.Database(DBName).Collection("domain_members").Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return ids, nil
+	}
+	defer cursor.Close(ctx)
+
+	var members []DomainMember
+	if err := cursor.All(ctx, &members); err == nil {
+		for _, m := range members {
+			ids = append(ids, m.DomainID)
+		}
+	}
+	return ids, nil
+}
@@ -3,10 +3,10 @@ package database
 import (
 	"context"
 	"errors"
-	"log"
 	"regexp"
 	"time"
 
+	"web-app-firewall-ml-detection/internal/log"
 	"web-app-firewall-ml-detection/internal/models"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -47,14 +47,14 @@ func CreateUser(client *mongo.Client, user models.User) error {
 	var existing models.User
 	err := client.Database(DBName).Collection("users").FindOne(ctx, bson.M{"email": user.Email}).Decode(&existing)
 	if err == nil {
-		return errors.New("email already registered")
+		return ErrDuplicate
 	}
 
 	if user.ID == "" {
 		user.ID = primitive.NewObjectID().Hex()
 	}
 	_, err = client.Database(DBName).Collection("users").InsertOne(ctx, user)
-	return err
+	return mapMongoErr(err)
 }
 
 func GetUserByEmail(client *mongo.Client, email string) (*models.User, error) {
@@ -89,6 +89,16 @@ func CreateDomain(client *mongo.Client, domain models.Domain) (models.Domain, er
 	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
 	defer cancel()
 
+	if domain.UserID != "" {
+		count, err := client.Database(DBName).Collection("domains").CountDocuments(ctx, bson.M{"user_id": domain.UserID})
+		if err != nil {
+			return models.Domain{}, err
+		}
+		if count >= int64(MaxDomainsPerUser) {
+			return models.Domain{}, ErrDomainQuotaExceeded
+		}
+	}
+
 	if domain.ID == "" {
 		domain.ID = primitive.NewObjectID().Hex()
 	}
@@ -96,7 +106,7 @@ func CreateDomain(client *mongo.Client, domain models.Domain) (models.Domain, er
 
 	_, err := client.Database(DBName).Collection("domains").InsertOne(ctx, domain)
 	if err != nil {
-		return models.Domain{}, err
+		return models.Domain{}, mapMongoErr(err)
 	}
 
 	return domain, nil
@@ -165,25 +175,68 @@ type DNSRecord struct {
 	TTL      int    `bson:"ttl" json:"ttl"`
 	Proxied  bool   `bson:"proxied" json:"proxied"`
 
+	// [NEW] Who created this record, for MaxRecordsPerUser and
+	// AssertUserOwnsRecord (dns_quota.go). Empty for records a system
+	// process created on a user's behalf (e.g. the ACME DNS-01 TXT
+	// challenge in internal/acme), which don't count against anyone's quota.
+	UserID string `bson:"user_id,omitempty" json:"user_id,omitempty"`
+
 	// [ADD THIS LINE]
 	OriginSSL bool `bson:"origin_ssl" json:"origin_ssl"`
 
+	// [NEW] Origin health, kept current by internal/origin's prober so
+	// multiple A/AAAA records sharing a Name can form a load-balanced,
+	// failover-aware upstream pool instead of a single static target.
+	Weight      int       `bson:"weight" json:"weight"`
+	Healthy     bool      `bson:"healthy" json:"healthy"`
+	LastChecked time.Time `bson:"last_checked,omitempty" json:"last_checked,omitempty"`
+	LatencyMS   int64     `bson:"latency_ms" json:"latency_ms"`
+
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+
+	// [NEW] The backing dnsbackend.Provider's own identifier for this
+	// record, when it has one (Cloudflare does; PowerDNS and Route53 key by
+	// name+type+content instead and leave this empty). Lets DeleteRecord
+	// address the record directly instead of re-discovering its id with a
+	// lookup call first.
+	ExternalID string `bson:"external_id,omitempty" json:"external_id,omitempty"`
 }
 
 func CreateDNSRecord(client *mongo.Client, record DNSRecord) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
 	defer cancel()
 
+	if record.UserID != "" {
+		count, err := client.Database(DBName).Collection("dns_records").CountDocuments(ctx, bson.M{"user_id": record.UserID})
+		if err != nil {
+			return "", err
+		}
+		if count >= int64(GetUserRecordQuota(client, record.UserID)) {
+			return "", ErrRecordQuotaExceeded
+		}
+	}
+
+	// MaxRecordsPerDomain applies regardless of who owns the record, so a
+	// system-created one (e.g. the ACME DNS-01 TXT challenge, UserID left
+	// empty) still counts against the domain's own cap.
+	domainCount, err := client.Database(DBName).Collection("dns_records").CountDocuments(ctx, bson.M{"domain_id": record.DomainID})
+	if err != nil {
+		return "", err
+	}
+	if domainCount >= int64(MaxRecordsPerDomain) {
+		return "", ErrDomainRecordQuotaExceeded
+	}
+
 	if record.ID == "" {
 		record.ID = primitive.NewObjectID().Hex()
 	}
 	record.CreatedAt = time.Now()
 
-	_, err := client.Database(DBName).Collection("dns_records").InsertOne(ctx, record)
+	_, err = client.Database(DBName).Collection("dns_records").InsertOne(ctx, record)
 	if err != nil {
 		return "", err
 	}
+	fireDNSInvalidationHooks()
 	return record.ID, nil
 }
 
@@ -260,15 +313,36 @@ func GetDNSRecordByID(client *mongo.Client, recordID string) (*DNSRecord, error)
 	return &record, nil
 }
 
-func DeleteDNSRecord(client *mongo.Client, recordID string) error {
+// DeleteDNSRecord removes recordID. userID is checked against
+// AssertUserOwnsRecord unless empty, which a system process (e.g. the ACME
+// DNS-01 challenge cleanup in internal/acme) uses to remove a record it
+// created on a user's behalf without needing to carry their identity.
+func DeleteDNSRecord(client *mongo.Client, userID, recordID string) error {
+	if userID != "" {
+		if err := AssertUserOwnsRecord(client, userID, recordID); err != nil {
+			return err
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
 	defer cancel()
 
 	_, err := client.Database(DBName).Collection("dns_records").DeleteOne(ctx, bson.M{"_id": recordID})
+	if err == nil {
+		fireDNSInvalidationHooks()
+	}
 	return err
 }
 
-func UpdateDNSRecordProxy(client *mongo.Client, recordID string, proxied bool) error {
+// UpdateDNSRecordProxy is DeleteDNSRecord's counterpart for a proxy-status
+// change; see its userID doc.
+func UpdateDNSRecordProxy(client *mongo.Client, userID, recordID string, proxied bool) error {
+	if userID != "" {
+		if err := AssertUserOwnsRecord(client, userID, recordID); err != nil {
+			return err
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
 	defer cancel()
 
@@ -277,6 +351,9 @@ func UpdateDNSRecordProxy(client *mongo.Client, recordID string, proxied bool) e
 	update := bson.M{"$set": bson.M{"proxied": proxied}}
 
 	_, err := collection.UpdateOne(ctx, filter, update)
+	if err == nil {
+		fireDNSInvalidationHooks()
+	}
 	return err
 }
 
@@ -357,19 +434,24 @@ func UpdateRule(client *mongo.Client, rule models.WAFRule) error {
 	return err
 }
 
+// DeleteRule removes a custom rule and, in the same transaction, every
+// per-user/per-domain RulePolicy override referencing it — without this,
+// toggling the rule back on by ID (ToggleRule) would silently resurrect a
+// policy for a rule that no longer exists.
 func DeleteRule(client *mongo.Client, ruleID, ownerID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
-	defer cancel()
+	return WithTx(client, func(sessCtx mongo.SessionContext) error {
+		filter := bson.M{"_id": ruleID, "owner_id": ownerID}
+		res, err := client.Database(DBName).Collection("rules").DeleteOne(sessCtx, filter)
+		if err != nil {
+			return err
+		}
+		if res.DeletedCount == 0 {
+			return errors.New("rule not found or unauthorized")
+		}
 
-	filter := bson.M{"_id": ruleID, "owner_id": ownerID}
-	res, err := client.Database(DBName).Collection("rules").DeleteOne(ctx, filter)
-	if err != nil {
+		_, err = client.Database(DBName).Collection("rule_policies").DeleteMany(sessCtx, bson.M{"rule_id": ruleID})
 		return err
-	}
-	if res.DeletedCount == 0 {
-		return errors.New("rule not found or unauthorized")
-	}
-	return nil
+	})
 }
 
 // ---------------------------------------------------------
@@ -421,16 +503,10 @@ type LogFilter struct {
 	Limit    int64
 }
 
-type PaginatedLogs struct {
-	// [CRITICAL FIX] Use specific struct, NOT interface{}
-	Data       []models.AttackLog `json:"data"`
-	Pagination struct {
-		CurrentPage int64 `json:"current_page"`
-		TotalPages  int64 `json:"total_pages"`
-		TotalItems  int64 `json:"total_items"`
-		PerPage     int64 `json:"per_page"`
-	} `json:"pagination"`
-}
+// PaginatedLogs is now just the AttackLog instantiation of the generic
+// PageResult (repo.go) — kept as a named type so every existing caller's
+// *database.PaginatedLogs signature keeps compiling unchanged.
+type PaginatedLogs = PageResult[models.AttackLog]
 
 func GetLogs(client *mongo.Client, filter LogFilter) (*PaginatedLogs, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -452,29 +528,19 @@ func GetLogs(client *mongo.Client, filter LogFilter) (*PaginatedLogs, error) {
 		mongoFilter["user_id"] = filter.UserID
 	}
 
+	opts := PageOpts{Page: filter.Page, Limit: filter.Limit}.normalize()
+
 	totalItems, err := collection.CountDocuments(ctx, mongoFilter)
 	if err != nil {
 		return nil, err
 	}
 
-	if filter.Page < 1 {
-		filter.Page = 1
-	}
-	if filter.Limit < 1 {
-		filter.Limit = 20
-	}
-	skip := (filter.Page - 1) * filter.Limit
-	totalPages := int64(0)
-	if filter.Limit > 0 {
-		totalPages = (totalItems + filter.Limit - 1) / filter.Limit
-	}
-
-	opts := options.Find().
+	findOpts := options.Find().
 		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
-		SetSkip(skip).
-		SetLimit(filter.Limit)
+		SetSkip((opts.Page - 1) * opts.Limit).
+		SetLimit(opts.Limit)
 
-	cursor, err := collection.Find(ctx, mongoFilter, opts)
+	cursor, err := collection.Find(ctx, mongoFilter, findOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -489,20 +555,7 @@ func GetLogs(client *mongo.Client, filter LogFilter) (*PaginatedLogs, error) {
 		logs = []models.AttackLog{}
 	}
 
-	return &PaginatedLogs{
-		Data: logs,
-		Pagination: struct {
-			CurrentPage int64 `json:"current_page"`
-			TotalPages  int64 `json:"total_pages"`
-			TotalItems  int64 `json:"total_items"`
-			PerPage     int64 `json:"per_page"`
-		}{
-			CurrentPage: filter.Page,
-			TotalPages:  totalPages,
-			TotalItems:  totalItems,
-			PerPage:     filter.Limit,
-		},
-	}, nil
+	return newPageResult(logs, opts, totalItems), nil
 }
 
 // --- GLOBAL FETCH HELPERS (For API Cache Reload) ---
@@ -572,7 +625,7 @@ func compileRegexes(rules []models.WAFRule) []models.WAFRule {
 					if err == nil {
 						cond.CompiledRegex = re
 					} else {
-						log.Printf("Error compiling regex for rule %s: %v", rules[i].ID, err)
+						log.Warnf("Error compiling regex for rule %s: %v", rules[i].ID, err)
 					}
 				}
 			}
@@ -646,7 +699,17 @@ func GetOriginRecord(client *mongo.Client, host string) (*DNSRecord, error) {
 
 // Add this function to the end of the file
 
-func UpdateDNSRecordOriginSSL(client *mongo.Client, recordID string, sslStatus bool) error {
+// UpdateDNSRecordOriginSSL is DeleteDNSRecord's counterpart for an
+// origin_ssl change; see its userID doc. internal/acme passes "" after
+// issuing a record-scoped certificate, which is a system action rather
+// than something the authenticated caller triggered directly.
+func UpdateDNSRecordOriginSSL(client *mongo.Client, userID, recordID string, sslStatus bool) error {
+	if userID != "" {
+		if err := AssertUserOwnsRecord(client, userID, recordID); err != nil {
+			return err
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -661,6 +724,24 @@ func UpdateDNSRecordOriginSSL(client *mongo.Client, recordID string, sslStatus b
 	return err
 }
 
+// SetDNSRecordExternalID persists the id a dnsbackend.Provider assigned a
+// record after publishing it (e.g. Cloudflare's own record id), so a later
+// DeleteRecord call can address it directly instead of re-discovering it
+// with a lookup call. A no-op for providers that return an empty id
+// (PowerDNS, Route53).
+func SetDNSRecordExternalID(client *mongo.Client, recordID, externalID string) error {
+	if externalID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	_, err := client.Database(DBName).Collection("dns_records").UpdateOne(ctx,
+		bson.M{"_id": recordID},
+		bson.M{"$set": bson.M{"external_id": externalID}})
+	return err
+}
+
 // GetAllDNSRecords fetches all DNS records (for WAF routing)
 func GetAllDNSRecords(client *mongo.Client) ([]DNSRecord, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"web-app-firewall-ml-detection/internal/detector"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrEmailOwnedByUnverifiedProvider is returned by UpsertOAuthUser when the
+// external identity's email matches an existing local account but the IdP
+// didn't assert email_verified — auto-linking here would let anyone who can
+// register that address at a permissive IdP take over the existing
+// password account. The caller must reject the login instead of silently
+// provisioning or linking.
+var ErrEmailOwnedByUnverifiedProvider = errors.New("oauth: email ownership not verified by identity provider")
+
+// GetUserByExternalID looks up a user previously auto-provisioned through an
+// OAuth/OIDC login for the given provider.
+func GetUserByExternalID(client *mongo.Client, authType, externalID string) (*detector.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	var user detector.User
+	filter := bson.M{"auth_type": authType, "external_id": externalID}
+	err := client.Database(DBName).Collection("users").FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpsertOAuthUser auto-provisions a user on first login from an external
+// identity provider, or returns the existing one on subsequent logins.
+// emailVerified must reflect the IdP's own email_verified claim (or
+// equivalent) for the email being linked — it's what gates linking to an
+// existing local account, see ErrEmailOwnedByUnverifiedProvider.
+func UpsertOAuthUser(client *mongo.Client, authType, externalID, email, name string, emailVerified bool) (*detector.User, error) {
+	if existing, err := GetUserByExternalID(client, authType, externalID); err == nil {
+		return existing, nil
+	}
+
+	// No external-id match yet. If the email is already registered
+	// (e.g. via password signup), link this provider to that account
+	// instead of creating a duplicate user — but only once ownership of
+	// the email is actually established, so an attacker can't take over
+	// victim@example.com's password account just by registering it at a
+	// permissive IdP and signing in.
+	if existing, err := GetUserByEmail(client, email); err == nil {
+		if !emailVerified {
+			return nil, ErrEmailOwnedByUnverifiedProvider
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+		defer cancel()
+		_, err := client.Database(DBName).Collection("users").UpdateOne(ctx,
+			bson.M{"_id": existing.ID},
+			bson.M{"$set": bson.M{"auth_type": authType, "external_id": externalID}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		existing.AuthType = authType
+		existing.ExternalID = externalID
+		return existing, nil
+	}
+
+	user := detector.User{
+		ID:         primitive.NewObjectID().Hex(),
+		Name:       name,
+		Email:      email,
+		AuthType:   authType,
+		ExternalID: externalID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+	if _, err := client.Database(DBName).Collection("users").InsertOne(ctx, user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
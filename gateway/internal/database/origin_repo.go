@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetOriginPool returns every A/AAAA record for host, i.e. the full set of
+// candidate upstreams a proxy can load-balance/failover across. Unlike
+// GetOriginRecord this deliberately does not fall back to CNAME — pooling
+// only makes sense across records that resolve to concrete addresses.
+func GetOriginPool(client *mongo.Client, host string) ([]DNSRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	cursor, err := client.Database(DBName).Collection("dns_records").Find(ctx, bson.M{
+		"name": host,
+		"type": bson.M{"$in": []string{"A", "AAAA"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []DNSRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// UpdateRecordHealth persists the result of a single health probe. Latency
+// is folded into the stored value as a simple EWMA so one slow/fast probe
+// doesn't whipsaw the least-latency policy.
+func UpdateRecordHealth(client *mongo.Client, recordID string, healthy bool, latency time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	record, err := GetDNSRecordByID(client, recordID)
+	if err != nil {
+		return err
+	}
+
+	sampleMS := latency.Milliseconds()
+	newLatency := sampleMS
+	if record.LatencyMS > 0 {
+		newLatency = (record.LatencyMS*3 + sampleMS) / 4
+	}
+
+	_, err = client.Database(DBName).Collection("dns_records").UpdateOne(ctx,
+		bson.M{"_id": recordID},
+		bson.M{"$set": bson.M{
+			"healthy":      healthy,
+			"last_checked": time.Now(),
+			"latency_ms":   newLatency,
+		}},
+	)
+	return err
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/detector"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const refreshTokensCollection = "refresh_tokens"
+
+// HashRefreshToken is the lookup key stored instead of the raw opaque
+// token, so a leaked database dump can't be replayed directly — the same
+// reasoning as storing a bcrypt hash of a password, just a plain SHA-256
+// since a refresh token is already high-entropy random data rather than
+// user-chosen.
+func HashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateRefreshToken persists a newly issued refresh token, hashed, and
+// returns its jti (the Mongo _id) for the rotation chain to reference.
+func CreateRefreshToken(client *mongo.Client, userID, rawToken, userAgent, ip string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	doc := detector.RefreshToken{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    userID,
+		TokenHash: HashRefreshToken(rawToken),
+		IssuedAt:  time.Now(),
+		ExpiresAt: time.Now().Add(ttl),
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+	if _, err := client.Database(DBName).Collection(refreshTokensCollection).InsertOne(ctx, doc); err != nil {
+		return "", err
+	}
+	return doc.ID, nil
+}
+
+// GetRefreshTokenByHash looks up a refresh token by the hash of its raw
+// value, as presented in the refresh_token cookie.
+func GetRefreshTokenByHash(client *mongo.Client, tokenHash string) (*detector.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	var doc detector.RefreshToken
+	err := client.Database(DBName).Collection(refreshTokensCollection).FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// GetRefreshTokenByID looks up a refresh token by its jti (Mongo _id), for
+// callers that already know which session they're checking (e.g. CheckAuth
+// confirming the session an access token was minted under is still live).
+func GetRefreshTokenByID(client *mongo.Client, jti string) (*detector.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	var doc detector.RefreshToken
+	err := client.Database(DBName).Collection(refreshTokensCollection).FindOne(ctx, bson.M{"_id": jti}).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// RotateRefreshToken atomically marks oldJTI as rotated to newJTI, so a
+// later presentation of the old token's raw value (already rotated away)
+// is recognizable as reuse rather than a legitimate refresh.
+func RotateRefreshToken(client *mongo.Client, oldJTI, newJTI string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	_, err := client.Database(DBName).Collection(refreshTokensCollection).UpdateOne(ctx,
+		bson.M{"_id": oldJTI},
+		bson.M{"$set": bson.M{"rotated_to": newJTI}},
+	)
+	return err
+}
+
+// RevokeRefreshToken marks a single token revoked, e.g. on /auth/logout or
+// a user revoking one session from /auth/sessions.
+func RevokeRefreshToken(client *mongo.Client, jti string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	_, err := client.Database(DBName).Collection(refreshTokensCollection).UpdateOne(ctx,
+		bson.M{"_id": jti},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+// RevokeAllRefreshTokensForUser cascade-revokes every refresh token for a
+// user: used both for "log out everywhere" and as the response to a
+// detected stolen-token reuse.
+func RevokeAllRefreshTokensForUser(client *mongo.Client, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	_, err := client.Database(DBName).Collection(refreshTokensCollection).UpdateMany(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+// ListRefreshTokensForUser returns every non-revoked, unexpired session for
+// a user, for the /auth/sessions listing endpoint.
+func ListRefreshTokensForUser(client *mongo.Client, userID string) ([]detector.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "revoked": false, "expires_at": bson.M{"$gt": time.Now()}}
+	cursor, err := client.Database(DBName).Collection(refreshTokensCollection).Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []detector.RefreshToken
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Sentinel errors every repository-style function should map driver errors
+// onto, so callers can errors.Is instead of string-matching err.Error() (as
+// CreateUser used to for "email already registered").
+var (
+	ErrNotFound  = errors.New("database: not found")
+	ErrDuplicate = errors.New("database: duplicate key")
+	ErrDecode    = errors.New("database: decode failed")
+)
+
+// mapMongoErr translates a raw mongo-driver error into one of the sentinels
+// above when it recognizes the shape, and returns err unchanged otherwise —
+// callers that don't care keep working exactly as before.
+func mapMongoErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if strings.Contains(err.Error(), "cannot decode") {
+		return ErrDecode
+	}
+	return err
+}
+
+// WithTx runs fn inside a MongoDB multi-document transaction: every write fn
+// makes through sessCtx either all commit or all roll back. Use this for the
+// rare case where two or more collections must change together (e.g.
+// DeleteRule's rule+policy cleanup) — most of this package's functions are
+// single-document writes and don't need it.
+func WithTx(client *mongo.Client, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// PageOpts is the paging input shared by every list endpoint that reuses
+// PageResult — the generic counterpart to the ad hoc Page/Limit fields
+// LogFilter already had before GetLogs grew a second caller.
+type PageOpts struct {
+	Page  int64
+	Limit int64
+}
+
+// normalize applies GetLogs's long-standing defaults (page 1, 20 per page)
+// to a zero-value PageOpts.
+func (p PageOpts) normalize() PageOpts {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = 20
+	}
+	return p
+}
+
+// PageResult is a reusable version of the pagination envelope GetLogs
+// returned as a one-off PaginatedLogs struct — any other list endpoint can
+// now return a PageResult[T] instead of hand-rolling the same Pagination
+// block.
+type PageResult[T any] struct {
+	Data       []T `json:"data"`
+	Pagination struct {
+		CurrentPage int64 `json:"current_page"`
+		TotalPages  int64 `json:"total_pages"`
+		TotalItems  int64 `json:"total_items"`
+		PerPage     int64 `json:"per_page"`
+	} `json:"pagination"`
+}
+
+// newPageResult builds a PageResult from a page of already-fetched data plus
+// the total document count the filter matched.
+func newPageResult[T any](data []T, opts PageOpts, totalItems int64) *PageResult[T] {
+	opts = opts.normalize()
+	totalPages := totalItems / opts.Limit
+	if totalItems%opts.Limit != 0 {
+		totalPages++
+	}
+
+	result := &PageResult[T]{Data: data}
+	result.Pagination.CurrentPage = opts.Page
+	result.Pagination.TotalPages = totalPages
+	result.Pagination.TotalItems = totalItems
+	result.Pagination.PerPage = opts.Limit
+	return result
+}
+
+// Repo is a thin generic wrapper around a single collection, for the
+// handful of types (RulePolicy, RefreshToken, ...) whose Find/Insert/Update/
+// Delete bodies would otherwise just repeat mongo.go's boilerplate with a
+// different collection name and struct. It's additive: existing
+// collection-specific functions in this package are untouched.
+type Repo[T any] struct {
+	client     *mongo.Client
+	collection string
+}
+
+// NewRepo returns a Repo bound to collection in the shared DBName database.
+func NewRepo[T any](client *mongo.Client, collection string) *Repo[T] {
+	return &Repo[T]{client: client, collection: collection}
+}
+
+func (r *Repo[T]) coll() *mongo.Collection {
+	return r.client.Database(DBName).Collection(r.collection)
+}
+
+// FindOne returns the first document matching filter, or ErrNotFound.
+func (r *Repo[T]) FindOne(ctx context.Context, filter bson.M) (*T, error) {
+	var out T
+	if err := r.coll().FindOne(ctx, filter).Decode(&out); err != nil {
+		return nil, mapMongoErr(err)
+	}
+	return &out, nil
+}
+
+// FindMany returns one page of documents matching filter, newest behavior
+// matching GetLogs: page/limit default to 1/20, and TotalItems counts the
+// whole filter, not just the returned page.
+func (r *Repo[T]) FindMany(ctx context.Context, filter bson.M, opts PageOpts) (*PageResult[T], error) {
+	opts = opts.normalize()
+
+	totalItems, err := r.coll().CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	findOpts := options.Find().
+		SetSkip((opts.Page - 1) * opts.Limit).
+		SetLimit(opts.Limit)
+
+	cursor, err := r.coll().Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []T
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, mapMongoErr(err)
+	}
+
+	return newPageResult(docs, opts, totalItems), nil
+}
+
+// Insert inserts doc as-is and maps a unique-index violation to
+// ErrDuplicate.
+func (r *Repo[T]) Insert(ctx context.Context, doc T) error {
+	_, err := r.coll().InsertOne(ctx, doc)
+	return mapMongoErr(err)
+}
+
+// Upsert updates the first document matching filter with update, inserting
+// one if none matched — the generic form of UpsertRulePolicy's
+// UpdateOne-with-upsert pattern.
+func (r *Repo[T]) Upsert(ctx context.Context, filter, update bson.M) error {
+	_, err := r.coll().UpdateOne(ctx, filter, bson.M{"$set": update}, options.Update().SetUpsert(true))
+	return mapMongoErr(err)
+}
+
+// Update applies update to the first document matching filter, returning
+// ErrNotFound if nothing matched.
+func (r *Repo[T]) Update(ctx context.Context, filter, update bson.M) error {
+	res, err := r.coll().UpdateOne(ctx, filter, bson.M{"$set": update})
+	if err != nil {
+		return mapMongoErr(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes the first document matching filter, returning ErrNotFound
+// if nothing matched.
+func (r *Repo[T]) Delete(ctx context.Context, filter bson.M) error {
+	res, err := r.coll().DeleteOne(ctx, filter)
+	if err != nil {
+		return mapMongoErr(err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
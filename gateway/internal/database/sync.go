@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultSyncCollections is what Syncer mirrors when SyncConfig.Collections
+// is empty — rules/rule_policies/domains/dns_records are small and
+// cluster-critical; the much larger attack-log history is deliberately
+// left out, the same reasoning watchedCollections (internal/dnsserver)
+// uses for which collections are worth watching at all.
+var DefaultSyncCollections = []string{"rules", "rule_policies", "domains", "dns_records"}
+
+// DefaultSyncInterval is the fallback Syncer uses whenever SyncConfig.Interval
+// is unset — the same interval config.ReplicaSyncConfig's default cron
+// ("*/5 * * * *") computes via config.computeSyncInterval.
+const DefaultSyncInterval = 5 * time.Minute
+
+// CollectionSyncStats is the per-collection tally one Sync pass produced
+// for one replica.
+type CollectionSyncStats struct {
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+	Errors  int `json:"errors"`
+}
+
+// ReplicaHealth is one replica's standing as of the last Sync pass.
+type ReplicaHealth struct {
+	Reachable   bool                           `json:"reachable"`
+	DriftCount  int                            `json:"drift_count"`
+	Collections map[string]CollectionSyncStats `json:"collections"`
+}
+
+// SyncStatus is the snapshot GET /api/replica/status reports.
+type SyncStatus struct {
+	LastSync time.Time                `json:"last_sync"`
+	Replicas map[string]ReplicaHealth `json:"replicas"`
+}
+
+// SyncConfig selects what Syncer mirrors and which fields a replica is
+// allowed to keep its own value for instead of being overwritten by the
+// primary — e.g. DNSRecord's origin_ssl, which an operator may deliberately
+// flip per-replica while testing origin TLS.
+type SyncConfig struct {
+	Collections     []string
+	ProtectedFields map[string][]string // collection name -> protected field names
+	Interval        time.Duration
+}
+
+// Syncer mirrors SyncConfig.Collections from a primary MongoDB to one or
+// more named replicas on Interval, using an updated_at watermark per
+// collection so a pass only touches documents that changed since the last
+// one. Conflicts resolve last-writer-wins by updated_at, except for
+// ProtectedFields, which always keep the replica's existing value.
+//
+// Deletions on the primary are only detected on a full pass (the first
+// one, or any pass after watermarks are reset) since that's the only time
+// this has the complete primary id set to diff against the replica's.
+// Incremental passes skip delete detection rather than re-scanning the
+// whole collection every cycle.
+type Syncer struct {
+	primary  *mongo.Client
+	replicas map[string]*mongo.Client
+	cfg      SyncConfig
+
+	mu         sync.Mutex
+	watermarks map[string]time.Time
+	status     SyncStatus
+}
+
+// NewSyncer wires a Syncer against primary and the named replicas. A zero
+// Collections/Interval in cfg falls back to DefaultSyncCollections/
+// DefaultSyncInterval.
+func NewSyncer(primary *mongo.Client, replicas map[string]*mongo.Client, cfg SyncConfig) *Syncer {
+	if len(cfg.Collections) == 0 {
+		cfg.Collections = DefaultSyncCollections
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultSyncInterval
+	}
+	return &Syncer{
+		primary:    primary,
+		replicas:   replicas,
+		cfg:        cfg,
+		watermarks: make(map[string]time.Time),
+	}
+}
+
+// Run calls Sync once immediately, then every cfg.Interval until ctx is
+// cancelled. Call this in its own goroutine, the same convention
+// internal/dnsserver's watchZoneChanges follows.
+func (s *Syncer) Run(ctx context.Context) {
+	s.runOnce(ctx)
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx)
+		}
+	}
+}
+
+func (s *Syncer) runOnce(ctx context.Context) {
+	status, err := s.Sync(ctx)
+	if err != nil {
+		log.Printf("[ERROR] database: replica sync failed: %v", err)
+		return
+	}
+	s.mu.Lock()
+	s.status = status
+	s.mu.Unlock()
+}
+
+// Status returns the SyncStatus computed by the most recent Sync pass —
+// the zero value before the first one has run. GET /api/replica/status
+// reads this rather than forcing a pass on every request.
+func (s *Syncer) Status() SyncStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Sync runs one pass across every configured collection and replica, and
+// returns the resulting SyncStatus (also cached for Status).
+func (s *Syncer) Sync(ctx context.Context) (SyncStatus, error) {
+	status := SyncStatus{
+		LastSync: time.Now(),
+		Replicas: make(map[string]ReplicaHealth, len(s.replicas)),
+	}
+
+	for name, replica := range s.replicas {
+		health := ReplicaHealth{Collections: make(map[string]CollectionSyncStats, len(s.cfg.Collections))}
+
+		if err := replica.Ping(ctx, nil); err != nil {
+			status.Replicas[name] = health
+			continue
+		}
+		health.Reachable = true
+
+		for _, coll := range s.cfg.Collections {
+			stats := s.syncCollection(ctx, replica, coll)
+			health.Collections[coll] = stats
+			health.DriftCount += stats.Errors
+		}
+		status.Replicas[name] = health
+	}
+
+	s.mu.Lock()
+	for _, coll := range s.cfg.Collections {
+		s.watermarks[coll] = status.LastSync
+	}
+	s.mu.Unlock()
+
+	return status, nil
+}
+
+// syncCollection mirrors one collection from primary to replica: every
+// primary document with updated_at newer than the last watermark is
+// upserted, preserving any ProtectedFields value the replica already had,
+// and (on a full pass only — see Syncer's doc comment) any document
+// present on replica but no longer on primary is deleted.
+//
+// Not every collection mirrored here stamps updated_at on every write yet
+// (domains/dns_records only carry created_at today) — a document that
+// never got an updated_at only ever syncs on a full pass, same as a brand
+// new replica. Backfilling updated_at onto every existing write path is
+// out of scope here; SyncConfig.Collections lets an operator leave a
+// collection out entirely until it does.
+func (s *Syncer) syncCollection(ctx context.Context, replica *mongo.Client, coll string) CollectionSyncStats {
+	var stats CollectionSyncStats
+
+	s.mu.Lock()
+	watermark := s.watermarks[coll]
+	s.mu.Unlock()
+
+	primaryColl := s.primary.Database(DBName).Collection(coll)
+	replicaColl := replica.Database(DBName).Collection(coll)
+
+	filter := bson.M{}
+	if !watermark.IsZero() {
+		filter["updated_at"] = bson.M{"$gt": watermark}
+	}
+
+	cursor, err := primaryColl.Find(ctx, filter)
+	if err != nil {
+		stats.Errors++
+		return stats
+	}
+	defer cursor.Close(ctx)
+
+	protected := s.cfg.ProtectedFields[coll]
+	primaryIDs := make(map[interface{}]struct{})
+
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			stats.Errors++
+			continue
+		}
+		id := doc["_id"]
+		primaryIDs[id] = struct{}{}
+
+		var existing bson.M
+		err := replicaColl.FindOne(ctx, bson.M{"_id": id}).Decode(&existing)
+		existed := err == nil
+		if err != nil && err != mongo.ErrNoDocuments {
+			stats.Errors++
+			continue
+		}
+
+		if existed {
+			for _, field := range protected {
+				if v, ok := existing[field]; ok {
+					doc[field] = v
+				}
+			}
+		}
+
+		if _, err := replicaColl.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": doc}, options.Update().SetUpsert(true)); err != nil {
+			stats.Errors++
+			continue
+		}
+		if existed {
+			stats.Updated++
+		} else {
+			stats.Added++
+		}
+	}
+
+	if watermark.IsZero() {
+		replicaCursor, err := replicaColl.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err == nil {
+			defer replicaCursor.Close(ctx)
+			for replicaCursor.Next(ctx) {
+				var doc bson.M
+				if err := replicaCursor.Decode(&doc); err != nil {
+					continue
+				}
+				if _, ok := primaryIDs[doc["_id"]]; ok {
+					continue
+				}
+				if _, err := replicaColl.DeleteOne(ctx, bson.M{"_id": doc["_id"]}); err != nil {
+					stats.Errors++
+				} else {
+					stats.Deleted++
+				}
+			}
+		}
+	}
+
+	return stats
+}
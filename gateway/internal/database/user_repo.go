@@ -2,6 +2,9 @@ package database
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 	"web-app-firewall-ml-detection/internal/models"
@@ -11,6 +14,42 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// VerificationTokenSecret keys HashVerificationToken's HMAC, so a MongoDB
+// dump of the users collection doesn't leak working verification links —
+// main.go sets it from the same Config.JWTSecret everything else signs
+// with, rather than minting a second secret for one more subsystem.
+var VerificationTokenSecret []byte
+
+// verificationTokenTTL bounds how long a token issued by Register/
+// RegenerateVerificationToken is accepted by VerifyUserToken before it's
+// treated as expired and must be re-issued.
+const verificationTokenTTL = 24 * time.Hour
+
+// verificationResendCooldown is the minimum gap RegenerateVerificationToken
+// enforces between two token issuances for the same user, so a compromised
+// or scripted client can't burn through the mail provider's send quota.
+const verificationResendCooldown = 5 * time.Minute
+
+// ErrTokenExpired is returned by VerifyUserToken when token was valid but
+// issued more than verificationTokenTTL ago.
+var ErrTokenExpired = errors.New("verification token expired")
+
+// ErrVerificationResendTooSoon is returned by RegenerateVerificationToken
+// when the user's last token was issued less than
+// verificationResendCooldown ago.
+var ErrVerificationResendTooSoon = errors.New("verification email already sent recently")
+
+// HashVerificationToken is the lookup key stored instead of the raw token a
+// user receives by email — the same reasoning HashRefreshToken
+// (refresh_tokens.go) gives for hashing refresh tokens, keyed here since
+// unlike a refresh token this one travels over email and a server secret
+// adds a second factor an attacker would also need.
+func HashVerificationToken(raw string) string {
+	mac := hmac.New(sha256.New, VerificationTokenSecret)
+	mac.Write([]byte(raw))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func CreateUser(client *mongo.Client, user models.User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
 	defer cancel()
@@ -50,25 +89,69 @@ func GetUserByID(client *mongo.Client, id string) (*models.User, error) {
 	return &user, nil
 }
 
+// VerifyUserToken marks the user holding token as verified, rejecting it
+// with ErrTokenExpired if it was issued more than verificationTokenTTL ago
+// — the raw token never reaches Mongo, only its HMAC, so a database dump
+// alone isn't enough to forge a working verification link.
 func VerifyUserToken(client *mongo.Client, token string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"verification_token": token}
+	var user models.User
+	hashed := HashVerificationToken(token)
+	if err := client.Database(DBName).Collection("users").FindOne(ctx, bson.M{"verification_token": hashed}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("invalid or expired verification token")
+		}
+		return err
+	}
+
+	if time.Since(user.VerificationTokenIssuedAt) > verificationTokenTTL {
+		return ErrTokenExpired
+	}
+
 	update := bson.M{
 		"$set": bson.M{
 			"is_verified":        true,
 			"verification_token": "", // Clear the token after use
 		},
 	}
+	_, err := client.Database(DBName).Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, update)
+	return err
+}
+
+// RegenerateVerificationToken issues a fresh verification token for email,
+// rate-limited to one reissue per verificationResendCooldown, and returns
+// the raw token to send in the new link (only its hash is persisted). Used
+// by the "resend verification email" flow once the original token has
+// expired or never arrived.
+func RegenerateVerificationToken(client *mongo.Client, email string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeoutDuration)
+	defer cancel()
 
-	result, err := client.Database(DBName).Collection("users").UpdateOne(ctx, filter, update)
+	user, err := GetUserByEmail(client, email)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if user.IsVerified {
+		return "", errors.New("email already verified")
 	}
-	if result.MatchedCount == 0 {
-		return errors.New("invalid or expired verification token")
+	if time.Since(user.VerificationTokenIssuedAt) < verificationResendCooldown {
+		return "", ErrVerificationResendTooSoon
 	}
 
-	return nil
+	rawToken := primitive.NewObjectID().Hex() + primitive.NewObjectID().Hex()
+	now := time.Now()
+
+	_, err = client.Database(DBName).Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{
+			"verification_token":           HashVerificationToken(rawToken),
+			"verification_token_issued_at": now,
+		}},
+	)
+	if err != nil {
+		return "", err
+	}
+	return rawToken, nil
 }
\ No newline at end of file
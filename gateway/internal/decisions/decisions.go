@@ -0,0 +1,25 @@
+// Package decisions mirrors a CrowdSec-compatible Local API's ban list
+// into an in-memory trie the WAF hot path can consult on every request
+// without a network round trip, plus a small admin surface to inspect or
+// override it manually.
+package decisions
+
+import "time"
+
+// Decision is one active ban, either pulled from the LAPI decisions stream
+// or added directly through the admin API.
+type Decision struct {
+	Value     string    `json:"value"`      // IP or CIDR, e.g. "1.2.3.4" or "1.2.3.0/24"
+	Type      string    `json:"type"`       // "ban" is the only type WAFHandler acts on today
+	Scope     string    `json:"scope"`      // "Ip" or "Range", as CrowdSec reports it
+	Reason    string    `json:"reason"`     // CrowdSec's "scenario", or the operator's note for a manual ban
+	Origin    string    `json:"origin"`     // "crowdsec" or "manual"
+	ExpiresAt time.Time `json:"expires_at"` // zero means "no expiry" (manual bans, or a CrowdSec duration that failed to parse)
+	Country   string    `json:"country,omitempty"` // ISO code from GeoIP, empty if unresolved/unconfigured/a range
+}
+
+// Expired reports whether d's ban window has passed. A zero ExpiresAt
+// never expires.
+func (d Decision) Expired() bool {
+	return !d.ExpiresAt.IsZero() && time.Now().After(d.ExpiresAt)
+}
@@ -0,0 +1,214 @@
+package decisions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/metrics"
+)
+
+// lapiDecision mirrors one entry of a CrowdSec LAPI decisions-stream
+// response. Duration is a Go-duration-formatted string like "3h59m48s",
+// occasionally negative once a decision is already past expiry.
+type lapiDecision struct {
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+	Scope    string `json:"scope"`
+	Scenario string `json:"scenario"`
+	Origin   string `json:"origin"`
+	Duration string `json:"duration"`
+}
+
+// streamResponse mirrors GET /v1/decisions/stream's body.
+type streamResponse struct {
+	New     []lapiDecision `json:"new"`
+	Deleted []lapiDecision `json:"deleted"`
+}
+
+// Feed polls a CrowdSec-compatible LAPI decisions stream and keeps its
+// Trie in sync with the new/deleted lists, so WAFHandler's hot-path
+// Lookup never itself makes a network call.
+type Feed struct {
+	baseURL  string
+	apiKey   string
+	interval time.Duration
+	client   *http.Client
+
+	trie *Trie
+	geo  *GeoIP // nil if no mmdb path was configured
+
+	lastPull   atomic.Value // time.Time
+	pullErrors int64        // atomic
+}
+
+// NewFeed builds a Feed against baseURL (e.g. "http://crowdsec:8080"),
+// polling every interval. geo may be nil to skip country enrichment.
+func NewFeed(baseURL, apiKey string, interval time.Duration, geo *GeoIP) *Feed {
+	return &Feed{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		apiKey:   apiKey,
+		interval: interval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		trie:     NewTrie(),
+		geo:      geo,
+	}
+}
+
+// Trie exposes the live decision set WAFHandler checks on the hot path.
+func (f *Feed) Trie() *Trie {
+	return f.trie
+}
+
+// Start launches the background poll loop: an immediate startup=true
+// pull, then startup=false every f.interval until ctx is cancelled.
+func (f *Feed) Start(ctx context.Context) {
+	go f.run(ctx)
+}
+
+func (f *Feed) run(ctx context.Context) {
+	f.pull(ctx, true)
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.pull(ctx, false)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (f *Feed) pull(ctx context.Context, startup bool) {
+	url := fmt.Sprintf("%s/v1/decisions/stream?startup=%t", f.baseURL, startup)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err == nil {
+		req.Header.Set("X-Api-Key", f.apiKey)
+	}
+
+	var parsed streamResponse
+	if err == nil {
+		var resp *http.Response
+		resp, err = f.client.Do(req)
+		if err == nil {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("decisions: LAPI returned %d", resp.StatusCode)
+			} else {
+				err = json.NewDecoder(resp.Body).Decode(&parsed)
+			}
+		}
+	}
+
+	if err != nil {
+		atomic.AddInt64(&f.pullErrors, 1)
+		metrics.IncDecisionsPullError()
+		return
+	}
+
+	for _, d := range parsed.Deleted {
+		f.trie.Delete(d.Value)
+	}
+	for _, d := range parsed.New {
+		f.trie.Insert(d.Value, f.toDecision(d))
+	}
+
+	f.lastPull.Store(time.Now())
+	f.publishStats()
+}
+
+func (f *Feed) toDecision(d lapiDecision) *Decision {
+	dec := &Decision{
+		Value:     d.Value,
+		Type:      d.Type,
+		Scope:     d.Scope,
+		Reason:    d.Scenario,
+		Origin:    d.Origin,
+		ExpiresAt: expiresAt(d.Duration),
+	}
+	if f.geo != nil && strings.EqualFold(d.Scope, "Ip") {
+		dec.Country = f.geo.Country(d.Value)
+	}
+	return dec
+}
+
+// expiresAt parses CrowdSec's Go-duration-formatted Duration field into an
+// absolute time.Time Decision.Expired can compare against. An unparsable
+// value leaves ExpiresAt zero (never expires) rather than dropping the ban.
+func expiresAt(d string) time.Time {
+	neg := strings.HasPrefix(d, "-")
+	dur, err := time.ParseDuration(strings.TrimPrefix(d, "-"))
+	if err != nil {
+		return time.Time{}
+	}
+	if neg {
+		return time.Now().Add(-dur)
+	}
+	return time.Now().Add(dur)
+}
+
+// AddManual inserts an operator-created ban directly into the trie,
+// bypassing the LAPI feed — e.g. to block an IP immediately while
+// CrowdSec itself hasn't caught up yet. ttl of zero never expires.
+func (f *Feed) AddManual(value, reason string, ttl time.Duration) error {
+	dec := &Decision{
+		Value:  value,
+		Type:   "ban",
+		Scope:  "Ip",
+		Origin: "manual",
+		Reason: reason,
+	}
+	if ttl > 0 {
+		dec.ExpiresAt = time.Now().Add(ttl)
+	}
+	if f.geo != nil {
+		dec.Country = f.geo.Country(value)
+	}
+	if err := f.trie.Insert(value, dec); err != nil {
+		return err
+	}
+	f.publishStats()
+	return nil
+}
+
+// Expire removes value's decision early, whether it came from the LAPI
+// feed or AddManual.
+func (f *Feed) Expire(value string) {
+	f.trie.Delete(value)
+	f.publishStats()
+}
+
+// List returns every active decision, for the admin list endpoint.
+func (f *Feed) List() []*Decision {
+	return f.trie.All()
+}
+
+// Stats is the snapshot SystemStatus and the admin API report.
+type Stats struct {
+	ActiveDecisions int
+	LastPullAge     time.Duration
+	PullErrors      int64
+}
+
+// Stats reports the feed's current health.
+func (f *Feed) Stats() Stats {
+	var age time.Duration
+	if t, ok := f.lastPull.Load().(time.Time); ok {
+		age = time.Since(t)
+	}
+	return Stats{
+		ActiveDecisions: f.trie.Count(),
+		LastPullAge:     age,
+		PullErrors:      atomic.LoadInt64(&f.pullErrors),
+	}
+}
+
+func (f *Feed) publishStats() {
+	stats := f.Stats()
+	metrics.SetDecisionsStats(stats.ActiveDecisions, stats.LastPullAge)
+}
@@ -0,0 +1,51 @@
+package decisions
+
+import (
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoIP resolves a client IP to its ISO country code from a MaxMind
+// GeoLite2-Country mmdb file on disk, so a manually-added ban (or the
+// admin list endpoint) can show an operator where a blocked IP is from
+// without a separate lookup.
+type GeoIP struct {
+	reader *geoip2.Reader
+}
+
+// OpenGeoIP loads the mmdb at path. Callers should treat a non-nil error
+// as "country lookups unavailable" rather than fatal — GeoIP is a
+// convenience on top of the decisions trie, not a dependency of it.
+func OpenGeoIP(path string) (*GeoIP, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIP{reader: reader}, nil
+}
+
+// Country returns ip's ISO country code (e.g. "DE"), or "" if it can't be
+// resolved.
+func (g *GeoIP) Country(ip string) string {
+	if g == nil {
+		return ""
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+	record, err := g.reader.Country(addr)
+	if err != nil {
+		return ""
+	}
+	return record.Country.IsoCode
+}
+
+// Close releases the underlying mmdb file handle.
+func (g *GeoIP) Close() error {
+	if g == nil {
+		return nil
+	}
+	return g.reader.Close()
+}
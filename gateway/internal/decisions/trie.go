@@ -0,0 +1,182 @@
+package decisions
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// trieNode is one bit of a binary trie over IPv4 addresses. Walking 32
+// levels deep gives O(32) lookups regardless of how many decisions are
+// loaded, and a decision banning a /24 just terminates its branch before
+// the host bits — any address under it matches without needing its own
+// node.
+type trieNode struct {
+	children [2]*trieNode
+	decision *Decision // non-nil if a decision terminates exactly here
+}
+
+// Trie indexes active decisions by IP/CIDR for the WAF hot path. Only
+// IPv4 is supported today, matching getRealIP's own IPv4-shaped
+// X-Forwarded-For/RemoteAddr handling elsewhere in this codebase.
+type Trie struct {
+	mu   sync.RWMutex
+	root *trieNode
+}
+
+// NewTrie returns an empty Trie.
+func NewTrie() *Trie {
+	return &Trie{root: &trieNode{}}
+}
+
+// Insert adds or replaces the decision for value (an IPv4 address or CIDR).
+func (t *Trie) Insert(value string, d *Decision) error {
+	bits, err := prefixBits(value)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node := t.root
+	for _, bit := range bits {
+		idx := 0
+		if bit {
+			idx = 1
+		}
+		if node.children[idx] == nil {
+			node.children[idx] = &trieNode{}
+		}
+		node = node.children[idx]
+	}
+	node.decision = d
+	return nil
+}
+
+// Delete removes value's decision, if present. Unlike Insert, a bad value
+// is simply a no-op — there's nothing to remove either way.
+func (t *Trie) Delete(value string) {
+	bits, err := prefixBits(value)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node := t.root
+	for _, bit := range bits {
+		idx := 0
+		if bit {
+			idx = 1
+		}
+		if node.children[idx] == nil {
+			return
+		}
+		node = node.children[idx]
+	}
+	node.decision = nil
+}
+
+// Lookup walks ip's 32 bits looking for the most specific (deepest)
+// decision covering it — an exact /32 ban takes precedence over a /24 one
+// that also matches. Expired decisions are treated as absent.
+func (t *Trie) Lookup(ip string) (*Decision, bool) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, false
+	}
+	addr4 := addr.To4()
+	if addr4 == nil {
+		return nil, false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	node := t.root
+	var found *Decision
+	if node.decision != nil && !node.decision.Expired() {
+		found = node.decision
+	}
+	for _, bit := range bitsOf(addr4) {
+		idx := 0
+		if bit {
+			idx = 1
+		}
+		next := node.children[idx]
+		if next == nil {
+			break
+		}
+		node = next
+		if node.decision != nil && !node.decision.Expired() {
+			found = node.decision
+		}
+	}
+
+	if found == nil {
+		return nil, false
+	}
+	return found, true
+}
+
+// All returns every non-expired decision currently loaded, for the admin
+// list endpoint. Order is unspecified.
+func (t *Trie) All() []*Decision {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var out []*Decision
+	var walk func(n *trieNode)
+	walk = func(n *trieNode) {
+		if n == nil {
+			return
+		}
+		if n.decision != nil && !n.decision.Expired() {
+			out = append(out, n.decision)
+		}
+		walk(n.children[0])
+		walk(n.children[1])
+	}
+	walk(t.root)
+	return out
+}
+
+// Count returns the number of non-expired decisions currently loaded.
+func (t *Trie) Count() int {
+	return len(t.All())
+}
+
+// prefixBits parses value as an IPv4 address or CIDR and returns the bits
+// of its network prefix, most significant first.
+func prefixBits(value string) ([]bool, error) {
+	if ip := net.ParseIP(value); ip != nil {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return nil, fmt.Errorf("decisions: %q is not an IPv4 address", value)
+		}
+		return bitsOf(ip4), nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("decisions: %q is not a valid IP or CIDR: %w", value, err)
+	}
+	ones, bits := ipNet.Mask.Size()
+	if bits != 32 {
+		return nil, fmt.Errorf("decisions: %q is not an IPv4 CIDR", value)
+	}
+	return bitsOf(ipNet.IP.To4())[:ones], nil
+}
+
+// bitsOf returns ip's 32 bits, most significant first.
+func bitsOf(ip net.IP) []bool {
+	bits := make([]bool, 0, 32)
+	for _, b := range ip {
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, b&(1<<uint(i)) != 0)
+		}
+	}
+	return bits
+}
@@ -0,0 +1,174 @@
+package detector
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// RuleConstraints is a WAFRule's Allowed*/Denied* fields compiled once —
+// CIDRs parsed into *net.IPNet, path globs translated into anchored
+// regexps — the same "parse once, match many" split Condition.CompiledRegex
+// already uses for rule conditions, so CheckRequest's hot path never
+// parses a CIDR or glob per request.
+type RuleConstraints struct {
+	AllowedHosts   []string
+	DeniedHosts    []string
+	AllowedCIDRs   []*net.IPNet
+	DeniedCIDRs    []*net.IPNet
+	AllowedMethods []string
+	DeniedMethods  []string
+	AllowedPaths   []*regexp.Regexp
+	DeniedPaths    []*regexp.Regexp
+}
+
+// CompileConstraints builds rule's RuleConstraints, returning a
+// field-prefixed error describing the first invalid CIDR or glob so
+// AddCustomRule can surface it to the UI instead of failing opaquely.
+func CompileConstraints(rule WAFRule) (*RuleConstraints, error) {
+	c := &RuleConstraints{
+		AllowedHosts:   rule.AllowedHosts,
+		DeniedHosts:    rule.DeniedHosts,
+		AllowedMethods: rule.AllowedMethods,
+		DeniedMethods:  rule.DeniedMethods,
+	}
+
+	var err error
+	if c.AllowedCIDRs, err = compileCIDRs(rule.AllowedCIDRs); err != nil {
+		return nil, fmt.Errorf("allowed_cidrs: %w", err)
+	}
+	if c.DeniedCIDRs, err = compileCIDRs(rule.DeniedCIDRs); err != nil {
+		return nil, fmt.Errorf("denied_cidrs: %w", err)
+	}
+	if c.AllowedPaths, err = compileGlobs(rule.AllowedPaths); err != nil {
+		return nil, fmt.Errorf("allowed_paths: %w", err)
+	}
+	if c.DeniedPaths, err = compileGlobs(rule.DeniedPaths); err != nil {
+		return nil, fmt.Errorf("denied_paths: %w", err)
+	}
+	return c, nil
+}
+
+func compileCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			// A bare IP is a valid single-host constraint too.
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not a valid IP or CIDR", v)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func compileGlobs(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := globToRegexp(p)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid glob: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// globToRegexp translates a shell-style glob (* matches any run of
+// characters, ? matches exactly one) into an anchored regexp.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// Allows reports whether a request for host/method/path from ip satisfies
+// every constraint dimension c defines. A nil Constraints (no constraints
+// compiled, or compilation never ran) always allows, matching "empty
+// lists mean no constraint" for a rule that sets none at all.
+func (c *RuleConstraints) Allows(host, method, path, ip string) bool {
+	if c == nil {
+		return true
+	}
+	return stringDimensionAllows(host, c.AllowedHosts, c.DeniedHosts) &&
+		stringDimensionAllows(method, c.AllowedMethods, c.DeniedMethods) &&
+		pathDimensionAllows(path, c.AllowedPaths, c.DeniedPaths) &&
+		cidrDimensionAllows(ip, c.AllowedCIDRs, c.DeniedCIDRs)
+}
+
+func stringDimensionAllows(value string, allowed, denied []string) bool {
+	for _, d := range denied {
+		if strings.EqualFold(d, value) {
+			return false
+		}
+	}
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func pathDimensionAllows(path string, allowed, denied []*regexp.Regexp) bool {
+	for _, d := range denied {
+		if d.MatchString(path) {
+			return false
+		}
+	}
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func cidrDimensionAllows(ip string, allowed, denied []*net.IPNet) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		// Nothing to evaluate against; don't let an unparsable clientIP
+		// block a rule that otherwise has no CIDR constraint.
+		return len(allowed) == 0
+	}
+	for _, d := range denied {
+		if d.Contains(addr) {
+			return false
+		}
+	}
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package detector
+
+import "testing"
+
+// TestCompileConstraintsRejectsBadCIDR verifies AddCustomRule's callers get
+// a field-prefixed error at creation time instead of the rule silently
+// loading unconstrained on the next reload.
+func TestCompileConstraintsRejectsBadCIDR(t *testing.T) {
+	_, err := CompileConstraints(WAFRule{AllowedCIDRs: []string{"not-a-cidr"}})
+	if err == nil {
+		t.Fatal("want an error for an invalid CIDR, got nil")
+	}
+}
+
+func TestRuleConstraintsAllowsNilIsUnconstrained(t *testing.T) {
+	var c *RuleConstraints
+	if !c.Allows("example.com", "GET", "/any/path", "203.0.113.1") {
+		t.Error("nil *RuleConstraints should allow everything")
+	}
+}
+
+func TestRuleConstraintsDenyAlwaysWins(t *testing.T) {
+	c, err := CompileConstraints(WAFRule{
+		AllowedHosts: []string{"example.com"},
+		DeniedHosts:  []string{"example.com"},
+	})
+	if err != nil {
+		t.Fatalf("CompileConstraints: %v", err)
+	}
+	if c.Allows("example.com", "GET", "/", "203.0.113.1") {
+		t.Error("an explicit deny should win over a matching allow")
+	}
+}
+
+func TestRuleConstraintsEmptyAllowListIsUnconstrained(t *testing.T) {
+	c, err := CompileConstraints(WAFRule{})
+	if err != nil {
+		t.Fatalf("CompileConstraints: %v", err)
+	}
+	if !c.Allows("anything.example", "DELETE", "/whatever", "198.51.100.7") {
+		t.Error("empty Allowed/Denied lists in every dimension should allow everything")
+	}
+}
+
+func TestRuleConstraintsAllowedMethodsRequiresMatch(t *testing.T) {
+	c, err := CompileConstraints(WAFRule{AllowedMethods: []string{"GET", "HEAD"}})
+	if err != nil {
+		t.Fatalf("CompileConstraints: %v", err)
+	}
+	if !c.Allows("example.com", "GET", "/", "203.0.113.1") {
+		t.Error("GET is in the allow list and should be allowed")
+	}
+	if c.Allows("example.com", "POST", "/", "203.0.113.1") {
+		t.Error("POST is not in the allow list and should be denied")
+	}
+}
+
+func TestRuleConstraintsAllowedPathGlob(t *testing.T) {
+	c, err := CompileConstraints(WAFRule{AllowedPaths: []string{"/api/*"}})
+	if err != nil {
+		t.Fatalf("CompileConstraints: %v", err)
+	}
+	if !c.Allows("example.com", "GET", "/api/users", "203.0.113.1") {
+		t.Error("/api/users should match the /api/* glob")
+	}
+	if c.Allows("example.com", "GET", "/admin", "203.0.113.1") {
+		t.Error("/admin should not match the /api/* glob")
+	}
+}
+
+func TestRuleConstraintsCIDR(t *testing.T) {
+	c, err := CompileConstraints(WAFRule{
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+		DeniedCIDRs:  []string{"10.1.0.0/16"},
+	})
+	if err != nil {
+		t.Fatalf("CompileConstraints: %v", err)
+	}
+	if !c.Allows("example.com", "GET", "/", "10.2.3.4") {
+		t.Error("10.2.3.4 is inside the allowed /8 and outside the denied /16")
+	}
+	if c.Allows("example.com", "GET", "/", "10.1.2.3") {
+		t.Error("10.1.2.3 is inside the denied /16 and should lose despite the broader allow")
+	}
+	if c.Allows("example.com", "GET", "/", "192.168.1.1") {
+		t.Error("192.168.1.1 is outside the allow list and should be denied")
+	}
+}
+
+func TestRuleConstraintsUnparsableIPWithNoCIDRConstraint(t *testing.T) {
+	c, err := CompileConstraints(WAFRule{})
+	if err != nil {
+		t.Fatalf("CompileConstraints: %v", err)
+	}
+	if !c.Allows("example.com", "GET", "/", "not-an-ip") {
+		t.Error("an unparsable IP shouldn't block a rule with no CIDR constraint")
+	}
+}
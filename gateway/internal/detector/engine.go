@@ -0,0 +1,114 @@
+package detector
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"web-app-firewall-ml-detection/internal/log"
+)
+
+// CheckRequest scores a request against the given rules. bodyBytes is the
+// bounded sample CaptureBody/CaptureMultipart already pulled out of
+// r.Body — CheckRequest itself never touches r.Body, the same convention
+// CheckML (ml.go) already follows, so the inspection cap only has to be
+// enforced in one place (waf.go).
+func CheckRequest(r *http.Request, bodyBytes []byte, rules []WAFRule, isRateLimited bool) (int, []string, bool, string) {
+	totalScore := 0
+	var triggeredTags []string
+	forceBlock := false
+
+	decodedPath, _ := url.QueryUnescape(r.URL.Path)
+	decodedQuery, _ := url.QueryUnescape(r.URL.RawQuery)
+	combinedPayload := decodedPath + " " + decodedQuery + " " + string(bodyBytes)
+
+	paramCount := len(r.URL.Query())
+	bodyLen := len(bodyBytes)
+	ip := clientIP(r)
+
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		if !rule.Constraints.Allows(r.Host, r.Method, decodedPath, ip) {
+			continue
+		}
+
+		matched := true
+		for _, cond := range rule.Conditions {
+			if !evaluate(cond, r, combinedPayload, paramCount, bodyLen, isRateLimited) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			log.WAFMatch(rule.ID, rule.Name, rule.OnMatch.ScoreAdd, ip, decodedPath, rule.OnMatch.Tags)
+			totalScore += rule.OnMatch.ScoreAdd
+			triggeredTags = append(triggeredTags, rule.OnMatch.Tags...)
+
+			if rule.OnMatch.HardBlock {
+				forceBlock = true
+			}
+		}
+	}
+
+	return totalScore, triggeredTags, forceBlock, combinedPayload
+}
+
+// clientIP returns the first X-Forwarded-For entry, or RemoteAddr's host
+// when that header is absent.
+func clientIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func evaluate(cond Condition, r *http.Request, combined string, paramCount, bodyLen int, isRateLimited bool) bool {
+	switch cond.Field {
+	case "request.combined":
+		if cond.CompiledRegex != nil {
+			return cond.CompiledRegex.MatchString(combined)
+		}
+	case "request.headers.User-Agent":
+		if cond.CompiledRegex != nil {
+			return cond.CompiledRegex.MatchString(r.UserAgent())
+		}
+	case "request.method":
+		if cond.Operator == "equals" {
+			valStr, ok := cond.Value.(string)
+			return ok && r.Method == valStr
+		}
+	case "meta.param_count":
+		return compareInt(cond.Value, paramCount)
+	case "meta.body_length":
+		return compareInt(cond.Value, bodyLen)
+	case "meta.rate_limited":
+		if cond.Operator == "equals_bool" {
+			valBool, ok := cond.Value.(bool)
+			return ok && (isRateLimited == valBool)
+		}
+	}
+	return false
+}
+
+func compareInt(val interface{}, actual int) bool {
+	switch v := val.(type) {
+	case int:
+		return actual > v
+	case float64:
+		return actual > int(v)
+	case int32:
+		return actual > int(v)
+	case int64:
+		return actual > int(v)
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package detector
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// DefaultMaxInspectBytes caps how much of a request body ever lands in
+// memory for rule/ML scoring. A domain can raise or lower this via
+// Domain.MaxInspectBytes — see Domain.EffectiveMaxInspectBytes.
+const DefaultMaxInspectBytes = 1 << 20 // 1 MiB
+
+// Oversize action values for Domain.OversizeAction: what WAFHandler does
+// when a body runs past its inspection cap and therefore couldn't be fully
+// scored.
+const (
+	OversizeAllow   = "allow"
+	OversizeBlock   = "block"
+	OversizeMonitor = "monitor"
+)
+
+// InspectedBody is what CaptureBody/CaptureMultipart hand back: a bounded
+// sample for the rule/ML engines to score, whether the body ran past the
+// inspection cap, and a Reader that reproduces the *entire* original body
+// (sample plus whatever of r.Body was left unread) so the reverse proxy can
+// still stream the full request to the origin.
+type InspectedBody struct {
+	Sample    []byte
+	Oversized bool
+	Reader    io.Reader
+}
+
+// CaptureBody reads at most maxInspect+1 bytes of r.Body into memory to
+// build a bounded sample, then reassembles a Reader of the full body
+// (sample + untouched remainder of r.Body) for the proxy to forward — so
+// inspecting a request never costs more memory than maxInspect, no matter
+// how large the real upload turns out to be.
+func CaptureBody(r *http.Request, maxInspect int64) (InspectedBody, error) {
+	if maxInspect <= 0 {
+		maxInspect = DefaultMaxInspectBytes
+	}
+	if r.Body == nil {
+		return InspectedBody{Reader: http.NoBody}, nil
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r.Body, maxInspect+1)
+	if err != nil && err != io.EOF {
+		return InspectedBody{}, err
+	}
+
+	oversized := n > maxInspect
+	sample := buf.Bytes()
+	if oversized {
+		sample = sample[:maxInspect]
+	}
+
+	return InspectedBody{
+		Sample:    append([]byte(nil), sample...),
+		Oversized: oversized,
+		Reader:    io.MultiReader(bytes.NewReader(buf.Bytes()), r.Body),
+	}, nil
+}
+
+// FilePartDigest is what CaptureMultipart records for a file part instead
+// of buffering its bytes: enough to feed a future malware-scan hook
+// without ever holding the file's content in memory ourselves.
+type FilePartDigest struct {
+	FieldName string
+	FileName  string
+	SHA256    string
+}
+
+// maxTextFieldBytes bounds how much of any one multipart text field gets
+// folded into the scoring sample, so a form with many large text fields
+// still can't bypass the overall inspection cap by much.
+const maxTextFieldBytes = 64 << 10 // 64 KiB
+
+// CaptureMultipart is the multipart/form-data counterpart to CaptureBody:
+// it streams part headers rather than buffering the whole body, folds only
+// small text fields into Sample for the rule/ML engines, and skips file
+// parts entirely — hashing each one instead, for a separate malware-scan
+// hook to consume later. Like CaptureBody it never reads more than
+// maxInspect+1 bytes of the original body, so a part that extends past the
+// cap (almost always the uploaded file itself) is simply left unexamined;
+// this is inherently best-effort since a cap can land mid-part.
+func CaptureMultipart(r *http.Request, boundary string, maxInspect int64) (InspectedBody, []FilePartDigest, error) {
+	body, err := CaptureBody(r, maxInspect)
+	if err != nil {
+		return InspectedBody{}, nil, err
+	}
+
+	var textFields bytes.Buffer
+	var files []FilePartDigest
+
+	mr := multipart.NewReader(bytes.NewReader(body.Sample), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			// io.EOF (all parts consumed) or a truncated final part because
+			// the inspection cap cut it off mid-stream — either way, stop;
+			// whatever we already extracted is the best-effort result.
+			break
+		}
+
+		if part.FileName() != "" {
+			h := sha256.New()
+			io.Copy(h, part)
+			files = append(files, FilePartDigest{
+				FieldName: part.FormName(),
+				FileName:  part.FileName(),
+				SHA256:    hex.EncodeToString(h.Sum(nil)),
+			})
+			continue
+		}
+
+		io.Copy(&textFields, io.LimitReader(part, maxTextFieldBytes))
+		textFields.WriteByte(' ')
+	}
+
+	body.Sample = textFields.Bytes()
+	return body, files, nil
+}
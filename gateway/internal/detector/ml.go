@@ -1,16 +1,13 @@
 package detector
 
 import (
-	"bytes"
-	"encoding/json"
 	"net/http"
-	"time"
 )
 
 type MLRequest struct {
-	Path   string `json:"path"`
-	Body   string `json:"body"`
-	Length int    `json:"length"`
+	Path    string            `json:"path"`
+	Body    string            `json:"body"`
+	Length  int               `json:"length"`
 	Headers map[string]string `json:"headers"`
 }
 
@@ -21,17 +18,18 @@ type MLResponse struct {
 	TriggerContent string  `json:"trigger_content"`
 }
 
-// Update signature to accept bodyBytes directly
+// CheckML scores one request against the ML scorer at mlURL, through the
+// shared MLClient for that URL (see ml_client.go) — its circuit breaker,
+// coalescing, and local fallback classifier all apply transparently, so
+// every call site keeps this exact signature regardless of scorer health.
 func CheckML(r *http.Request, bodyBytes []byte, mlURL string) (bool, float64, string, string) {
-	
-	// FIX 1: Send the Full URI (Path + Query) so ML sees "?id=<script>"
+	// Send the full URI (path + query) so the scorer sees "?id=<script>".
 	fullPath := r.URL.Path
 	if r.URL.RawQuery != "" {
 		fullPath += "?" + r.URL.RawQuery
 	}
 
-
-	// 1. Extract Headers (Flatten them to simple Key:Value)
+	// Flatten headers to simple Key:Value.
 	headerMap := make(map[string]string)
 	for k, v := range r.Header {
 		if len(v) > 0 {
@@ -39,34 +37,18 @@ func CheckML(r *http.Request, bodyBytes []byte, mlURL string) (bool, float64, st
 		}
 	}
 
-	// FIX 2: Use the bytes passed in. Do not touch r.Body again.
 	payload := MLRequest{
-		Path:   fullPath,
-		Body:   string(bodyBytes),
-		Length: len(bodyBytes),
+		Path:    fullPath,
+		Body:    string(bodyBytes),
+		Length:  len(bodyBytes),
 		Headers: headerMap,
 	}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return false, 0.0, "", ""
-	}
-
-	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Post(mlURL, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return false, 0.0, "", ""
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return false, 0.0, "", ""
-	}
-
-	var result MLResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return false, 0.0, "", ""
-	}
+	return clientFor(mlURL).Check(r.Context(), payload)
+}
 
-	return result.IsAnomaly, result.AnomalyScore, result.AttackType, result.TriggerContent
-}
\ No newline at end of file
+// BreakerStatus reports CheckML's circuit-breaker state for mlURL, for
+// SystemStatus to surface alongside the scorer's own /health probe.
+func BreakerStatus(mlURL string) string {
+	return clientFor(mlURL).Status()
+}
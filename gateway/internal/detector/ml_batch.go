@@ -0,0 +1,341 @@
+package detector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/metrics"
+)
+
+// Batching turns MLClient.call's one-request-per-round-trip pattern into a
+// small dispatcher that groups requests arriving within a short window into
+// one /predict_batch call. Model servers pay most of their per-call cost in
+// fixed overhead (HTTP parsing, a GPU/CPU dispatch), so batching a handful
+// of requests together is usually an order of magnitude cheaper per request
+// than calling /predict one at a time, at the cost of a few ms of queueing.
+const (
+	// batchMaxWait is how long the dispatcher waits to fill a batch before
+	// flushing whatever it has.
+	batchMaxWait = 10 * time.Millisecond
+
+	minBatchSize    = 4
+	maxBatchSizeCap = 64
+
+	// batchP95HighMs/batchP95LowMs drive adaptive sizing: climbing p95 means
+	// the scorer (or network) is under stress, so shrink batches to cut
+	// queueing delay; a comfortably low p95 means there's room to grow
+	// batches for better throughput.
+	batchP95HighMs = 200.0
+	batchP95LowMs  = 50.0
+
+	// latencyWindowSize is how many recent flush latencies adapt() looks at.
+	latencyWindowSize = 50
+)
+
+// batchItem is one caller's pending request, waiting on resultCh for a
+// dispatcher flush to fill it in.
+type batchItem struct {
+	id       uint64
+	payload  MLRequest
+	resultCh chan batchResult
+	enqueued time.Time
+}
+
+type batchResult struct {
+	resp MLResponse
+	ok   bool
+}
+
+type batchRequestItem struct {
+	ID      uint64   `json:"id"`
+	Request MLRequest `json:"request"`
+}
+
+type batchRequestBody struct {
+	Items []batchRequestItem `json:"items"`
+}
+
+type batchResponseItem struct {
+	ID       uint64  `json:"id"`
+	MLResponse
+}
+
+type batchResponseBody struct {
+	Items []batchResponseItem `json:"items"`
+}
+
+// mlBatcher collects MLClient.call's requests into batches and flushes them
+// to a /predict_batch endpoint, fanning results back out by ID.
+type mlBatcher struct {
+	url    string
+	client *http.Client
+
+	submitCh chan *batchItem
+	nextID   uint64
+
+	sem chan struct{} // bounds concurrent in-flight batch flushes
+
+	mu        sync.Mutex
+	batchSize int
+	latency   *latencyWindow
+}
+
+func newMLBatcher(mlURL string) *mlBatcher {
+	b := &mlBatcher{
+		url: predictBatchURLFor(mlURL),
+		client: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 100,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		submitCh:  make(chan *batchItem, maxBatchSizeCap*4),
+		sem:       make(chan struct{}, mlMaxConcurrent),
+		batchSize: minBatchSize,
+		latency:   newLatencyWindow(latencyWindowSize),
+	}
+	go b.run()
+	return b
+}
+
+// predictBatchURLFor derives the /predict_batch sibling of whatever
+// /predict (or bare base) URL the gateway was configured with, mirroring
+// fetchRemoteHealth's own URL munging in internal/api/system.go.
+func predictBatchURLFor(mlURL string) string {
+	rootURL := mlURL
+	if len(rootURL) > 0 && rootURL[len(rootURL)-1] == '/' {
+		rootURL = rootURL[:len(rootURL)-1]
+	}
+	if strings.HasSuffix(rootURL, "/predict") {
+		rootURL = strings.TrimSuffix(rootURL, "/predict")
+	}
+	return rootURL + "/predict_batch"
+}
+
+// submit enqueues payload and blocks until the batch it lands in has been
+// flushed and decoded, or ctx is done first.
+func (b *mlBatcher) submit(ctx context.Context, payload MLRequest) (MLResponse, bool) {
+	item := &batchItem{
+		id:       atomic.AddUint64(&b.nextID, 1),
+		payload:  payload,
+		resultCh: make(chan batchResult, 1),
+		enqueued: time.Now(),
+	}
+
+	select {
+	case b.submitCh <- item:
+	case <-ctx.Done():
+		return MLResponse{}, false
+	}
+
+	select {
+	case res := <-item.resultCh:
+		metrics.ObserveMLWait(time.Since(item.enqueued))
+		return res.resp, res.ok
+	case <-ctx.Done():
+		return MLResponse{}, false
+	}
+}
+
+// run collects items off submitCh into batches of up to the current
+// batchSize, flushing early once batchMaxWait elapses since the first item
+// of the batch arrived.
+func (b *mlBatcher) run() {
+	for {
+		first := <-b.submitCh
+		batch := []*batchItem{first}
+
+		timer := time.NewTimer(batchMaxWait)
+	collecting:
+		for len(batch) < b.currentBatchSize() {
+			select {
+			case item := <-b.submitCh:
+				batch = append(batch, item)
+			case <-timer.C:
+				break collecting
+			}
+		}
+		timer.Stop()
+
+		metrics.SetMLQueueDepth(len(b.submitCh))
+		metrics.ObserveMLBatchSize(len(batch))
+
+		select {
+		case b.sem <- struct{}{}:
+			go func(batch []*batchItem) {
+				defer func() { <-b.sem }()
+				b.flush(batch)
+			}(batch)
+		default:
+			// Already mlMaxConcurrent flushes in flight — flush inline
+			// rather than let the queue grow unbounded.
+			b.flush(batch)
+		}
+	}
+}
+
+func (b *mlBatcher) currentBatchSize() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.batchSize
+}
+
+// flush POSTs one batch to predict_batch, fans responses back out by ID,
+// and feeds the round-trip latency into the adaptive batch sizer. Any
+// failure (network error, bad status, undecodable body) fails every item
+// in the batch, which falls back to the local classifier in MLClient.Check.
+func (b *mlBatcher) flush(batch []*batchItem) {
+	body := batchRequestBody{Items: make([]batchRequestItem, len(batch))}
+	for i, item := range batch {
+		body.Items[i] = batchRequestItem{ID: item.id, Request: item.payload}
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		failAll(batch)
+		return
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), mlCallTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		failAll(batch)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		failAll(batch)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		failAll(batch)
+		return
+	}
+
+	var respBody batchResponseBody
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		failAll(batch)
+		return
+	}
+
+	b.adapt(elapsed)
+
+	byID := make(map[uint64]MLResponse, len(respBody.Items))
+	for _, r := range respBody.Items {
+		byID[r.ID] = r.MLResponse
+	}
+	for _, item := range batch {
+		if r, ok := byID[item.id]; ok {
+			item.resultCh <- batchResult{resp: r, ok: true}
+		} else {
+			item.resultCh <- batchResult{ok: false}
+		}
+	}
+}
+
+func failAll(batch []*batchItem) {
+	for _, item := range batch {
+		item.resultCh <- batchResult{ok: false}
+	}
+}
+
+// adapt shrinks or grows batchSize based on the rolling p95 flush latency:
+// above batchP95HighMs the scorer is struggling, so smaller batches cut
+// queueing delay; below batchP95LowMs there's headroom to batch more
+// aggressively for throughput.
+func (b *mlBatcher) adapt(d time.Duration) {
+	p95 := b.latency.record(d)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case p95 > batchP95HighMs && b.batchSize > minBatchSize:
+		b.batchSize /= 2
+		if b.batchSize < minBatchSize {
+			b.batchSize = minBatchSize
+		}
+	case p95 < batchP95LowMs && b.batchSize < maxBatchSizeCap:
+		b.batchSize *= 2
+		if b.batchSize > maxBatchSizeCap {
+			b.batchSize = maxBatchSizeCap
+		}
+	}
+}
+
+// status reports the batcher's current tuning for SystemStatus.
+func (b *mlBatcher) status() (batchSize, queueDepth int, p95Millis float64) {
+	b.mu.Lock()
+	size := b.batchSize
+	b.mu.Unlock()
+	return size, len(b.submitCh), b.latency.p95Millis()
+}
+
+// latencyWindow is a small ring buffer of recent flush latencies, just
+// enough for adapt() to react to trend without querying Prometheus's own
+// histogram (which isn't built for point-reads of its quantiles).
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	filled  int
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, size)}
+}
+
+func (w *latencyWindow) record(d time.Duration) float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+	if w.filled < len(w.samples) {
+		w.filled++
+	}
+	return w.p95MillisLocked()
+}
+
+func (w *latencyWindow) p95Millis() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.p95MillisLocked()
+}
+
+func (w *latencyWindow) p95MillisLocked() float64 {
+	if w.filled == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, w.filled)
+	copy(sorted, w.samples[:w.filled])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idx := int(float64(len(sorted)) * 0.95)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return float64(sorted[idx].Microseconds()) / 1000
+}
+
+// BatchStatus reports mlURL's batcher tuning for SystemStatus, mirroring
+// BreakerStatus.
+func BatchStatus(mlURL string) (batchSize, queueDepth int, p95Millis float64) {
+	return clientFor(mlURL).batcher.status()
+}
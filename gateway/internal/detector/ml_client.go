@@ -0,0 +1,265 @@
+package detector
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/metrics"
+)
+
+// breakerState mirrors the classic closed/open/half-open circuit breaker
+// states, reported as gateway_ml_breaker_state and SystemStatus's ml_breaker
+// entry.
+type breakerState int32
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+const (
+	// breakerWindow is how many of the most recent calls feed the error
+	// rate the breaker trips on.
+	breakerWindow = 20
+	// breakerTripRate opens the breaker once this fraction of breakerWindow
+	// errored.
+	breakerTripRate = 0.5
+	// breakerCooldown is how long the breaker stays open before allowing a
+	// probe call through as half-open.
+	breakerCooldown = 10 * time.Second
+	// mlMaxConcurrent bounds in-flight calls to the scorer so a slow
+	// scorer can't let unbounded goroutines pile up behind it.
+	mlMaxConcurrent = 32
+	// mlCallTimeout caps a single scorer call when the incoming request
+	// has no deadline of its own (or a longer one) to derive from.
+	mlCallTimeout = 2 * time.Second
+)
+
+// MLClient replaces the old one-shot CheckML http.Post with a pooled client
+// that protects the gateway from a slow/unhealthy ML scorer: a circuit
+// breaker stops sending calls once the scorer is clearly failing, in-flight
+// calls are bounded and deduplicated by payload (singleFlight), and a local
+// fallback classifier keeps producing an AnomalyScore/AttackType while the
+// breaker is open instead of silently disabling detection.
+type MLClient struct {
+	url     string
+	batcher *mlBatcher
+
+	mu       sync.Mutex
+	results  [breakerWindow]bool
+	next     int
+	filled   int
+	state    breakerState
+	openedAt time.Time
+
+	singleFlight *mlSingleFlight
+	fallback     *fallbackClassifier
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*MLClient)
+)
+
+// clientFor returns the shared MLClient for mlURL, creating it on first
+// use. CheckML is called concurrently from many request-path goroutines,
+// all sharing the same mlURL in practice, so this is what actually gives
+// them one pooled connection, one breaker, and one fallback instance.
+func clientFor(mlURL string) *MLClient {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if c, ok := clients[mlURL]; ok {
+		return c
+	}
+	c := newMLClient(mlURL)
+	clients[mlURL] = c
+	return c
+}
+
+func newMLClient(mlURL string) *MLClient {
+	return &MLClient{
+		url:          mlURL,
+		batcher:      newMLBatcher(mlURL),
+		singleFlight: newMLSingleFlight(),
+		fallback:     loadFallbackClassifier(),
+	}
+}
+
+// Check scores one request, returning (isAnomaly, score, attackType,
+// triggerContent) — CheckML's original shape, so every existing call site
+// is unaffected by what's happening underneath.
+func (c *MLClient) Check(ctx context.Context, payload MLRequest) (bool, float64, string, string) {
+	if c.breakerIsOpen() {
+		metrics.IncMLCall("breaker_open")
+		return c.fallback.classify(payload)
+	}
+
+	resp, ok := c.singleFlight.do(payload, func() (MLResponse, bool) {
+		return c.call(ctx, payload)
+	})
+
+	c.recordResult(ok)
+
+	if !ok {
+		metrics.IncMLCall("error")
+		return c.fallback.classify(payload)
+	}
+
+	metrics.IncMLCall("success")
+	return resp.IsAnomaly, resp.AnomalyScore, resp.AttackType, resp.TriggerContent
+}
+
+// call hands payload to this client's batcher, which groups it with
+// whatever else arrives within a few milliseconds into one /predict_batch
+// round-trip. The deadline is still derived from ctx's own remaining
+// budget so a near-expired caller doesn't wait out a full batch cycle.
+func (c *MLClient) call(ctx context.Context, payload MLRequest) (MLResponse, bool) {
+	callCtx, cancel := boundedContext(ctx, mlCallTimeout)
+	defer cancel()
+	return c.batcher.submit(callCtx, payload)
+}
+
+// boundedContext derives a deadline from whatever budget ctx already has
+// (the incoming request's own deadline, if any), capped at max, so a
+// near-expired request doesn't wait out the full ML timeout just to be
+// cancelled anyway.
+func boundedContext(ctx context.Context, max time.Duration) (context.Context, context.CancelFunc) {
+	if dl, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(dl); remaining < max {
+			if remaining <= 0 {
+				return context.WithTimeout(ctx, 0)
+			}
+			return context.WithTimeout(ctx, remaining)
+		}
+	}
+	return context.WithTimeout(ctx, max)
+}
+
+// recordResult feeds one call's outcome into the rolling error window and
+// updates the breaker state accordingly.
+func (c *MLClient) recordResult(success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results[c.next] = success
+	c.next = (c.next + 1) % breakerWindow
+	if c.filled < breakerWindow {
+		c.filled++
+	}
+
+	switch c.state {
+	case breakerClosed:
+		if c.filled == breakerWindow && c.errorRateLocked() >= breakerTripRate {
+			c.openLocked()
+		}
+	case breakerHalfOpen:
+		if success {
+			c.state = breakerClosed
+			c.filled, c.next = 0, 0
+			metrics.SetMLBreakerState(int(breakerClosed))
+		} else {
+			c.openLocked()
+		}
+	}
+}
+
+func (c *MLClient) openLocked() {
+	c.state = breakerOpen
+	c.openedAt = time.Now()
+	metrics.SetMLBreakerState(int(breakerOpen))
+}
+
+func (c *MLClient) errorRateLocked() float64 {
+	if c.filled == 0 {
+		return 0
+	}
+	errs := 0
+	for i := 0; i < c.filled; i++ {
+		if !c.results[i] {
+			errs++
+		}
+	}
+	return float64(errs) / float64(c.filled)
+}
+
+// breakerIsOpen reports whether Check should skip the real scorer and go
+// straight to the fallback classifier, flipping open->half_open once
+// breakerCooldown has elapsed. Unlike a textbook breaker this doesn't gate
+// half-open down to a single trial call — concurrency right after a
+// cooldown is low in practice, and singleFlight already collapses repeats
+// of the same payload, so the simplification costs little.
+func (c *MLClient) breakerIsOpen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == breakerOpen && time.Since(c.openedAt) >= breakerCooldown {
+		c.state = breakerHalfOpen
+		metrics.SetMLBreakerState(int(breakerHalfOpen))
+	}
+	return c.state == breakerOpen
+}
+
+// Status reports this client's circuit state as a string, for SystemStatus.
+func (c *MLClient) Status() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.String()
+}
+
+// mlSingleFlight deduplicates identical in-flight payloads so a burst of
+// requests carrying the same body/path during a scorer hiccup share one
+// call instead of each paying its own timeout.
+type mlSingleFlight struct {
+	mu      sync.Mutex
+	pending map[string]*mlCall
+}
+
+type mlCall struct {
+	done chan struct{}
+	resp MLResponse
+	ok   bool
+}
+
+func newMLSingleFlight() *mlSingleFlight {
+	return &mlSingleFlight{pending: make(map[string]*mlCall)}
+}
+
+func (sf *mlSingleFlight) do(payload MLRequest, fn func() (MLResponse, bool)) (MLResponse, bool) {
+	key, err := json.Marshal(payload)
+	if err != nil {
+		return fn()
+	}
+
+	sf.mu.Lock()
+	if call, ok := sf.pending[string(key)]; ok {
+		sf.mu.Unlock()
+		<-call.done
+		return call.resp, call.ok
+	}
+	call := &mlCall{done: make(chan struct{})}
+	sf.pending[string(key)] = call
+	sf.mu.Unlock()
+
+	call.resp, call.ok = fn()
+	close(call.done)
+
+	sf.mu.Lock()
+	delete(sf.pending, string(key))
+	sf.mu.Unlock()
+
+	return call.resp, call.ok
+}
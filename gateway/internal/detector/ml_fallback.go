@@ -0,0 +1,108 @@
+package detector
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// fallbackClassifier scores a request when the real ML scorer is
+// unreachable: a handful of compiled signatures for the loudest attack
+// families (cheap, high precision, easy to audit) plus a small character-
+// trigram logistic model for everything else, so Check still returns a
+// usable AnomalyScore/AttackType instead of silently waving requests
+// through while the breaker is open.
+type fallbackClassifier struct {
+	signatures []fallbackSignature
+	ngram      *ngramModel
+}
+
+type fallbackSignature struct {
+	attackType string
+	pattern    *regexp.Regexp
+}
+
+var defaultSignatures = []struct {
+	attackType string
+	pattern    string
+}{
+	{"sqli", `(?i)(\bunion\b\s+\bselect\b|\bor\b\s+1=1|;--|\bdrop\b\s+\btable\b)`},
+	{"xss", `(?i)(<script|onerror\s*=|javascript:)`},
+	{"path_traversal", `(\.\./){2,}`},
+	{"cmdi", `(?i)(;|\|)\s*(cat|ls|wget|curl|nc|bash|sh)\s`},
+}
+
+// ngramModelPath is where loadFallbackClassifier looks for trained weights
+// at startup; a missing/unreadable file just means the ngram half of the
+// classifier always scores 0, leaving signature matches as the only
+// fallback signal.
+const ngramModelPath = "ml_fallback_model.json"
+
+type ngramModel struct {
+	Weights   map[string]float64 `json:"weights"`
+	Bias      float64            `json:"bias"`
+	Threshold float64            `json:"threshold"`
+}
+
+func loadFallbackClassifier() *fallbackClassifier {
+	fc := &fallbackClassifier{}
+	for _, s := range defaultSignatures {
+		fc.signatures = append(fc.signatures, fallbackSignature{
+			attackType: s.attackType,
+			pattern:    regexp.MustCompile(s.pattern),
+		})
+	}
+
+	data, err := os.ReadFile(ngramModelPath)
+	if err != nil {
+		log.Printf("[WARN] detector: no ML fallback model at %s, falling back to signature matching only: %v", ngramModelPath, err)
+		return fc
+	}
+
+	var model ngramModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		log.Printf("[WARN] detector: failed to parse ML fallback model %s: %v", ngramModelPath, err)
+		return fc
+	}
+	fc.ngram = &model
+	return fc
+}
+
+// classify mirrors MLClient.Check's return shape so callers can't tell
+// whether the answer came from the real scorer or this fallback.
+func (fc *fallbackClassifier) classify(payload MLRequest) (bool, float64, string, string) {
+	text := payload.Path + " " + payload.Body
+
+	for _, sig := range fc.signatures {
+		if loc := sig.pattern.FindStringIndex(text); loc != nil {
+			return true, 0.9, sig.attackType, text[loc[0]:loc[1]]
+		}
+	}
+
+	if fc.ngram == nil {
+		return false, 0, "", ""
+	}
+
+	score := fc.ngram.score(text)
+	if score >= fc.ngram.Threshold {
+		return true, score, "anomalous_fallback", ""
+	}
+	return false, score, "", ""
+}
+
+// score runs the logistic model over text's character trigrams: a dot
+// product of trigram presence against Weights, squashed through a sigmoid.
+// Trigrams the model wasn't trained on contribute nothing.
+func (m *ngramModel) score(text string) float64 {
+	text = strings.ToLower(text)
+	z := m.Bias
+	for i := 0; i+3 <= len(text); i++ {
+		if w, ok := m.Weights[text[i:i+3]]; ok {
+			z += w
+		}
+	}
+	return 1 / (1 + math.Exp(-z))
+}
@@ -12,6 +12,45 @@ type User struct {
 	Name     string `bson:"name" json:"name"`
 	Email    string `bson:"email" json:"email"`
 	Password string `bson:"password" json:"-"`
+
+	// [NEW] Set for accounts provisioned through an OAuth/OIDC provider.
+	// AuthType is "password" for the bcrypt/email flow, or the provider
+	// name ("google", "github", "oidc") otherwise. ExternalID is the
+	// provider's subject/user id and is unique per AuthType.
+	AuthType   string `bson:"auth_type" json:"auth_type"`
+	ExternalID string `bson:"external_id,omitempty" json:"-"`
+
+	// [NEW] Failed-login lockout and audit tracking, maintained by
+	// database.RegisterLoginAttempt/CheckLoginLock (dns_quota.go's sibling
+	// for auth rather than DNS). FailedAttempts resets to 0 on a
+	// successful login or once it triggers a lockout; LockedUntil is the
+	// zero time when the account isn't currently locked out.
+	FailedAttempts int       `bson:"failed_attempts" json:"-"`
+	LockedUntil    time.Time `bson:"locked_until,omitempty" json:"-"`
+	LastLoginAt    time.Time `bson:"last_login_at,omitempty" json:"-"`
+
+	// [NEW] Per-user override of database.MaxRecordsPerUser, for paid tiers
+	// that get a larger DNS record quota than the default. Zero means "use
+	// the global default" — see database.GetUserRecordQuota.
+	MaxRecordsOverride int `bson:"max_records_override,omitempty" json:"max_records_override,omitempty"`
+}
+
+// RefreshToken is one issued refresh-token session, stored hashed (never
+// the raw opaque value) so a database dump alone can't be replayed.
+// RotatedTo chains forward to the token it was exchanged for; a refresh
+// request presenting a token that already has a RotatedTo set means the
+// token was reused after rotation — a stolen-token signal that revokes
+// every token for UserID.
+type RefreshToken struct {
+	ID        string    `bson:"_id,omitempty" json:"id"`
+	UserID    string    `bson:"user_id" json:"user_id"`
+	TokenHash string    `bson:"token_hash" json:"-"`
+	IssuedAt  time.Time `bson:"issued_at" json:"issued_at"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+	RotatedTo string    `bson:"rotated_to,omitempty" json:"-"`
+	Revoked   bool      `bson:"revoked" json:"revoked"`
+	UserAgent string    `bson:"user_agent,omitempty" json:"user_agent"`
+	IP        string    `bson:"ip,omitempty" json:"ip"`
 }
 
 type UserInput struct {
@@ -27,6 +66,63 @@ type Domain struct {
 	Nameservers []string  `bson:"nameservers" json:"nameservers"`
 	Status      string    `bson:"status" json:"status"`
 	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+
+	// [NEW] Which DNS-01 solver internal/acme uses for this domain's
+	// certificates. Empty means "internal" (our own PowerDNS zone).
+	DNSProvider string `bson:"dns_provider,omitempty" json:"dns_provider,omitempty"`
+
+	// [NEW] Per-domain body-inspection limits (waf.go/inspect.go). Zero/empty
+	// fall back to DefaultMaxInspectBytes/OversizeAllow, so existing domains
+	// behave exactly as before until an owner opts into something stricter.
+	MaxInspectBytes int64  `bson:"max_inspect_bytes,omitempty" json:"max_inspect_bytes,omitempty"`
+	OversizeAction  string `bson:"oversize_action,omitempty" json:"oversize_action,omitempty"`
+
+	// [NEW] TLSMode controls whether internal/acme manages this domain's
+	// certificate at all: "acme" (default/empty) issues and auto-renews via
+	// DNS-01, "custom" means the operator uploads their own cert/key
+	// out-of-band and the renewal ticker must leave it alone, "off" serves
+	// no TLS cert for this domain (HTTP only, or BYO terminator upstream).
+	TLSMode string `bson:"tls_mode,omitempty" json:"tls_mode,omitempty"`
+	// Email overrides the ACME account contact for this domain's own order
+	// (e.g. a reseller domain billed to a different address); empty uses
+	// the gateway-wide default passed to acme.NewManager.
+	Email string `bson:"email,omitempty" json:"email,omitempty"`
+	// KeyType selects the private key algorithm lego requests
+	// ("ec256"/"ec384"/"rsa2048"/"rsa4096"); empty defaults to EC P-256,
+	// matching the account key acme.Manager already generates.
+	KeyType string `bson:"key_type,omitempty" json:"key_type,omitempty"`
+}
+
+// EffectiveTLSMode returns TLSMode, or "acme" (today's implicit behavior)
+// if the domain hasn't overridden it.
+func (d Domain) EffectiveTLSMode() string {
+	switch d.TLSMode {
+	case "custom", "off":
+		return d.TLSMode
+	default:
+		return "acme"
+	}
+}
+
+// EffectiveMaxInspectBytes returns MaxInspectBytes, or
+// DefaultMaxInspectBytes if the domain hasn't overridden it.
+func (d Domain) EffectiveMaxInspectBytes() int64 {
+	if d.MaxInspectBytes > 0 {
+		return d.MaxInspectBytes
+	}
+	return DefaultMaxInspectBytes
+}
+
+// EffectiveOversizeAction returns OversizeAction, or OversizeAllow (today's
+// implicit behavior — inspect whatever fit under the cap, forward the
+// rest) if the domain hasn't overridden it.
+func (d Domain) EffectiveOversizeAction() string {
+	switch d.OversizeAction {
+	case OversizeBlock, OversizeMonitor:
+		return d.OversizeAction
+	default:
+		return OversizeAllow
+	}
 }
 
 type DNSRecord struct {
@@ -50,6 +146,27 @@ type WAFRule struct {
 	Conditions []Condition `bson:"conditions" json:"conditions"`
 	OnMatch    Action      `bson:"on_match" json:"on_match"`
 	Enabled    bool        `bson:"-" json:"enabled"`
+
+	// Constraint lists scope when this rule is even considered, the way a
+	// smallstep x509 name-constraint extension scopes a CA: an explicit
+	// Denied* entry always wins, a non-empty Allowed* list requires a
+	// match in that dimension, and an empty list leaves the dimension
+	// unconstrained. A request failing any dimension simply skips this
+	// rule, the same as a request failing one of Conditions.
+	AllowedHosts   []string `bson:"allowed_hosts,omitempty" json:"allowed_hosts,omitempty"`
+	DeniedHosts    []string `bson:"denied_hosts,omitempty" json:"denied_hosts,omitempty"`
+	AllowedCIDRs   []string `bson:"allowed_cidrs,omitempty" json:"allowed_cidrs,omitempty"`
+	DeniedCIDRs    []string `bson:"denied_cidrs,omitempty" json:"denied_cidrs,omitempty"`
+	AllowedMethods []string `bson:"allowed_methods,omitempty" json:"allowed_methods,omitempty"`
+	DeniedMethods  []string `bson:"denied_methods,omitempty" json:"denied_methods,omitempty"`
+	AllowedPaths   []string `bson:"allowed_paths,omitempty" json:"allowed_paths,omitempty"` // glob
+	DeniedPaths    []string `bson:"denied_paths,omitempty" json:"denied_paths,omitempty"`   // glob
+
+	// Constraints is AllowedHosts/DeniedHosts/... compiled once per
+	// ReloadRules by CompileConstraints; CheckRequest only ever does
+	// pointer-indexed matcher calls against it, never its own string
+	// parsing.
+	Constraints *RuleConstraints `bson:"-" json:"-"`
 }
 
 type RulePolicy struct {
@@ -0,0 +1,244 @@
+package dnsbackend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+)
+
+const cloudflareAPIBase = "https://api.cloudflare.com/client/v4"
+
+// CloudflareProvider publishes records through the Cloudflare REST API
+// instead of our own PowerDNS/MySQL instance, for operators who'd rather
+// delegate authoritative DNS to Cloudflare and just point their zone's
+// proxied records at the WAF.
+type CloudflareProvider struct {
+	apiToken string
+	zoneIDs  map[string]string // zone name -> Cloudflare zone id
+	client   *http.Client
+}
+
+// NewCloudflareProvider returns a Provider backed by the Cloudflare REST
+// API. apiToken authenticates as "Authorization: Bearer <apiToken>";
+// zoneIDs maps every zone this gateway manages to the Cloudflare zone id
+// that owns it (config.CloudflareConfig.ZoneIDs).
+func NewCloudflareProvider(apiToken string, zoneIDs map[string]string) *CloudflareProvider {
+	return &CloudflareProvider{
+		apiToken: apiToken,
+		zoneIDs:  zoneIDs,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *CloudflareProvider) zoneID(zone string) (string, error) {
+	id, ok := p.zoneIDs[zone]
+	if !ok {
+		return "", fmt.Errorf("cloudflare: no zone id configured for %s", zone)
+	}
+	return id, nil
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl,omitempty"`
+	Proxied bool   `json:"proxied"`
+}
+
+type cloudflareResponse struct {
+	Success bool              `json:"success"`
+	Errors  []cloudflareError `json:"errors"`
+	Result  json.RawMessage   `json:"result"`
+}
+
+type cloudflareError struct {
+	Message string `json:"message"`
+}
+
+// do issues one Cloudflare API call and decodes its envelope into out
+// (ignored if nil).
+func (p *CloudflareProvider) do(method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, cloudflareAPIBase+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var cfResp cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cfResp); err != nil {
+		return fmt.Errorf("cloudflare: decode response: %w", err)
+	}
+	if !cfResp.Success {
+		if len(cfResp.Errors) > 0 {
+			return fmt.Errorf("cloudflare API error: %s", cfResp.Errors[0].Message)
+		}
+		return fmt.Errorf("cloudflare API error: status %d", resp.StatusCode)
+	}
+	if out != nil && len(cfResp.Result) > 0 {
+		return json.Unmarshal(cfResp.Result, out)
+	}
+	return nil
+}
+
+// CreateZone adds name to this Cloudflare account. Cloudflare assigns its
+// own nameserver pair on creation and ignores ns — unlike PowerDNSProvider,
+// which serves the zone itself and must be told which nameservers to
+// advertise.
+func (p *CloudflareProvider) CreateZone(name string, ns []string) error {
+	var result struct {
+		ID string `json:"id"`
+	}
+	body := map[string]interface{}{"name": name, "type": "full"}
+	if err := p.do(http.MethodPost, "/zones", body, &result); err != nil {
+		return err
+	}
+	p.zoneIDs[name] = result.ID
+	return nil
+}
+
+func (p *CloudflareProvider) DeleteZone(name string) error {
+	zoneID, err := p.zoneID(name)
+	if err != nil {
+		return err
+	}
+	if err := p.do(http.MethodDelete, "/zones/"+zoneID, nil, nil); err != nil {
+		return err
+	}
+	delete(p.zoneIDs, name)
+	return nil
+}
+
+func (p *CloudflareProvider) UpsertRecord(r database.DNSRecord, wafIP string, proxied bool) (string, error) {
+	zone, _, err := database.ResolveZone(r.Name)
+	if err != nil {
+		return "", err
+	}
+	zoneID, err := p.zoneID(zone)
+	if err != nil {
+		return "", err
+	}
+
+	// Same proxy-masking rule AddPowerDNSRecord applies: publish an A
+	// record pointing at wafIP for proxied hosts, except meta records that
+	// must stay publicly visible for verification.
+	finalType, finalContent := r.Type, r.Content
+	shouldProxy := proxied
+	if r.Type == "TXT" || r.Type == "MX" || r.Type == "NS" || r.Type == "SOA" {
+		shouldProxy = false
+	}
+	if shouldProxy {
+		finalType, finalContent = "A", wafIP
+	}
+
+	ttl := r.TTL
+	if ttl == 0 {
+		ttl = 300
+	}
+	body := cloudflareRecord{Type: finalType, Name: r.Name, Content: finalContent, TTL: ttl}
+
+	// Cloudflare addresses records by its own id; UpsertRecord does not
+	// consult r.ExternalID, so look up whether one already exists for this
+	// name+type before deciding whether to PUT or POST.
+	existingID, err := p.findRecordID(zoneID, r.Name, finalType, "")
+	if err != nil {
+		return "", err
+	}
+
+	var result cloudflareRecord
+	if existingID != "" {
+		err = p.do(http.MethodPut, fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, existingID), body, &result)
+	} else {
+		err = p.do(http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", zoneID), body, &result)
+	}
+	if err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+func (p *CloudflareProvider) DeleteRecord(id, name, rtype, content string) error {
+	zone, _, err := database.ResolveZone(name)
+	if err != nil {
+		return err
+	}
+	zoneID, err := p.zoneID(zone)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		id, err = p.findRecordID(zoneID, name, rtype, content)
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			return fmt.Errorf("cloudflare: no matching record for %s %s %s", name, rtype, content)
+		}
+	}
+	return p.do(http.MethodDelete, fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, id), nil, nil)
+}
+
+func (p *CloudflareProvider) ListRecords(zone string) ([]database.DNSRecord, error) {
+	zoneID, err := p.zoneID(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []cloudflareRecord
+	if err := p.do(http.MethodGet, fmt.Sprintf("/zones/%s/dns_records", zoneID), nil, &results); err != nil {
+		return nil, err
+	}
+
+	records := make([]database.DNSRecord, 0, len(results))
+	for _, rec := range results {
+		records = append(records, database.DNSRecord{
+			ID:      rec.ID,
+			Name:    rec.Name,
+			Type:    rec.Type,
+			Content: rec.Content,
+			TTL:     rec.TTL,
+			Proxied: rec.Proxied,
+		})
+	}
+	return records, nil
+}
+
+// findRecordID returns the id of the first record matching name+rtype, and
+// (if content is non-empty) also matching content exactly. It returns ""
+// with a nil error when nothing matches.
+func (p *CloudflareProvider) findRecordID(zoneID, name, rtype, content string) (string, error) {
+	var results []cloudflareRecord
+	path := fmt.Sprintf("/zones/%s/dns_records?type=%s&name=%s", zoneID, rtype, name)
+	if err := p.do(http.MethodGet, path, nil, &results); err != nil {
+		return "", err
+	}
+	for _, rec := range results {
+		if content == "" || rec.Content == content {
+			return rec.ID, nil
+		}
+	}
+	return "", nil
+}
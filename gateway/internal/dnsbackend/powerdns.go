@@ -0,0 +1,61 @@
+package dnsbackend
+
+import (
+	"fmt"
+
+	"web-app-firewall-ml-detection/internal/database"
+)
+
+// PowerDNSProvider is the default Provider: every method is a thin
+// pass-through to the PowerDNS/MySQL functions internal/database already
+// had, so wiring it in changes no behavior for domains that don't opt into
+// an external backend.
+type PowerDNSProvider struct{}
+
+// NewPowerDNSProvider returns a Provider backed by our own PowerDNS/MySQL
+// instance (internal/database.ConnectDNS).
+func NewPowerDNSProvider() *PowerDNSProvider {
+	return &PowerDNSProvider{}
+}
+
+func (p *PowerDNSProvider) CreateZone(name string, ns []string) error {
+	return database.CreateDNSZone(name, ns)
+}
+
+func (p *PowerDNSProvider) DeleteZone(name string) error {
+	return database.DeleteDNSZone(name)
+}
+
+func (p *PowerDNSProvider) UpsertRecord(r database.DNSRecord, wafIP string, proxied bool) (string, error) {
+	if err := database.AddPowerDNSRecord(r.Name, r.Type, r.Content, proxied, wafIP); err != nil {
+		return "", err
+	}
+	// PowerDNS/MySQL has no id we surface back up here; name+type+content
+	// is the key DeleteRecord (DeletePowerDNSRecordByContent) already
+	// matches on, so an empty id is fine.
+	return "", nil
+}
+
+func (p *PowerDNSProvider) DeleteRecord(id, name, rtype, content string) error {
+	return database.DeletePowerDNSRecordByContent(name, rtype, content)
+}
+
+func (p *PowerDNSProvider) ListRecords(zone string) ([]database.DNSRecord, error) {
+	raw, err := database.GetPowerDNSRecords(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]database.DNSRecord, 0, len(raw))
+	for _, m := range raw {
+		ttl, _ := m["ttl"].(int)
+		records = append(records, database.DNSRecord{
+			ID:      fmt.Sprintf("%v", m["id"]),
+			Name:    fmt.Sprintf("%v", m["name"]),
+			Type:    fmt.Sprintf("%v", m["type"]),
+			Content: fmt.Sprintf("%v", m["content"]),
+			TTL:     ttl,
+		})
+	}
+	return records, nil
+}
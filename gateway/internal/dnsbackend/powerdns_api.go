@@ -0,0 +1,187 @@
+package dnsbackend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+)
+
+// PowerDNSAPIProvider publishes records through PowerDNS's own REST API
+// (the authoritative server's built-in webserver) instead of writing
+// straight into its MySQL backend the way PowerDNSProvider does. Going
+// through the API means the auth server picks up every change immediately
+// — no manual `pdns_control notify` needed after a direct SQL write.
+type PowerDNSAPIProvider struct {
+	baseURL string // e.g. "http://pdns:8081/api/v1/servers/localhost"
+	apiKey  string
+	client  *http.Client
+}
+
+// NewPowerDNSAPIProvider returns a Provider backed by PowerDNS's REST API.
+// baseURL is the server's api endpoint (config.PowerDNSAPIConfig.URL);
+// apiKey authenticates as the "X-API-Key" header PowerDNS expects.
+func NewPowerDNSAPIProvider(baseURL, apiKey string) *PowerDNSAPIProvider {
+	return &PowerDNSAPIProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type pdnsRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type pdnsRRSet struct {
+	Name       string       `json:"name"`
+	Type       string       `json:"type"`
+	TTL        int          `json:"ttl,omitempty"`
+	ChangeType string       `json:"changetype,omitempty"`
+	Records    []pdnsRecord `json:"records"`
+}
+
+type pdnsZone struct {
+	Name        string      `json:"name"`
+	Kind        string      `json:"kind,omitempty"`
+	Nameservers []string    `json:"nameservers,omitempty"`
+	RRSets      []pdnsRRSet `json:"rrsets,omitempty"`
+}
+
+// do issues one PowerDNS API call. A nil out skips response decoding —
+// PATCH /zones/{zone} returns 204 No Content on success, for instance.
+func (p *PowerDNSAPIProvider) do(method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, p.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("powerdns api: %s %s: status %d: %s", method, path, resp.StatusCode, string(raw))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// CreateZone provisions name as a NATIVE zone (we're authoritative, not
+// AXFR-pulling from anywhere else), advertising ns as its nameservers.
+func (p *PowerDNSAPIProvider) CreateZone(name string, ns []string) error {
+	fqdn := dnsFqdn(name)
+	nsRecords := make([]string, len(ns))
+	for i, n := range ns {
+		nsRecords[i] = dnsFqdn(n)
+	}
+	body := pdnsZone{Name: fqdn, Kind: "Native", Nameservers: nsRecords}
+	return p.do(http.MethodPost, "/zones", body, nil)
+}
+
+func (p *PowerDNSAPIProvider) DeleteZone(name string) error {
+	return p.do(http.MethodDelete, "/zones/"+dnsFqdn(name), nil, nil)
+}
+
+// UpsertRecord REPLACEs the rrset for r's name+type with a single record —
+// the same masking rule AddPowerDNSRecord applies for proxied hosts. PowerDNS
+// treats every record in a changetype:REPLACE rrset as the complete set, so
+// this doesn't support multiple values for one name+type (round-robin A
+// records, for instance); that's a known limitation of this provider versus
+// the SQL one, which stores each row independently.
+func (p *PowerDNSAPIProvider) UpsertRecord(r database.DNSRecord, wafIP string, proxied bool) (string, error) {
+	zone, _, err := database.ResolveZone(r.Name)
+	if err != nil {
+		return "", err
+	}
+
+	finalType, finalContent := r.Type, r.Content
+	shouldProxy := proxied
+	if r.Type == "TXT" || r.Type == "MX" || r.Type == "NS" || r.Type == "SOA" {
+		shouldProxy = false
+	}
+	if shouldProxy {
+		finalType, finalContent = "A", wafIP
+	}
+
+	ttl := r.TTL
+	if ttl == 0 {
+		ttl = 300
+	}
+
+	rrset := pdnsRRSet{
+		Name:       dnsFqdn(r.Name),
+		Type:       finalType,
+		TTL:        ttl,
+		ChangeType: "REPLACE",
+		Records:    []pdnsRecord{{Content: finalContent}},
+	}
+	patch := pdnsZone{RRSets: []pdnsRRSet{rrset}}
+	if err := p.do(http.MethodPatch, "/zones/"+dnsFqdn(zone), patch, nil); err != nil {
+		return "", err
+	}
+	// PowerDNS addresses rrsets by name+type, not a separate record id.
+	return "", nil
+}
+
+func (p *PowerDNSAPIProvider) DeleteRecord(id, name, rtype, content string) error {
+	zone, _, err := database.ResolveZone(name)
+	if err != nil {
+		return err
+	}
+
+	rrset := pdnsRRSet{Name: dnsFqdn(name), Type: rtype, ChangeType: "DELETE"}
+	patch := pdnsZone{RRSets: []pdnsRRSet{rrset}}
+	return p.do(http.MethodPatch, "/zones/"+dnsFqdn(zone), patch, nil)
+}
+
+func (p *PowerDNSAPIProvider) ListRecords(zone string) ([]database.DNSRecord, error) {
+	var z pdnsZone
+	if err := p.do(http.MethodGet, "/zones/"+dnsFqdn(zone), nil, &z); err != nil {
+		return nil, err
+	}
+
+	var records []database.DNSRecord
+	for _, rrset := range z.RRSets {
+		for _, rec := range rrset.Records {
+			records = append(records, database.DNSRecord{
+				Name:    strings.TrimSuffix(rrset.Name, "."),
+				Type:    rrset.Type,
+				Content: rec.Content,
+				TTL:     rrset.TTL,
+			})
+		}
+	}
+	return records, nil
+}
+
+// dnsFqdn appends a trailing dot if name doesn't already have one — every
+// name PowerDNS's API returns or expects is fully-qualified.
+func dnsFqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
@@ -0,0 +1,35 @@
+// Package dnsbackend abstracts the authoritative DNS backend a zone's
+// records are actually published to. Before this package existed, the DNS
+// HTTP handlers (internal/api/dns.go) called internal/database's
+// PowerDNS/MySQL functions directly; Provider lets them depend on an
+// interface instead, so an operator can point a domain at Cloudflare (or
+// another host) instead of our own PowerDNS instance without touching the
+// proxying/ownership/validation logic in dns.go.
+package dnsbackend
+
+import "web-app-firewall-ml-detection/internal/database"
+
+// Provider publishes and withdraws DNS records for zones this gateway
+// manages. Mongo (via internal/database) always stays the source of truth
+// for what the user asked for; a Provider is just where that intent gets
+// resolved into something the public internet can actually query.
+type Provider interface {
+	// CreateZone provisions a new zone with the given nameservers. Some
+	// backends (Cloudflare) assign their own nameservers and ignore ns.
+	CreateZone(name string, ns []string) error
+	DeleteZone(name string) error
+
+	// UpsertRecord publishes r, masking it behind an A record pointing at
+	// wafIP when proxied is true — the same meta-record exclusions
+	// AddPowerDNSRecord always applied (TXT/MX/NS/SOA are never proxied).
+	// It returns the backend's identifier for the published record, if the
+	// backend has one; callers aren't required to persist it.
+	UpsertRecord(r database.DNSRecord, wafIP string, proxied bool) (string, error)
+
+	// DeleteRecord removes the record matching id (if non-empty and the
+	// backend supports lookup by id) or, failing that, name+rtype+content.
+	DeleteRecord(id, name, rtype, content string) error
+
+	// ListRecords returns every record published for zone.
+	ListRecords(zone string) ([]database.DNSRecord, error)
+}
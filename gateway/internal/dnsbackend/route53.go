@@ -0,0 +1,337 @@
+package dnsbackend
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+)
+
+const (
+	route53Endpoint = "https://route53.amazonaws.com"
+	route53APIVers  = "2013-04-01"
+	// Route53 is a global service billed out of us-east-1 regardless of
+	// where its hosted zones actually serve from — SigV4 still wants a
+	// region to scope the signature to.
+	route53Region  = "us-east-1"
+	route53Service = "route53"
+)
+
+// Route53Provider publishes records through AWS Route 53's REST API,
+// signed with SigV4, for operators who host authoritative DNS there
+// instead of our own PowerDNS/MySQL instance or Cloudflare.
+type Route53Provider struct {
+	accessKeyID     string
+	secretAccessKey string
+	hostedZoneIDs   map[string]string // zone name -> Route53 hosted zone id
+	client          *http.Client
+}
+
+// NewRoute53Provider returns a Provider backed by AWS Route 53.
+// hostedZoneIDs maps every zone this gateway manages to the Route53
+// hosted zone id that owns it (config.Route53Config.HostedZoneIDs).
+func NewRoute53Provider(accessKeyID, secretAccessKey string, hostedZoneIDs map[string]string) *Route53Provider {
+	return &Route53Provider{
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		hostedZoneIDs:   hostedZoneIDs,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Route53Provider) zoneID(zone string) (string, error) {
+	id, ok := p.hostedZoneIDs[zone]
+	if !ok {
+		return "", fmt.Errorf("route53: no hosted zone id configured for %s", zone)
+	}
+	return id, nil
+}
+
+type route53ChangeBatch struct {
+	XMLName xml.Name             `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeResourceRecordSetsRequest"`
+	Changes []route53ChangeEntry `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53ChangeEntry struct {
+	Action            string                   `xml:"Action"`
+	Name              string                   `xml:"ResourceRecordSet>Name"`
+	Type              string                   `xml:"ResourceRecordSet>Type"`
+	TTL               int                      `xml:"ResourceRecordSet>TTL"`
+	ResourceRecordSet []route53ResourceRecord  `xml:"ResourceRecordSet>ResourceRecords>ResourceRecord"`
+}
+
+type route53ResourceRecord struct {
+	Value string `xml:"Value"`
+}
+
+type route53ListResponse struct {
+	ResourceRecordSets []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+}
+
+type route53RecordSet struct {
+	Name            string                  `xml:"Name"`
+	Type            string                  `xml:"Type"`
+	TTL             int                     `xml:"TTL"`
+	ResourceRecords []route53ResourceRecord `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53ErrorResponse struct {
+	Message string `xml:"Error>Message"`
+}
+
+// changeRecordSet submits one CREATE/DELETE/UPSERT change for name/rtype,
+// e.g. the same single-record-at-a-time model CloudflareProvider exposes
+// through UpsertRecord/DeleteRecord.
+func (p *Route53Provider) changeRecordSet(zoneID, action, name, rtype, content string, ttl int) error {
+	if ttl == 0 {
+		ttl = 300
+	}
+	batch := route53ChangeBatch{
+		Changes: []route53ChangeEntry{{
+			Action: action,
+			Name:   name,
+			Type:   rtype,
+			TTL:    ttl,
+			ResourceRecordSet: []route53ResourceRecord{
+				{Value: route53QuoteIfTXT(rtype, content)},
+			},
+		}},
+	}
+
+	body, err := xml.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("/%s/hostedzone/%s/rrset", route53APIVers, zoneID)
+	return p.do(http.MethodPost, path, body, nil)
+}
+
+// route53QuoteIfTXT wraps TXT content in quotes the way Route53 requires
+// for its ResourceRecord Value field — every other record type is sent
+// as-is.
+func route53QuoteIfTXT(rtype, content string) string {
+	if rtype == "TXT" && !strings.HasPrefix(content, "\"") {
+		return `"` + content + `"`
+	}
+	return content
+}
+
+func (p *Route53Provider) CreateZone(name string, ns []string) error {
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<CreateHostedZoneRequest xmlns="https://route53.amazonaws.com/doc/2013-04-01/">
+  <Name>%s</Name>
+  <CallerReference>%s</CallerReference>
+</CreateHostedZoneRequest>`, name, name+"-"+route53Region)
+
+	var result struct {
+		XMLName xml.Name `xml:"CreateHostedZoneResponse"`
+		HostedZone struct {
+			ID string `xml:"Id"`
+		} `xml:"HostedZone"`
+	}
+	if err := p.doDecode(http.MethodPost, "/"+route53APIVers+"/hostedzone", []byte(body), &result); err != nil {
+		return err
+	}
+	// AWS returns Id as "/hostedzone/ABCDEF" — ChangeResourceRecordSets and
+	// friends want just the trailing id.
+	p.hostedZoneIDs[name] = strings.TrimPrefix(result.HostedZone.ID, "/hostedzone/")
+	return nil
+}
+
+func (p *Route53Provider) DeleteZone(name string) error {
+	zoneID, err := p.zoneID(name)
+	if err != nil {
+		return err
+	}
+	if err := p.do(http.MethodDelete, "/"+route53APIVers+"/hostedzone/"+zoneID, nil, nil); err != nil {
+		return err
+	}
+	delete(p.hostedZoneIDs, name)
+	return nil
+}
+
+func (p *Route53Provider) UpsertRecord(r database.DNSRecord, wafIP string, proxied bool) (string, error) {
+	zone, _, err := database.ResolveZone(r.Name)
+	if err != nil {
+		return "", err
+	}
+	zoneID, err := p.zoneID(zone)
+	if err != nil {
+		return "", err
+	}
+
+	// Same proxy-masking rule AddPowerDNSRecord/CloudflareProvider apply:
+	// publish an A record pointing at wafIP for proxied hosts, except meta
+	// records that must stay publicly visible for verification.
+	finalType, finalContent := r.Type, r.Content
+	shouldProxy := proxied
+	if r.Type == "TXT" || r.Type == "MX" || r.Type == "NS" || r.Type == "SOA" {
+		shouldProxy = false
+	}
+	if shouldProxy {
+		finalType, finalContent = "A", wafIP
+	}
+
+	// UPSERT needs no prior lookup — unlike Cloudflare's id-addressed API,
+	// Route53 keys a change by name+type and replaces whatever was there.
+	if err := p.changeRecordSet(zoneID, "UPSERT", r.Name, finalType, finalContent, r.TTL); err != nil {
+		return "", err
+	}
+	// Route53 has no per-record id of its own; name+type is the key
+	// DeleteRecord already matches on, so an empty id is fine, same as
+	// PowerDNSProvider.
+	return "", nil
+}
+
+func (p *Route53Provider) DeleteRecord(id, name, rtype, content string) error {
+	zone, _, err := database.ResolveZone(name)
+	if err != nil {
+		return err
+	}
+	zoneID, err := p.zoneID(zone)
+	if err != nil {
+		return err
+	}
+	return p.changeRecordSet(zoneID, "DELETE", name, rtype, content, 0)
+}
+
+func (p *Route53Provider) ListRecords(zone string) ([]database.DNSRecord, error) {
+	zoneID, err := p.zoneID(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var result route53ListResponse
+	if err := p.doDecode(http.MethodGet, "/"+route53APIVers+"/hostedzone/"+zoneID+"/rrset", nil, &result); err != nil {
+		return nil, err
+	}
+
+	records := make([]database.DNSRecord, 0, len(result.ResourceRecordSets))
+	for _, rec := range result.ResourceRecordSets {
+		content := ""
+		if len(rec.ResourceRecords) > 0 {
+			content = rec.ResourceRecords[0].Value
+		}
+		records = append(records, database.DNSRecord{
+			Name:    rec.Name,
+			Type:    rec.Type,
+			Content: content,
+			TTL:     rec.TTL,
+		})
+	}
+	return records, nil
+}
+
+// do issues one signed Route53 API call and discards the response body
+// beyond checking for an error envelope.
+func (p *Route53Provider) do(method, path string, body []byte, _ interface{}) error {
+	return p.doDecode(method, path, body, nil)
+}
+
+// doDecode issues one signed Route53 API call and, if out is non-nil,
+// unmarshals the XML response body into it.
+func (p *Route53Provider) doDecode(method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, route53Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	if err := p.signSigV4(req, body); err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var errResp route53ErrorResponse
+		if xml.Unmarshal(respBody, &errResp) == nil && errResp.Message != "" {
+			return fmt.Errorf("route53 API error: %s", errResp.Message)
+		}
+		return fmt.Errorf("route53 API error: status %d", resp.StatusCode)
+	}
+
+	if out != nil && len(respBody) > 0 {
+		return xml.Unmarshal(respBody, out)
+	}
+	return nil
+}
+
+// signSigV4 signs req per AWS Signature Version 4 (the same algorithm
+// every AWS service uses, hand-rolled here rather than pulling in the
+// official SDK for what's otherwise a handful of REST calls).
+func (p *Route53Provider) signSigV4(req *http.Request, body []byte) error {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Host = "route53.amazonaws.com"
+
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.Host, payloadHash, amzDate)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	credentialScope := fmt.Sprintf("%s/%s/%s/aws4_request", dateStamp, route53Region, route53Service)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := route53SigningKey(p.secretAccessKey, dateStamp, route53Region, route53Service)
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	authHeader := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKeyID, credentialScope, signedHeaders, signature)
+	req.Header.Set("Authorization", authHeader)
+
+	return nil
+}
+
+func route53SigningKey(secretKey, dateStamp, region, service string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secretKey), dateStamp)
+	kRegion := hmacSHA256(kDate, region)
+	kService := hmacSHA256(kRegion, service)
+	return hmacSHA256(kService, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,125 @@
+package dnsserver
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// forwardCacheMaxEntries bounds the recursive-answer cache so a flood of
+// distinct foreign names can't grow it without limit — once full, the
+// least recently used entry is evicted to make room for the new one.
+const forwardCacheMaxEntries = 10000
+
+// cacheEntry holds one forwarded answer plus the wall-clock time it stops
+// being servable. The TTL is taken from the answer's own minimum record
+// TTL, so this cache never serves a name past what the upstream resolver
+// itself advertised.
+type cacheEntry struct {
+	key     string
+	msg     *dns.Msg
+	expires time.Time
+}
+
+// forwardCache is a small bounded TTL+LRU cache for answers relayed from
+// Config.Recursors, keyed by qname+qtype. It exists so a burst of repeat
+// lookups for the same foreign name (ad/analytics domains embedded in
+// pages behind this gateway, for instance) doesn't round-trip to the
+// upstream resolver on every single query. order tracks recency of use —
+// front is most-recently-used — so a full cache evicts the coldest entry
+// rather than an arbitrary one.
+type forwardCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // key -> element holding a *cacheEntry
+	order   *list.List
+}
+
+func newForwardCache() *forwardCache {
+	return &forwardCache{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func forwardCacheKey(q dns.Question) string {
+	return q.Name + "|" + dns.TypeToString[q.Qtype]
+}
+
+// get returns a cached reply for q, reframed as a response to r, or nil if
+// there's no unexpired entry. A hit moves the entry to the front of order.
+func (c *forwardCache) get(r *dns.Msg, q dns.Question) *dns.Msg {
+	key := forwardCacheKey(q)
+
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.removeElement(elem)
+		c.mu.Unlock()
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	msg := entry.msg
+	c.mu.Unlock()
+
+	reply := msg.Copy()
+	reply.SetReply(r)
+	reply.Answer = msg.Answer
+	return reply
+}
+
+// set stores resp under q, expiring it after the lowest TTL among its
+// answer records (or minForwardCacheTTL if it has none, e.g. NXDOMAIN),
+// evicting the least recently used entry first if the cache is already at
+// forwardCacheMaxEntries.
+func (c *forwardCache) set(q dns.Question, resp *dns.Msg) {
+	ttl := minForwardCacheTTL
+	for i, rr := range resp.Answer {
+		rrTTL := time.Duration(rr.Header().Ttl) * time.Second
+		if i == 0 || rrTTL < ttl {
+			ttl = rrTTL
+		}
+	}
+	if ttl < minForwardCacheTTL {
+		ttl = minForwardCacheTTL
+	}
+
+	key := forwardCacheKey(q)
+	entry := &cacheEntry{key: key, msg: resp.Copy(), expires: time.Now().Add(ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	for len(c.entries) >= forwardCacheMaxEntries {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeElement(oldest)
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+}
+
+// removeElement drops elem from both order and entries. Caller holds c.mu.
+func (c *forwardCache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// minForwardCacheTTL floors how long even a zero/negative-TTL upstream
+// answer is cached for, so a pathological upstream record can't force a
+// cache lookup on every single query.
+const minForwardCacheTTL = 5 * time.Second
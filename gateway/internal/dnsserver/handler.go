@@ -0,0 +1,171 @@
+package dnsserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"github.com/miekg/dns"
+)
+
+// ServeDNS implements dns.Handler. It answers authoritatively for any name
+// falling inside a zone this gateway manages — applying the same
+// proxied/meta-record rewrite AddPowerDNSRecord (internal/database/dns.go)
+// applies for PowerDNS, but at query time — and forwards anything else to
+// Recursors, or REFUSED if none are configured or all of them fail, rather
+// than spoofing an answer for a zone it doesn't actually serve.
+func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	if len(r.Question) == 0 {
+		msg := new(dns.Msg)
+		msg.SetRcode(r, dns.RcodeFormatError)
+		w.WriteMsg(msg)
+		return
+	}
+	s.recordQuery()
+
+	q := r.Question[0]
+	qname := strings.TrimSuffix(strings.ToLower(q.Name), ".")
+
+	zoneName, nameservers, records, ok := s.lookupZone(qname)
+	if !ok {
+		s.forward(w, r)
+		return
+	}
+
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+	msg.Authoritative = true
+
+	// Synthesize NS/SOA for the zone apex from the domain's Nameservers
+	// field instead of requiring an operator to hand-create those as
+	// ordinary dns_records rows.
+	if qname == zoneName && len(nameservers) > 0 {
+		switch q.Qtype {
+		case dns.TypeNS:
+			msg.Answer = append(msg.Answer, nsRecords(zoneName, nameservers)...)
+			w.WriteMsg(msg)
+			return
+		case dns.TypeSOA:
+			if rr, err := soaRecord(zoneName, nameservers[0]); err == nil {
+				msg.Answer = append(msg.Answer, rr)
+			}
+			w.WriteMsg(msg)
+			return
+		}
+	}
+
+	for _, rec := range records {
+		if strings.TrimSuffix(strings.ToLower(rec.Name), ".") != qname {
+			continue
+		}
+
+		rrType, content := answerFor(rec, s.wafIP)
+		if q.Qtype != dns.TypeANY && dns.StringToType[rrType] != q.Qtype {
+			continue
+		}
+
+		rr, err := dns.NewRR(fmt.Sprintf("%s. %d IN %s %s", qname, rec.TTL, rrType, formatContent(rrType, content)))
+		if err != nil {
+			continue
+		}
+		msg.Answer = append(msg.Answer, rr)
+	}
+
+	w.WriteMsg(msg)
+}
+
+// answerFor applies the exact proxied/meta-record rule AddPowerDNSRecord
+// applies before publishing to PowerDNS: meta records (TXT/MX/NS/SOA) are
+// never proxied, and anything else the operator marked Proxied gets its
+// real destination replaced with wafIP.
+func answerFor(rec database.DNSRecord, wafIP string) (rrType, content string) {
+	shouldProxy := rec.Proxied
+	if rec.Type == "TXT" || rec.Type == "MX" || rec.Type == "NS" || rec.Type == "SOA" {
+		shouldProxy = false
+	}
+	if shouldProxy {
+		return "A", wafIP
+	}
+	return rec.Type, rec.Content
+}
+
+// formatContent quotes TXT content for dns.NewRR's zone-file-style parser;
+// every other record type's content is already a bare token (an IP, a
+// hostname) NewRR accepts as-is.
+func formatContent(rrType, content string) string {
+	if rrType == "TXT" {
+		return strconv.Quote(content)
+	}
+	return content
+}
+
+// lookupZone walks qname's dot-separated suffixes, most specific first, and
+// returns the zone name, nameservers, and records for the first one that
+// matches a zone this server is authoritative for — the same walk
+// ResolveZone (internal/database/dns.go) does against PowerDNS's "domains"
+// table.
+func (s *Server) lookupZone(qname string) (zoneName string, nameservers []string, records []database.DNSRecord, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	labels := strings.Split(qname, ".")
+	for i := 0; i < len(labels); i++ {
+		candidate := strings.Join(labels[i:], ".")
+		if z, found := s.zones[candidate]; found {
+			return candidate, z.nameservers, z.records, true
+		}
+	}
+	return "", nil, nil, false
+}
+
+// zoneMetaTTL is the TTL synthesized NS/SOA answers carry — these aren't
+// backed by a DNSRecord.TTL since there's no record for them in Mongo.
+const zoneMetaTTL = 3600
+
+// nsRecords builds one NS answer per nameserver for zone's apex.
+func nsRecords(zoneName string, nameservers []string) []dns.RR {
+	var out []dns.RR
+	for _, ns := range nameservers {
+		rr, err := dns.NewRR(fmt.Sprintf("%s. %d IN NS %s", zoneName, zoneMetaTTL, dns.Fqdn(ns)))
+		if err != nil {
+			continue
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
+// soaRecord builds a single SOA answer for zone's apex, naming primaryNS as
+// the MNAME the way PowerDNS's own SOA template does.
+func soaRecord(zoneName, primaryNS string) (dns.RR, error) {
+	serial := time.Now().Unix()
+	return dns.NewRR(fmt.Sprintf("%s. %d IN SOA %s admin.%s. %d 7200 3600 1209600 %d", zoneName, zoneMetaTTL, dns.Fqdn(primaryNS), zoneName, serial, zoneMetaTTL))
+}
+
+// forward relays r to the first configured recursor that answers, serving
+// out of fwdCache when possible instead of round-tripping upstream, and
+// falling back to REFUSED if none are configured or all of them fail.
+func (s *Server) forward(w dns.ResponseWriter, r *dns.Msg) {
+	q := r.Question[0]
+	if cached := s.fwdCache.get(r, q); cached != nil {
+		w.WriteMsg(cached)
+		return
+	}
+
+	client := &dns.Client{Timeout: 3 * time.Second}
+	for _, upstream := range s.recursors {
+		resp, _, err := client.Exchange(r, upstream)
+		if err == nil && resp != nil {
+			s.fwdCache.set(q, resp)
+			w.WriteMsg(resp)
+			return
+		}
+	}
+
+	msg := new(dns.Msg)
+	msg.SetRcode(r, dns.RcodeRefused)
+	w.WriteMsg(msg)
+}
@@ -0,0 +1,214 @@
+// Package dnsserver is an optional, in-process authoritative DNS server
+// (github.com/miekg/dns) that answers straight out of the same dns_records
+// Mongo collection /api/dns/records manages, instead of requiring an
+// external PowerDNS/MySQL deployment (internal/dnsbackend.PowerDNSProvider).
+// It applies the same proxied/A-rewrite rule AddPowerDNSRecord applies for
+// PowerDNS, but at query time rather than write time, and falls back to
+// configured upstream recursive resolvers for names outside any zone it's
+// authoritative for.
+package dnsserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"github.com/miekg/dns"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Config is everything Server needs to bind and answer queries. A nil/empty
+// Recursors just means foreign-zone queries get REFUSED instead of
+// forwarded, same as an authoritative-only PowerDNS deployment would.
+type Config struct {
+	Addr        string   // host:port to bind UDP+TCP, e.g. ":53"
+	WafPublicIP string   // published as the A record for proxied hosts, same as AddPowerDNSRecord's wafIP
+	Recursors   []string // upstream resolvers tried in order, e.g. "1.1.1.1:53"
+}
+
+// zone is the in-memory answer set for one authoritative domain, rebuilt
+// wholesale on every reload rather than patched record-by-record — the
+// same tradeoff ReloadRules makes for the WAF rule cache (internal/api),
+// and for the same reason: dns_records is small enough that a full reload
+// is cheap and a lot simpler to reason about than incremental diffing.
+type zone struct {
+	// nameservers is the domain's models.Domain.Nameservers, used to
+	// synthesize NS/SOA answers for the zone apex instead of requiring an
+	// operator to hand-create those as ordinary dns_records rows.
+	nameservers []string
+	records     []database.DNSRecord
+}
+
+// Server answers DNS queries for every zone it's authoritative for and
+// forwards everything else to Config.Recursors. Running it is entirely
+// optional — cmd/server only starts one when DNSServer.Enabled is set, and
+// internal/dnsbackend's PowerDNS/Cloudflare path keeps working either way.
+type Server struct {
+	client    *mongo.Client
+	addr      string
+	wafIP     string
+	recursors []string
+
+	udp *dns.Server
+	tcp *dns.Server
+
+	mu    sync.RWMutex
+	zones map[string]*zone
+
+	// fwdCache holds recent answers relayed from recursors, so a burst of
+	// repeat lookups for the same foreign name doesn't round-trip upstream
+	// every time. Nothing served out of s.zones touches it.
+	fwdCache *forwardCache
+
+	// reloadCh is shared between watchZoneChanges' change-stream watchers
+	// and invalidate (the database.RegisterDNSInvalidationHook callback),
+	// so a write made through this very process (CreateDNSRecord etc.)
+	// reloads the cache immediately instead of waiting on the next change
+	// stream event.
+	reloadCh chan struct{}
+
+	queriesMu sync.Mutex
+	window    []time.Time // query timestamps within the last minute, mirrors internal/metrics' tracker
+	total     uint64
+}
+
+// NewServer wires a Server against client; call Start to load the zone
+// cache and begin answering queries.
+func NewServer(client *mongo.Client, cfg Config) *Server {
+	return &Server{
+		client:    client,
+		addr:      cfg.Addr,
+		wafIP:     cfg.WafPublicIP,
+		recursors: cfg.Recursors,
+		zones:     make(map[string]*zone),
+		reloadCh:  make(chan struct{}, 1),
+		fwdCache:  newForwardCache(),
+	}
+}
+
+// invalidate schedules a zone-cache reload, coalesced the same way
+// watchZoneChanges debounces change-stream events. It's registered with
+// internal/database as a DNS-mutation hook (see Start) so a record created,
+// deleted, or re-proxied through this very process takes effect without
+// waiting for that write's own change-stream event to arrive.
+func (s *Server) invalidate() {
+	select {
+	case s.reloadCh <- struct{}{}:
+	default:
+	}
+}
+
+// Start loads the zone cache, binds UDP and TCP listeners on Addr, and
+// launches the change-stream watcher (watch.go) that keeps the cache
+// current without a restart. It returns once both listeners have
+// confirmed startup (or after a short timeout, so a slow bind doesn't hang
+// the gateway's own boot sequence). Cancelling ctx stops the change-stream
+// watcher; call Stop to shut the listeners down.
+func (s *Server) Start(ctx context.Context) error {
+	if err := s.reloadZones(); err != nil {
+		log.Printf("[WARN] dnsserver: initial reloadZones failed, starting with an empty zone cache: %v", err)
+	}
+
+	// [NEW] Reload instantly on a DNS record mutation made through this
+	// process, rather than waiting on the change-stream watcher below.
+	database.RegisterDNSInvalidationHook(s.invalidate)
+
+	s.udp = &dns.Server{Addr: s.addr, Net: "udp", Handler: s}
+	s.tcp = &dns.Server{Addr: s.addr, Net: "tcp", Handler: s}
+
+	udpReady := make(chan struct{}, 1)
+	s.udp.NotifyStartedFunc = func() { udpReady <- struct{}{} }
+	go func() {
+		if err := s.udp.ListenAndServe(); err != nil {
+			log.Printf("[ERROR] dnsserver: udp listener on %s stopped: %v", s.addr, err)
+		}
+	}()
+
+	tcpReady := make(chan struct{}, 1)
+	s.tcp.NotifyStartedFunc = func() { tcpReady <- struct{}{} }
+	go func() {
+		if err := s.tcp.ListenAndServe(); err != nil {
+			log.Printf("[ERROR] dnsserver: tcp listener on %s stopped: %v", s.addr, err)
+		}
+	}()
+
+	select {
+	case <-udpReady:
+	case <-time.After(2 * time.Second):
+		log.Printf("[WARN] dnsserver: udp listener on %s did not confirm startup within 2s", s.addr)
+	}
+	select {
+	case <-tcpReady:
+	case <-time.After(2 * time.Second):
+		log.Printf("[WARN] dnsserver: tcp listener on %s did not confirm startup within 2s", s.addr)
+	}
+
+	go s.watchZoneChanges(ctx)
+
+	log.Printf("🌐 dnsserver: authoritative DNS listening on %s (udp+tcp), %d recursor(s) configured", s.addr, len(s.recursors))
+	return nil
+}
+
+// Stop gracefully shuts down both listeners.
+func (s *Server) Stop() error {
+	var errs []error
+	if s.udp != nil {
+		if err := s.udp.Shutdown(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.tcp != nil {
+		if err := s.tcp.Shutdown(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("dnsserver: shutdown errors: %v", errs)
+	}
+	return nil
+}
+
+// recordQuery marks one query in the rolling one-minute window Stats reads.
+func (s *Server) recordQuery() {
+	s.queriesMu.Lock()
+	defer s.queriesMu.Unlock()
+
+	s.total++
+	now := time.Now()
+	s.window = append(s.window, now)
+	cutoff := now.Add(-1 * time.Minute)
+	trimmed := s.window[:0]
+	for _, ts := range s.window {
+		if ts.After(cutoff) {
+			trimmed = append(trimmed, ts)
+		}
+	}
+	s.window = trimmed
+}
+
+// Stats is the live snapshot internal/api's SystemStatus renders into a
+// ComponentStatus.
+type Stats struct {
+	QueriesPerMin int
+	ZoneCount     int
+	TotalQueries  uint64
+}
+
+// Stats returns the server's current query rate and the number of zones it
+// currently holds authoritative records for.
+func (s *Server) Stats() Stats {
+	s.queriesMu.Lock()
+	qpm := len(s.window)
+	total := s.total
+	s.queriesMu.Unlock()
+
+	s.mu.RLock()
+	zoneCount := len(s.zones)
+	s.mu.RUnlock()
+
+	return Stats{QueriesPerMin: qpm, ZoneCount: zoneCount, TotalQueries: total}
+}
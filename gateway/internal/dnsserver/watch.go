@@ -0,0 +1,135 @@
+package dnsserver
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// domainDoc is the subset of a "domains" document reloadZones needs — just
+// which zones are active — without going through internal/models (the
+// import the rest of internal/database still uses for the full Domain
+// type).
+type domainDoc struct {
+	ID          string   `bson:"_id"`
+	Name        string   `bson:"name"`
+	Status      string   `bson:"status"`
+	Nameservers []string `bson:"nameservers"`
+}
+
+// reloadZones rebuilds the in-memory zone map wholesale from Mongo. This is
+// the same cold-start/full-reload tradeoff internal/api's ReloadRules makes
+// for the WAF rule cache: dns_records is small enough that a full reload is
+// simpler than diffing it record-by-record.
+func (s *Server) reloadZones() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.client.Database(database.DBName).Collection("domains").Find(ctx, bson.M{"status": "active"})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var domains []domainDoc
+	if err := cursor.All(ctx, &domains); err != nil {
+		return err
+	}
+
+	records, err := database.GetAllDNSRecords(s.client)
+	if err != nil {
+		return err
+	}
+
+	byDomain := make(map[string][]database.DNSRecord)
+	for _, rec := range records {
+		byDomain[rec.DomainID] = append(byDomain[rec.DomainID], rec)
+	}
+
+	zones := make(map[string]*zone, len(domains))
+	for _, d := range domains {
+		zones[strings.ToLower(d.Name)] = &zone{nameservers: d.Nameservers, records: byDomain[d.ID]}
+	}
+
+	s.mu.Lock()
+	s.zones = zones
+	s.mu.Unlock()
+
+	return nil
+}
+
+// watchedCollections feed reloadZones: any insert/update/delete on one of
+// these can change what this server should answer.
+var watchedCollections = []string{"domains", "dns_records"}
+
+// reloadDebounce coalesces a burst of change events into a single
+// reloadZones call, matching internal/api/watch.go's ReloadRules debounce.
+const reloadDebounce = 250 * time.Millisecond
+
+// watchZoneChanges watches domains/dns_records via MongoDB change streams
+// and calls reloadZones whenever either changes, so an edit made through
+// /api/dns/records takes effect without restarting this server. If change
+// streams aren't available (e.g. Mongo isn't running as a replica set) this
+// just logs and the server keeps answering from whatever reloadZones last
+// computed, at Start or after a write made through this process (invalidate).
+//
+// Call this in its own goroutine; it blocks until ctx is cancelled.
+func (s *Server) watchZoneChanges(ctx context.Context) {
+	for _, coll := range watchedCollections {
+		go s.watchCollection(ctx, coll, s.reloadCh)
+	}
+
+	debounce := time.NewTimer(0)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+	pending := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.reloadCh:
+			if !pending {
+				pending = true
+				debounce.Reset(reloadDebounce)
+			}
+		case <-debounce.C:
+			if pending {
+				pending = false
+				if err := s.reloadZones(); err != nil {
+					log.Printf("[WARN] dnsserver: reloadZones failed: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// watchCollection runs a single change-stream cursor against one
+// collection, signalling reloadCh on every event until ctx is cancelled or
+// the stream errors out.
+func (s *Server) watchCollection(ctx context.Context, collection string, reloadCh chan<- struct{}) {
+	stream, err := s.client.Database(database.DBName).Collection(collection).Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		log.Printf("[WARN] dnsserver: watchCollection(%s): change streams unavailable, falling back to the static zone cache: %v", collection, err)
+		return
+	}
+	defer stream.Close(ctx)
+
+	for stream.Next(ctx) {
+		var event bson.M
+		if err := stream.Decode(&event); err != nil {
+			continue
+		}
+		select {
+		case reloadCh <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -2,21 +2,38 @@ package handler
 
 import (
 	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
 	"web-app-firewall-ml-detection/internal/core"
+	"web-app-firewall-ml-detection/internal/repository/dnsprovider"
 	"web-app-firewall-ml-detection/internal/repository/sql"
 )
 
+// txtChallengeLabel is the DNS label VerifyDomainTXT looks up, mirroring
+// the "_acme-challenge" convention the ACME subsystem already uses.
+const txtChallengeLabel = "_minishield-challenge"
+
+// generateVerificationToken returns a random "minishield-verify=<32 hex>"
+// token for the TXT-record ownership check.
+func generateVerificationToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := cryptorand.Read(buf); err != nil {
+		return "", err
+	}
+	return "minishield-verify=" + hex.EncodeToString(buf), nil
+}
+
 var realNameservers = []string{
-	"jiniyas", "rabin", "niraj", "sabin", "rita", 
+	"jiniyas", "rabin", "niraj", "sabin", "rita",
 	"sneha", "exam", "bikalpa", "raju", "dhiren", "sanket",
 }
 
@@ -29,46 +46,31 @@ type RDAPResponse struct {
 }
 
 type DomainHandler struct {
-	repo    core.DomainRepository
-	dnsRepo *sql.DNSRepository 
-	wafIP   string 
+	repo      core.DomainRepository
+	dnsRepo   *sql.DNSRepository
+	providers *dnsprovider.Registry
+	wafIP     string
 }
 
-func NewDomainHandler(r core.DomainRepository, d *sql.DNSRepository, wafIP string) *DomainHandler {
-	return &DomainHandler{repo: r, dnsRepo: d, wafIP: wafIP}
+func NewDomainHandler(r core.DomainRepository, d *sql.DNSRepository, providers *dnsprovider.Registry, wafIP string) *DomainHandler {
+	return &DomainHandler{repo: r, dnsRepo: d, providers: providers, wafIP: wafIP}
+}
+
+// providerFor resolves the core.DNSProvider backend a domain publishes
+// through, keyed by its DNSProviderKind, so the split-brain logic below
+// never talks to PowerDNS/MySQL directly.
+func (h *DomainHandler) providerFor(domain *core.Domain) (core.DNSProvider, error) {
+	return h.providers.For(domain.DNSProviderKind)
 }
 
 func getRootDomain(domain string) string {
 	parts := strings.Split(domain, ".")
-	if len(parts) < 2 { return domain }
+	if len(parts) < 2 {
+		return domain
+	}
 	return parts[len(parts)-2] + "." + parts[len(parts)-1]
 }
 
-func checkRegistrarRDAP(domain string) ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-	
-	url := fmt.Sprintf("https://rdap.org/domain/%s", domain)
-	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
-	req.Header.Set("Accept", "application/rdap+json")
-	
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil { return nil, err }
-	defer resp.Body.Close()
-	
-	if resp.StatusCode == 404 { return nil, fmt.Errorf("domain not found") }
-	body, _ := io.ReadAll(resp.Body)
-	
-	var rdapResp RDAPResponse
-	json.Unmarshal(body, &rdapResp)
-	
-	var nameservers []string
-	for _, ns := range rdapResp.Nameservers {
-		nameservers = append(nameservers, strings.TrimSuffix(ns.LdhName, "."))
-	}
-	return nameservers, nil
-}
-
 func (h *DomainHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
 	var domain core.Domain
 	if err := json.NewDecoder(r.Body).Decode(&domain); err != nil {
@@ -102,14 +104,23 @@ func (h *DomainHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
 	rng := rand.New(source)
 	idx1 := rng.Intn(len(realNameservers))
 	idx2 := rng.Intn(len(realNameservers))
-	for idx1 == idx2 { idx2 = rng.Intn(len(realNameservers)) }
-	
+	for idx1 == idx2 {
+		idx2 = rng.Intn(len(realNameservers))
+	}
+
 	ns1 := realNameservers[idx1] + nsSuffix
 	ns2 := realNameservers[idx2] + nsSuffix
 	domain.Nameservers = []string{ns1, ns2}
 	domain.Status = "pending_verification"
 	domain.ProxyEnabled = true // Default setting
 
+	token, err := generateVerificationToken()
+	if err != nil {
+		JSONError(w, "Failed to generate verification token", http.StatusInternalServerError)
+		return
+	}
+	domain.VerificationToken = token
+
 	// Create in Mongo
 	createdDomain, err := h.repo.Create(r.Context(), domain)
 	if err != nil {
@@ -121,18 +132,27 @@ func (h *DomainHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Provision PowerDNS (SOA + NS only)
+	// Provision the authoritative zone (SOA + NS only)
 	go func(d core.Domain, n1, n2 string) {
-		if h.dnsRepo == nil { return }
+		provider, err := h.providerFor(&d)
+		if err != nil {
+			log.Printf("[ERROR] dnsprovider: no backend for %s: %v", d.Name, err)
+			return
+		}
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
+		if err := provider.EnsureZone(ctx, d.Name); err != nil {
+			log.Printf("[ERROR] dnsprovider: failed to ensure zone for %s: %v", d.Name, err)
+			return
+		}
+
 		serial := time.Now().Format("2006010201")
 		soaContent := fmt.Sprintf("ns1.minishield.tech. hostmaster.minishield.tech. %s 10800 3600 604800 3600", serial)
-		
-		h.dnsRepo.CreateRecord(ctx, d.Name, core.DNSRecord{Name: d.Name, Type: "SOA", Content: soaContent, TTL: 3600})
-		h.dnsRepo.CreateRecord(ctx, d.Name, core.DNSRecord{Name: d.Name, Type: "NS", Content: n1, TTL: 3600})
-		h.dnsRepo.CreateRecord(ctx, d.Name, core.DNSRecord{Name: d.Name, Type: "NS", Content: n2, TTL: 3600})
+
+		provider.CreateRecord(ctx, d.Name, core.DNSRecord{Name: d.Name, Type: "SOA", Content: soaContent, TTL: 3600})
+		provider.CreateRecord(ctx, d.Name, core.DNSRecord{Name: d.Name, Type: "NS", Content: n1, TTL: 3600})
+		provider.CreateRecord(ctx, d.Name, core.DNSRecord{Name: d.Name, Type: "NS", Content: n2, TTL: 3600})
 	}(createdDomain, ns1, ns2)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -163,10 +183,10 @@ func (h *DomainHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Security Check (RDAP)
-	foundNS, err := checkRegistrarRDAP(domain.Name)
+	// Security Check (multi-source registrar verification)
+	result, err := VerifyNameservers(r.Context(), domain.Name)
 	if err != nil {
-		log.Printf("RDAP Lookup failed: %v", err)
+		log.Printf("Registrar verification failed: %v", err)
 		w.WriteHeader(http.StatusServiceUnavailable)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Verification Unavailable", "details": err.Error()})
 		return
@@ -174,7 +194,7 @@ func (h *DomainHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
 
 	matchedCount := 0
 	for _, assignedNS := range domain.Nameservers {
-		for _, liveNS := range foundNS {
+		for _, liveNS := range result.Nameservers {
 			if strings.EqualFold(liveNS, assignedNS) {
 				matchedCount++
 				break
@@ -187,33 +207,11 @@ func (h *DomainHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if verified {
-		h.repo.RevokeOldOwnership(r.Context(), domain.Name, domain.ID)
-		
-		// Activate AND Enable Proxy
-		domain.Status = "active"
-		domain.ProxyEnabled = true
-
-		h.repo.UpdateStatus(r.Context(), domain.ID, "active")
-		h.repo.UpdateProxyMode(r.Context(), domain.ID, true)
-
-		// [SPLIT BRAIN] Add Default WAF A Record to SQL ONLY
-		// We do NOT add this to Mongo because it's a system record, not user input.
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			
-			log.Printf("🔹 Adding Default WAF A Record to SQL: %s -> %s", domain.Name, h.wafIP)
-			h.dnsRepo.CreateRecord(ctx, domain.Name, core.DNSRecord{
-				Name:    domain.Name,
-				Type:    "A",
-				Content: h.wafIP, 
-				TTL:     3600,
-			})
-		}()
-
-		json.NewEncoder(w).Encode(map[string]string{
+		h.activateDomain(r.Context(), domain)
+		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status":  "active",
 			"message": "Domain verified! Proxy Mode is ON by default.",
+			"source":  strings.Join(result.AgreedBy, "+"),
 		})
 	} else {
 		w.WriteHeader(http.StatusConflict)
@@ -221,13 +219,146 @@ func (h *DomainHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
 			"status":             "pending_verification",
 			"message":            "Verification failed. Nameservers do not match.",
 			"assigned_ns":        domain.Nameservers,
-			"found_at_registrar": foundNS,
+			"found_at_registrar": result.Nameservers,
+			"sources":            result.RawBySource,
+			"source_errors":      result.Errors,
+		})
+	}
+}
+
+// VerifyDomainTXT is the alternative to VerifyDomain for registrars that
+// don't allow custom nameservers (or whose RDAP endpoint is down): it
+// confirms ownership by looking up "_minishield-challenge.<domain>" TXT
+// against public resolvers instead of checking delegated NS records, then
+// converges on the same activateDomain path.
+func (h *DomainHandler) VerifyDomainTXT(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domainID := r.URL.Query().Get("id")
+	if domainID == "" {
+		JSONError(w, "Missing domain id", http.StatusBadRequest)
+		return
+	}
+
+	domain, err := h.repo.GetByID(r.Context(), domainID)
+	if err != nil {
+		JSONError(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	if domain.UserID != userID {
+		JSONError(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	if domain.VerificationToken == "" {
+		JSONError(w, "Domain has no verification token", http.StatusBadRequest)
+		return
+	}
+
+	found, err := lookupTXTBypassingCache(r.Context(), txtChallengeLabel+"."+domain.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Verification Unavailable", "details": err.Error()})
+		return
+	}
+
+	verified := false
+	for _, txt := range found {
+		if strings.Contains(txt, domain.VerificationToken) {
+			verified = true
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if verified {
+		h.activateDomain(r.Context(), domain)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "active",
+			"message": "Domain verified via TXT record! Proxy Mode is ON by default.",
 		})
+		return
+	}
+
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":            "pending_verification",
+		"message":           "Verification failed. TXT record not found or token mismatch.",
+		"expected_record":   txtChallengeLabel + "." + domain.Name,
+		"expected_value":    domain.VerificationToken,
+		"found_txt_records": found,
+	})
+}
+
+// lookupTXTBypassingCache resolves a TXT record directly against public
+// resolvers (1.1.1.1, 8.8.8.8) rather than the host's configured recursor,
+// so a just-published record isn't masked by local negative caching.
+func lookupTXTBypassingCache(ctx context.Context, name string) ([]string, error) {
+	resolvers := []string{"1.1.1.1:53", "8.8.8.8:53"}
+
+	var lastErr error
+	for _, addr := range resolvers {
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: 5 * time.Second}
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		records, err := resolver.LookupTXT(lookupCtx, name)
+		cancel()
+		if err == nil {
+			return records, nil
+		}
+		lastErr = err
 	}
+	return nil, fmt.Errorf("TXT lookup failed against all resolvers: %w", lastErr)
+}
+
+// activateDomain marks domain active, enables proxy mode, and provisions
+// the default WAF A record (it does not start ACME issuance) — the single
+// path both VerifyDomain (NS delegation) and VerifyDomainTXT converge on.
+func (h *DomainHandler) activateDomain(ctx context.Context, domain *core.Domain) {
+	h.repo.RevokeOldOwnership(ctx, domain.Name, domain.ID)
+
+	domain.Status = "active"
+	domain.ProxyEnabled = true
+
+	h.repo.UpdateStatus(ctx, domain.ID, "active")
+	h.repo.UpdateProxyMode(ctx, domain.ID, true)
+
+	// [SPLIT BRAIN] Add Default WAF A Record to the authoritative backend ONLY
+	// We do NOT add this to Mongo because it's a system record, not user input.
+	go func() {
+		recordCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		provider, err := h.providerFor(domain)
+		if err != nil {
+			log.Printf("[ERROR] dnsprovider: no backend for %s: %v", domain.Name, err)
+			return
+		}
+
+		log.Printf("🔹 Adding Default WAF A Record via %s: %s -> %s", domain.DNSProviderKind, domain.Name, h.wafIP)
+		provider.CreateRecord(recordCtx, domain.Name, core.DNSRecord{
+			Name:    domain.Name,
+			Type:    "A",
+			Content: h.wafIP,
+			TTL:     3600,
+		})
+	}()
 }
 
 func (h *DomainHandler) ManageRecords(w http.ResponseWriter, r *http.Request) {
-	if h.dnsRepo == nil {
+	if h.providers == nil {
 		JSONError(w, "DNS service unavailable", http.StatusServiceUnavailable)
 		return
 	}
@@ -267,30 +398,33 @@ func (h *DomainHandler) ManageRecords(w http.ResponseWriter, r *http.Request) {
 			JSONError(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
-		
+
 		record.DomainID = domainID
-		
+
 		// 1. Save to MongoDB (Source of Truth)
 		id, err := h.repo.CreateRecord(r.Context(), record)
 		if err != nil {
 			JSONError(w, "Database error", http.StatusInternalServerError)
 			return
 		}
-		
-		// 2. SQL Sync Logic (Split Brain)
-		// Non-routable records (MX, TXT, NS) must always go to SQL to work.
-		if record.Type == "NS" || record.Type == "MX" || record.Type == "TXT" {
-			go h.dnsRepo.CreateRecord(context.Background(), domain.Name, record)
+
+		// 2. Authoritative backend sync (Split Brain)
+		// Non-routable records (MX, TXT, NS) must always be published to work.
+		provider, provErr := h.providerFor(domain)
+		if provErr != nil {
+			log.Printf("[ERROR] dnsprovider: no backend for %s: %v", domain.Name, provErr)
+		} else if record.Type == "NS" || record.Type == "MX" || record.Type == "TXT" {
+			go provider.CreateRecord(context.Background(), domain.Name, record)
 		} else if record.Type == "A" || record.Type == "CNAME" {
-			// Routable records only go to SQL if Proxy is OFF.
-			// If Proxy is ON, we ignore them in SQL (so the default WAF IP stays active).
+			// Routable records only go to the backend if Proxy is OFF.
+			// If Proxy is ON, we ignore them there (so the default WAF IP stays active).
 			if !domain.ProxyEnabled {
-				go h.dnsRepo.CreateRecord(context.Background(), domain.Name, record)
+				go provider.CreateRecord(context.Background(), domain.Name, record)
 			}
 		}
 
 		JSONSuccess(w, map[string]string{"id": id})
-	
+
 	default:
 		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -326,18 +460,27 @@ func (h *DomainHandler) ToggleProxyMode(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// 3. Switch SQL Records (The Big Swap)
+	// 3. Switch the authoritative backend's records (The Big Swap), picked up
+	// from whichever provider this domain's DNSProviderKind currently names —
+	// an operator can migrate a zone to a new backend and this goroutine
+	// follows without any code change here.
 	go func() {
 		ctx := context.Background()
-		
+
+		provider, err := h.providerFor(domain)
+		if err != nil {
+			log.Printf("[ERROR] dnsprovider: no backend for %s: %v", domain.Name, err)
+			return
+		}
+
 		if req.Enabled {
 			// --- ENABLING PROXY MODE ---
-			// 1. Remove User's A/CNAME records from SQL (they expose the real IP)
-			h.dnsRepo.DeleteRecordsByType(ctx, domain.Name, "A")
-			h.dnsRepo.DeleteRecordsByType(ctx, domain.Name, "CNAME")
-			
+			// 1. Remove User's A/CNAME records (they expose the real IP)
+			provider.DeleteRecordsByType(ctx, domain.Name, "A")
+			provider.DeleteRecordsByType(ctx, domain.Name, "CNAME")
+
 			// 2. Add the "Shield" (Default WAF A Record)
-			h.dnsRepo.CreateRecord(ctx, domain.Name, core.DNSRecord{
+			provider.CreateRecord(ctx, domain.Name, core.DNSRecord{
 				Name:    domain.Name,
 				Type:    "A",
 				Content: h.wafIP,
@@ -348,13 +491,13 @@ func (h *DomainHandler) ToggleProxyMode(w http.ResponseWriter, r *http.Request)
 		} else {
 			// --- DISABLING PROXY MODE (DNS ONLY) ---
 			// 1. Remove the "Shield" (WAF IP)
-			h.dnsRepo.DeleteRecordsByType(ctx, domain.Name, "A")
-			
-			// 2. Push ALL User A/CNAME records from Mongo -> SQL
+			provider.DeleteRecordsByType(ctx, domain.Name, "A")
+
+			// 2. Push ALL User A/CNAME records from Mongo -> the backend
 			userRecords, _ := h.repo.GetRecords(ctx, req.DomainID)
 			for _, rec := range userRecords {
 				if rec.Type == "A" || rec.Type == "CNAME" {
-					h.dnsRepo.CreateRecord(ctx, domain.Name, rec)
+					provider.CreateRecord(ctx, domain.Name, rec)
 				}
 			}
 			log.Printf("🌍 DNS Mode for %s: Exposed User Records directly", domain.Name)
@@ -372,4 +515,4 @@ func (h *DomainHandler) ListDomains(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	JSONSuccess(w, domains)
-}
\ No newline at end of file
+}
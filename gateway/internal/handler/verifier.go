@@ -0,0 +1,422 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verifier resolves the nameservers a registry/registrar has on file for
+// domain, via whatever source it wraps (RDAP, DNS, WHOIS, ...).
+type Verifier func(ctx context.Context, domain string) ([]string, error)
+
+// namedVerifier pairs a Verifier with the source name reported back in the
+// JSON response, so operators can see which source answered for a slow TLD.
+type namedVerifier struct {
+	source string
+	verify Verifier
+}
+
+// verifierChain tries, in order of how authoritative/cheap each source is:
+// IANA bootstrap RDAP, then direct NS lookups against public resolvers,
+// then WHOIS. All three run regardless of earlier failures so two
+// independent sources can still agree even if the first one errors out.
+func verifierChain() []namedVerifier {
+	return []namedVerifier{
+		{source: "iana-rdap", verify: ianaBootstrapRDAPLookup},
+		{source: "dns-ns", verify: publicResolverNSLookup},
+		{source: "whois", verify: whoisNSLookup},
+	}
+}
+
+// VerificationResult is what VerifyNameservers hands back: the agreed-upon
+// NS pair (if any two sources matched), which sources were in that
+// agreement, and the raw per-source results for debugging a disagreement.
+type VerificationResult struct {
+	Nameservers []string
+	AgreedBy    []string
+	RawBySource map[string][]string
+	Errors      map[string]string
+}
+
+// normalizeNS lowercases, trims the trailing dot, and sorts a nameserver
+// list so two sources that agree but format differently (case, trailing
+// dot, order) still compare equal.
+func normalizeNS(ns []string) []string {
+	out := make([]string, 0, len(ns))
+	for _, n := range ns {
+		out = append(out, strings.ToLower(strings.TrimSuffix(strings.TrimSpace(n), ".")))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sameNS(a, b []string) bool {
+	na, nb := normalizeNS(a), normalizeNS(b)
+	if len(na) == 0 || len(na) != len(nb) {
+		return false
+	}
+	for i := range na {
+		if na[i] != nb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyNameservers runs every source in the chain concurrently and
+// considers domain verified if any two of them return the same NS set.
+// It only returns an error when every source failed outright (e.g. no
+// network); a clean disagreement between sources is reported via a nil
+// Nameservers field, not an error, so callers can show the mismatch.
+func VerifyNameservers(ctx context.Context, domain string) (*VerificationResult, error) {
+	chain := verifierChain()
+
+	type outcome struct {
+		source string
+		ns     []string
+		err    error
+	}
+	results := make([]outcome, len(chain))
+
+	var wg sync.WaitGroup
+	for i, nv := range chain {
+		wg.Add(1)
+		go func(i int, nv namedVerifier) {
+			defer wg.Done()
+			sourceCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			ns, err := nv.verify(sourceCtx, domain)
+			results[i] = outcome{source: nv.source, ns: ns, err: err}
+		}(i, nv)
+	}
+	wg.Wait()
+
+	out := &VerificationResult{
+		RawBySource: make(map[string][]string),
+		Errors:      make(map[string]string),
+	}
+
+	var ok []outcome
+	for _, r := range results {
+		if r.err != nil {
+			out.Errors[r.source] = r.err.Error()
+			continue
+		}
+		out.RawBySource[r.source] = r.ns
+		ok = append(ok, r)
+	}
+
+	if len(ok) == 0 {
+		return out, fmt.Errorf("all verification sources failed: %v", out.Errors)
+	}
+
+	for i := 0; i < len(ok); i++ {
+		for j := i + 1; j < len(ok); j++ {
+			if sameNS(ok[i].ns, ok[j].ns) {
+				out.Nameservers = ok[i].ns
+				out.AgreedBy = []string{ok[i].source, ok[j].source}
+				return out, nil
+			}
+		}
+	}
+
+	// No two sources agreed; leave Nameservers nil so the caller reports a
+	// mismatch instead of a hard failure.
+	return out, nil
+}
+
+// --- Source 1: IANA bootstrap-based RDAP ----------------------------------
+
+type ianaBootstrapFile struct {
+	Services [][]json.RawMessage `json:"services"`
+}
+
+const ianaBootstrapURL = "https://data.iana.org/rdap/dns.json"
+const ianaBootstrapTTL = 24 * time.Hour
+
+var (
+	bootstrapMu      sync.Mutex
+	bootstrapByTLD   map[string][]string
+	bootstrapFetched time.Time
+)
+
+// ianaRDAPServers resolves which RDAP base URLs are authoritative for tld,
+// refreshing the IANA bootstrap registry from data.iana.org at most once
+// every 24h.
+func ianaRDAPServers(ctx context.Context, tld string) ([]string, error) {
+	bootstrapMu.Lock()
+	defer bootstrapMu.Unlock()
+
+	if bootstrapByTLD == nil || time.Since(bootstrapFetched) > ianaBootstrapTTL {
+		fresh, err := fetchIANABootstrap(ctx)
+		if err != nil {
+			if bootstrapByTLD != nil {
+				// Stale cache beats no answer at all.
+				servers, ok := bootstrapByTLD[tld]
+				if ok {
+					return servers, nil
+				}
+			}
+			return nil, err
+		}
+		bootstrapByTLD = fresh
+		bootstrapFetched = time.Now()
+	}
+
+	servers, ok := bootstrapByTLD[tld]
+	if !ok {
+		return nil, fmt.Errorf("iana bootstrap: no RDAP server registered for .%s", tld)
+	}
+	return servers, nil
+}
+
+func fetchIANABootstrap(ctx context.Context) (map[string][]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ianaBootstrapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var file ianaBootstrapFile
+	if err := json.Unmarshal(body, &file); err != nil {
+		return nil, err
+	}
+
+	byTLD := make(map[string][]string)
+	for _, entry := range file.Services {
+		if len(entry) < 2 {
+			continue
+		}
+		var tlds []string
+		var servers []string
+		if err := json.Unmarshal(entry[0], &tlds); err != nil {
+			continue
+		}
+		if err := json.Unmarshal(entry[1], &servers); err != nil {
+			continue
+		}
+		for _, t := range tlds {
+			byTLD[strings.ToLower(t)] = servers
+		}
+	}
+	return byTLD, nil
+}
+
+// ianaBootstrapRDAPLookup finds the authoritative RDAP server for domain's
+// TLD via the IANA bootstrap registry, then queries it directly instead of
+// going through the rdap.org mirror.
+func ianaBootstrapRDAPLookup(ctx context.Context, domain string) ([]string, error) {
+	tld := lastLabel(domain)
+	servers, err := ianaRDAPServers(ctx, tld)
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("iana bootstrap: no RDAP servers for .%s", tld)
+	}
+
+	base := strings.TrimSuffix(servers[0], "/")
+	url := fmt.Sprintf("%s/domain/%s", base, domain)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("iana-rdap: domain not found")
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var rdapResp RDAPResponse
+	if err := json.Unmarshal(body, &rdapResp); err != nil {
+		return nil, err
+	}
+
+	var ns []string
+	for _, n := range rdapResp.Nameservers {
+		ns = append(ns, strings.TrimSuffix(n.LdhName, "."))
+	}
+	if len(ns) == 0 {
+		return nil, fmt.Errorf("iana-rdap: no nameservers in response")
+	}
+	return ns, nil
+}
+
+func lastLabel(domain string) string {
+	parts := strings.Split(domain, ".")
+	return strings.ToLower(parts[len(parts)-1])
+}
+
+// --- Source 2: direct NS lookups against public resolvers -----------------
+
+// publicNSResolvers mirrors lookupTXTBypassingCache's pattern of bypassing
+// whatever recursor the host is configured with, so a registrar's NS glue
+// can't be masked by a local negative-cache entry.
+var publicNSResolvers = []string{"1.1.1.1:53", "8.8.8.8:53", "9.9.9.9:53"}
+
+// publicResolverNSLookup queries NS records for domain against several
+// public resolvers directly.
+//
+// Note: Go's net.Resolver doesn't expose EDNS0/DNSSEC (AD-bit) validation,
+// so this only gives resolver-diversity, not a DNSSEC chain-of-trust check
+// — neither the AD bit nor any RRSIG is checked here, even though the tree
+// already carries miekg/dns (internal/dnsserver).
+func publicResolverNSLookup(ctx context.Context, domain string) ([]string, error) {
+	var lastErr error
+	for _, addr := range publicNSResolvers {
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: 5 * time.Second}
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		nsRecords, err := resolver.LookupNS(lookupCtx, domain)
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var ns []string
+		for _, r := range nsRecords {
+			ns = append(ns, strings.TrimSuffix(r.Host, "."))
+		}
+		if len(ns) > 0 {
+			return ns, nil
+		}
+	}
+	return nil, fmt.Errorf("dns-ns: NS lookup failed against all resolvers: %w", lastErr)
+}
+
+// --- Source 3: WHOIS fallback ----------------------------------------------
+
+const whoisPort = "43"
+
+// whoisNSLookup is the last resort for TLDs with no RDAP service: it asks
+// IANA's root WHOIS server which registry is authoritative for the TLD,
+// then queries that registry's WHOIS server and scrapes "Name Server:"
+// lines from the plain-text response.
+func whoisNSLookup(ctx context.Context, domain string) ([]string, error) {
+	tld := lastLabel(domain)
+
+	referral, err := whoisQuery(ctx, "whois.iana.org:"+whoisPort, tld)
+	if err != nil {
+		return nil, fmt.Errorf("whois: iana referral lookup failed: %w", err)
+	}
+
+	server := parseWhoisField(referral, "refer")
+	if server == "" {
+		return nil, fmt.Errorf("whois: no WHOIS server registered for .%s", tld)
+	}
+
+	raw, err := whoisQuery(ctx, server+":"+whoisPort, domain)
+	if err != nil {
+		return nil, fmt.Errorf("whois: query to %s failed: %w", server, err)
+	}
+
+	ns := parseWhoisNameservers(raw)
+	if len(ns) == 0 {
+		return nil, fmt.Errorf("whois: no nameservers found in response from %s", server)
+	}
+	return ns, nil
+}
+
+func whoisQuery(ctx context.Context, hostPort, query string) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	if _, err := conn.Write([]byte(query + "\r\n")); err != nil {
+		return "", err
+	}
+
+	body, err := io.ReadAll(conn)
+	if err != nil && len(body) == 0 {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// parseWhoisField returns the value of the first "field: value" line whose
+// field matches name, case-insensitively.
+func parseWhoisField(raw, name string) string {
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(parts[0]), name) {
+			return strings.ToLower(strings.TrimSpace(parts[1]))
+		}
+	}
+	return ""
+}
+
+// parseWhoisNameservers scrapes every "Name Server:" (or "nserver:" or
+// "nameserver:") line, the spellings most registry WHOIS servers use.
+func parseWhoisNameservers(raw string) []string {
+	var ns []string
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		field := strings.ToLower(strings.TrimSpace(parts[0]))
+		if field == "name server" || field == "nserver" || field == "nameserver" {
+			value := strings.TrimSpace(parts[1])
+			// Some registries append a glue IP after the hostname, e.g.
+			// "nserver: NS1.EXAMPLE.COM 192.0.2.1".
+			if fields := strings.Fields(value); len(fields) > 0 {
+				ns = append(ns, strings.TrimSuffix(fields[0], "."))
+			}
+		}
+	}
+	return ns
+}
@@ -1,56 +1,101 @@
 package limiter
 
 import (
+	"hash/fnv"
 	"sync"
 	"time"
 )
 
+// shardCount is the number of stripes RateLimiter's default MemoryStore
+// spreads client IPs across. Picked as a fixed power of two large enough
+// to keep per-shard contention low under WAF-scale traffic without
+// needing to be tunable per deployment.
+const shardCount = 32
+
+// evictInterval is how often the background evictor sweeps each shard for
+// stale entries.
+const evictInterval = time.Minute
+
 type ClientStatus struct {
 	CurrCount       int       // Requests in current window
 	PrevCount       int       // Requests in previous window
 	CurrWindowStart time.Time // When the current window started
 }
 
-type RateLimiter struct {
-	clients map[string]*ClientStatus
+// Store is the sliding-window counter backend a RateLimiter drives. Allow
+// increments ip's counter for window and reports whether it should be let
+// through; when it returns false, retryAfter is how long the caller should
+// wait before the window's weighted estimate is expected to drop back
+// under limit. MemoryStore is the default, single-process backend;
+// RedisStore shares counters across gateway nodes.
+type Store interface {
+	Allow(ip string, limit float64, window time.Duration) (allowed bool, retryAfter time.Duration)
+}
+
+// shard is one stripe of a MemoryStore: its own mutex and client map, so
+// requests for IPs in different shards never block each other.
+type shard struct {
 	mu      sync.Mutex
-	limit   float64       // Use float for precise calculation
-	window  time.Duration // e.g., 1 Minute
+	clients map[string]*ClientStatus
 }
 
-func New(limit int, window time.Duration) *RateLimiter {
-	return &RateLimiter{
-		clients: make(map[string]*ClientStatus),
-		limit:   float64(limit),
-		window:  window,
+// MemoryStore is the sharded, single-process Store implementation. Client
+// IPs are spread across shardCount stripes by FNV(ip) % shardCount, and a
+// background goroutine periodically evicts entries whose window hasn't
+// seen a request in a while, so a process handling millions of distinct
+// IPs over its lifetime doesn't keep every one of them in memory forever.
+type MemoryStore struct {
+	shards [shardCount]*shard
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewMemoryStore builds a MemoryStore and starts its background evictor.
+// Callers don't need to hold onto the returned store beyond passing it to
+// New/NewWithStore — RateLimiter never calls Close, since every RateLimiter
+// in this codebase lives for the lifetime of the process.
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{stop: make(chan struct{})}
+	for i := range s.shards {
+		s.shards[i] = &shard{clients: make(map[string]*ClientStatus)}
 	}
+	go s.evictLoop()
+	return s
 }
 
-func (rl *RateLimiter) Allow(ip string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+func (s *MemoryStore) shardFor(ip string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(ip))
+	return s.shards[h.Sum32()%shardCount]
+}
+
+func (s *MemoryStore) Allow(ip string, limit float64, window time.Duration) (bool, time.Duration) {
+	sh := s.shardFor(ip)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
 
 	now := time.Now()
 	// Calculate which "window" slot we are in (e.g., the 12:05 slot)
 	// Truncate floors the time to the nearest window interval
-	currWindowStart := now.Truncate(rl.window)
+	currWindowStart := now.Truncate(window)
+
+	status, exists := sh.clients[ip]
 
-	status, exists := rl.clients[ip]
-	
 	if !exists {
 		// New user
-		rl.clients[ip] = &ClientStatus{
+		sh.clients[ip] = &ClientStatus{
 			CurrCount:       1,
 			CurrWindowStart: currWindowStart,
 		}
-		return true
+		return true, 0
 	}
 
 	// Check if we have moved to a new window since the last request
 	if currWindowStart.After(status.CurrWindowStart) {
 		// Calculate how many windows have passed
-		elapsedWindows := currWindowStart.Sub(status.CurrWindowStart) / rl.window
-		
+		elapsedWindows := currWindowStart.Sub(status.CurrWindowStart) / window
+
 		if elapsedWindows == 1 {
 			// Normally moved to the immediate next window
 			// The old "Current" becomes "Previous"
@@ -70,20 +115,141 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	timeIntoWindow := now.Sub(currWindowStart)
 	// Percentage of the *previous* window that still "weighs" on us
 	// If we are 10% into new window, 90% of previous window counts.
-	prevWeight := float64(rl.window-timeIntoWindow) / float64(rl.window)
+	prevWeight := float64(window-timeIntoWindow) / float64(window)
 
 	estimatedRate := float64(status.PrevCount)*prevWeight + float64(status.CurrCount)
 
-	if estimatedRate >= rl.limit {
-		return false // Blocked
+	if estimatedRate >= limit {
+		return false, retryAfterFor(estimatedRate, limit, status.PrevCount, window, timeIntoWindow)
 	}
 
 	// Allowed: Increment count
 	status.CurrCount++
-	return true
+	return true, 0
+}
+
+// retryAfterFor estimates how long until the weighted estimate drops back
+// under limit, by solving prevWeight*prevCount == limit for the elapsed
+// time into the window. A blocked caller with no previous-window weight
+// left to decay (or an already-zero prevCount) just waits out the rest of
+// the current window.
+func retryAfterFor(estimatedRate, limit float64, prevCount int, window, timeIntoWindow time.Duration) time.Duration {
+	remaining := window - timeIntoWindow
+	if prevCount == 0 {
+		return remaining
+	}
+	// prevWeight needed to bring the estimate down to limit:
+	neededWeight := limit / float64(prevCount)
+	if neededWeight >= 1 {
+		return 0
+	}
+	// prevWeight = (window-timeIntoWindow)/window, so solve for timeIntoWindow:
+	waitUntil := time.Duration((1 - neededWeight) * float64(window))
+	if waitUntil <= timeIntoWindow {
+		return 0
+	}
+	return waitUntil - timeIntoWindow
+}
+
+// evictLoop periodically drops clients whose window hasn't been touched
+// in a while, so IPs that stop sending requests don't sit in memory
+// forever.
+func (s *MemoryStore) evictLoop() {
+	ticker := time.NewTicker(evictInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.evictStale()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) evictStale() {
+	cutoff := time.Now().Add(-2 * evictInterval)
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for ip, status := range sh.clients {
+			if status.CurrWindowStart.Before(cutoff) {
+				delete(sh.clients, ip)
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// Close stops the background evictor. Safe to call more than once.
+func (s *MemoryStore) Close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// RateLimiter is the sliding-window rate limiter every route/middleware in
+// this codebase shares; it just drives whichever Store it's given — a
+// MemoryStore by default, or a RedisStore when deployments run more than
+// one gateway node behind the same limits.
+type RateLimiter struct {
+	store  Store
+	mu     sync.Mutex
+	limit  float64       // Use float for precise calculation
+	window time.Duration // e.g., 1 Minute
+
+	lastRetryAfter sync.Map // ip string -> time.Duration, last Allow's retryAfter
+}
+
+// New builds a RateLimiter backed by a MemoryStore, the default for a
+// single-node deployment.
+func New(limit int, window time.Duration) *RateLimiter {
+	return NewWithStore(NewMemoryStore(), limit, window)
+}
+
+// NewWithStore builds a RateLimiter against an explicit Store, e.g. a
+// RedisStore so multiple gateway nodes share the same counters.
+func NewWithStore(store Store, limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		store:  store,
+		limit:  float64(limit),
+		window: window,
+	}
+}
+
+func (rl *RateLimiter) Allow(ip string) bool {
+	rl.mu.Lock()
+	limit, window := rl.limit, rl.window
+	rl.mu.Unlock()
+
+	allowed, retryAfter := rl.store.Allow(ip, limit, window)
+	if !allowed {
+		rl.lastRetryAfter.Store(ip, retryAfter)
+	} else {
+		rl.lastRetryAfter.Delete(ip)
+	}
+	return allowed
 }
 
 // IsRateLimited returns true if the user is blocked
 func (rl *RateLimiter) IsRateLimited(ip string) bool {
 	return !rl.Allow(ip)
-}
\ No newline at end of file
+}
+
+// RetryAfter returns how long ip should wait before retrying, per its most
+// recent blocked Allow call. Zero if ip has never been blocked (or was
+// last seen allowed) — callers should treat that as "no Retry-After
+// header", not "retry immediately".
+func (rl *RateLimiter) RetryAfter(ip string) time.Duration {
+	v, ok := rl.lastRetryAfter.Load(ip)
+	if !ok {
+		return 0
+	}
+	return v.(time.Duration)
+}
+
+// SetLimit updates the requests-per-window threshold in place, so a config
+// reload can tighten or loosen rate limiting without restarting the
+// gateway (and dropping every client's window state in the process).
+func (rl *RateLimiter) SetLimit(limit int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limit = float64(limit)
+}
@@ -0,0 +1,95 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMemoryStoreAllowsUpToLimit verifies a single IP is let through up to
+// limit requests in its first window and blocked on the next one.
+func TestMemoryStoreAllowsUpToLimit(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+
+	const limit = 3
+	window := time.Minute
+
+	for i := 0; i < limit; i++ {
+		allowed, _ := store.Allow("1.2.3.4", limit, window)
+		if !allowed {
+			t.Fatalf("request %d: want allowed, got blocked", i+1)
+		}
+	}
+
+	allowed, retryAfter := store.Allow("1.2.3.4", limit, window)
+	if allowed {
+		t.Fatalf("request %d: want blocked once over limit, got allowed", limit+1)
+	}
+	if retryAfter <= 0 {
+		t.Errorf("retryAfter = %v, want a positive wait", retryAfter)
+	}
+}
+
+// TestMemoryStoreShardsAreIndependent verifies two IPs that hash to the
+// different shards don't share a counter — one IP exhausting its limit
+// must not affect another IP's own window.
+func TestMemoryStoreShardsAreIndependent(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+
+	const limit = 1
+	window := time.Minute
+
+	if allowed, _ := store.Allow("10.0.0.1", limit, window); !allowed {
+		t.Fatalf("10.0.0.1's first request should be allowed")
+	}
+	if allowed, _ := store.Allow("10.0.0.1", limit, window); allowed {
+		t.Fatalf("10.0.0.1's second request should be blocked")
+	}
+	if allowed, _ := store.Allow("10.0.0.2", limit, window); !allowed {
+		t.Errorf("10.0.0.2 should be unaffected by 10.0.0.1's limit")
+	}
+}
+
+// TestMemoryStoreEvictStaleDropsOldEntries verifies evictStale removes
+// entries whose window hasn't been touched in over 2*evictInterval, so a
+// long-running process doesn't keep every IP it's ever seen in memory.
+func TestMemoryStoreEvictStaleDropsOldEntries(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+
+	store.Allow("203.0.113.1", 5, time.Minute)
+
+	sh := store.shardFor("203.0.113.1")
+	sh.mu.Lock()
+	sh.clients["203.0.113.1"].CurrWindowStart = time.Now().Add(-3 * evictInterval)
+	sh.mu.Unlock()
+
+	store.evictStale()
+
+	sh.mu.Lock()
+	_, exists := sh.clients["203.0.113.1"]
+	sh.mu.Unlock()
+	if exists {
+		t.Errorf("expected stale entry to be evicted")
+	}
+}
+
+// TestRateLimiterSetLimitAppliesImmediately verifies SetLimit changes take
+// effect on the very next Allow call, without resetting any client's window
+// state the old limit had already accumulated in MemoryStore.
+func TestRateLimiterSetLimitAppliesImmediately(t *testing.T) {
+	rl := New(1, time.Minute)
+
+	if !rl.Allow("198.51.100.1") {
+		t.Fatalf("first request under limit 1 should be allowed")
+	}
+	if rl.Allow("198.51.100.1") {
+		t.Fatalf("second request should be blocked under limit 1")
+	}
+
+	rl.SetLimit(10)
+	if !rl.Allow("198.51.100.1") {
+		t.Errorf("request after raising the limit should be allowed")
+	}
+}
@@ -0,0 +1,90 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore is the Store implementation for deployments running more
+// than one gateway node behind the same rate limits — every node INCRs
+// the same "{ip}:{bucket}" key, so a client can't reset its budget just by
+// landing on a different node.
+type RedisStore struct {
+	client *redis.Client
+
+	// prefix namespaces this store's keys, so two RateLimiters guarding
+	// different routes (e.g. the WAF hot path and /api/auth/login) can
+	// share one Redis instance without their counters colliding.
+	prefix string
+}
+
+// NewRedisStore returns a Store backed by addr (host:port). db selects the
+// Redis logical database; password may be empty for an unauthenticated
+// instance. prefix namespaces every key this store writes — pass a
+// distinct prefix per RateLimiter sharing the same Redis instance.
+func NewRedisStore(addr, password string, db int, prefix string) *RedisStore {
+	return &RedisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+		prefix: prefix,
+	}
+}
+
+// bucketKey mirrors MemoryStore's CurrWindowStart truncation, so the
+// in-memory and Redis backends count the same way: one key per ip per
+// window slot.
+func (s *RedisStore) bucketKey(ip string, bucketStart time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", s.prefix, ip, bucketStart.Unix())
+}
+
+// Allow reconstructs the same weighted current/previous-window estimate
+// MemoryStore.Allow uses, from two INCR/GET round trips instead of one
+// ClientStatus map entry: the current bucket's post-increment count, and
+// the previous bucket's plain count.
+func (s *RedisStore) Allow(ip string, limit float64, window time.Duration) (bool, time.Duration) {
+	ctx := context.Background()
+
+	now := time.Now()
+	currStart := now.Truncate(window)
+	prevStart := currStart.Add(-window)
+
+	currKey := s.bucketKey(ip, currStart)
+	prevKey := s.bucketKey(ip, prevStart)
+
+	currCount, err := s.client.Incr(ctx, currKey).Result()
+	if err != nil {
+		// Redis is unavailable: fail open rather than taking every WAF
+		// request down with it.
+		return true, 0
+	}
+	if currCount == 1 {
+		// First hit in this bucket: set it to expire once it can no
+		// longer be "current" or "previous", so stale buckets don't
+		// accumulate in Redis the way unevicted IPs would in memory.
+		s.client.Expire(ctx, currKey, 2*window)
+	}
+
+	prevCount, err := s.client.Get(ctx, prevKey).Int64()
+	if err != nil && err != redis.Nil {
+		return true, 0
+	}
+
+	timeIntoWindow := now.Sub(currStart)
+	prevWeight := float64(window-timeIntoWindow) / float64(window)
+	// currCount already includes this request (INCR is unconditional, unlike
+	// MemoryStore's check-then-increment), so a request that tips the
+	// estimate over limit still gets counted — the tradeoff for not needing
+	// a Lua script/WATCH to peek before incrementing.
+	estimatedRate := float64(prevCount)*prevWeight + float64(currCount)
+
+	if estimatedRate >= limit {
+		return false, retryAfterFor(estimatedRate, limit, int(prevCount), window, timeIntoWindow)
+	}
+	return true, 0
+}
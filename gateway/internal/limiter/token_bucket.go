@@ -0,0 +1,92 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a single token bucket: tokens refill continuously at
+// refillPerSec and cap out at capacity, so a burst up to capacity is
+// allowed but the sustained rate is bounded.
+type bucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// TokenBucketLimiter is a per-key token-bucket limiter, distinct from
+// RateLimiter's sliding window — UserRateLimiter pairs two of these (read
+// and write) so a user's bulk GETs don't eat into the budget a mutating
+// ManageRecords call needs.
+type TokenBucketLimiter struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	capacity float64
+	refill   float64 // tokens added per second
+}
+
+// NewTokenBucket builds a limiter with the given burst capacity and
+// sustained refill rate (tokens/second).
+func NewTokenBucket(capacity float64, refillPerSec float64) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		buckets:  make(map[string]*bucket),
+		capacity: capacity,
+		refill:   refillPerSec,
+	}
+}
+
+// Allow reports whether key has a token available, consuming one if so.
+func (l *TokenBucketLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.capacity, lastRefill: now}
+		l.buckets[key] = b
+	} else {
+		elapsed := now.Sub(b.lastRefill).Seconds()
+		b.tokens = min(l.capacity, b.tokens+elapsed*l.refill)
+		b.lastRefill = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func min(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// UserRateLimiter pairs separate read and write TokenBucketLimiters keyed
+// on user_id, so a heavy GET /api/dns/records poller can't starve a user's
+// own write budget (and vice versa).
+type UserRateLimiter struct {
+	Read  *TokenBucketLimiter
+	Write *TokenBucketLimiter
+}
+
+// NewUserRateLimiter builds a UserRateLimiter with separate burst/refill
+// settings for reads and writes — writes are typically given a tighter
+// budget since they each trigger a PowerDNS propagation call.
+func NewUserRateLimiter(readCapacity, readRefillPerSec, writeCapacity, writeRefillPerSec float64) *UserRateLimiter {
+	return &UserRateLimiter{
+		Read:  NewTokenBucket(readCapacity, readRefillPerSec),
+		Write: NewTokenBucket(writeCapacity, writeRefillPerSec),
+	}
+}
+
+// Allow consumes a token from the read bucket for GET/HEAD requests, or the
+// write bucket otherwise.
+func (u *UserRateLimiter) Allow(userID, method string) bool {
+	if method == "GET" || method == "HEAD" {
+		return u.Read.Allow(userID)
+	}
+	return u.Write.Allow(userID)
+}
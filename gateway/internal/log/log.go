@@ -0,0 +1,63 @@
+// Package log is a small leveled wrapper around log/slog, so call sites
+// only need Debugf/Infof/Warnf/Errorf instead of threading a *slog.Logger
+// or sprinkling ad-hoc "[WARN]"/"ERROR:" prefixes through log.Printf. The
+// handler is JSON in production and text in development (APP_ENV, the
+// same values config.Config.AppEnv itself uses), and the minimum level is
+// LOG_LEVEL ("debug"|"info"|"warn"|"error", default "info") — read
+// directly from the environment rather than through internal/config, to
+// avoid a dependency cycle and because logging needs to be configurable
+// before config.Load even runs.
+package log
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+var logger = newLogger()
+
+func newLogger() *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(os.Getenv("LOG_LEVEL"))}
+
+	var handler slog.Handler
+	if os.Getenv("APP_ENV") == "production" {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+	return slog.New(handler)
+}
+
+func parseLevel(raw string) slog.Level {
+	switch raw {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func Debugf(format string, args ...interface{}) { logger.Debug(fmt.Sprintf(format, args...)) }
+func Infof(format string, args ...interface{})  { logger.Info(fmt.Sprintf(format, args...)) }
+func Warnf(format string, args ...interface{})  { logger.Warn(fmt.Sprintf(format, args...)) }
+func Errorf(format string, args ...interface{}) { logger.Error(fmt.Sprintf(format, args...)) }
+
+// WAFMatch emits a structured record for a WAF rule hit — rule_id,
+// rule_name, score, client_ip, path, tags — so operators can build
+// dashboards off it instead of regex-scraping stdout for "[WAF MATCH]"
+// lines the way log.Printf forced.
+func WAFMatch(ruleID, ruleName string, score int, clientIP, path string, tags []string) {
+	logger.Info("waf match",
+		slog.String("rule_id", ruleID),
+		slog.String("rule_name", ruleName),
+		slog.Int("score", score),
+		slog.String("client_ip", clientIP),
+		slog.String("path", path),
+		slog.Any("tags", tags),
+	)
+}
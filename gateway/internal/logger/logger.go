@@ -5,6 +5,8 @@ package logger
 import (
 	"context"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"web-app-firewall-ml-detection/internal/detector" // Imported and USED now
@@ -19,19 +21,309 @@ import (
 
 var logCollection *mongo.Collection
 
-// [UPDATED] Use detector.AttackLog
-var broadcast = make(chan detector.AttackLog, 100)
-
 func Init(client *mongo.Client, dbName string) {
 	logCollection = client.Database(dbName).Collection("logs")
 }
 
-// [UPDATED] Use detector.AttackLog
-func GetBroadcastChannel() chan detector.AttackLog {
-	return broadcast
+// activeSink is the batched Mongo writer LogAttack enqueues onto once
+// InitSink has run. It stays nil until then, so LogAttack still works (via
+// its own best-effort InsertOne fallback) for any caller that never wires
+// one up.
+var activeSink *Sink
+
+// InitSink builds and starts the batched Mongo writer behind LogAttack. It
+// must be called after Init, since it writes to the same "logs" collection.
+func InitSink(client *mongo.Client, dbName string, batchSize, queueSize int, flushInterval time.Duration, walDir string, breakerThreshold int, breakerBackoff time.Duration) error {
+	s, err := NewSink(client.Database(dbName).Collection("logs"), batchSize, queueSize, flushInterval, walDir, breakerThreshold, breakerBackoff)
+	if err != nil {
+		return err
+	}
+	if err := s.Start(context.Background()); err != nil {
+		return err
+	}
+
+	activeSink = s
+	return nil
+}
+
+// DrainSink flushes and stops the active sink, if InitSink was called. It's
+// a no-op otherwise, so callers don't need to guard it themselves.
+func DrainSink(ctx context.Context) {
+	if activeSink != nil {
+		activeSink.Drain(ctx)
+	}
+}
+
+// defaultRingSize bounds how many recent events a reconnecting SSE client
+// can replay via Last-Event-ID when SetRingSize is never called; anything
+// older is only recoverable from Mongo through GetRecentLogs/database.GetLogs.
+const defaultRingSize = 1024
+
+// subscriberBuffer bounds how far a single slow client can fall behind
+// before the broker starts dropping its oldest buffered events instead of
+// blocking LogAttack or growing the channel without limit.
+const subscriberBuffer = 100
+
+// [UPDATED] Event pairs a broadcast entry with the monotonic ID it was
+// stamped with, so a client's "Last-Event-ID" header can resume exactly
+// where it left off instead of re-reading the firehose from the start.
+type Event struct {
+	ID  uint64
+	Log detector.AttackLog
+}
+
+// [UPDATED] SubscribeOptions narrows a subscription to one tenant/domain/
+// score threshold/action set, and (via SinceID) tells Subscribe how far
+// back to replay from the ring buffer before the caller starts reading
+// live events — folding what used to be a separate LogFilter+Replay call
+// pair into one. Without the tenant/domain filters, an admin watching the
+// live log stream would see every tenant's traffic mixed together.
+type SubscribeOptions struct {
+	// SinceID resumes a reconnecting client: every buffered event with
+	// ID > SinceID is replayed before Subscribe returns. 0 means "no
+	// replay, live events only" — the first-ever connection case.
+	SinceID uint64
+
+	UserID   string
+	DomainID string
+
+	// MinScore drops any event scoring below it; 0 (the zero value)
+	// disables the filter rather than matching only score-0 events.
+	MinScore int
+
+	// Actions restricts to AttackLog.Action values ("Blocked", "Flagged",
+	// "Access"); empty means no filter. Replaces the old single-value
+	// Severity field so a dashboard can watch e.g. both "Blocked" and
+	// "Flagged" in one subscription.
+	Actions []string
+}
+
+func (f SubscribeOptions) matches(e detector.AttackLog) bool {
+	if f.UserID != "" && f.UserID != e.UserID {
+		return false
+	}
+	if f.DomainID != "" && f.DomainID != e.DomainID {
+		return false
+	}
+	if f.MinScore > 0 && e.Score < f.MinScore {
+		return false
+	}
+	if len(f.Actions) > 0 && !containsAction(f.Actions, e.Action) {
+		return false
+	}
+	return true
+}
+
+func containsAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// ring is the backlog of recently published events, used to replay
+// whatever a reconnecting SSE client missed. Its capacity defaults to
+// defaultRingSize and can be resized once at startup via SetRingSize.
+type ring struct {
+	mu     sync.Mutex
+	items  []Event
+	nextID uint64
+	size   int
+}
+
+func (r *ring) push(e detector.AttackLog) Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	ev := Event{ID: r.nextID, Log: e}
+
+	r.items = append(r.items, ev)
+	if len(r.items) > r.size {
+		r.items = r.items[len(r.items)-r.size:]
+	}
+	return ev
+}
+
+// since returns every buffered event with ID > lastID, in publish order.
+// Events older than the ring's retention are simply unavailable.
+func (r *ring) since(lastID uint64) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]Event, 0, len(r.items))
+	for _, ev := range r.items {
+		if ev.ID > lastID {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+var backlog = &ring{size: defaultRingSize}
+
+// SetRingSize resizes the replay ring, trimming any events beyond the new
+// capacity immediately. Call it once at startup, before Publish runs
+// concurrently with it (main wires it from config.LogSinkConfig.
+// SSEReplayBufferSize right next to Init); n <= 0 is ignored so a missing
+// or zero config value leaves defaultRingSize in place.
+func SetRingSize(n int) {
+	if n <= 0 {
+		return
+	}
+
+	backlog.mu.Lock()
+	defer backlog.mu.Unlock()
+
+	backlog.size = n
+	if len(backlog.items) > n {
+		backlog.items = backlog.items[len(backlog.items)-n:]
+	}
+}
+
+// [NEW] Subscription is one SSE client's bounded view of the broker. A slow
+// client never blocks LogAttack: once its buffer fills, the broker drops
+// the oldest queued event and counts it in Dropped.
+type Subscription struct {
+	ch          chan Event
+	filter      SubscribeOptions
+	dropped     uint64
+	connectedAt time.Time
+}
+
+// Events is the channel to range/select over; it is closed on Unsubscribe.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns how many events this subscription has lost to
+// backpressure so far, for surfacing as an SSE "event: dropped" message.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscription) deliver(e Event) {
+	if !s.filter.matches(e.Log) {
+		return
+	}
+
+	select {
+	case s.ch <- e:
+		return
+	default:
+	}
+
+	// Buffer's full: drop the oldest queued event to make room rather than
+	// block the broadcaster or drop the newest (and thus most relevant) one.
+	select {
+	case <-s.ch:
+		atomic.AddUint64(&s.dropped, 1)
+	default:
+	}
+	select {
+	case s.ch <- e:
+	default:
+	}
+}
+
+type broker struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+var global = &broker{subs: make(map[*Subscription]struct{})}
+
+// Subscribe registers a new SSE client scoped to opts and returns its
+// channel, having already replayed whatever buffered events opts.SinceID
+// requests — folding the old separate Subscribe+Replay call pair into one,
+// so a caller can't forget to replay before reading the live channel.
+// Callers must Unsubscribe when the connection ends.
+func Subscribe(opts SubscribeOptions) *Subscription {
+	s := &Subscription{ch: make(chan Event, subscriberBuffer), filter: opts, connectedAt: time.Now()}
+
+	global.mu.Lock()
+	global.subs[s] = struct{}{}
+	global.mu.Unlock()
+
+	for _, ev := range backlog.since(opts.SinceID) {
+		s.deliver(ev)
+	}
+
+	return s
 }
 
-// [UPDATED] Use detector.AttackLog
+// SubscriberStats is a point-in-time snapshot of one connected SSE client,
+// for the admin endpoint that surfaces which dashboards are too slow.
+type SubscriberStats struct {
+	UserID      string    `json:"user_id"`
+	DomainID    string    `json:"domain_id"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Dropped     uint64    `json:"dropped"`
+}
+
+// Subscribers snapshots every currently connected SSE client's filter and
+// drop count, so an operator can see which dashboards are falling behind
+// without guessing from Mongo/SSE proxy logs alone.
+func Subscribers() []SubscriberStats {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+
+	out := make([]SubscriberStats, 0, len(global.subs))
+	for s := range global.subs {
+		out = append(out, SubscriberStats{
+			UserID:      s.filter.UserID,
+			DomainID:    s.filter.DomainID,
+			ConnectedAt: s.connectedAt,
+			Dropped:     s.Dropped(),
+		})
+	}
+	return out
+}
+
+// Unsubscribe removes a subscription and closes its channel.
+func Unsubscribe(s *Subscription) {
+	global.mu.Lock()
+	defer global.mu.Unlock()
+
+	if _, ok := global.subs[s]; ok {
+		delete(global.subs, s)
+		close(s.ch)
+	}
+}
+
+// CloseAll disconnects every live SSE subscription, closing their channels
+// so each SSEHandler's select loop returns instead of leaking a goroutine
+// per connected client. Called once, from main's shutdown sequence.
+func CloseAll() {
+	global.mu.Lock()
+	defer global.mu.Unlock()
+
+	for s := range global.subs {
+		close(s.ch)
+		delete(global.subs, s)
+	}
+}
+
+// Publish pushes entry onto the ring buffer and fans it out to every
+// matching subscriber, without touching Mongo. LogAttack uses this for WAF
+// verdicts; callers with nothing to persist (e.g. a generic access-log
+// entry) can call it directly.
+func Publish(entry detector.AttackLog) {
+	ev := backlog.push(entry)
+
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	for s := range global.subs {
+		s.deliver(ev)
+	}
+}
+
+// GetRecentLogs is the historical counterpart to the live SSE stream: it
+// reads the "logs" collection LogAttack persists to. An entry sent only
+// through Publish, or dropped by a full sink queue, is in the replay ring
+// but never appears here.
 func GetRecentLogs(limit int64) ([]detector.AttackLog, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -69,24 +361,24 @@ func LogAttack(userID, domainID, ip, path, reason, action, source string, tags [
 		TriggerPayload: trigger,
 	}
 
-	// Run entire logging flow asynchronously
+	// Broadcast first: live subscribers shouldn't wait on Mongo, and the
+	// sink (or its WAL fallback) persists independently of who's watching.
+	Publish(entry)
+
+	if activeSink != nil {
+		activeSink.Enqueue(entry)
+		return
+	}
+
+	// [FALLBACK] InitSink was never called (e.g. a one-off tool or a test
+	// harness) — keep the old per-request best-effort insert rather than
+	// silently dropping every entry.
 	go func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		// 1. Save to DB first
-		res, err := logCollection.InsertOne(ctx, entry)
-		if err != nil {
+		if _, err := logCollection.InsertOne(ctx, entry); err != nil {
 			log.Printf("Failed to log attack to DB: %v", err)
-		} else {
-			// 2. Update with generated ID
-			entry.ID = res.InsertedID
-		}
-
-		// 3. Broadcast
-		select {
-		case broadcast <- entry:
-		default:
 		}
 	}()
-}
\ No newline at end of file
+}
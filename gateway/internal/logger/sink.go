@@ -0,0 +1,261 @@
+package logger
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/metrics"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Sink is the batched Mongo writer behind LogAttack: entries are queued on a
+// buffered channel and flushed in InsertMany batches (on size or a timer,
+// whichever comes first) instead of one goroutine-per-request InsertOne.
+// A circuit breaker skips Mongo entirely after too many consecutive flush
+// failures, falling back to a disk-backed WAL so an outage loses nothing
+// it can't replay once Mongo is reachable again.
+type Sink struct {
+	collection    *mongo.Collection
+	queue         chan detector.AttackLog
+	batchSize     int
+	flushInterval time.Duration
+	wal           *wal
+
+	breakerThreshold int
+	breakerBackoff   time.Duration
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	breakerOpenUntil    time.Time
+	lastFlushLatency    time.Duration
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewSink builds a Sink writing to collection, queuing up to queueSize
+// entries and flushing every batchSize entries or flushInterval, whichever
+// is sooner. walDir holds the fallback write-ahead log used whenever the
+// breaker is open or a flush fails outright.
+func NewSink(collection *mongo.Collection, batchSize, queueSize int, flushInterval time.Duration, walDir string, breakerThreshold int, breakerBackoff time.Duration) (*Sink, error) {
+	w, err := newWAL(walDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sink{
+		collection:       collection,
+		queue:            make(chan detector.AttackLog, queueSize),
+		batchSize:        batchSize,
+		flushInterval:    flushInterval,
+		wal:              w,
+		breakerThreshold: breakerThreshold,
+		breakerBackoff:   breakerBackoff,
+		stop:             make(chan struct{}),
+		stopped:          make(chan struct{}),
+	}, nil
+}
+
+// Start replays whatever the WAL is still holding from a prior crash/outage
+// (best effort — left in place on failure, to retry on the next flush) and
+// launches the background batching loop.
+func (s *Sink) Start(ctx context.Context) error {
+	if err := s.drainWAL(ctx); err != nil {
+		log.Printf("logger: WAL replay on startup failed, will retry: %v", err)
+	}
+
+	go s.run()
+	return nil
+}
+
+// Enqueue hands entry to the batching loop without blocking the caller; a
+// full queue drops the entry and counts it rather than stalling LogAttack.
+func (s *Sink) Enqueue(entry detector.AttackLog) {
+	select {
+	case s.queue <- entry:
+	default:
+		metrics.IncLogSinkDropped()
+	}
+}
+
+func (s *Sink) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]detector.AttackLog, 0, s.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.flush(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-s.queue:
+			batch = append(batch, entry)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.stop:
+			// Drain whatever's still sitting in the channel before the
+			// final flush, so Drain(ctx) doesn't lose the last partial batch.
+			for {
+				select {
+				case entry := <-s.queue:
+					batch = append(batch, entry)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch to Mongo, falling back to the WAL when the breaker is
+// open or the InsertMany itself fails.
+func (s *Sink) flush(batch []detector.AttackLog) {
+	if s.breakerOpen() {
+		s.writeWAL(batch)
+		return
+	}
+
+	start := time.Now()
+	err := s.insertMany(batch)
+	latency := time.Since(start)
+	metrics.ObserveLogSinkFlush(latency)
+
+	if err != nil {
+		log.Printf("logger: batched attack-log flush failed, falling back to WAL: %v", err)
+		s.recordFailure()
+		s.writeWAL(batch)
+		return
+	}
+
+	s.recordSuccess(latency)
+
+	// Mongo is healthy again: take the opportunity to clear any backlog a
+	// prior breaker trip or failed flush left behind.
+	if err := s.drainWAL(context.Background()); err != nil {
+		log.Printf("logger: WAL drain failed, will retry: %v", err)
+	}
+}
+
+func (s *Sink) insertMany(batch []detector.AttackLog) error {
+	docs := make([]interface{}, len(batch))
+	for i, entry := range batch {
+		docs[i] = entry
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.collection.InsertMany(ctx, docs)
+	return err
+}
+
+func (s *Sink) writeWAL(batch []detector.AttackLog) {
+	for _, entry := range batch {
+		if err := s.wal.append(entry); err != nil {
+			log.Printf("logger: WAL append failed, entry lost: %v", err)
+		}
+	}
+}
+
+// drainWAL replays the WAL into Mongo and clears it on success. A failed
+// replay leaves the WAL untouched so it's retried on the next opportunity.
+func (s *Sink) drainWAL(ctx context.Context) error {
+	entries, err := s.wal.replay()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		docs[i] = entry
+	}
+
+	insertCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if _, err := s.collection.InsertMany(insertCtx, docs); err != nil {
+		return err
+	}
+	return s.wal.clear()
+}
+
+func (s *Sink) breakerOpen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	open := !s.breakerOpenUntil.IsZero() && time.Now().Before(s.breakerOpenUntil)
+	metrics.SetLogSinkStats(len(s.queue), s.wal.size(), open)
+	return open
+}
+
+func (s *Sink) recordFailure() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.consecutiveFailures++
+	if s.consecutiveFailures >= s.breakerThreshold {
+		s.breakerOpenUntil = time.Now().Add(s.breakerBackoff)
+		s.consecutiveFailures = 0
+	}
+}
+
+func (s *Sink) recordSuccess(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.consecutiveFailures = 0
+	s.breakerOpenUntil = time.Time{}
+	s.lastFlushLatency = latency
+}
+
+// Stats is a point-in-time snapshot of the sink's backpressure, for the
+// admin/metrics endpoint that surfaces whether it's keeping up with Mongo.
+type Stats struct {
+	Queued           int
+	WALBytes         int64
+	LastFlushLatency time.Duration
+}
+
+// Stats returns the sink's current queue depth, WAL backlog size, and the
+// latency of its last successful flush.
+func (s *Sink) Stats() Stats {
+	s.mu.Lock()
+	latency := s.lastFlushLatency
+	s.mu.Unlock()
+
+	return Stats{
+		Queued:           len(s.queue),
+		WALBytes:         s.wal.size(),
+		LastFlushLatency: latency,
+	}
+}
+
+// Drain stops the batching loop after one final flush of whatever's still
+// queued, blocking until that finishes or ctx expires. Called from the
+// shutdown sequence so a SIGTERM never drops the last in-flight batch.
+func (s *Sink) Drain(ctx context.Context) {
+	close(s.stop)
+	select {
+	case <-s.stopped:
+	case <-ctx.Done():
+		log.Printf("logger: sink drain timed out, %d entries still queued", len(s.queue))
+	}
+}
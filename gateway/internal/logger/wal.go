@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"web-app-firewall-ml-detection/internal/detector"
+)
+
+// wal is Sink's disk-backed fallback: an append-only, newline-delimited JSON
+// file written whenever Mongo can't take an entry right now (breaker open,
+// or an InsertMany flush failed), and replayed on Sink.Start and after each
+// successful flush so a restart never silently drops what a prior
+// crash/outage left behind. Entries dropped by a full queue never reach it.
+type wal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func newWAL(dir string) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "sink.wal")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wal{path: path, file: f}, nil
+}
+
+// append writes one entry as a JSON line, fsync'd so a crash right after
+// doesn't lose it from the OS page cache along with everything else.
+func (w *wal) append(entry detector.AttackLog) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.file.Write(data); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// replay reads back every entry previously appended, in write order. It
+// does not truncate the file itself; callers clear it once the entries are
+// safely in Mongo.
+func (w *wal) replay() ([]detector.AttackLog, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	f, err := os.Open(w.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []detector.AttackLog
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry detector.AttackLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// clear truncates the WAL, reopening it for append. Called after a
+// successful replay-into-Mongo, or after a flush that drained everything
+// the WAL was holding for.
+func (w *wal) clear() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.path, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	return nil
+}
+
+// size returns the WAL file's current byte size, for Sink.Stats().
+func (w *wal) size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	info, err := w.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,359 @@
+// Package metrics exposes the gateway's Prometheus collectors and a small
+// in-memory tracker SystemStatus reads from so its JSON response can report
+// real numbers instead of "Managed (External)"/"N/A" placeholders.
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_requests_total",
+		Help: "Total requests handled by the WAF gateway, by route/method/status.",
+	}, []string{"route", "method", "code"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_request_duration_seconds",
+		Help:    "Request latency in seconds, by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "status"})
+
+	// ResponseBytesTotal is fed by internal/middleware.RequestLogger's
+	// byte-counting statusRecorder, the same one that passes Flush through
+	// for SSE responses — those still count the bytes written before the
+	// connection closes, just never reach a final WriteHeader-driven total.
+	ResponseBytesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_response_bytes_total",
+		Help: "Response bytes written, by route.",
+	}, []string{"route"})
+
+	InFlightRequests = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_in_flight_requests",
+		Help: "Requests currently being handled.",
+	})
+
+	UpstreamErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_errors_total",
+		Help: "Reverse-proxy errors talking to the origin, by host.",
+	}, []string{"host"})
+
+	WAFBlocksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_waf_blocks_total",
+		Help: "Requests blocked by the WAF, by reason.",
+	}, []string{"reason"})
+
+	MLScorerDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gateway_ml_scorer_duration_seconds",
+		Help:    "Latency of calls to the ML scorer service.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// CacheLookupsTotal covers the request-path caches fed by
+	// internal/api.RulesConfig/ReloadRules (domain routing, rules, origin
+	// pools) — a "miss" means the lookup fell through to whatever default
+	// behavior the caller has for an absent cache entry, not that it fell
+	// back to Mongo (the cache is always authoritative once ReloadRules
+	// has run once).
+	CacheLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_cache_lookups_total",
+		Help: "Request-path cache lookups, by cache name and hit/miss.",
+	}, []string{"cache", "result"})
+
+	// MLCallsTotal covers every detector.MLClient.Check outcome — "success"
+	// (real scorer answered), "error" (call failed, fell back locally), or
+	// "breaker_open" (skipped the real scorer entirely).
+	MLCallsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_ml_calls_total",
+		Help: "ML scorer calls, by outcome (success/error/breaker_open).",
+	}, []string{"outcome"})
+
+	// MLBreakerState is detector.MLClient's circuit state as a gauge
+	// (0=closed, 1=open, 2=half_open) so it can be graphed/alerted on like
+	// any other breaker metric.
+	MLBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_ml_breaker_state",
+		Help: "ML scorer circuit breaker state: 0=closed, 1=open, 2=half_open.",
+	})
+
+	// MLBatchSize is how many MLRequest items detector's batching
+	// dispatcher (ml_batch.go) flushed to /predict_batch in one call.
+	MLBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gateway_ml_batch_size",
+		Help:    "Number of requests grouped into one /predict_batch call.",
+		Buckets: []float64{1, 2, 4, 8, 16, 32, 64, 128},
+	})
+
+	// MLQueueDepth is how many MLRequest items were still waiting to be
+	// picked up by the dispatcher at the moment the last batch started.
+	MLQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_ml_queue_depth",
+		Help: "Requests queued for ML batching, not yet sent.",
+	})
+
+	// MLWaitDuration is the full time one request's caller spent blocked
+	// on its result channel — queueing plus the batch's own round-trip.
+	MLWaitDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gateway_ml_wait_seconds",
+		Help:    "End-to-end time a request waited for its ML batch result.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// DecisionsActive is how many entries internal/decisions.Feed's trie
+	// currently holds, refreshed on every LAPI pull.
+	DecisionsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_decisions_active",
+		Help: "Active threat-feed decisions (bans) currently loaded.",
+	})
+
+	// DecisionsLastPullAge is how long ago internal/decisions.Feed last
+	// completed a decisions-stream pull, regardless of success — a
+	// stuck/unreachable LAPI shows up here as a steadily climbing value.
+	DecisionsLastPullAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_decisions_last_pull_age_seconds",
+		Help: "Seconds since the last decisions-stream pull attempt.",
+	})
+
+	// DecisionsPullErrorsTotal counts failed decisions-stream pulls
+	// (network error, non-200, bad JSON).
+	DecisionsPullErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "gateway_decisions_pull_errors_total",
+		Help: "Decisions-stream pulls that failed.",
+	})
+
+	// LogSinkQueued is how many entries internal/logger.Sink currently has
+	// buffered, waiting for the next batched InsertMany.
+	LogSinkQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_log_sink_queued",
+		Help: "Attack-log entries currently queued for the batched Mongo writer.",
+	})
+
+	// LogSinkDroppedTotal counts entries Sink.Enqueue discarded because its
+	// buffered channel was full, rather than blocking the request path.
+	LogSinkDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "gateway_log_sink_dropped_total",
+		Help: "Attack-log entries dropped because the sink's queue was full.",
+	})
+
+	// LogSinkWALBytes is the write-ahead log's current on-disk size, the
+	// backlog Sink still owes Mongo after circuit-breaker/failed-flush falls.
+	LogSinkWALBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_log_sink_wal_bytes",
+		Help: "Current size of the log sink's write-ahead log, in bytes.",
+	})
+
+	// LogSinkFlushDuration is how long each InsertMany batch flush took.
+	LogSinkFlushDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gateway_log_sink_flush_duration_seconds",
+		Help:    "Latency of the log sink's batched InsertMany flush.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// LogSinkBreakerState mirrors Sink's circuit breaker (0=closed, 1=open)
+	// so a tripped breaker (falling back to the WAL) shows up on dashboards.
+	LogSinkBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_log_sink_breaker_state",
+		Help: "Log sink circuit breaker state: 0=closed, 1=open.",
+	})
+)
+
+// ObserveCacheLookup records one request-path cache lookup for cache,
+// hit=true meaning the key was present.
+func ObserveCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheLookupsTotal.WithLabelValues(cache, result).Inc()
+}
+
+// Handler serves the Prometheus text exposition format for scraping.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// tracker keeps a small rolling window of request latencies so SystemStatus
+// can report live RPM/p95 without querying the Prometheus registry, which
+// isn't built for point-reads of its own histogram quantiles.
+type tracker struct {
+	mu        sync.Mutex
+	latencies []time.Duration // ring buffer
+	next      int
+	count     uint64
+	window    []time.Time // request timestamps within the last minute
+}
+
+const latencySampleSize = 500
+
+var global = &tracker{latencies: make([]time.Duration, latencySampleSize)}
+
+func (t *tracker) record(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.latencies[t.next] = d
+	t.next = (t.next + 1) % latencySampleSize
+	t.count++
+
+	now := time.Now()
+	t.window = append(t.window, now)
+	cutoff := now.Add(-1 * time.Minute)
+	trimmed := t.window[:0]
+	for _, ts := range t.window {
+		if ts.After(cutoff) {
+			trimmed = append(trimmed, ts)
+		}
+	}
+	t.window = trimmed
+}
+
+// Stats is the live snapshot SystemStatus renders into ComponentStatus.
+type Stats struct {
+	RPM        uint64
+	P95Millis  float64
+	TotalCalls uint64
+}
+
+// Snapshot returns the gateway's current request rate and tail latency.
+func Snapshot() Stats {
+	global.mu.Lock()
+	defer global.mu.Unlock()
+
+	samples := make([]time.Duration, 0, latencySampleSize)
+	for _, d := range global.latencies {
+		if d > 0 {
+			samples = append(samples, d)
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	var p95 float64
+	if len(samples) > 0 {
+		idx := int(float64(len(samples)) * 0.95)
+		if idx >= len(samples) {
+			idx = len(samples) - 1
+		}
+		p95 = float64(samples[idx].Microseconds()) / 1000
+	}
+
+	return Stats{
+		RPM:        uint64(len(global.window)),
+		P95Millis:  p95,
+		TotalCalls: global.count,
+	}
+}
+
+// statusRecorder wraps http.ResponseWriter to capture the status code the
+// handler actually wrote, since ResponseWriter doesn't expose it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Middleware records count/duration/in-flight for every request that passes
+// through it. The route label is the raw r.URL.Path, not a path template;
+// main.go wraps the whole mux with it once.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		InFlightRequests.Inc()
+		defer InFlightRequests.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		duration := time.Since(start)
+		route := r.URL.Path
+
+		RequestsTotal.WithLabelValues(route, r.Method, http.StatusText(rec.status)).Inc()
+		RequestDuration.WithLabelValues(route, strconv.Itoa(rec.status)).Observe(duration.Seconds())
+		global.record(duration)
+	})
+}
+
+// IncUpstreamError records a reverse-proxy failure reaching host.
+func IncUpstreamError(host string) {
+	UpstreamErrorsTotal.WithLabelValues(host).Inc()
+}
+
+// IncWAFBlock records a WAF block decision with its trigger reason.
+func IncWAFBlock(reason string) {
+	WAFBlocksTotal.WithLabelValues(reason).Inc()
+}
+
+// ObserveMLScorer records the latency of one call to the ML scorer.
+func ObserveMLScorer(d time.Duration) {
+	MLScorerDuration.Observe(d.Seconds())
+}
+
+// IncMLCall records one detector.MLClient.Check outcome.
+func IncMLCall(outcome string) {
+	MLCallsTotal.WithLabelValues(outcome).Inc()
+}
+
+// SetMLBreakerState publishes detector.MLClient's current breaker state.
+func SetMLBreakerState(state int) {
+	MLBreakerState.Set(float64(state))
+}
+
+// ObserveMLBatchSize records one /predict_batch call's item count.
+func ObserveMLBatchSize(n int) {
+	MLBatchSize.Observe(float64(n))
+}
+
+// SetMLQueueDepth publishes how many requests were waiting on the batcher's
+// submit channel when the last batch started collecting.
+func SetMLQueueDepth(n int) {
+	MLQueueDepth.Set(float64(n))
+}
+
+// ObserveMLWait records one request's total queue+round-trip wait time.
+func ObserveMLWait(d time.Duration) {
+	MLWaitDuration.Observe(d.Seconds())
+}
+
+// SetDecisionsStats publishes internal/decisions.Feed's latest snapshot.
+func SetDecisionsStats(active int, lastPullAge time.Duration) {
+	DecisionsActive.Set(float64(active))
+	DecisionsLastPullAge.Set(lastPullAge.Seconds())
+}
+
+// IncDecisionsPullError records one failed decisions-stream pull.
+func IncDecisionsPullError() {
+	DecisionsPullErrorsTotal.Inc()
+}
+
+// SetLogSinkStats publishes internal/logger.Sink's latest snapshot.
+func SetLogSinkStats(queued int, walBytes int64, breakerOpen bool) {
+	LogSinkQueued.Set(float64(queued))
+	LogSinkWALBytes.Set(float64(walBytes))
+	state := 0
+	if breakerOpen {
+		state = 1
+	}
+	LogSinkBreakerState.Set(float64(state))
+}
+
+// IncLogSinkDropped records one attack-log entry lost to a full sink queue.
+func IncLogSinkDropped() {
+	LogSinkDroppedTotal.Inc()
+}
+
+// ObserveLogSinkFlush records one batched InsertMany flush's latency.
+func ObserveLogSinkFlush(d time.Duration) {
+	LogSinkFlushDuration.Observe(d.Seconds())
+}
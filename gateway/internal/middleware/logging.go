@@ -1,16 +1,23 @@
 package middleware
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
+
+	"web-app-firewall-ml-detection/internal/chain"
+	"web-app-firewall-ml-detection/internal/metrics"
 )
 
-// statusRecorder wraps http.ResponseWriter to capture the status code
+// statusRecorder wraps http.ResponseWriter to capture the status code and
+// byte count a handler actually wrote, since ResponseWriter exposes
+// neither.
 type statusRecorder struct {
 	http.ResponseWriter
 	Status int
-	// We could also track written bytes here if needed
+	Bytes  int
 }
 
 func (r *statusRecorder) WriteHeader(status int) {
@@ -18,6 +25,15 @@ func (r *statusRecorder) WriteHeader(status int) {
 	r.ResponseWriter.WriteHeader(status)
 }
 
+// Write counts bytes written so SSE/streamed responses (which may never
+// call WriteHeader a second time, or return a meaningful Content-Length)
+// still get an accurate total.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.Bytes += n
+	return n, err
+}
+
 // [FIX] Implement the http.Flusher interface.
 // This allows the "Flush()" call from the SSE handler to reach the actual ResponseWriter.
 func (r *statusRecorder) Flush() {
@@ -26,6 +42,45 @@ func (r *statusRecorder) Flush() {
 	}
 }
 
+// requestLogEntry is the JSON shape RequestLogger emits per request, a
+// separate field set from the attack-log pipeline's models.AttackLog —
+// method/path/remote for correlation, status/bytes/duration_ms
+// for traffic-pattern aggregation, and user_id/domain_id/trace_id so a line
+// can be joined back to a specific tenant or request.
+type requestLogEntry struct {
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Remote     string  `json:"remote"`
+	Status     int     `json:"status"`
+	Bytes      int     `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	UserID     string  `json:"user_id,omitempty"`
+	DomainID   string  `json:"domain_id,omitempty"`
+	TraceID    string  `json:"trace_id,omitempty"`
+}
+
+// userIDFromContext reads the "user_id" value internal/middleware/auth.go
+// and internal/api/auth.go both stamp on an authenticated request's
+// context. Neither exports a typed accessor, so this matches their raw
+// string key directly.
+func userIDFromContext(r *http.Request) string {
+	userID, _ := r.Context().Value("user_id").(string)
+	return userID
+}
+
+// domainIDFromContext reads an optional "domain_id" context value, for
+// handlers that resolve one (e.g. a future per-domain WAF proxy path).
+// Nothing sets it yet, so this is best-effort and reports "" until
+// something does.
+func domainIDFromContext(r *http.Request) string {
+	domainID, _ := r.Context().Value("domain_id").(string)
+	return domainID
+}
+
+// RequestLogger emits one JSON log line per request and feeds
+// metrics.RequestDuration/ResponseBytesTotal, so WAF traffic patterns can
+// be aggregated and alerted on instead of grepped out of a plain
+// log.Printf line.
 func RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -38,14 +93,29 @@ func RequestLogger(next http.Handler) http.Handler {
 
 		next.ServeHTTP(recorder, r)
 
-		// Log the request details
-		log.Printf(
-			"[%s] %s %s %d %s",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			recorder.Status,
-			time.Since(start),
-		)
+		duration := time.Since(start)
+		route := r.URL.Path
+
+		entry := requestLogEntry{
+			Method:     r.Method,
+			Path:       route,
+			Remote:     r.RemoteAddr,
+			Status:     recorder.Status,
+			Bytes:      recorder.Bytes,
+			DurationMS: float64(duration.Microseconds()) / 1000,
+			UserID:     userIDFromContext(r),
+			DomainID:   domainIDFromContext(r),
+			TraceID:    chain.RequestIDFromContext(r.Context()),
+		}
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			log.Printf("[%s] %s %s %d %s", r.Method, r.RequestURI, r.RemoteAddr, recorder.Status, duration)
+		} else {
+			log.Println(string(line))
+		}
+
+		metrics.RequestDuration.WithLabelValues(route, strconv.Itoa(recorder.Status)).Observe(duration.Seconds())
+		metrics.ResponseBytesTotal.WithLabelValues(route).Add(float64(recorder.Bytes))
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,182 @@
+// Package netutil resolves the true client IP of an incoming request
+// behind zero or more trusted reverse proxies/CDNs, replacing the
+// "take the first X-Forwarded-For value" shortcut scattered across
+// internal/api and internal/chain — which any client can spoof simply by
+// sending their own X-Forwarded-For header.
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxyConfig is the set of proxies RealIP trusts to have appended
+// (rather than forged) an X-Forwarded-For/Forwarded entry. A peer outside
+// every CIDR here is never trusted, no matter what it sends.
+type TrustedProxyConfig struct {
+	cidrs []*net.IPNet
+
+	// TrustedHeader is an optional single-value header (e.g.
+	// "CF-Connecting-IP", "True-Client-IP") RealIP reads instead of
+	// walking X-Forwarded-For, but only when the immediate peer
+	// (r.RemoteAddr) is itself trusted.
+	TrustedHeader string
+}
+
+// NewTrustedProxyConfig parses cidrs (each a CIDR like "10.0.0.0/8" or a
+// bare IP, treated as a /32 or /128) into a TrustedProxyConfig.
+// trustedHeader may be empty to disable the CDN-header shortcut entirely.
+func NewTrustedProxyConfig(cidrs []string, trustedHeader string) (TrustedProxyConfig, error) {
+	cfg := TrustedProxyConfig{TrustedHeader: trustedHeader}
+	for _, s := range cidrs {
+		n, err := parseCIDROrIP(s)
+		if err != nil {
+			return TrustedProxyConfig{}, err
+		}
+		cfg.cidrs = append(cfg.cidrs, n)
+	}
+	return cfg, nil
+}
+
+func parseCIDROrIP(s string) (*net.IPNet, error) {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("netutil: %q is not a valid CIDR or IP", s)
+	}
+	bits := 32
+	if ip.To4() == nil {
+		bits = 128
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func (c TrustedProxyConfig) trusts(ip net.IP) bool {
+	for _, n := range c.cidrs {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP resolves r's true client IP under cfg's trusted proxy chain.
+//
+// The immediate peer (r.RemoteAddr) must itself be inside cfg's trusted
+// CIDRs before anything it sent is consulted at all — otherwise any direct,
+// untrusted client could simply set its own X-Forwarded-For/Forwarded/CDN
+// header and have it returned verbatim. With an untrusted (or zero-value,
+// "trusts nothing") cfg, RealIP always returns the immediate peer.
+//
+// Once the peer is trusted, and an X-Forwarded-For (or, failing that, an
+// RFC 7239 Forwarded) header is present, it walks the hop list
+// right-to-left — the rightmost entry was appended by whichever proxy
+// handed the request to us — returning the first hop that is NOT inside a
+// trusted CIDR. Everything further left could have been forged by that
+// untrusted hop, so it's never consulted.
+//
+// If every hop (or the peer, when there's no forwarding header at all) is
+// trusted, cfg.TrustedHeader is checked next — e.g. a CDN that stamps
+// "CF-Connecting-IP" with the real client IP instead of XFF. With no
+// trusted header configured either, the leftmost (original) hop is
+// returned, or the peer if there were no hops.
+func RealIP(r *http.Request, cfg TrustedProxyConfig) string {
+	peer := hostOnly(r.RemoteAddr)
+	peerIP := net.ParseIP(peer)
+	peerTrusted := peerIP != nil && cfg.trusts(peerIP)
+
+	if !peerTrusted {
+		return peer
+	}
+
+	hops := forwardedHops(r)
+	if len(hops) == 0 {
+		if v := trustedHeaderValue(r, cfg); v != "" {
+			return v
+		}
+		return peer
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			continue
+		}
+		if !cfg.trusts(ip) {
+			return hops[i]
+		}
+	}
+
+	// Every parseable hop was trusted: prefer the CDN header if configured,
+	// else the original (leftmost) hop.
+	if v := trustedHeaderValue(r, cfg); v != "" {
+		return v
+	}
+	return hops[0]
+}
+
+func trustedHeaderValue(r *http.Request, cfg TrustedProxyConfig) string {
+	if cfg.TrustedHeader == "" {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get(cfg.TrustedHeader))
+}
+
+// forwardedHops returns the client-IP hop chain, leftmost (original
+// client) first, from X-Forwarded-For or else the RFC 7239 Forwarded
+// header. nil if neither is present.
+func forwardedHops(r *http.Request) []string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		parts := strings.Split(xff, ",")
+		hops := make([]string, 0, len(parts))
+		for _, p := range parts {
+			if ip := hostOnly(strings.TrimSpace(p)); ip != "" {
+				hops = append(hops, ip)
+			}
+		}
+		return hops
+	}
+
+	if fwd := r.Header.Get("Forwarded"); fwd != "" {
+		return forwardedHeaderHops(fwd)
+	}
+
+	return nil
+}
+
+// forwardedHeaderHops extracts every "for=" parameter across a Forwarded
+// header's comma-separated elements (themselves semicolon-separated
+// key=value pairs, per RFC 7239), in header order.
+func forwardedHeaderHops(fwd string) []string {
+	var hops []string
+	for _, elem := range strings.Split(fwd, ",") {
+		for _, kv := range strings.Split(elem, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(k), "for") {
+				continue
+			}
+			v = strings.Trim(strings.TrimSpace(v), `"`)
+			if ip := hostOnly(v); ip != "" {
+				hops = append(hops, ip)
+			}
+		}
+	}
+	return hops
+}
+
+// hostOnly strips a ":port" suffix and IPv6 brackets, e.g.
+// "[::1]:8080" -> "::1", "1.2.3.4:8080" -> "1.2.3.4". A bare address with
+// neither (the common XFF case) passes through unchanged.
+func hostOnly(hostAddr string) string {
+	if hostAddr == "" {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(hostAddr); err == nil {
+		return host
+	}
+	return strings.Trim(hostAddr, "[]")
+}
@@ -0,0 +1,64 @@
+package netutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func trustedCfg(t *testing.T, trustedHeader string) TrustedProxyConfig {
+	t.Helper()
+	cfg, err := NewTrustedProxyConfig([]string{"10.0.0.0/8"}, trustedHeader)
+	if err != nil {
+		t.Fatalf("NewTrustedProxyConfig: %v", err)
+	}
+	return cfg
+}
+
+// TestRealIPUntrustedPeerIgnoresForwardedFor verifies that a direct,
+// untrusted client can't spoof its IP just by sending its own
+// X-Forwarded-For header: with no trusted peer, RealIP must ignore every
+// forwarded header and return the immediate peer.
+func TestRealIPUntrustedPeerIgnoresForwardedFor(t *testing.T) {
+	cfg := trustedCfg(t, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+	if got := RealIP(req, cfg); got != "203.0.113.5" {
+		t.Errorf("RealIP() = %q, want the untrusted peer %q", got, "203.0.113.5")
+	}
+}
+
+// TestRealIPTrustedPeerWalksHopsPastUntrustedEntry verifies that with a
+// trusted immediate peer, RealIP walks X-Forwarded-For right-to-left and
+// returns the first entry that isn't itself inside a trusted CIDR —
+// everything to the left of that entry could have been forged by it.
+func TestRealIPTrustedPeerWalksHopsPastUntrustedEntry(t *testing.T) {
+	cfg := trustedCfg(t, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234" // trusted immediate peer (our own proxy)
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 10.0.0.2")
+
+	if got := RealIP(req, cfg); got != "203.0.113.9" {
+		t.Errorf("RealIP() = %q, want the untrusted client hop %q", got, "203.0.113.9")
+	}
+}
+
+// TestRealIPTrustedPeerUsesHeaderFallback verifies that when every
+// forwarded hop is itself trusted, RealIP prefers cfg.TrustedHeader (e.g. a
+// CDN's own "real client IP" header) over the leftmost forwarded hop.
+func TestRealIPTrustedPeerUsesHeaderFallback(t *testing.T) {
+	cfg := trustedCfg(t, "CF-Connecting-IP")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.2")
+	req.Header.Set("CF-Connecting-IP", "198.51.100.7")
+
+	if got := RealIP(req, cfg); got != "198.51.100.7" {
+		t.Errorf("RealIP() = %q, want the trusted header value %q", got, "198.51.100.7")
+	}
+}
@@ -0,0 +1,166 @@
+// Package oidc implements the authorization-code + PKCE flow against a
+// Keycloak-style OIDC realm, independent of the simpler access-token +
+// userinfo flow internal/api/oauth.go already uses for Google/GitHub: an
+// ID token's signature is verified locally against the realm's JWKS
+// instead of trusting whatever a userinfo endpoint returns.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Provider is one configured realm: its authorization/token/JWKS endpoints
+// plus the client credentials the gateway was registered with.
+type Provider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	AuthURL      string
+	TokenURL     string
+	Issuer       string
+
+	jwks *JWKSCache
+}
+
+// NewKeycloakProvider derives the standard Keycloak realm endpoints from
+// issuerURL (e.g. "https://idp.example.com/realms/waf"), so a deployment
+// only has to configure the realm's base URL plus client credentials.
+func NewKeycloakProvider(name, issuerURL, clientID, clientSecret, redirectURL string) *Provider {
+	issuerURL = strings.TrimSuffix(issuerURL, "/")
+	return &Provider{
+		Name:         name,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      issuerURL + "/protocol/openid-connect/auth",
+		TokenURL:     issuerURL + "/protocol/openid-connect/token",
+		Issuer:       issuerURL,
+		jwks:         NewJWKSCache(issuerURL + "/protocol/openid-connect/certs"),
+	}
+}
+
+// AuthCodeURL builds the redirect to the realm's login page, binding state
+// (CSRF) and a PKCE code_challenge to this one login attempt.
+func (p *Provider) AuthCodeURL(state, codeChallenge string) string {
+	v := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	return p.AuthURL + "?" + v.Encode()
+}
+
+// tokenResponse is the subset of the token endpoint's response body the
+// gateway cares about; access_token is kept only in case a caller ever
+// needs it, the login flow itself only trusts id_token.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+}
+
+// Exchange trades the authorization code, plus the PKCE verifier minted
+// alongside it, for an ID token at the realm's token endpoint.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token endpoint returned %d", resp.StatusCode)
+	}
+
+	var tok tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode token response: %w", err)
+	}
+	if tok.IDToken == "" {
+		return nil, fmt.Errorf("oidc: token response missing id_token")
+	}
+	return &tok, nil
+}
+
+// Identity is what VerifyIDToken extracts once the ID token's signature,
+// issuer, audience, and expiry all check out.
+type Identity struct {
+	Subject string
+	Email   string
+	Name    string
+
+	// EmailVerified mirrors the id_token's email_verified claim; a missing
+	// claim is treated as false (not verified) rather than assumed true.
+	EmailVerified bool
+}
+
+// VerifyIDToken checks the ID token's RS256 signature against the realm's
+// cached JWKS, then validates iss/aud/exp before trusting any of its
+// claims.
+func (p *Provider) VerifyIDToken(idToken string) (Identity, error) {
+	token, err := jwt.Parse(idToken, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != "RS256" {
+			return nil, fmt.Errorf("unexpected signing method %q", t.Method.Alg())
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.jwks.Key(kid)
+	}, jwt.WithIssuer(p.Issuer), jwt.WithAudience(p.ClientID), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return Identity{}, fmt.Errorf("oidc: id_token verification failed: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Identity{}, fmt.Errorf("oidc: id_token has unexpected claims type")
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return Identity{}, fmt.Errorf("oidc: id_token missing sub")
+	}
+
+	return Identity{
+		Subject:       sub,
+		Email:         stringClaim(claims, "email"),
+		Name:          stringClaim(claims, "name"),
+		EmailVerified: boolClaim(claims, "email_verified"),
+	}, nil
+}
+
+func stringClaim(claims jwt.MapClaims, key string) string {
+	if v, ok := claims[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func boolClaim(claims jwt.MapClaims, key string) bool {
+	v, _ := claims[key].(bool)
+	return v
+}
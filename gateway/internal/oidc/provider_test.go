@@ -0,0 +1,34 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestBoolClaimMissingIsFalse verifies an id_token with no email_verified
+// claim (or a non-bool value for it) is treated as unverified rather than
+// assumed true — see Identity.EmailVerified.
+func TestBoolClaimMissingIsFalse(t *testing.T) {
+	if boolClaim(jwt.MapClaims{}, "email_verified") {
+		t.Error("a missing claim should report false")
+	}
+	if boolClaim(jwt.MapClaims{"email_verified": "true"}, "email_verified") {
+		t.Error("a non-bool claim value should report false, not be coerced")
+	}
+}
+
+func TestBoolClaimReadsBoolValue(t *testing.T) {
+	if !boolClaim(jwt.MapClaims{"email_verified": true}, "email_verified") {
+		t.Error("a true bool claim should report true")
+	}
+	if boolClaim(jwt.MapClaims{"email_verified": false}, "email_verified") {
+		t.Error("a false bool claim should report false")
+	}
+}
+
+func TestStringClaimMissingIsEmpty(t *testing.T) {
+	if got := stringClaim(jwt.MapClaims{}, "email"); got != "" {
+		t.Errorf("stringClaim() = %q, want empty for a missing claim", got)
+	}
+}
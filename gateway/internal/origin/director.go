@@ -0,0 +1,127 @@
+package origin
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// candidatesKey stores the failover order (everything after the primary
+// pick) on the request context so FailoverTransport can retry without a
+// second trip to Mongo.
+type candidatesKey struct{}
+
+// PoolCache is the request-path cache internal/api.RulesConfig exposes via
+// OriginPool — passing one in lets NewDirector answer a pool lookup with a
+// map read instead of database.GetOriginPool's per-request Mongo round
+// trip. nil is valid (falls back to the live Mongo lookup every time),
+// which keeps internal/origin usable standalone, e.g. in a future
+// deployment that doesn't boot internal/api at all.
+type PoolCache interface {
+	OriginPool(host string) ([]database.DNSRecord, bool)
+}
+
+// NewDirector builds an httputil.ReverseProxy Director that looks up the
+// origin pool for the request's Host, picks one via picker, and rewrites
+// the request to target it — falling back to the legacy single-record
+// lookup and finally to defaultOrigin if the domain has no pooled records.
+// cache replaces the live Mongo pool lookup when non-nil; a cache miss is
+// treated as "no pool", so the legacy single-record lookup runs next.
+func NewDirector(client *mongo.Client, defaultOrigin string, picker *Picker, cache PoolCache) func(*http.Request) {
+	defaultURL, _ := url.Parse(defaultOrigin)
+
+	return func(req *http.Request) {
+		incomingHost := req.Host
+
+		targets := poolTargets(client, incomingHost, picker, cache)
+		if len(targets) == 0 {
+			targets = legacyTargets(client, incomingHost, defaultURL)
+		}
+
+		primary := targets[0]
+		req.URL.Scheme = primary.Scheme
+		req.URL.Host = primary.Host
+		req.Header.Set("X-Forwarded-Host", incomingHost)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Real-IP", req.RemoteAddr)
+
+		if len(targets) > 1 {
+			bufferBodyForRetry(req)
+			ctx := context.WithValue(req.Context(), candidatesKey{}, targets[1:])
+			*req = *req.WithContext(ctx)
+		}
+
+		log.Printf("[Proxy] Routing %s -> %s (candidates: %d)", incomingHost, primary.Host, len(targets))
+	}
+}
+
+func poolTargets(client *mongo.Client, host string, picker *Picker, cache PoolCache) []*url.URL {
+	var pool []database.DNSRecord
+	if cache != nil {
+		if cached, ok := cache.OriginPool(host); ok {
+			pool = cached
+		}
+	} else {
+		pool, _ = database.GetOriginPool(client, host)
+	}
+	if len(pool) == 0 {
+		return nil
+	}
+
+	var targets []*url.URL
+	for _, r := range picker.Order(pool) {
+		targets = append(targets, buildTargetURL(r))
+	}
+	return targets
+}
+
+func legacyTargets(client *mongo.Client, host string, defaultURL *url.URL) []*url.URL {
+	if record, err := database.GetOriginRecord(client, host); err == nil && record != nil {
+		return []*url.URL{buildTargetURL(*record)}
+	}
+	return []*url.URL{defaultURL}
+}
+
+// buildTargetURL mirrors the scheme-selection the gateway has always used:
+// OriginSSL decides http vs https unless Content already carries a scheme.
+func buildTargetURL(r database.DNSRecord) *url.URL {
+	raw := r.Content
+	hasScheme := len(raw) >= 4 && raw[:4] == "http"
+
+	if !hasScheme {
+		if r.OriginSSL {
+			raw = "https://" + raw
+		} else {
+			raw = "http://" + raw
+		}
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return &url.URL{Scheme: "http", Host: r.Content}
+	}
+	return parsed
+}
+
+// bufferBodyForRetry reads the request body once and installs GetBody so a
+// failover retry (which needs a fresh, unread body) can replay it.
+func bufferBodyForRetry(req *http.Request) {
+	if req.Body == nil {
+		return
+	}
+	bodyBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(bodyBytes)), nil
+	}
+}
@@ -0,0 +1,77 @@
+package origin
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ProbeInterval is how often every A/AAAA record gets re-checked.
+const ProbeInterval = 30 * time.Second
+
+const probeTimeout = 3 * time.Second
+
+// StartHealthChecks launches a background ticker that probes every A/AAAA
+// record's Content and persists the result via database.UpdateRecordHealth.
+// It never returns; callers start it with `go origin.StartHealthChecks(...)`.
+func StartHealthChecks(client *mongo.Client) {
+	ticker := time.NewTicker(ProbeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		runProbeRound(client)
+	}
+}
+
+func runProbeRound(client *mongo.Client) {
+	records, err := database.GetAllDNSRecords(client)
+	if err != nil {
+		log.Printf("[ERROR] origin: health round failed to list records: %v", err)
+		return
+	}
+
+	for _, r := range records {
+		if r.Type != "A" && r.Type != "AAAA" {
+			continue
+		}
+		healthy, latency := probe(r)
+		if err := database.UpdateRecordHealth(client, r.ID, healthy, latency); err != nil {
+			log.Printf("[ERROR] origin: failed to persist health for %s (%s): %v", r.Name, r.Content, err)
+		}
+	}
+}
+
+// probe checks a single record: HTTP(S) GET against "/" when OriginSSL is
+// set (since the backend is expected to terminate TLS), otherwise a plain
+// TCP dial — mirroring the scheme selection already used by the director.
+func probe(r database.DNSRecord) (bool, time.Duration) {
+	start := time.Now()
+
+	if r.OriginSSL {
+		client := &http.Client{Timeout: probeTimeout}
+		resp, err := client.Get("https://" + r.Content + "/")
+		if err != nil {
+			return false, time.Since(start)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode < 500, time.Since(start)
+	}
+
+	conn, err := net.DialTimeout("tcp", withPort(r.Content, "80"), probeTimeout)
+	if err != nil {
+		return false, time.Since(start)
+	}
+	conn.Close()
+	return true, time.Since(start)
+}
+
+func withPort(hostOrHostPort, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(hostOrHostPort); err == nil {
+		return hostOrHostPort
+	}
+	return net.JoinHostPort(hostOrHostPort, defaultPort)
+}
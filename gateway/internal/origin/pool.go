@@ -0,0 +1,118 @@
+// Package origin turns the DNS records for a host into a health-checked
+// upstream pool: multiple A/AAAA records sharing a Name are candidates a
+// request can be load-balanced across, with automatic failover away from
+// anything the prober has marked unhealthy.
+package origin
+
+import (
+	"sync/atomic"
+
+	"web-app-firewall-ml-detection/internal/database"
+)
+
+// Policy picks one candidate out of a pool of healthy origins.
+type Policy string
+
+const (
+	RoundRobin   Policy = "round_robin"
+	LeastLatency Policy = "least_latency"
+	Weighted     Policy = "weighted"
+)
+
+// Picker selects an origin from a pool according to Policy. It is safe for
+// concurrent use; the round-robin cursor is a single atomic counter shared
+// across all hosts, which is fine since it's only ever used for its low bits.
+type Picker struct {
+	Policy Policy
+
+	rrCounter uint64
+}
+
+// NewPicker returns a Picker using policy, defaulting to RoundRobin for an
+// unrecognized/empty value rather than erroring, since this almost always
+// comes from an env var or config file.
+func NewPicker(policy Policy) *Picker {
+	switch policy {
+	case RoundRobin, LeastLatency, Weighted:
+		return &Picker{Policy: policy}
+	default:
+		return &Picker{Policy: RoundRobin}
+	}
+}
+
+// Healthy filters pool down to records the prober has marked healthy. If
+// none are healthy, it returns the full pool so a misbehaving prober (or one
+// that hasn't run yet) doesn't take every origin offline.
+func Healthy(pool []database.DNSRecord) []database.DNSRecord {
+	var healthy []database.DNSRecord
+	for _, r := range pool {
+		if r.Healthy {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return pool
+	}
+	return healthy
+}
+
+// Order returns the candidates in pool ranked for this call according to
+// p.Policy — index 0 is the primary pick, the rest is the failover order.
+func (p *Picker) Order(pool []database.DNSRecord) []database.DNSRecord {
+	candidates := Healthy(pool)
+	if len(candidates) <= 1 {
+		return candidates
+	}
+
+	ordered := append([]database.DNSRecord(nil), candidates...)
+
+	switch p.Policy {
+	case LeastLatency:
+		sortByLatency(ordered)
+	case Weighted:
+		sortByWeight(ordered)
+		start := p.next(len(ordered))
+		return rotate(ordered, start)
+	default: // RoundRobin
+		start := p.next(len(ordered))
+		return rotate(ordered, start)
+	}
+	return ordered
+}
+
+// next advances the shared round-robin cursor and returns a start index
+// in [0, n).
+func (p *Picker) next(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return int(atomic.AddUint64(&p.rrCounter, 1) % uint64(n))
+}
+
+func rotate(records []database.DNSRecord, start int) []database.DNSRecord {
+	rotated := make([]database.DNSRecord, 0, len(records))
+	rotated = append(rotated, records[start:]...)
+	rotated = append(rotated, records[:start]...)
+	return rotated
+}
+
+func sortByLatency(records []database.DNSRecord) {
+	for i := 1; i < len(records); i++ {
+		for j := i; j > 0 && records[j].LatencyMS < records[j-1].LatencyMS; j-- {
+			records[j], records[j-1] = records[j-1], records[j]
+		}
+	}
+}
+
+func sortByWeight(records []database.DNSRecord) {
+	for i := range records {
+		if records[i].Weight <= 0 {
+			records[i].Weight = 1
+		}
+	}
+	for i := 1; i < len(records); i++ {
+		for j := i; j > 0 && records[j].Weight > records[j-1].Weight; j-- {
+			records[j], records[j-1] = records[j-1], records[j]
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package origin
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// FailoverTransport wraps a base RoundTripper and retries against the next
+// healthy candidate (stashed on the request context by Director) whenever
+// the first attempt fails outright or comes back with a 5xx.
+type FailoverTransport struct {
+	Base http.RoundTripper
+}
+
+// NewFailoverTransport returns a FailoverTransport; base defaults to
+// http.DefaultTransport if nil.
+func NewFailoverTransport(base http.RoundTripper) *FailoverTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &FailoverTransport{Base: base}
+}
+
+func (t *FailoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if isUpstreamOK(resp, err) {
+		return resp, err
+	}
+
+	remaining, _ := req.Context().Value(candidatesKey{}).([]*url.URL)
+	for _, next := range remaining {
+		retryReq, cloneErr := cloneForRetry(req, next)
+		if cloneErr != nil {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		resp, err = t.Base.RoundTrip(retryReq)
+		if isUpstreamOK(resp, err) {
+			return resp, err
+		}
+	}
+	return resp, err
+}
+
+func isUpstreamOK(resp *http.Response, err error) bool {
+	return err == nil && resp.StatusCode < http.StatusInternalServerError
+}
+
+func cloneForRetry(req *http.Request, target *url.URL) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	clone.URL.Scheme = target.Scheme
+	clone.URL.Host = target.Host
+	clone.Host = target.Host
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
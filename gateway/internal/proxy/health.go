@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/core"
+)
+
+// HealthEvent is one origin's up/down transition, broadcast on healthEvents
+// so the dashboard's SSE stream can show pool flaps in real time.
+type HealthEvent struct {
+	Host      string    `json:"host"`
+	Origin    string    `json:"origin"`
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// healthBroker fans HealthEvent out to every subscriber's buffered
+// channel, dropping an event for a subscriber whose buffer is full.
+type healthBroker struct {
+	mu          sync.RWMutex
+	subscribers map[chan HealthEvent]struct{}
+}
+
+var events = &healthBroker{subscribers: make(map[chan HealthEvent]struct{})}
+
+// SubscribeHealth hands back a dedicated channel of origin state changes,
+// for whatever handler ends up serving this lane's /api/stream (see
+// handler.LogHandler.SSEHandler for the existing SSE loop shape) to
+// multiplex alongside attack logs. Callers must UnsubscribeHealth on
+// disconnect.
+func SubscribeHealth() chan HealthEvent {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	ch := make(chan HealthEvent, 20)
+	events.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func UnsubscribeHealth(ch chan HealthEvent) {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	if _, ok := events.subscribers[ch]; ok {
+		delete(events.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (b *healthBroker) publish(ev HealthEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Drop for a slow client rather than blocking the checker loop.
+		}
+	}
+}
+
+// originKey identifies one candidate upstream within a host's pool.
+type originKey struct {
+	host   string
+	origin string
+}
+
+// healthChecker periodically probes every origin in a host's pool and keeps
+// an in-memory up/down verdict, so director never dials an origin it
+// already knows is dead.
+type healthChecker struct {
+	mu       sync.RWMutex
+	state    map[originKey]bool
+	hosts    map[string]struct{}
+	path     string
+	interval time.Duration
+	client   *http.Client
+}
+
+func newHealthChecker(path string, interval time.Duration) *healthChecker {
+	if path == "" {
+		path = "/"
+	}
+	return &healthChecker{
+		state:    make(map[originKey]bool),
+		hosts:    make(map[string]struct{}),
+		path:     path,
+		interval: interval,
+		client:   &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+// registerHost records host as one the background loop should keep
+// probing; director calls this on every request so a newly-seen domain
+// joins the check cycle without any separate wiring.
+func (hc *healthChecker) registerHost(host string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.hosts[host] = struct{}{}
+}
+
+func (hc *healthChecker) knownHosts() []string {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	hosts := make([]string, 0, len(hc.hosts))
+	for h := range hc.hosts {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// run probes every registered host's origin pool once per interval until
+// ctx is cancelled; it's meant to be started once as a background
+// goroutine from NewProxy.
+func (hc *healthChecker) run(ctx context.Context, repo core.DomainRepository) {
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, host := range hc.knownHosts() {
+				poolCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				pool, err := repo.GetOriginPool(poolCtx, host)
+				cancel()
+				if err != nil {
+					continue
+				}
+				for _, record := range pool {
+					scheme := "http"
+					if record.OriginSSL {
+						scheme = "https"
+					}
+					checkCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+					hc.check(checkCtx, host, record.Content, scheme)
+					cancel()
+				}
+			}
+		}
+	}
+}
+
+// isHealthy reports the last known verdict for origin; an origin with no
+// recorded probe yet is assumed healthy so a brand-new pool isn't rejected
+// before the first check tick runs.
+func (hc *healthChecker) isHealthy(host, origin string) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	healthy, seen := hc.state[originKey{host, origin}]
+	if !seen {
+		return true
+	}
+	return healthy
+}
+
+func (hc *healthChecker) probe(ctx context.Context, host, origin, scheme string) bool {
+	url := fmt.Sprintf("%s://%s%s", scheme, origin, hc.path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode < 500
+}
+
+// check probes one origin and records/broadcasts a state change.
+func (hc *healthChecker) check(ctx context.Context, host, origin, scheme string) {
+	healthy := hc.probe(ctx, host, origin, scheme)
+
+	key := originKey{host, origin}
+	hc.mu.Lock()
+	prev, seen := hc.state[key]
+	hc.state[key] = healthy
+	hc.mu.Unlock()
+
+	if !seen || prev != healthy {
+		events.publish(HealthEvent{Host: host, Origin: origin, Healthy: healthy, CheckedAt: time.Now()})
+	}
+}
@@ -1,8 +1,10 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httputil"
@@ -10,16 +12,36 @@ import (
 	"os"
 	"time"
 
+	"web-app-firewall-ml-detection/internal/certmanager"
 	"web-app-firewall-ml-detection/internal/core"
 )
 
+// healthCheckInterval is how often the background checker re-probes every
+// origin in every known host's pool.
+const healthCheckInterval = 15 * time.Second
+
+// healthCheckPath is the path probed on each origin ("GET <path>"); kept as
+// a constant for now rather than per-domain config, matching the scope of
+// the first cut of this feature.
+const healthCheckPath = "/"
+
 type ProxyManager struct {
 	repo          core.DomainRepository
 	defaultOrigin string
 	errorPage     []byte
+	checker       *healthChecker
 }
 
-func NewProxy(repo core.DomainRepository, defaultOrigin string) *httputil.ReverseProxy {
+// unreachableKey marks a request whose director found no healthy origin in
+// the pool, so deadTransport can short-circuit straight to the error page
+// instead of dialing an origin it already knows is down.
+type unreachableKey struct{}
+
+// NewProxy builds the reverse proxy plus the tls.Config the HTTPS listener
+// should use for SNI-driven certificate selection: store is the ACME
+// subsystem's CertificateStore (see internal/certmanager), so every
+// proxied domain terminates TLS with its own cert on this one listener.
+func NewProxy(repo core.DomainRepository, defaultOrigin string, store *certmanager.CertificateStore) (*httputil.ReverseProxy, *tls.Config) {
 	// Load 502 Page once
 	page502, err := os.ReadFile("pages/502.html")
 	if err != nil {
@@ -31,49 +53,46 @@ func NewProxy(repo core.DomainRepository, defaultOrigin string) *httputil.Revers
 		repo:          repo,
 		defaultOrigin: defaultOrigin,
 		errorPage:     page502,
+		checker:       newHealthChecker(healthCheckPath, healthCheckInterval),
 	}
 
-	return &httputil.ReverseProxy{
-		Director:      p.director,
-		ErrorHandler:  p.errorHandler,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // Trust backend IPs
+	go p.checker.run(context.Background(), repo)
+
+	reverseProxy := &httputil.ReverseProxy{
+		Director:     p.director,
+		ErrorHandler: p.errorHandler,
+		Transport: &deadTransport{
+			errorPage: p.errorPage,
+			inner: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // Trust backend IPs
+			},
 		},
 	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: store.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	return reverseProxy, tlsConfig
 }
 
 func (p *ProxyManager) director(req *http.Request) {
 	incomingHost := req.Host
-	var targetURL *url.URL
 
-	// 1. Lookup Origin Record
+	// 1. Lookup Origin Pool
 	// Use a short timeout to avoid hanging the request on DB lookups
 	ctx, cancel := context.WithTimeout(req.Context(), 1*time.Second)
 	defer cancel()
 
-	record, err := p.repo.GetOriginRecord(ctx, incomingHost)
-
-	if err == nil && record != nil {
-		rawTarget := record.Content
-		
-		// 2. Dynamic Scheme Selection (HTTP vs HTTPS)
-		if record.OriginSSL {
-			if len(rawTarget) < 4 || rawTarget[:4] != "http" {
-				rawTarget = "https://" + rawTarget
-			}
-		} else {
-			if len(rawTarget) < 4 || rawTarget[:4] != "http" {
-				rawTarget = "http://" + rawTarget
-			}
-		}
+	p.checker.registerHost(incomingHost)
 
-		targetURL, _ = url.Parse(rawTarget)
-	} else {
-		// 3. Fallback
-		targetURL, _ = url.Parse(p.defaultOrigin)
+	targetURL, healthy := p.pickOrigin(ctx, incomingHost)
+	if !healthy {
+		*req = *req.WithContext(context.WithValue(req.Context(), unreachableKey{}, true))
 	}
 
-	// 4. Rewrite Request
+	// 2. Rewrite Request
 	req.URL.Scheme = targetURL.Scheme
 	req.URL.Host = targetURL.Host
 	req.Header.Set("X-Forwarded-Host", incomingHost)
@@ -81,6 +100,75 @@ func (p *ProxyManager) director(req *http.Request) {
 	req.Header.Set("X-Real-IP", req.RemoteAddr)
 }
 
+// pickOrigin returns the first healthy origin in host's pool, falling back
+// to the legacy single-record lookup and finally defaultOrigin when the
+// domain has no pooled A/AAAA records. The bool return is false only when
+// the pool is non-empty but every origin in it is currently marked down —
+// that's the case deadTransport must short-circuit instead of dialing.
+func (p *ProxyManager) pickOrigin(ctx context.Context, host string) (*url.URL, bool) {
+	pool, err := p.repo.GetOriginPool(ctx, host)
+	if err == nil && len(pool) > 0 {
+		for _, record := range pool {
+			if p.checker.isHealthy(host, record.Content) {
+				return buildTargetURL(record), true
+			}
+		}
+		// Every pooled origin is down: still point somewhere (for logging/
+		// X-Forwarded-Host purposes) but flag the request as unreachable.
+		return buildTargetURL(pool[0]), false
+	}
+
+	if record, err := p.repo.GetOriginRecord(ctx, host); err == nil && record != nil {
+		return buildTargetURL(*record), true
+	}
+
+	targetURL, _ := url.Parse(p.defaultOrigin)
+	return targetURL, true
+}
+
+// buildTargetURL mirrors the scheme-selection the gateway has always used:
+// OriginSSL decides http vs https unless Content already carries a scheme.
+func buildTargetURL(r core.DNSRecord) *url.URL {
+	rawTarget := r.Content
+	if len(rawTarget) < 4 || rawTarget[:4] != "http" {
+		if r.OriginSSL {
+			rawTarget = "https://" + rawTarget
+		} else {
+			rawTarget = "http://" + rawTarget
+		}
+	}
+	targetURL, err := url.Parse(rawTarget)
+	if err != nil {
+		return &url.URL{Scheme: "http", Host: r.Content}
+	}
+	return targetURL
+}
+
+// deadTransport wraps the real transport so a request whose director
+// already knows has no healthy origin never attempts a doomed dial — it
+// answers with the 502 page immediately instead of waiting out a connect
+// timeout.
+type deadTransport struct {
+	inner     http.RoundTripper
+	errorPage []byte
+}
+
+func (t *deadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if unreachable, _ := req.Context().Value(unreachableKey{}).(bool); unreachable {
+		return &http.Response{
+			StatusCode: http.StatusBadGateway,
+			Status:     "502 Bad Gateway",
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": []string{"text/html"}},
+			Body:       io.NopCloser(bytes.NewReader(t.errorPage)),
+			Request:    req,
+		}, nil
+	}
+	return t.inner.RoundTrip(req)
+}
+
 func (p *ProxyManager) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
 	log.Printf("🔥 Proxy Error for %s: %v", r.Host, err)
 	if r.Context().Err() != nil {
@@ -0,0 +1,488 @@
+// Package rdap resolves a domain's live nameservers the way a registrar
+// would report them, for DomainService.VerifyDomainOwner to compare against
+// the WAF's own assigned NS. It replaces a single hardcoded rdap.org call
+// with IANA's own bootstrap registry, a fallback chain for when any one
+// upstream is down, and a short-lived Mongo cache so a burst of
+// verification attempts for the same domain doesn't hit any upstream twice.
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	bootstrapURL    = "https://data.iana.org/rdap/dns.json"
+	bootstrapTTL    = 24 * time.Hour
+	bootstrapClient = 10 * time.Second
+
+	cacheCollection = "rdap_cache"
+	cacheTTL        = 5 * time.Minute
+
+	lookupTimeout = 15 * time.Second
+
+	// maxRetries is how many times query retries the SAME provider on a
+	// 429/503 before giving up on it and letting providerChain move on to
+	// the next one.
+	maxRetries = 2
+	// maxRetryWait caps how long we'll honor a Retry-After for — a
+	// registry asking us to wait 10 minutes isn't worth blocking a
+	// VerifyDomain request over; fail over to the next provider instead.
+	maxRetryWait = 5 * time.Second
+)
+
+// Provider is one upstream in the fallback chain.
+type Provider string
+
+const (
+	ProviderAuthoritative Provider = "authoritative" // IANA bootstrap-selected RDAP base for the TLD
+	ProviderRDAPOrg       Provider = "rdap.org"
+	ProviderWHOIS         Provider = "whois.iana.org"
+)
+
+// rdapResponse is the subset of an RDAP domain response this resolver
+// reads: the live nameservers, and any rel=related link for a registrar
+// redirect (some TLD registries return a referral rather than the record
+// itself).
+type rdapResponse struct {
+	Nameservers []struct {
+		LdhName string `json:"ldhName"`
+	} `json:"nameservers"`
+	Links []struct {
+		Rel  string `json:"rel"`
+		Href string `json:"href"`
+	} `json:"links"`
+}
+
+// cachedLookup is the document shape kept in cacheCollection, keyed by
+// domain name.
+type cachedLookup struct {
+	Domain      string    `bson:"_id"`
+	Nameservers []string  `bson:"nameservers"`
+	Provider    string    `bson:"provider"`
+	CachedAt    time.Time `bson:"cached_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// stats is the running per-provider error/hit counters Resolver reports to
+// SystemStatus.
+type stats struct {
+	mu          sync.Mutex
+	cacheHits   int64
+	cacheMisses int64
+	errors      map[Provider]int64
+}
+
+// Stats is a point-in-time snapshot of Resolver's cache hit rate and
+// per-provider error counts.
+type Stats struct {
+	CacheHits    int64
+	CacheMisses  int64
+	ProviderErrs map[Provider]int64
+}
+
+// Resolver looks up a domain's authoritative nameservers, preferring the
+// RDAP server IANA's bootstrap registry names for that domain's TLD, and
+// falling back through a fixed provider chain when that (or any later
+// provider) returns 429/5xx.
+type Resolver struct {
+	mongo  *mongo.Client
+	client *http.Client
+
+	mu          sync.Mutex
+	bootstrap   map[string][]string // TLD (lowercase, no dot) -> RDAP base URLs
+	bootstrapAt time.Time
+
+	stats stats
+}
+
+// NewResolver constructs a Resolver. The bootstrap file is fetched lazily
+// on first lookup rather than here, so constructing one never blocks on
+// network access.
+func NewResolver(client *mongo.Client) *Resolver {
+	return &Resolver{
+		mongo:  client,
+		client: &http.Client{Timeout: lookupTimeout},
+		stats:  stats{errors: make(map[Provider]int64)},
+	}
+}
+
+// RDAPResponse is what Lookup hands back to callers — just the live
+// nameservers, since that's all VerifyDomain and VerifyDomainOwner compare
+// against the WAF's own assigned NS.
+type RDAPResponse struct {
+	Nameservers []string
+}
+
+// Lookup returns domain's live nameservers, trying the Mongo cache first,
+// then the authoritative RDAP server for its TLD, then rdap.org, then a
+// WHOIS fallback, stopping at the first provider that answers. ctx bounds
+// only the Retry-After waits a provider asks for; each upstream request
+// runs under its own lookupTimeout, independent of ctx.
+func (r *Resolver) Lookup(ctx context.Context, domain string) (*RDAPResponse, error) {
+	if ns, ok := r.cacheGet(domain); ok {
+		r.stats.mu.Lock()
+		r.stats.cacheHits++
+		r.stats.mu.Unlock()
+		return &RDAPResponse{Nameservers: ns}, nil
+	}
+	r.stats.mu.Lock()
+	r.stats.cacheMisses++
+	r.stats.mu.Unlock()
+
+	chain := r.providerChain(domain)
+
+	var lastErr error
+	for _, p := range chain {
+		ns, err := r.query(ctx, p.provider, p.url, domain)
+		if err == nil {
+			r.cacheSet(domain, ns, p.provider)
+			return &RDAPResponse{Nameservers: ns}, nil
+		}
+		r.stats.mu.Lock()
+		r.stats.errors[p.provider]++
+		r.stats.mu.Unlock()
+		lastErr = err
+	}
+	return nil, fmt.Errorf("all RDAP providers failed for %s: %w", domain, lastErr)
+}
+
+type providerTarget struct {
+	provider Provider
+	url      string
+}
+
+// providerChain builds the ordered fallback list: the TLD's authoritative
+// RDAP base first (if the bootstrap file named one), then rdap.org, then a
+// WHOIS-based fallback.
+func (r *Resolver) providerChain(domain string) []providerTarget {
+	var chain []providerTarget
+
+	if base, ok := r.authoritativeBase(domain); ok {
+		chain = append(chain, providerTarget{ProviderAuthoritative, base})
+	}
+	chain = append(chain, providerTarget{ProviderRDAPOrg, "https://rdap.org"})
+	chain = append(chain, providerTarget{ProviderWHOIS, "https://whois.iana.org"})
+	return chain
+}
+
+// authoritativeBase returns the RDAP base URL IANA's bootstrap file names
+// for domain's TLD, refreshing the bootstrap file first if it's stale.
+func (r *Resolver) authoritativeBase(domain string) (string, bool) {
+	r.ensureBootstrap()
+
+	tld := tldOf(domain)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bases, ok := r.bootstrap[tld]
+	if !ok || len(bases) == 0 {
+		return "", false
+	}
+	return strings.TrimSuffix(bases[0], "/"), true
+}
+
+func tldOf(domain string) string {
+	domain = strings.TrimSuffix(domain, ".")
+	parts := strings.Split(domain, ".")
+	return strings.ToLower(parts[len(parts)-1])
+}
+
+// ensureBootstrap fetches data.iana.org/rdap/dns.json if it's never been
+// fetched or bootstrapTTL has elapsed. Failures are swallowed — the
+// provider chain just skips straight to rdap.org for this and future
+// lookups until a fetch eventually succeeds.
+func (r *Resolver) ensureBootstrap() {
+	r.mu.Lock()
+	stale := time.Since(r.bootstrapAt) >= bootstrapTTL
+	r.mu.Unlock()
+	if !stale {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bootstrapClient)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bootstrapURL, nil)
+	if err != nil {
+		return
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	var doc struct {
+		Services [][][]string `json:"services"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return
+	}
+
+	parsed := make(map[string][]string)
+	for _, svc := range doc.Services {
+		if len(svc) != 2 {
+			continue
+		}
+		tlds, bases := svc[0], svc[1]
+		for _, tld := range tlds {
+			parsed[strings.ToLower(tld)] = bases
+		}
+	}
+
+	r.mu.Lock()
+	r.bootstrap = parsed
+	r.bootstrapAt = time.Now()
+	r.mu.Unlock()
+}
+
+// query performs one provider's lookup. The WHOIS provider parses a
+// freeform WHOIS response for "Name Server:" lines instead of RDAP JSON,
+// since whois.iana.org has no RDAP endpoint of its own.
+func (r *Resolver) query(ctx context.Context, provider Provider, baseURL, domain string) ([]string, error) {
+	if provider == ProviderWHOIS {
+		return r.queryWHOIS(domain)
+	}
+	return r.queryRDAPWithRetry(ctx, baseURL, domain)
+}
+
+// queryRDAPWithRetry retries queryRDAP against the same provider up to
+// maxRetries times when it hits a 429/503, honoring that response's
+// Retry-After (capped at maxRetryWait) before trying again. Any other
+// error, or retries exhausted, falls through to the next provider in the
+// chain.
+func (r *Resolver) queryRDAPWithRetry(ctx context.Context, baseURL, domain string) ([]string, error) {
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		ns, retryAfter, err := r.queryRDAP(baseURL, domain)
+		if err == nil {
+			return ns, nil
+		}
+		lastErr = err
+		if retryAfter <= 0 || attempt == maxRetries {
+			break
+		}
+		select {
+		case <-time.After(retryAfter):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, lastErr
+}
+
+// queryRDAP performs a single RDAP request. On a 429/503 it also returns
+// how long the caller should wait before retrying (parsed from
+// Retry-After, capped at maxRetryWait, or a small default if the header is
+// missing or unparsable).
+func (r *Resolver) queryRDAP(baseURL, domain string) ([]string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/domain/%s", strings.TrimSuffix(baseURL, "/"), domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		return nil, retryAfterOrDefault(resp.Header.Get("Retry-After")), fmt.Errorf("%s returned %d", baseURL, resp.StatusCode)
+	}
+	if resp.StatusCode >= 500 {
+		return nil, 0, fmt.Errorf("%s returned %d", baseURL, resp.StatusCode)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, 0, fmt.Errorf("domain not found in registry")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("%s returned %d", baseURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var parsed rdapResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, 0, err
+	}
+
+	// A registrar referral (rel=related) means this server doesn't hold the
+	// record itself — follow it once rather than reporting an empty NS set.
+	if len(parsed.Nameservers) == 0 {
+		for _, link := range parsed.Links {
+			if link.Rel == "related" {
+				ns, err := r.queryRDAPURL(link.Href)
+				return ns, 0, err
+			}
+		}
+	}
+
+	var nameservers []string
+	for _, ns := range parsed.Nameservers {
+		nameservers = append(nameservers, strings.TrimSuffix(ns.LdhName, "."))
+	}
+	return nameservers, 0, nil
+}
+
+// retryAfterOrDefault parses an HTTP Retry-After header (seconds form only
+// — RDAP/registry throttling doesn't use the HTTP-date form in practice)
+// and caps it at maxRetryWait. An empty or unparsable header falls back to
+// a 1s default rather than skipping the retry outright.
+func retryAfterOrDefault(header string) time.Duration {
+	if header == "" {
+		return time.Second
+	}
+	secs, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || secs <= 0 {
+		return time.Second
+	}
+	wait := time.Duration(secs) * time.Second
+	if wait > maxRetryWait {
+		return maxRetryWait
+	}
+	return wait
+}
+
+func (r *Resolver) queryRDAPURL(fullURL string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed rdapResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+
+	var nameservers []string
+	for _, ns := range parsed.Nameservers {
+		nameservers = append(nameservers, strings.TrimSuffix(ns.LdhName, "."))
+	}
+	return nameservers, nil
+}
+
+// queryWHOIS asks whois.iana.org's RDAP-less plaintext WHOIS port for
+// domain and parses out its "Name Server:" lines — the last resort when
+// both the authoritative RDAP server and rdap.org are unavailable.
+func (r *Resolver) queryWHOIS(domain string) ([]string, error) {
+	conn, err := (&net.Dialer{Timeout: lookupTimeout}).Dial("tcp", "whois.iana.org:43")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(lookupTimeout))
+	if _, err := conn.Write([]byte(domain + "\r\n")); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var nameservers []string
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(strings.ToLower(line), "name server:") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			nameservers = append(nameservers, strings.TrimSuffix(strings.TrimSpace(parts[1]), "."))
+		}
+	}
+	if len(nameservers) == 0 {
+		return nil, fmt.Errorf("no nameservers found in WHOIS response for %s", domain)
+	}
+	return nameservers, nil
+}
+
+// cacheGet returns a still-fresh cached nameserver set for domain, if any.
+func (r *Resolver) cacheGet(domain string) ([]string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), database.TimeoutDuration)
+	defer cancel()
+
+	var doc cachedLookup
+	err := r.mongo.Database(database.DBName).Collection(cacheCollection).
+		FindOne(ctx, bson.M{"_id": domain, "expires_at": bson.M{"$gt": time.Now()}}).Decode(&doc)
+	if err != nil {
+		return nil, false
+	}
+	return doc.Nameservers, true
+}
+
+// cacheSet records a successful lookup so the next verification attempt
+// for domain within cacheTTL skips every upstream provider.
+func (r *Resolver) cacheSet(domain string, nameservers []string, provider Provider) {
+	ctx, cancel := context.WithTimeout(context.Background(), database.TimeoutDuration)
+	defer cancel()
+
+	doc := cachedLookup{
+		Domain:      domain,
+		Nameservers: nameservers,
+		Provider:    string(provider),
+		CachedAt:    time.Now(),
+		ExpiresAt:   time.Now().Add(cacheTTL),
+	}
+	_, _ = r.mongo.Database(database.DBName).Collection(cacheCollection).
+		ReplaceOne(ctx, bson.M{"_id": domain}, doc, options.Replace().SetUpsert(true))
+}
+
+// Stats snapshots the resolver's cache hit rate and per-provider error
+// counts, for SystemStatus.
+func (r *Resolver) Stats() Stats {
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+
+	errs := make(map[Provider]int64, len(r.stats.errors))
+	for k, v := range r.stats.errors {
+		errs[k] = v
+	}
+	return Stats{
+		CacheHits:    r.stats.cacheHits,
+		CacheMisses:  r.stats.cacheMisses,
+		ProviderErrs: errs,
+	}
+}
@@ -0,0 +1,172 @@
+package dnsprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/core"
+)
+
+const cloudflareAPIBase = "https://api.cloudflare.com/client/v4"
+
+// CloudflareProvider implements core.DNSProvider against Cloudflare's v4
+// REST API, for operators who host their zone there instead of our
+// PowerDNS instance.
+type CloudflareProvider struct {
+	apiToken string
+	zoneIDs  map[string]string // domain name -> Cloudflare zone id
+	client   *http.Client
+}
+
+// NewCloudflareProvider authenticates as "Authorization: Bearer <apiToken>";
+// zoneIDs maps every domain this gateway manages on Cloudflare to the zone
+// id that owns it.
+func NewCloudflareProvider(apiToken string, zoneIDs map[string]string) *CloudflareProvider {
+	return &CloudflareProvider{
+		apiToken: apiToken,
+		zoneIDs:  zoneIDs,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *CloudflareProvider) zoneID(domain string) (string, error) {
+	id, ok := p.zoneIDs[domain]
+	if !ok {
+		return "", fmt.Errorf("cloudflare: no zone id configured for %s", domain)
+	}
+	return id, nil
+}
+
+type cfRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl,omitempty"`
+}
+
+type cfResponse struct {
+	Success bool            `json:"success"`
+	Errors  []cfError       `json:"errors"`
+	Result  json.RawMessage `json:"result"`
+}
+
+type cfError struct {
+	Message string `json:"message"`
+}
+
+func (p *CloudflareProvider) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, cloudflareAPIBase+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var decoded cfResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("cloudflare: failed to decode response: %w", err)
+	}
+	if !decoded.Success {
+		if len(decoded.Errors) > 0 {
+			return fmt.Errorf("cloudflare: %s", decoded.Errors[0].Message)
+		}
+		return fmt.Errorf("cloudflare: request failed with status %d", resp.StatusCode)
+	}
+	if out != nil {
+		return json.Unmarshal(decoded.Result, out)
+	}
+	return nil
+}
+
+// EnsureZone creates nothing: Cloudflare zones are created through their
+// dashboard/account API out of band, not per-domain by this gateway. It
+// only returns an error when no zone id is configured for domain.
+func (p *CloudflareProvider) EnsureZone(ctx context.Context, domain string) error {
+	_, err := p.zoneID(domain)
+	return err
+}
+
+func (p *CloudflareProvider) CreateRecord(ctx context.Context, domain string, record core.DNSRecord) (string, error) {
+	zoneID, err := p.zoneID(domain)
+	if err != nil {
+		return "", err
+	}
+
+	var created cfRecord
+	err = p.do(ctx, http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", zoneID), cfRecord{
+		Type:    record.Type,
+		Name:    record.Name,
+		Content: record.Content,
+		TTL:     record.TTL,
+	}, &created)
+	if err != nil {
+		return "", fmt.Errorf("cloudflare: failed to create record: %w", err)
+	}
+	return created.ID, nil
+}
+
+// DeleteRecordsByType lists every record of recordType in the zone and
+// deletes each one, since Cloudflare's API has no bulk-delete-by-type call.
+func (p *CloudflareProvider) DeleteRecordsByType(ctx context.Context, domain, recordType string) error {
+	zoneID, err := p.zoneID(domain)
+	if err != nil {
+		return err
+	}
+
+	var records []cfRecord
+	if err := p.do(ctx, http.MethodGet, fmt.Sprintf("/zones/%s/dns_records?type=%s", zoneID, recordType), nil, &records); err != nil {
+		return fmt.Errorf("cloudflare: failed to list records: %w", err)
+	}
+
+	for _, r := range records {
+		if err := p.do(ctx, http.MethodDelete, fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, r.ID), nil, nil); err != nil {
+			return fmt.Errorf("cloudflare: failed to delete record %s: %w", r.ID, err)
+		}
+	}
+	return nil
+}
+
+func (p *CloudflareProvider) ListRecords(ctx context.Context, domain string) ([]core.DNSRecord, error) {
+	zoneID, err := p.zoneID(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []cfRecord
+	if err := p.do(ctx, http.MethodGet, fmt.Sprintf("/zones/%s/dns_records", zoneID), nil, &raw); err != nil {
+		return nil, fmt.Errorf("cloudflare: failed to list records: %w", err)
+	}
+
+	records := make([]core.DNSRecord, 0, len(raw))
+	for _, r := range raw {
+		records = append(records, core.DNSRecord{
+			ID:      r.ID,
+			Name:    r.Name,
+			Type:    r.Type,
+			Content: r.Content,
+			TTL:     r.TTL,
+		})
+	}
+	return records, nil
+}
@@ -0,0 +1,54 @@
+package dnsprovider
+
+import (
+	"fmt"
+
+	"web-app-firewall-ml-detection/internal/core"
+	"web-app-firewall-ml-detection/internal/repository/sql"
+)
+
+// Kind is the name stored on core.Domain.DNSProviderKind and used to select
+// a backend, mirroring how lego picks a DNS-01 provider by name.
+type Kind string
+
+const (
+	KindPowerDNS   Kind = "powerdns"
+	KindCloudflare Kind = "cloudflare"
+	KindRoute53    Kind = "route53"
+)
+
+// Registry resolves a Kind to the concrete core.DNSProvider that should
+// handle a domain's records, so handlers never construct a provider
+// directly — they ask the registry once per domain.
+type Registry struct {
+	providers map[Kind]core.DNSProvider
+	fallback  Kind
+}
+
+// NewRegistry builds a Registry seeded with every provider an operator has
+// configured credentials for; sqlRepo's PowerDNSProvider is always present
+// and is the fallback for domains with an empty DNSProviderKind. An unknown
+// kind is not given the fallback: For reports it as an error.
+func NewRegistry(sqlRepo *sql.DNSRepository, external map[Kind]core.DNSProvider) *Registry {
+	providers := map[Kind]core.DNSProvider{
+		KindPowerDNS: NewPowerDNSProvider(sqlRepo),
+	}
+	for kind, p := range external {
+		providers[kind] = p
+	}
+	return &Registry{providers: providers, fallback: KindPowerDNS}
+}
+
+// For returns the provider a domain should use, given the Kind stored on
+// its core.Domain (empty falls back to PowerDNS).
+func (r *Registry) For(kind string) (core.DNSProvider, error) {
+	k := Kind(kind)
+	if k == "" {
+		k = r.fallback
+	}
+	p, ok := r.providers[k]
+	if !ok {
+		return nil, fmt.Errorf("dnsprovider: unknown provider kind %q", kind)
+	}
+	return p, nil
+}
@@ -0,0 +1,44 @@
+// Package dnsprovider implements core.DNSProvider, the pluggable
+// authoritative-DNS backend the AddDomain/VerifyDomain/ManageRecords/
+// ToggleProxyMode split-brain logic mutates through. PowerDNS (the existing
+// sql.DNSRepository) is the default; Cloudflare and Route53 let an operator
+// migrate a zone to an externally-hosted DNS provider without any handler
+// change.
+package dnsprovider
+
+import (
+	"context"
+
+	"web-app-firewall-ml-detection/internal/core"
+	"web-app-firewall-ml-detection/internal/repository/sql"
+)
+
+// PowerDNSProvider is a thin adapter from core.DNSProvider onto the existing
+// sql.DNSRepository, so domains that don't opt into an external backend see
+// no behavior change.
+type PowerDNSProvider struct {
+	repo *sql.DNSRepository
+}
+
+func NewPowerDNSProvider(repo *sql.DNSRepository) *PowerDNSProvider {
+	return &PowerDNSProvider{repo: repo}
+}
+
+func (p *PowerDNSProvider) EnsureZone(ctx context.Context, domain string) error {
+	return p.repo.EnsureZone(ctx, domain)
+}
+
+// CreateRecord publishes record under zone domain. record.Name carries the
+// actual FQDN being published (which may be a subdomain of domain, e.g. an
+// ACME challenge label); domain only identifies which zone owns it.
+func (p *PowerDNSProvider) CreateRecord(ctx context.Context, domain string, record core.DNSRecord) (string, error) {
+	return p.repo.CreateRecord(ctx, record)
+}
+
+func (p *PowerDNSProvider) DeleteRecordsByType(ctx context.Context, domain, recordType string) error {
+	return p.repo.DeleteRecordsByType(ctx, domain, recordType)
+}
+
+func (p *PowerDNSProvider) ListRecords(ctx context.Context, domain string) ([]core.DNSRecord, error) {
+	return p.repo.GetRecords(ctx, domain)
+}
@@ -0,0 +1,129 @@
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+
+	"web-app-firewall-ml-detection/internal/core"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Route53Provider implements core.DNSProvider against an AWS Route53
+// hosted zone, for operators who already run authoritative DNS there.
+type Route53Provider struct {
+	client     *route53.Client
+	hostedZone map[string]string // domain name -> hosted zone id
+}
+
+func NewRoute53Provider(client *route53.Client, hostedZone map[string]string) *Route53Provider {
+	return &Route53Provider{client: client, hostedZone: hostedZone}
+}
+
+func (p *Route53Provider) zoneID(domain string) (string, error) {
+	id, ok := p.hostedZone[domain]
+	if !ok {
+		return "", fmt.Errorf("route53: no hosted zone configured for %s", domain)
+	}
+	return id, nil
+}
+
+// EnsureZone creates nothing: hosted zones are provisioned out of band
+// through the AWS account, not per-domain by this gateway. It only returns
+// an error when no hosted zone is configured for domain.
+func (p *Route53Provider) EnsureZone(ctx context.Context, domain string) error {
+	_, err := p.zoneID(domain)
+	return err
+}
+
+func (p *Route53Provider) CreateRecord(ctx context.Context, domain string, record core.DNSRecord) (string, error) {
+	zoneID, err := p.zoneID(domain)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = p.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &types.ChangeBatch{
+			Changes: []types.Change{{
+				Action: types.ChangeActionUpsert,
+				ResourceRecordSet: &types.ResourceRecordSet{
+					Name:            aws.String(record.Name),
+					Type:            types.RRType(record.Type),
+					TTL:             aws.Int64(int64(record.TTL)),
+					ResourceRecords: []types.ResourceRecord{{Value: aws.String(record.Content)}},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("route53: failed to upsert record: %w", err)
+	}
+	// Route53 record sets are keyed by name+type, not an opaque id.
+	return record.Name + ":" + record.Type, nil
+}
+
+func (p *Route53Provider) DeleteRecordsByType(ctx context.Context, domain, recordType string) error {
+	records, err := p.ListRecords(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := p.zoneID(domain)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if r.Type != recordType {
+			continue
+		}
+		_, err := p.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneID),
+			ChangeBatch: &types.ChangeBatch{
+				Changes: []types.Change{{
+					Action: types.ChangeActionDelete,
+					ResourceRecordSet: &types.ResourceRecordSet{
+						Name:            aws.String(r.Name),
+						Type:            types.RRType(r.Type),
+						TTL:             aws.Int64(int64(r.TTL)),
+						ResourceRecords: []types.ResourceRecord{{Value: aws.String(r.Content)}},
+					},
+				}},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("route53: failed to delete record %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func (p *Route53Provider) ListRecords(ctx context.Context, domain string) ([]core.DNSRecord, error) {
+	zoneID, err := p.zoneID(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := p.client.ListResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("route53: failed to list records: %w", err)
+	}
+
+	var records []core.DNSRecord
+	for _, rs := range out.ResourceRecordSets {
+		for _, rr := range rs.ResourceRecords {
+			records = append(records, core.DNSRecord{
+				Name:    aws.ToString(rs.Name),
+				Type:    string(rs.Type),
+				Content: aws.ToString(rr.Value),
+				TTL:     int(aws.ToInt64(rs.TTL)),
+			})
+		}
+	}
+	return records, nil
+}
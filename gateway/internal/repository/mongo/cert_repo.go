@@ -0,0 +1,49 @@
+package mongo
+
+import (
+	"context"
+
+	"web-app-firewall-ml-detection/internal/core"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CertificateRepository struct {
+	db *mongo.Database
+}
+
+func NewCertificateRepository(client *mongo.Client) *CertificateRepository {
+	return &CertificateRepository{
+		db: client.Database("waf"),
+	}
+}
+
+// Upsert replaces the stored certificate for cert.Domain, creating it if
+// this is the domain's first issuance.
+func (r *CertificateRepository) Upsert(ctx context.Context, cert core.Certificate) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := r.db.Collection("certificates").ReplaceOne(ctx, bson.M{"_id": cert.Domain}, cert, opts)
+	return err
+}
+
+func (r *CertificateRepository) GetByDomain(ctx context.Context, domain string) (*core.Certificate, error) {
+	var cert core.Certificate
+	err := r.db.Collection("certificates").FindOne(ctx, bson.M{"_id": domain}).Decode(&cert)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func (r *CertificateRepository) GetAll(ctx context.Context) ([]core.Certificate, error) {
+	cursor, err := r.db.Collection("certificates").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var certs []core.Certificate
+	err = cursor.All(ctx, &certs)
+	return certs, err
+}
@@ -155,6 +155,20 @@ func (r *DomainRepository) GetOriginRecord(ctx context.Context, host string) (*c
 	// Try CNAME
 	err = r.db.Collection("dns_records").FindOne(ctx, bson.M{"name": host, "type": "CNAME"}).Decode(&record)
 	if err == nil { return &record, nil }
-	
+
 	return nil, err
+}
+
+func (r *DomainRepository) GetOriginPool(ctx context.Context, host string) ([]core.DNSRecord, error) {
+	cursor, err := r.db.Collection("dns_records").Find(ctx, bson.M{
+		"name": host,
+		"type": bson.M{"$in": []string{"A", "AAAA"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var records []core.DNSRecord
+	err = cursor.All(ctx, &records)
+	return records, err
 }
\ No newline at end of file
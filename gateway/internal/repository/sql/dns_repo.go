@@ -103,9 +103,45 @@ func (r *DNSRepository) GetRecords(ctx context.Context, domainName string) ([]co
 // DeleteRecord removes a record by its ID.
 func (r *DNSRepository) DeleteRecord(ctx context.Context, id string) error {
 	_, err := r.db.ExecContext(ctx, "DELETE FROM records WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete record %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteRecordsByType removes every record of rType for domainName, used by
+// the proxy-toggle split-brain swap (e.g. clearing A/CNAME before switching
+// between "exposed" and "behind the WAF IP").
+func (r *DNSRepository) DeleteRecordsByType(ctx context.Context, domainName, rType string) error {
+	query := `
+		DELETE r FROM records r
+		JOIN domains d ON r.domain_id = d.id
+		WHERE d.name = ? AND r.type = ?`
+	_, err := r.db.ExecContext(ctx, query, domainName, rType)
 	return err
 }
 
+// EnsureZone makes sure domainName exists in the PowerDNS "domains" table,
+// creating it if this is the first record ever published for it. CreateRecord
+// already does this inline; EnsureZone exists so callers that only need the
+// zone (no record yet) don't have to fake one.
+func (r *DNSRepository) EnsureZone(ctx context.Context, domainName string) error {
+	var id int64
+	err := r.db.QueryRowContext(ctx, "SELECT id FROM domains WHERE name = ?", domainName).Scan(&id)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("failed to lookup domain: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, "INSERT INTO domains (name, type) VALUES (?, 'NATIVE')", domainName)
+	if err != nil {
+		return fmt.Errorf("failed to create zone: %w", err)
+	}
+	return nil
+}
+
 // GetRecordByID fetches a single record.
 func (r *DNSRepository) GetRecordByID(ctx context.Context, id string) (*core.DNSRecord, error) {
 	query := "SELECT id, name, type, content, ttl FROM records WHERE id = ?"
@@ -114,7 +150,7 @@ func (r *DNSRepository) GetRecordByID(ctx context.Context, id string) (*core.DNS
 	var intID int64
 	err := r.db.QueryRowContext(ctx, query, id).Scan(&intID, &rec.Name, &rec.Type, &rec.Content, &rec.TTL)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get record %s: %w", id, err)
 	}
 	rec.ID = fmt.Sprintf("%d", intID)
 	return &rec, nil
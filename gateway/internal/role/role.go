@@ -0,0 +1,44 @@
+// Package role defines the per-domain access hierarchy used to decide
+// which members may view or mutate a domain's rules, DNS records, and
+// policies.
+package role
+
+// Role is a per-(user, domain) grant stored in the domain_members
+// collection. Higher roles satisfy every lower role's requirements.
+type Role string
+
+const (
+	Owner  Role = "owner"  // Created the domain. Can manage membership.
+	Admin  Role = "admin"  // Full control except membership management.
+	Editor Role = "editor" // Can mutate rules/DNS records.
+	Viewer Role = "viewer" // Read-only access.
+)
+
+// rank orders roles from least to most privileged so two roles can be
+// compared directly.
+var rank = map[Role]int{
+	Viewer: 0,
+	Editor: 1,
+	Admin:  2,
+	Owner:  3,
+}
+
+// Valid reports whether r is one of the known roles.
+func Valid(r Role) bool {
+	_, ok := rank[r]
+	return ok
+}
+
+// Satisfies reports whether r meets or exceeds the minimum required role.
+// An unknown role never satisfies anything.
+func Satisfies(r, min Role) bool {
+	have, ok := rank[r]
+	if !ok {
+		return false
+	}
+	need, ok := rank[min]
+	if !ok {
+		return false
+	}
+	return have >= need
+}
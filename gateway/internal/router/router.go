@@ -27,6 +27,11 @@ func Setup(apiHandler *api.APIHandler) *http.ServeMux {
 	mux.HandleFunc("/api/domains", middleware.Auth(apiHandler.ListDomains))
 	mux.HandleFunc("/api/domains/add", middleware.Auth(apiHandler.AddDomain))
 	mux.HandleFunc("/api/domains/verify", middleware.Auth(apiHandler.VerifyDomain))
+	mux.HandleFunc("/api/domains/cert", middleware.Auth(apiHandler.CertStatus))
+	mux.HandleFunc("/api/domains/cert/renew", middleware.Auth(apiHandler.ForceRenewCert))
+	mux.HandleFunc("/api/domains/dnssec/ds", middleware.Auth(apiHandler.DSRecords))
+	mux.HandleFunc("/api/domains/dnssec/rotate", middleware.Auth(apiHandler.RotateDSKey))
+	mux.HandleFunc("/api/domains/dnssec/promote", middleware.Auth(apiHandler.PromoteDSKey))
 
 	// DNS Record Management (Protected)
 	mux.HandleFunc("/api/dns/records", middleware.Auth(apiHandler.ManageRecords))
@@ -34,15 +34,19 @@ func (s *AuthService) Register(input models.UserInput) error {
 		return err
 	}
 
-	// Generate a simple token (current timestamp in nanos)
-	token := fmt.Sprintf("%d", time.Now().UnixNano())
+	// rawToken is what's emailed to the user; only its hash is persisted
+	// (see database.VerifyUserToken), so a database dump alone can't be
+	// replayed as a working verification link.
+	rawToken := fmt.Sprintf("%d", time.Now().UnixNano())
+	issuedAt := time.Now()
 
 	user := models.User{
-		Name:              input.Name,
-		Email:             input.Email,
-		Password:          string(hashed),
-		IsVerified:        false, 
-		VerificationToken: token, 
+		Name:                      input.Name,
+		Email:                     input.Email,
+		Password:                  string(hashed),
+		IsVerified:                false,
+		VerificationToken:         database.HashVerificationToken(rawToken),
+		VerificationTokenIssuedAt: issuedAt,
 	}
 
 	if err := database.CreateUser(s.Mongo, user); err != nil {
@@ -50,7 +54,7 @@ func (s *AuthService) Register(input models.UserInput) error {
 	}
 
 	// Send Verification Email
-	s.Notifier.SendSignupVerification(user.Email, user.Name, token)
+	s.Notifier.SendSignupVerification(user.Email, user.Name, rawToken)
 
 	return nil
 }
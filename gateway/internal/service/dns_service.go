@@ -9,6 +9,7 @@ import (
 
 	"web-app-firewall-ml-detection/internal/config"
 	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/dnsbackend"
 	"web-app-firewall-ml-detection/internal/models" // [ADDED]
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -16,15 +17,31 @@ import (
 
 var domainRegex = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
 
+// DNSService publishes through a dnsbackend.Provider instead of calling
+// internal/database's PowerDNS functions directly, so it gets the same
+// Cloudflare/Route53/PowerDNS selection internal/api/dns.go already has
+// (see internal/dnsbackend and cmd/server/main.go's cfg.DNSProvider
+// switch) instead of a second, DNSService-only backend implementation.
 type DNSService struct {
-	Mongo *mongo.Client
-	Cfg   *config.Config
+	Mongo    *mongo.Client
+	Cfg      *config.Config
+	Provider dnsbackend.Provider
 }
 
-func NewDNSService(client *mongo.Client, cfg *config.Config) *DNSService {
+// NewDNSService defaults Provider to dnsbackend.NewPowerDNSProvider() — the
+// same zero-config default cfg.DNSProvider="" resolves to in main.go — so
+// passing a nil provider here behaves exactly like before this abstraction
+// existed. Pass a dnsbackend.NewCloudflareProvider/NewRoute53Provider built
+// from cfg.Cloudflare/cfg.Route53 to publish this service's records
+// elsewhere.
+func NewDNSService(client *mongo.Client, cfg *config.Config, provider dnsbackend.Provider) *DNSService {
+	if provider == nil {
+		provider = dnsbackend.NewPowerDNSProvider()
+	}
 	return &DNSService{
-		Mongo: client,
-		Cfg:   cfg,
+		Mongo:    client,
+		Cfg:      cfg,
+		Provider: provider,
 	}
 }
 
@@ -113,11 +130,18 @@ func (s *DNSService) AddRecord(req database.DNSRecord, userID string) (*database
 	}
 	newRecord.ID = id
 
-	// 10. Save to PowerDNS
-	err = database.AddPowerDNSRecord(recordName, req.Type, req.Content, req.Proxied, s.Cfg.WafPublicIP)
+	// 10. Publish to the authoritative DNS backend (PowerDNS by default, or
+	// whatever Provider this service was constructed with).
+	externalID, err := s.Provider.UpsertRecord(newRecord, s.Cfg.WafPublicIP, req.Proxied)
 	if err != nil {
 		return nil, fmt.Errorf("DNS Propagation Error: %v", err)
 	}
+	if externalID != "" {
+		newRecord.ExternalID = externalID
+		if err := database.SetDNSRecordExternalID(s.Mongo, id, externalID); err != nil {
+			return nil, fmt.Errorf("failed to persist external id: %v", err)
+		}
+	}
 
 	return &newRecord, nil
 }
@@ -160,8 +184,8 @@ func (s *DNSService) UpdateRecord(recordID, userID string, updateReq models.DNSU
 		typeToDelete = "A"
 	}
 
-	// B. Delete Old from PowerDNS
-	if err := database.DeletePowerDNSRecordByContent(record.Name, typeToDelete, contentToDelete); err != nil {
+	// B. Delete Old from the DNS backend
+	if err := s.Provider.DeleteRecord(record.ExternalID, record.Name, typeToDelete, contentToDelete); err != nil {
 		return nil, fmt.Errorf("failed to delete old DNS entry: %v", err)
 	}
 
@@ -170,10 +194,16 @@ func (s *DNSService) UpdateRecord(recordID, userID string, updateReq models.DNSU
 		return nil, err
 	}
 
-	// D. Add New to PowerDNS
-	if err := database.AddPowerDNSRecord(record.Name, record.Type, record.Content, updateReq.Proxied, s.Cfg.WafPublicIP); err != nil {
+	// D. Add New to the DNS backend
+	externalID, err := s.Provider.UpsertRecord(*record, s.Cfg.WafPublicIP, updateReq.Proxied)
+	if err != nil {
 		return nil, fmt.Errorf("failed to add new DNS entry: %v", err)
 	}
+	if externalID != "" {
+		if err := database.SetDNSRecordExternalID(s.Mongo, recordID, externalID); err != nil {
+			return nil, fmt.Errorf("failed to persist external id: %v", err)
+		}
+	}
 
 	return map[string]interface{}{"proxied": updateReq.Proxied}, nil
 }
@@ -198,8 +228,8 @@ func (s *DNSService) DeleteRecord(recordID, userID string) error {
 		sqlContent = s.Cfg.WafPublicIP
 	}
 
-	// 3. Delete from PowerDNS
-	if err := database.DeletePowerDNSRecordByContent(record.Name, sqlType, sqlContent); err != nil {
+	// 3. Delete from the DNS backend
+	if err := s.Provider.DeleteRecord(record.ExternalID, record.Name, sqlType, sqlContent); err != nil {
 		return fmt.Errorf("backend delete failed: %v", err)
 	}
 
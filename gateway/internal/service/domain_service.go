@@ -2,17 +2,16 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"math/rand"
-	"net/http"
 	"strings"
 	"time"
 
+	"web-app-firewall-ml-detection/internal/acme"
 	"web-app-firewall-ml-detection/internal/database"
 	"web-app-firewall-ml-detection/internal/models"
+	"web-app-firewall-ml-detection/internal/rdap"
 
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -24,19 +23,22 @@ var realNameservers = []string{
 
 const nsSuffix = ".ns.minishield.tech"
 
-// RDAP Response Structure
-type RDAPResponse struct {
-	Nameservers []struct {
-		LdhName string `json:"ldhName"`
-	} `json:"nameservers"`
-}
-
 type DomainService struct {
 	Mongo *mongo.Client
+
+	// CertManager issues/renews the domain's ACME certificate once
+	// VerifyDomainOwner activates it. Nil disables auto-issuance (e.g. a
+	// deployment that only ever uploads custom certs).
+	CertManager *acme.Manager
+
+	// RDAP resolves a domain's live nameservers for VerifyDomainOwner,
+	// falling back through rdap.org/WHOIS when the TLD's own authoritative
+	// RDAP server is unavailable.
+	RDAP *rdap.Resolver
 }
 
-func NewDomainService(client *mongo.Client) *DomainService {
-	return &DomainService{Mongo: client}
+func NewDomainService(client *mongo.Client, certManager *acme.Manager, rdapResolver *rdap.Resolver) *DomainService {
+	return &DomainService{Mongo: client, CertManager: certManager, RDAP: rdapResolver}
 }
 
 func (s *DomainService) ListDomains(userID string) ([]models.Domain, error) {
@@ -84,6 +86,13 @@ func (s *DomainService) AddDomain(input models.DomainInput, userID string) (*mod
 	if err != nil {
 		// Log error but continue, or return error depending on strictness
 		fmt.Printf("ERROR: Failed to create DNS Zone: %v\n", err)
+	} else {
+		// 5. Generate the zone's KSK/ZSK so PowerDNS signs its responses
+		// from day one — the DS record the owner still needs to publish
+		// at their registrar comes back out of VerifyDomainOwner.
+		if _, err := database.GenerateZoneKeys(s.Mongo, domain.Name); err != nil {
+			fmt.Printf("ERROR: Failed to generate DNSSEC keys for %s: %v\n", domain.Name, err)
+		}
 	}
 
 	return &createdDomain, nil
@@ -101,16 +110,16 @@ func (s *DomainService) VerifyDomainOwner(domainID, userID string) (bool, map[st
 	}
 
 	// 2. Check RDAP (The Security Check)
-	foundNS, err := s.checkRegistrarRDAP(domain.Name)
+	rdapResp, err := s.RDAP.Lookup(context.Background(), domain.Name)
 	if err != nil {
-		return false, nil, fmt.Errorf("RDAP verification unavailable: %v", err)
+		return false, nil, fmt.Errorf("RDAP verification unavailable: %w", err)
 	}
 
 	// 3. Compare Found NS vs Assigned NS
 	matchedCount := 0
 	for _, assignedNS := range domain.Nameservers {
 		found := false
-		for _, liveNS := range foundNS {
+		for _, liveNS := range rdapResp.Nameservers {
 			if strings.EqualFold(liveNS, assignedNS) {
 				found = true
 				break
@@ -131,60 +140,38 @@ func (s *DomainService) VerifyDomainOwner(domainID, userID string) (bool, map[st
 		if err := database.UpdateDomainStatus(s.Mongo, domain.ID, "active"); err != nil {
 			return false, nil, err
 		}
-		return true, nil, nil
+
+		// 6. Kick off certificate issuance in the background, matching the
+		// fire-and-forget provisioning pattern handler.DomainHandler uses
+		// for its own post-activation work. A slow/failed ACME order must
+		// not hold up the verification response.
+		if s.CertManager != nil && domain.EffectiveTLSMode() == "acme" {
+			go func(name string) {
+				if err := s.CertManager.IssueOrRenew(name); err != nil {
+					fmt.Printf("ERROR: ACME issuance failed for %s: %v\n", name, err)
+				}
+			}(domain.Name)
+		}
+
+		// 7. Hand back the DS record set alongside the "verified" result so
+		// the UI can immediately tell the owner what to publish at their
+		// registrar to close the NS-only trust gap DNSSEC exists for.
+		ds, err := database.CurrentDSRecords(s.Mongo, domain.Name)
+		if err != nil {
+			fmt.Printf("WARN: Failed to load DS records for %s: %v\n", domain.Name, err)
+			return true, nil, nil
+		}
+		return true, map[string]interface{}{"ds_records": ds}, nil
 	}
 
 	// Return details for the UI to show what went wrong
 	details := map[string]interface{}{
 		"assigned_ns":        domain.Nameservers,
-		"found_at_registrar": foundNS,
+		"found_at_registrar": rdapResp.Nameservers,
 	}
 	return false, details, nil
 }
 
-// Helper: RDAP Lookup
-func (s *DomainService) checkRegistrarRDAP(domain string) ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	url := fmt.Sprintf("https://rdap.org/domain/%s", domain)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/rdap+json")
-
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 404 {
-		return nil, fmt.Errorf("domain not found in registry")
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var rdapResp RDAPResponse
-	if err := json.Unmarshal(body, &rdapResp); err != nil {
-		return nil, err
-	}
-
-	var nameservers []string
-	for _, ns := range rdapResp.Nameservers {
-		cleanName := strings.TrimSuffix(ns.LdhName, ".")
-		nameservers = append(nameservers, cleanName)
-	}
-
-	return nameservers, nil
-}
-
 func getRootDomain(domain string) string {
 	parts := strings.Split(domain, ".")
 	if len(parts) < 2 {
@@ -2,11 +2,11 @@ package service
 
 import (
 	"fmt"
-	"log"
 	"sync"
 	"time"
 
 	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/log"
 	"web-app-firewall-ml-detection/internal/utils"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -71,13 +71,13 @@ func (s *NotificationService) SendSignupVerification(email, name, token string)
 	// Send asynchronously
 	go func() {
 		// Log for debugging
-		log.Printf("📧 Sending verification link to %s...", email)
-		
+		log.Debugf("Sending verification link to %s...", email)
+
 		// [REQUIRES email.go UPDATE] We now pass htmlBody AND textBody
 		if err := s.Mailer.Send(email, subject, htmlBody, textBody, "Minishield Verification"); err != nil {
-			log.Printf("[EMAIL ERROR] Failed to send verification to %s: %v", email, err)
+			log.Errorf("Failed to send verification to %s: %v", email, err)
 		} else {
-			log.Printf("✅ Verification email sent to %s", email)
+			log.Infof("Verification email sent to %s", email)
 		}
 	}()
 }
@@ -100,7 +100,7 @@ func (s *NotificationService) NotifyAttack(userID, domainName, attackType, ip st
 		// A. Lookup User Email
 		user, err := database.GetUserByID(s.Mongo, userID)
 		if err != nil {
-			log.Printf("[EMAIL ERROR] Could not find user %s for alert: %v", userID, err)
+			log.Errorf("Could not find user %s for alert: %v", userID, err)
 			return
 		}
 
@@ -145,9 +145,9 @@ func (s *NotificationService) NotifyAttack(userID, domainName, attackType, ip st
 
 		// C. Send Email (Pass 5 Arguments: email, subject, html, text, senderName)
 		if err := s.Mailer.Send(user.Email, subject, htmlBody, textBody, "Minishield Security"); err != nil {
-			log.Printf("[EMAIL ERROR] Failed to send alert to %s: %v", user.Email, err)
+			log.Errorf("Failed to send alert to %s: %v", user.Email, err)
 		} else {
-			log.Printf("📧 Alert sent to %s regarding %s", user.Email, domainName)
+			log.Infof("Alert sent to %s regarding %s", user.Email, domainName)
 		}
 	}()
 }
\ No newline at end of file
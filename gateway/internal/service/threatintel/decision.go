@@ -0,0 +1,19 @@
+package threatintel
+
+import "time"
+
+// Decision is one banned IP or CIDR pulled from the community feed, keyed
+// by Value in Store's trie. Locally generated blocks are not Decisions:
+// Feed.Push sends them out as a feedEntry.
+type Decision struct {
+	Value     string
+	Type      string // "ban", matching the feed's vocabulary
+	Scope     string // "Ip" or "Range"
+	Scenario  string
+	ExpiresAt time.Time // zero means it never expires
+}
+
+// Expired reports whether d's ban window has passed.
+func (d *Decision) Expired() bool {
+	return !d.ExpiresAt.IsZero() && time.Now().After(d.ExpiresAt)
+}
@@ -0,0 +1,172 @@
+package threatintel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// feedEntry mirrors one row of the community feed's JSON response, and
+// doubles as the reverse stream's wire format so a peer gateway pulling
+// this one's PushURL as its own FeedURL needs no translation.
+type feedEntry struct {
+	Scope    string `json:"scope"`
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+	Duration string `json:"duration"`
+	Scenario string `json:"scenario"`
+}
+
+// Feed polls a CrowdSec-style external blocklist and keeps Store in sync,
+// and separately pushes this gateway's own high-confidence blocks out to
+// PushURL so other nodes subscribed to the same feed benefit from them —
+// the federated-defense half of the subsystem.
+type Feed struct {
+	feedURL  string
+	apiKey   string
+	interval time.Duration
+	pushURL  string
+
+	client *http.Client
+	store  *Store
+}
+
+// NewFeed builds a Feed pulling feedURL (and pushing reverse decisions to
+// pushURL, if set) every interval. apiKey is sent as X-Api-Key on both the
+// pull and the push.
+func NewFeed(feedURL, apiKey string, interval time.Duration, pushURL string) *Feed {
+	return &Feed{
+		feedURL:  feedURL,
+		apiKey:   apiKey,
+		interval: interval,
+		pushURL:  pushURL,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		store:    NewStore(),
+	}
+}
+
+// Store exposes the live decision set CheckRequest consults on the hot
+// path.
+func (f *Feed) Store() *Store {
+	return f.store
+}
+
+// Start launches the background poll loop: an immediate pull, then every
+// f.interval until ctx is cancelled.
+func (f *Feed) Start(ctx context.Context) {
+	go f.run(ctx)
+}
+
+func (f *Feed) run(ctx context.Context) {
+	f.pull(ctx)
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.pull(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (f *Feed) pull(ctx context.Context) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.feedURL, nil)
+	if err != nil {
+		log.Printf("⚠️ threatintel: failed to build feed request: %v", err)
+		return
+	}
+	if f.apiKey != "" {
+		req.Header.Set("X-Api-Key", f.apiKey)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		log.Printf("⚠️ threatintel: feed pull failed: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("⚠️ threatintel: feed returned %s", resp.Status)
+		return
+	}
+
+	var entries []feedEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		log.Printf("⚠️ threatintel: failed to decode feed response: %v", err)
+		return
+	}
+
+	decisions := make([]*Decision, 0, len(entries))
+	for _, e := range entries {
+		decisions = append(decisions, &Decision{
+			Value:     e.Value,
+			Type:      e.Type,
+			Scope:     e.Scope,
+			Scenario:  e.Scenario,
+			ExpiresAt: expiresAt(e.Duration),
+		})
+	}
+	f.store.Load(decisions)
+}
+
+// expiresAt parses a Go-duration-formatted Duration field ("3h59m48s")
+// into an absolute time.Time; an unparsable or empty value never expires
+// rather than dropping the ban.
+func expiresAt(d string) time.Time {
+	if d == "" {
+		return time.Time{}
+	}
+	dur, err := time.ParseDuration(d)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Now().Add(dur)
+}
+
+// Push sends one locally-generated block out to PushURL for federated
+// defense. It's fire-and-forget on its own goroutine: a down or
+// misconfigured sink shouldn't delay or fail the request that triggered
+// it, so errors are only logged. A nil/empty PushURL makes this a no-op.
+func (f *Feed) Push(clientIP, scenario string, ttl time.Duration) {
+	if f.pushURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(feedEntry{
+		Scope:    "Ip",
+		Value:    clientIP,
+		Type:     "ban",
+		Duration: ttl.String(),
+		Scenario: scenario,
+	})
+	if err != nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.pushURL, bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if f.apiKey != "" {
+			req.Header.Set("X-Api-Key", f.apiKey)
+		}
+
+		resp, err := f.client.Do(req)
+		if err != nil {
+			log.Printf("⚠️ threatintel: push failed for %s: %v", clientIP, err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
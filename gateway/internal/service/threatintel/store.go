@@ -0,0 +1,154 @@
+package threatintel
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// node is one bit of a binary trie over an IP's prefix bits. Walking at
+// most 32 (IPv4) or 128 (IPv6) levels gives O(log n) lookups regardless of
+// how many decisions are loaded, and a decision banning a whole CIDR just
+// terminates its branch before the host bits — any address under it
+// matches without needing its own node.
+type node struct {
+	children [2]*node
+	decision *Decision
+}
+
+// snapshot is one immutable version of the trie, covering both address
+// families. Store swaps the whole snapshot atomically on every poll
+// instead of mutating nodes in place, so Lookup on the request hot path
+// never blocks behind a writer.
+type snapshot struct {
+	v4 *node
+	v6 *node
+}
+
+// Store indexes active decisions by IP/CIDR for WAFService.CheckRequest.
+// It is safe for concurrent use: Lookup only ever reads the snapshot
+// current points at, and Load builds the next one off to the side before
+// swapping it in.
+type Store struct {
+	current atomic.Value // *snapshot
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	s := &Store{}
+	s.current.Store(&snapshot{v4: &node{}, v6: &node{}})
+	return s
+}
+
+// Load replaces the active decision set with decisions in one atomic step.
+func (s *Store) Load(decisions []*Decision) {
+	next := &snapshot{v4: &node{}, v6: &node{}}
+	for _, d := range decisions {
+		insert(next, d)
+	}
+	s.current.Store(next)
+}
+
+func insert(snap *snapshot, d *Decision) {
+	bits, isV6, err := prefixBits(d.Value)
+	if err != nil {
+		return
+	}
+
+	root := snap.v4
+	if isV6 {
+		root = snap.v6
+	}
+
+	n := root
+	for _, bit := range bits {
+		idx := 0
+		if bit {
+			idx = 1
+		}
+		if n.children[idx] == nil {
+			n.children[idx] = &node{}
+		}
+		n = n.children[idx]
+	}
+	n.decision = d
+}
+
+// Lookup returns the most specific non-expired decision covering ip, if
+// any — an exact /32 (or /128) ban takes precedence over a wider CIDR that
+// also matches.
+func (s *Store) Lookup(ip string) (*Decision, bool) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, false
+	}
+
+	snap := s.current.Load().(*snapshot)
+
+	var bits []bool
+	root := snap.v4
+	if v4 := addr.To4(); v4 != nil {
+		bits = bitsOf(v4)
+	} else {
+		bits = bitsOf(addr.To16())
+		root = snap.v6
+	}
+
+	n := root
+	var found *Decision
+	if n.decision != nil && !n.decision.Expired() {
+		found = n.decision
+	}
+	for _, bit := range bits {
+		idx := 0
+		if bit {
+			idx = 1
+		}
+		next := n.children[idx]
+		if next == nil {
+			break
+		}
+		n = next
+		if n.decision != nil && !n.decision.Expired() {
+			found = n.decision
+		}
+	}
+
+	if found == nil {
+		return nil, false
+	}
+	return found, true
+}
+
+// prefixBits parses value as an IPv4/IPv6 address or CIDR and returns the
+// bits of its network prefix, most significant first, plus whether it's
+// IPv6.
+func prefixBits(value string) (bits []bool, isV6 bool, err error) {
+	if ip := net.ParseIP(value); ip != nil {
+		if v4 := ip.To4(); v4 != nil {
+			return bitsOf(v4), false, nil
+		}
+		return bitsOf(ip.To16()), true, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, false, fmt.Errorf("threatintel: %q is not a valid IP or CIDR", value)
+	}
+	ones, size := ipNet.Mask.Size()
+	if size == 32 {
+		return bitsOf(ipNet.IP.To4())[:ones], false, nil
+	}
+	return bitsOf(ipNet.IP.To16())[:ones], true, nil
+}
+
+// bitsOf returns ip's bits, most significant first.
+func bitsOf(ip net.IP) []bool {
+	bits := make([]bool, 0, len(ip)*8)
+	for _, b := range ip {
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, b&(1<<uint(i)) != 0)
+		}
+	}
+	return bits
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
@@ -11,10 +12,21 @@ import (
 
 	"web-app-firewall-ml-detection/internal/core"
 	"web-app-firewall-ml-detection/internal/service/detector"
+	"web-app-firewall-ml-detection/internal/service/threatintel"
 	"web-app-firewall-ml-detection/internal/utils/limiter"
 	"web-app-firewall-ml-detection/internal/utils/logger"
 )
 
+// repeatBlockWindow/repeatBlockThreshold gate the reverse decision stream:
+// a single Rule Violation is routine noise, but the same IP racking up
+// repeatBlockThreshold of them within repeatBlockWindow looks like an
+// attacker worth warning the rest of the federation about.
+const (
+	repeatBlockWindow    = time.Minute
+	repeatBlockThreshold = 3
+	repeatBlockPushTTL   = 1 * time.Hour
+)
+
 type WAFService struct {
 	domainRepo  core.DomainRepository
 	ruleRepo    core.RuleRepository
@@ -22,21 +34,33 @@ type WAFService struct {
 	mlURL       string
 	rateLimiter *limiter.RateLimiter
 
+	// threatIntel is the community blocklist CheckRequest consults before
+	// rule evaluation, and the sink locally-generated blocks are pushed
+	// back out to. Nil disables both halves of the subsystem.
+	threatIntel *threatintel.Feed
+
 	// Cache
 	mu          sync.RWMutex
 	domainMap   map[string]core.Domain    // Host -> Domain Config
 	domainRules map[string][]core.WAFRule // Host -> Active Rules
+
+	// blockHistMu/blockHist track recent Rule Violation blocks per IP so
+	// pushIfRepeatOffender can tell a one-off from a repeat offender.
+	blockHistMu sync.Mutex
+	blockHist   map[string][]time.Time
 }
 
-func NewWAFService(d core.DomainRepository, r core.RuleRepository, l core.LogRepository, mlURL string, rateLimiter *limiter.RateLimiter) *WAFService {
+func NewWAFService(d core.DomainRepository, r core.RuleRepository, l core.LogRepository, mlURL string, rateLimiter *limiter.RateLimiter, ti *threatintel.Feed) *WAFService {
 	s := &WAFService{
 		domainRepo:  d,
 		ruleRepo:    r,
 		logRepo:     l,
 		mlURL:       mlURL,
 		rateLimiter: rateLimiter,
+		threatIntel: ti,
 		domainMap:   make(map[string]core.Domain),
 		domainRules: make(map[string][]core.WAFRule),
+		blockHist:   make(map[string][]time.Time),
 	}
 	s.ReloadRules()
 	return s
@@ -130,6 +154,16 @@ func (s *WAFService) CheckRequest(r *http.Request, clientIP string) (action stri
 		return "404", "Domain not configured"
 	}
 
+	// 0. Threat Intel Check — a community-feed hit short-circuits before
+	// any rule or ML cost is paid.
+	if s.threatIntel != nil {
+		if dec, hit := s.threatIntel.Store().Lookup(clientIP); hit {
+			reason = fmt.Sprintf("ThreatIntel: %s", dec.Scenario)
+			s.logAsync(domain, clientIP, r, "Block", reason, "ThreatIntel", nil, 100, 0, "")
+			return "Block", reason
+		}
+	}
+
 	// 1. Rule Check
 	score, tags, block, payload := detector.CheckRequest(r, rules, isRateLimited)
 
@@ -167,33 +201,75 @@ func (s *WAFService) CheckRequest(r *http.Request, clientIP string) (action stri
 		reason = "Suspicious"
 	}
 
-go func() {
-    ctx, cancel := context.WithTimeout(context. Background(), 5*time.Second)
-    defer cancel()
-
-    logEntry := core.AttackLog{
-        UserID:      domain.UserID,
-        DomainID:    domain.ID,
-        Timestamp:   time.Now(),
-        ClientIP:    clientIP,
-        RequestPath: r.URL.Path,
-        Reason:      reason,
-        Action:      verdict,
-        Source:      "WAF",
-        Tags:        tags,
-        RuleScore:   score,
-        MLScore:     confidence,
-        Trigger:     finalTrigger,
-    }
-
-    // A. Save to Database (Persistent Storage)
-    if err := s.logRepo.LogAttack(ctx, logEntry); err != nil {
-        log.Printf("❌ Failed to save log to DB: %v", err)
-    }
-	// B. Broadcast to SSE Stream
-        logger.LogAttack(logEntry)
-        log.Printf("📡 Broadcasted log:  %s | %s", clientIP, reason)
-
-}()
-    return verdict, reason
-}
\ No newline at end of file
+	s.logAsync(domain, clientIP, r, verdict, reason, "WAF", tags, score, confidence, finalTrigger)
+
+	if verdict == "Block" && score >= 10 {
+		s.pushIfRepeatOffender(clientIP, reason)
+	}
+
+	return verdict, reason
+}
+
+// logAsync persists logEntry to the database and broadcasts it to the SSE
+// stream off the request's hot path, the way CheckRequest's single
+// terminal goroutine always did — pulled out into its own method so the
+// Threat Intel short-circuit can log a block the same way a rule/ML one
+// does instead of duplicating the goroutine body.
+func (s *WAFService) logAsync(domain core.Domain, clientIP string, r *http.Request, verdict, reason, source string, tags []string, score int, confidence float64, trigger string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		logEntry := core.AttackLog{
+			UserID:      domain.UserID,
+			DomainID:    domain.ID,
+			Timestamp:   time.Now(),
+			ClientIP:    clientIP,
+			RequestPath: r.URL.Path,
+			Reason:      reason,
+			Action:      verdict,
+			Source:      source,
+			Tags:        tags,
+			RuleScore:   score,
+			MLScore:     confidence,
+			Trigger:     trigger,
+		}
+
+		// A. Save to Database (Persistent Storage)
+		if err := s.logRepo.LogAttack(ctx, logEntry); err != nil {
+			log.Printf("❌ Failed to save log to DB: %v", err)
+		}
+		// B. Broadcast to SSE Stream
+		logger.LogAttack(logEntry)
+		log.Printf("📡 Broadcasted log:  %s | %s", clientIP, reason)
+	}()
+}
+
+// pushIfRepeatOffender records a Rule Violation block against clientIP and,
+// once it's racked up repeatBlockThreshold of them within
+// repeatBlockWindow, pushes a ban for it out to the Threat Intel reverse
+// stream so the rest of the federation doesn't have to rediscover it
+// independently. A lone block is routine noise and isn't pushed.
+func (s *WAFService) pushIfRepeatOffender(clientIP, reason string) {
+	if s.threatIntel == nil {
+		return
+	}
+
+	now := time.Now()
+	s.blockHistMu.Lock()
+	hist := append(s.blockHist[clientIP], now)
+	cutoff := now.Add(-repeatBlockWindow)
+	kept := hist[:0]
+	for _, t := range hist {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	s.blockHist[clientIP] = kept
+	count := len(kept)
+	s.blockHistMu.Unlock()
+
+	if count >= repeatBlockThreshold {
+		s.threatIntel.Push(clientIP, reason, repeatBlockPushTTL)
+	}
+}
@@ -36,6 +36,13 @@ type WAFService struct {
 	domainMap      map[string]models.Domain
 	globalFallback []models.WAFRule
 
+	// originCache memoizes GetTargetURL's lookup so a steady stream of
+	// requests for the same host doesn't hit Mongo every time. Entries are
+	// invalidated by ChangeStreamWatcher as soon as the backing dns_record
+	// changes, rather than on a TTL, so a flipped origin takes effect
+	// immediately instead of up to some staleness window later.
+	originCache map[string]*url.URL
+
 	// Stats Buffer (To prevent hitting DB on every request)
 	statsMu     sync.Mutex
 	statsBuffer map[string]*statsDelta
@@ -47,6 +54,7 @@ func NewWAFService(client *mongo.Client, cfg *config.Config) *WAFService {
 		Cfg:         cfg,
 		domainRules: make(map[string][]models.WAFRule),
 		domainMap:   make(map[string]models.Domain),
+		originCache: make(map[string]*url.URL),
 		statsBuffer: make(map[string]*statsDelta),
 	}
 	
@@ -129,6 +137,25 @@ func (s *WAFService) GetRoutingInfo(host string) ([]models.WAFRule, models.Domai
 
 // GetTargetURL determines where to proxy the request
 func (s *WAFService) GetTargetURL(incomingHost string) *url.URL {
+	s.mu.RLock()
+	if cached, ok := s.originCache[incomingHost]; ok {
+		s.mu.RUnlock()
+		return cached
+	}
+	s.mu.RUnlock()
+
+	u := s.resolveTargetURL(incomingHost)
+
+	s.mu.Lock()
+	s.originCache[incomingHost] = u
+	s.mu.Unlock()
+
+	return u
+}
+
+// resolveTargetURL is GetTargetURL's uncached lookup, kept separate so the
+// cache-population logic above stays free of the DB/parsing details.
+func (s *WAFService) resolveTargetURL(incomingHost string) *url.URL {
 	// 1. Check DB for specific Origin Record
 	record, err := database.GetOriginRecord(s.Mongo, incomingHost)
 	if err == nil && record != nil {
@@ -154,6 +181,24 @@ func (s *WAFService) GetTargetURL(incomingHost string) *url.URL {
 	return u
 }
 
+// invalidateOrigin drops incomingHost's cached target, forcing the next
+// GetTargetURL call to re-resolve it from Mongo. Called by
+// ChangeStreamWatcher when that host's dns_record changes.
+func (s *WAFService) invalidateOrigin(incomingHost string) {
+	s.mu.Lock()
+	delete(s.originCache, incomingHost)
+	s.mu.Unlock()
+}
+
+// invalidateAllOrigins clears the whole cache — used when a change-stream
+// event doesn't carry enough information to target a single host (e.g. a
+// delete, which has no fullDocument).
+func (s *WAFService) invalidateAllOrigins() {
+	s.mu.Lock()
+	s.originCache = make(map[string]*url.URL)
+	s.mu.Unlock()
+}
+
 // ReloadRules loads all configurations from DB
 func (s *WAFService) ReloadRules() {
 	s.mu.Lock()
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// watchedCollections feed ReloadRules: any insert/update/delete on one of
+// these can change the effective ruleset or routing for some domain.
+var watchedCollections = []string{"rules", "rule_policies", "domains", "dns_records"}
+
+// reloadDebounce coalesces a burst of change events (e.g. a bulk policy
+// toggle touching hundreds of documents) into a single ReloadRules call.
+const reloadDebounce = 250 * time.Millisecond
+
+// pollFallbackInterval is how often ChangeStreamWatcher re-runs ReloadRules
+// when change streams aren't available at all (a standalone Mongo without a
+// replica set/oplog), so that deployment still converges eventually instead
+// of staying stale until the next process restart.
+const pollFallbackInterval = 30 * time.Second
+
+// ChangeStreamWatcher keeps a WAFService's in-memory routing/rule caches in
+// sync across a fleet of gateway nodes: each node opens its own change
+// streams against watchedCollections, so an edit made through the admin API
+// on one node is picked up by every other node within reloadDebounce instead
+// of waiting for a restart. dns_records changes additionally invalidate just
+// the affected host's GetTargetURL cache entry and nudge PowerDNS's zone
+// freshness, rather than waiting on the debounced full ReloadRules.
+type ChangeStreamWatcher struct {
+	svc *WAFService
+}
+
+// NewChangeStreamWatcher wraps svc; call Run in its own goroutine.
+func NewChangeStreamWatcher(svc *WAFService) *ChangeStreamWatcher {
+	return &ChangeStreamWatcher{svc: svc}
+}
+
+// Run blocks until ctx is cancelled.
+func (w *ChangeStreamWatcher) Run(ctx context.Context) {
+	reloadCh := make(chan struct{}, 1)
+	dnsEvents := make(chan bson.M, 16)
+
+	streamsOK := true
+	for _, coll := range watchedCollections {
+		if !w.watchCollection(ctx, coll, reloadCh, dnsEvents) {
+			streamsOK = false
+		}
+	}
+
+	var pollC <-chan time.Time
+	if !streamsOK {
+		log.Printf("[WARN] ChangeStreamWatcher: change streams unavailable on at least one collection, falling back to polling every %s", pollFallbackInterval)
+		poll := time.NewTicker(pollFallbackInterval)
+		defer poll.Stop()
+		pollC = poll.C
+	}
+
+	debounce := time.NewTimer(0)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+	pending := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-dnsEvents:
+			w.applyDNSEvent(ev)
+			if !pending {
+				pending = true
+				debounce.Reset(reloadDebounce)
+			}
+		case <-reloadCh:
+			if !pending {
+				pending = true
+				debounce.Reset(reloadDebounce)
+			}
+		case <-debounce.C:
+			if pending {
+				pending = false
+				w.svc.ReloadRules()
+			}
+		case <-pollC:
+			w.svc.invalidateAllOrigins()
+			w.svc.ReloadRules()
+		}
+	}
+}
+
+// applyDNSEvent invalidates the origin cache for the one host a dns_records
+// change affects, falling back to clearing the whole cache when the event
+// doesn't carry a fullDocument (deletes) and best-effort bumping that zone's
+// PowerDNS freshness.
+func (w *ChangeStreamWatcher) applyDNSEvent(ev bson.M) {
+	full, _ := ev["fullDocument"].(bson.M)
+	if full == nil {
+		w.svc.invalidateAllOrigins()
+		return
+	}
+
+	name, _ := full["name"].(string)
+	if name == "" {
+		w.svc.invalidateAllOrigins()
+		return
+	}
+
+	w.svc.invalidateOrigin(name)
+
+	root := getRootDomain(name)
+	go func() {
+		if err := database.TouchZoneSerial(root); err != nil {
+			log.Printf("[WARN] ChangeStreamWatcher: failed to bump zone freshness for %s: %v", root, err)
+		}
+	}()
+}
+
+// watchCollection runs a single change-stream cursor against one
+// collection, routing dns_records events to dnsEvents (so applyDNSEvent can
+// do targeted cache invalidation) and everything else to reloadCh. Returns
+// false if the stream couldn't be opened at all (e.g. standalone Mongo
+// without oplog support), so Run knows to fall back to polling.
+func (w *ChangeStreamWatcher) watchCollection(ctx context.Context, collection string, reloadCh chan<- struct{}, dnsEvents chan<- bson.M) bool {
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	stream, err := w.svc.Mongo.Database(database.DBName).Collection(collection).Watch(ctx, mongo.Pipeline{}, opts)
+	if err != nil {
+		log.Printf("[WARN] ChangeStreamWatcher.watchCollection(%s): change streams unavailable: %v", collection, err)
+		return false
+	}
+
+	go func() {
+		defer stream.Close(ctx)
+		for stream.Next(ctx) {
+			var event bson.M
+			if err := stream.Decode(&event); err != nil {
+				continue
+			}
+			if collection == "dns_records" {
+				select {
+				case dnsEvents <- event:
+				default:
+				}
+				continue
+			}
+			select {
+			case reloadCh <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return true
+}
@@ -0,0 +1,82 @@
+package sessionstore
+
+import (
+	"context"
+	"time"
+
+	"web-app-firewall-ml-detection/internal/database"
+	"web-app-firewall-ml-detection/internal/detector"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoStore is the default Store, delegating to internal/database's
+// refresh_tokens collection — the same Mongo-backed persistence every
+// deployment already ran with before this package existed.
+type MongoStore struct {
+	client *mongo.Client
+}
+
+// NewMongoStore returns a Store backed by client's "refresh_tokens"
+// collection (via internal/database).
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	return &MongoStore{client: client}
+}
+
+func (s *MongoStore) Create(ctx context.Context, userID, rawToken, userAgent, ip string, ttl time.Duration) (string, error) {
+	return database.CreateRefreshToken(s.client, userID, rawToken, userAgent, ip, ttl)
+}
+
+func (s *MongoStore) GetByHash(ctx context.Context, tokenHash string) (*Session, error) {
+	doc, err := database.GetRefreshTokenByHash(s.client, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return fromRefreshToken(doc), nil
+}
+
+func (s *MongoStore) Get(ctx context.Context, id string) (*Session, error) {
+	doc, err := database.GetRefreshTokenByID(s.client, id)
+	if err != nil {
+		return nil, err
+	}
+	return fromRefreshToken(doc), nil
+}
+
+func (s *MongoStore) Rotate(ctx context.Context, oldID, newID string) error {
+	return database.RotateRefreshToken(s.client, oldID, newID)
+}
+
+func (s *MongoStore) Revoke(ctx context.Context, id string) error {
+	return database.RevokeRefreshToken(s.client, id)
+}
+
+func (s *MongoStore) RevokeAll(ctx context.Context, userID string) error {
+	return database.RevokeAllRefreshTokensForUser(s.client, userID)
+}
+
+func (s *MongoStore) List(ctx context.Context, userID string) ([]Session, error) {
+	docs, err := database.ListRefreshTokensForUser(s.client, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(docs))
+	for _, doc := range docs {
+		sessions = append(sessions, *fromRefreshToken(&doc))
+	}
+	return sessions, nil
+}
+
+func fromRefreshToken(doc *detector.RefreshToken) *Session {
+	return &Session{
+		ID:        doc.ID,
+		UserID:    doc.UserID,
+		IssuedAt:  doc.IssuedAt,
+		ExpiresAt: doc.ExpiresAt,
+		RotatedTo: doc.RotatedTo,
+		Revoked:   doc.Revoked,
+		UserAgent: doc.UserAgent,
+		IP:        doc.IP,
+	}
+}
@@ -0,0 +1,153 @@
+package sessionstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RedisStore is the Store implementation for deployments running more than
+// one gateway node: every node sees the same revocation state on
+// /auth/check and /auth/refresh without round-tripping Mongo for it.
+// Each session is a JSON blob at "sessions:id:<id>", indexed by token hash
+// at "sessions:hash:<hash>" for GetByHash, and tracked per-user in the set
+// "sessions:user:<userID>" for List/RevokeAll.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore returns a Store backed by addr (host:port). db selects the
+// Redis logical database; password may be empty for an unauthenticated
+// instance.
+func NewRedisStore(addr, password string, db int) *RedisStore {
+	return &RedisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+	}
+}
+
+func (s *RedisStore) idKey(id string) string       { return "sessions:id:" + id }
+func (s *RedisStore) hashKey(hash string) string   { return "sessions:hash:" + hash }
+func (s *RedisStore) userKey(userID string) string { return "sessions:user:" + userID }
+
+func (s *RedisStore) Create(ctx context.Context, userID, rawToken, userAgent, ip string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	sess := Session{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    userID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return "", err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.idKey(sess.ID), data, ttl)
+	pipe.Set(ctx, s.hashKey(HashToken(rawToken)), sess.ID, ttl)
+	pipe.SAdd(ctx, s.userKey(userID), sess.ID)
+	pipe.Expire(ctx, s.userKey(userID), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return sess.ID, nil
+}
+
+func (s *RedisStore) GetByHash(ctx context.Context, tokenHash string) (*Session, error) {
+	id, err := s.client.Get(ctx, s.hashKey(tokenHash)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	data, err := s.client.Get(ctx, s.idKey(id)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+func (s *RedisStore) save(ctx context.Context, sess *Session) error {
+	ttl := s.client.TTL(ctx, s.idKey(sess.ID)).Val()
+	if ttl <= 0 {
+		ttl = time.Until(sess.ExpiresAt)
+	}
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.idKey(sess.ID), data, ttl).Err()
+}
+
+func (s *RedisStore) Rotate(ctx context.Context, oldID, newID string) error {
+	sess, err := s.Get(ctx, oldID)
+	if err != nil {
+		return err
+	}
+	sess.RotatedTo = newID
+	return s.save(ctx, sess)
+}
+
+func (s *RedisStore) Revoke(ctx context.Context, id string) error {
+	sess, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	sess.Revoked = true
+	return s.save(ctx, sess)
+}
+
+func (s *RedisStore) RevokeAll(ctx context.Context, userID string) error {
+	ids, err := s.client.SMembers(ctx, s.userKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := s.Revoke(ctx, id); err != nil {
+			continue
+		}
+	}
+	return nil
+}
+
+func (s *RedisStore) List(ctx context.Context, userID string) ([]Session, error) {
+	ids, err := s.client.SMembers(ctx, s.userKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(ids))
+	for _, id := range ids {
+		sess, err := s.Get(ctx, id)
+		if err != nil {
+			// Expired/evicted session: prune the stale membership rather
+			// than surfacing it as an active one.
+			s.client.SRem(ctx, s.userKey(userID), id)
+			continue
+		}
+		if sess.Revoked || time.Now().After(sess.ExpiresAt) {
+			continue
+		}
+		sessions = append(sessions, *sess)
+	}
+	return sessions, nil
+}
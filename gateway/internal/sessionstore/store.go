@@ -0,0 +1,74 @@
+// Package sessionstore abstracts where refresh-token session records live.
+// Before this package existed, the auth handlers (internal/api/auth.go,
+// sessions.go) called internal/database's refresh-token functions directly
+// against Mongo; Store lets them depend on an interface instead, so a
+// multi-node deployment can point sessions at Redis (shared, low-latency
+// revocation checks on every /auth/check) instead of every node round-
+// tripping Mongo for it.
+package sessionstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Session is one issued refresh token, independent of which backend holds
+// it. ID is what the rotation chain and /auth/sessions refer to it by —
+// the Mongo _id for MongoStore, a generated opaque id for RedisStore.
+type Session struct {
+	ID        string
+	UserID    string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	RotatedTo string
+	Revoked   bool
+	UserAgent string
+	IP        string
+}
+
+// Store persists and looks up refresh-token sessions. MongoStore is the
+// default (backed by internal/database, same persistence this package
+// replaces direct calls to); RedisStore is the optional multi-node backend.
+type Store interface {
+	// Create persists a newly issued refresh token (hashed via HashToken
+	// before storage) and returns its id for the rotation chain to
+	// reference.
+	Create(ctx context.Context, userID, rawToken, userAgent, ip string, ttl time.Duration) (string, error)
+
+	// GetByHash looks up a session by the hash of its raw refresh token
+	// value, as presented in the refresh_token cookie.
+	GetByHash(ctx context.Context, tokenHash string) (*Session, error)
+
+	// Get looks up a single session by id, for CheckAuth to confirm the
+	// session an access token was minted under is still live.
+	Get(ctx context.Context, id string) (*Session, error)
+
+	// Rotate atomically marks oldID as rotated to newID, so a later
+	// presentation of the old token's raw value is recognizable as reuse
+	// rather than a legitimate refresh.
+	Rotate(ctx context.Context, oldID, newID string) error
+
+	// Revoke marks a single session revoked, e.g. on /auth/logout or a
+	// user revoking one session from /auth/sessions.
+	Revoke(ctx context.Context, id string) error
+
+	// RevokeAll cascade-revokes every session for a user: used both for
+	// "log out everywhere" and as the response to a detected refresh-token
+	// reuse (a stolen token family).
+	RevokeAll(ctx context.Context, userID string) error
+
+	// List returns every active (non-revoked, unexpired) session for a
+	// user, for the /auth/sessions listing endpoint.
+	List(ctx context.Context, userID string) ([]Session, error)
+}
+
+// HashToken is the lookup key stored instead of the raw opaque token, so a
+// leaked store dump can't be replayed directly — the same reasoning as
+// storing a bcrypt hash of a password, just a plain SHA-256 since a refresh
+// token is already high-entropy random data rather than user-chosen.
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
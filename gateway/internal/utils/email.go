@@ -42,43 +42,43 @@ func (s *EmailSender) Send(to, subject, body, senderName string) error {
 	// This is the key difference from standard smtp.SendMail
 	conn, err := tls.Dial("tcp", addr, tlsConfig)
 	if err != nil {
-		return fmt.Errorf("failed to dial tls: %v", err)
+		return fmt.Errorf("failed to dial tls: %w", err)
 	}
 	defer conn.Close()
 
 	// 5. Create SMTP Client over the TLS connection
 	client, err := smtp.NewClient(conn, s.cfg.SMTPHost)
 	if err != nil {
-		return fmt.Errorf("failed to create smtp client: %v", err)
+		return fmt.Errorf("failed to create smtp client: %w", err)
 	}
 	defer client.Quit()
 
 	// 6. Authenticate
 	auth := smtp.PlainAuth("", s.cfg.SMTPUser, s.cfg.SMTPPass, s.cfg.SMTPHost)
 	if err = client.Auth(auth); err != nil {
-		return fmt.Errorf("auth failed: %v", err)
+		return fmt.Errorf("auth failed: %w", err)
 	}
 
 	// 7. Send Email
 	if err = client.Mail(s.cfg.SMTPUser); err != nil {
-		return fmt.Errorf("mail command failed: %v", err)
+		return fmt.Errorf("mail command failed: %w", err)
 	}
 	if err = client.Rcpt(to); err != nil {
-		return fmt.Errorf("rcpt command failed: %v", err)
+		return fmt.Errorf("rcpt command failed: %w", err)
 	}
 
 	w, err := client.Data()
 	if err != nil {
-		return fmt.Errorf("data command failed: %v", err)
+		return fmt.Errorf("data command failed: %w", err)
 	}
 	
 	_, err = w.Write(msg)
 	if err != nil {
-		return fmt.Errorf("write failed: %v", err)
+		return fmt.Errorf("write failed: %w", err)
 	}
 
 	if err = w.Close(); err != nil {
-		return fmt.Errorf("close failed: %v", err)
+		return fmt.Errorf("close failed: %w", err)
 	}
 
 	return nil
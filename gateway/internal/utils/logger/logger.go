@@ -1,55 +1,106 @@
+// Package logger is a thin core.AttackLog adapter over
+// internal/logger's broker — the two packages used to each keep their own
+// subscriber map and channel set (this one untyped by tenant/domain, with
+// no ring buffer or backpressure accounting), so a reconnecting client on
+// one side could never see what the other had already buffered. Converting
+// at the boundary means there is now exactly one broker, one ring buffer,
+// and one set of drop counters behind both AttackLog shapes.
 package logger
 
 import (
 	"sync"
+
 	"web-app-firewall-ml-detection/internal/core"
+	"web-app-firewall-ml-detection/internal/detector"
+	"web-app-firewall-ml-detection/internal/logger"
 )
 
-// Broker manages the list of active SSE clients
-type Broker struct {
-	mu          sync.RWMutex // Read/Write lock for thread safety
-	subscribers map[chan core.AttackLog]struct{}
-}
-
-// Global broker instance
-var broker = &Broker{
-	subscribers: make(map[chan core.AttackLog]struct{}),
-}
+var (
+	adaptersMu sync.Mutex
+	adapters   = make(map[chan core.AttackLog]*logger.Subscription)
+)
 
-// creates a new dedicated channel for a client
+// Subscribe registers a new SSE client and returns a core.AttackLog
+// channel, converting each event internal/logger delivers at the boundary.
+// Callers must Unsubscribe when the connection ends.
 func Subscribe() chan core.AttackLog {
-	broker.mu.Lock()
-	defer broker.mu.Unlock()
-
-	// Buffer of 50 prevents minor network lag from dropping logs
+	sub := logger.Subscribe(logger.SubscribeOptions{})
 	ch := make(chan core.AttackLog, 50)
-	broker.subscribers[ch] = struct{}{}
+
+	adaptersMu.Lock()
+	adapters[ch] = sub
+	adaptersMu.Unlock()
+
+	go func() {
+		for ev := range sub.Events() {
+			select {
+			case ch <- toCoreAttackLog(ev.Log):
+			default:
+				// Mirrors the old Broker.LogAttack behavior: a slow
+				// client drops the newest entry rather than blocking.
+			}
+		}
+		close(ch)
+	}()
+
 	return ch
 }
 
-//  removes a client and closes their channel
+// Unsubscribe removes a subscription and closes its channel.
 func Unsubscribe(ch chan core.AttackLog) {
-	broker.mu.Lock()
-	defer broker.mu.Unlock()
+	adaptersMu.Lock()
+	sub, ok := adapters[ch]
+	if ok {
+		delete(adapters, ch)
+	}
+	adaptersMu.Unlock()
 
-	if _, ok := broker.subscribers[ch]; ok {
-		delete(broker.subscribers, ch)
-		close(ch) 
+	if ok {
+		logger.Unsubscribe(sub)
 	}
 }
 
-// broadcasts a log entry to ALL active subscribers
+// LogAttack converts entry and publishes it through internal/logger's
+// broker, so it lands in the same ring buffer and reaches the same
+// subscribers (of either AttackLog shape) that detector.AttackLog entries
+// do.
 func LogAttack(entry core.AttackLog) {
-	broker.mu.RLock() // concurrent broadcasting
-	defer broker.mu.RUnlock()
-
-	for ch := range broker.subscribers {
-		select {
-		case ch <- entry:
-			// Message sent successfully
-		default:
-			// Drop message for slower clients to protect the WAF from blocking.
-		}
+	logger.Publish(toDetectorAttackLog(entry))
+}
+
+func toDetectorAttackLog(e core.AttackLog) detector.AttackLog {
+	return detector.AttackLog{
+		ID:             e.ID,
+		UserID:         e.UserID,
+		DomainID:       e.DomainID,
+		Timestamp:      e.Timestamp,
+		IP:             e.ClientIP,
+		RequestPath:    e.RequestPath,
+		Reason:         e.Reason,
+		Source:         e.Source,
+		Tags:           e.Tags,
+		Action:         e.Action,
+		Score:          e.RuleScore,
+		MLConfidence:   e.MLScore,
+		TriggerPayload: e.Trigger,
 	}
 }
 
+func toCoreAttackLog(e detector.AttackLog) core.AttackLog {
+	id, _ := e.ID.(string)
+	return core.AttackLog{
+		ID:          id,
+		UserID:      e.UserID,
+		DomainID:    e.DomainID,
+		Timestamp:   e.Timestamp,
+		ClientIP:    e.IP,
+		RequestPath: e.RequestPath,
+		Reason:      e.Reason,
+		Action:      e.Action,
+		Source:      e.Source,
+		Tags:        e.Tags,
+		RuleScore:   e.Score,
+		MLScore:     e.MLConfidence,
+		Trigger:     e.TriggerPayload,
+	}
+}